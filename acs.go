@@ -0,0 +1,567 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+)
+
+// ACSFormat designates the layout of a BEHAVIOR lump.
+type ACSFormat int
+
+const (
+	// ACSFormatAuto compiles to ACS0 unless the source uses features
+	// that need ACSE.
+	ACSFormatAuto ACSFormat = iota
+
+	// ACSFormatACS0 is the format of Hexen, with a single directory of
+	// scripts and strings.
+	ACSFormatACS0
+
+	// ACSFormatACSE is the format of ZDoom, which stores functions,
+	// map variables and other extensions in chunks.
+	ACSFormatACSE
+
+	// ACSFormatACSe is ACSE with compact pcodes of one or two bytes.
+	// It can be decoded but not compiled.
+	ACSFormatACSe
+)
+
+// ACS script types, which are added to the script number in multiples
+// of 1000 in ACS0 directories.
+const (
+	acsScriptClosed     = 0
+	acsScriptOpen       = 1
+	acsScriptRespawn    = 2
+	acsScriptDeath      = 3
+	acsScriptEnter      = 4
+	acsScriptLightning  = 12
+	acsScriptUnloading  = 13
+	acsScriptDisconnect = 14
+	acsScriptReturn     = 15
+)
+
+// acsScriptTypeNames holds the keyword of each script type.
+var acsScriptTypeNames = map[int]string{
+	0: "closed", 1: "open", 2: "respawn", 3: "death", 4: "enter",
+	5: "pickup", 6: "bluereturn", 7: "redreturn", 8: "whitereturn",
+	12: "lightning", 13: "unloading", 14: "disconnect", 15: "return",
+	16: "event", 17: "kill", 18: "reopen",
+}
+
+// ACS script flags, stored in the SFLG chunk.
+const (
+	acsFlagNet        = 1
+	acsFlagClientside = 2
+)
+
+// Pcodes emitted by the compiler.  Each pcode and argument is four
+// bytes long in both ACS0 and ACSE, except for the arguments of
+// CALLFUNC, which are a byte and a short in every format.
+const (
+	pcdTerminate      = 1
+	pcdSuspend        = 2
+	pcdPushNumber     = 3
+	pcdLSpec1         = 4
+	pcdAdd            = 14
+	pcdSubtract       = 15
+	pcdMultiply       = 16
+	pcdDivide         = 17
+	pcdModulus        = 18
+	pcdEQ             = 19
+	pcdNE             = 20
+	pcdLT             = 21
+	pcdGT             = 22
+	pcdLE             = 23
+	pcdGE             = 24
+	pcdGoto           = 52
+	pcdIfGoto         = 53
+	pcdDrop           = 54
+	pcdRestart        = 69
+	pcdAndLogical     = 70
+	pcdOrLogical      = 71
+	pcdAndBitwise     = 72
+	pcdOrBitwise      = 73
+	pcdEorBitwise     = 74
+	pcdNegateLogical  = 75
+	pcdLShift         = 76
+	pcdRShift         = 77
+	pcdUnaryMinus     = 78
+	pcdIfNotGoto      = 79
+	pcdCaseGoto       = 84
+	pcdBeginPrint     = 85
+	pcdEndPrint       = 86
+	pcdPrintString    = 87
+	pcdPrintNumber    = 88
+	pcdPrintCharacter = 89
+	pcdEndPrintBold   = 101
+	pcdPrintName      = 131
+	pcdPrintFixed     = 157
+	pcdPrintLocalized = 158
+	pcdMoreHudMessage = 159
+	pcdOptHudMessage  = 160
+	pcdEndHudMessage  = 161
+	pcdEndHudBold     = 162
+	pcdCall           = 203
+	pcdCallDiscard    = 204
+	pcdReturnVoid     = 205
+	pcdReturnVal      = 206
+	pcdTagString      = 225
+	pcdLSpec5Result   = 263
+	pcdEndLog         = 270
+	pcdNegateBinary   = 330
+	pcdPrintBind      = 333
+	pcdPrintBinary    = 349
+	pcdPrintHex       = 350
+	pcdCallFunc       = 351
+)
+
+// acsPCode describes a pcode for disassembly.
+type acsPCode struct {
+	Name string
+	Args int
+}
+
+// acsPCodes holds the name and argument count of every pcode the
+// compiler can emit, the rest of the Hexen instruction set, and the
+// ZDoom pcodes up to CALLFUNC.
+var acsPCodes = map[int]acsPCode{
+	0: {"NOP", 0}, 1: {"TERMINATE", 0}, 2: {"SUSPEND", 0},
+	3: {"PUSHNUMBER", 1}, 4: {"LSPEC1", 1}, 5: {"LSPEC2", 1},
+	6: {"LSPEC3", 1}, 7: {"LSPEC4", 1}, 8: {"LSPEC5", 1},
+	9: {"LSPEC1DIRECT", 2}, 10: {"LSPEC2DIRECT", 3},
+	11: {"LSPEC3DIRECT", 4}, 12: {"LSPEC4DIRECT", 5},
+	13: {"LSPEC5DIRECT", 6}, 14: {"ADD", 0}, 15: {"SUBTRACT", 0},
+	16: {"MULTIPLY", 0}, 17: {"DIVIDE", 0}, 18: {"MODULUS", 0},
+	19: {"EQ", 0}, 20: {"NE", 0}, 21: {"LT", 0}, 22: {"GT", 0},
+	23: {"LE", 0}, 24: {"GE", 0}, 25: {"ASSIGNSCRIPTVAR", 1},
+	26: {"ASSIGNMAPVAR", 1}, 27: {"ASSIGNWORLDVAR", 1},
+	28: {"PUSHSCRIPTVAR", 1}, 29: {"PUSHMAPVAR", 1},
+	30: {"PUSHWORLDVAR", 1}, 31: {"ADDSCRIPTVAR", 1},
+	32: {"ADDMAPVAR", 1}, 33: {"ADDWORLDVAR", 1},
+	34: {"SUBSCRIPTVAR", 1}, 35: {"SUBMAPVAR", 1},
+	36: {"SUBWORLDVAR", 1}, 37: {"MULSCRIPTVAR", 1},
+	38: {"MULMAPVAR", 1}, 39: {"MULWORLDVAR", 1},
+	40: {"DIVSCRIPTVAR", 1}, 41: {"DIVMAPVAR", 1},
+	42: {"DIVWORLDVAR", 1}, 43: {"MODSCRIPTVAR", 1},
+	44: {"MODMAPVAR", 1}, 45: {"MODWORLDVAR", 1},
+	46: {"INCSCRIPTVAR", 1}, 47: {"INCMAPVAR", 1},
+	48: {"INCWORLDVAR", 1}, 49: {"DECSCRIPTVAR", 1},
+	50: {"DECMAPVAR", 1}, 51: {"DECWORLDVAR", 1}, 52: {"GOTO", 1},
+	53: {"IFGOTO", 1}, 54: {"DROP", 0}, 55: {"DELAY", 0},
+	56: {"DELAYDIRECT", 1}, 57: {"RANDOM", 0}, 58: {"RANDOMDIRECT", 2},
+	59: {"THINGCOUNT", 0}, 60: {"THINGCOUNTDIRECT", 2},
+	61: {"TAGWAIT", 0}, 62: {"TAGWAITDIRECT", 1}, 63: {"POLYWAIT", 0},
+	64: {"POLYWAITDIRECT", 1}, 65: {"CHANGEFLOOR", 0},
+	66: {"CHANGEFLOORDIRECT", 2}, 67: {"CHANGECEILING", 0},
+	68: {"CHANGECEILINGDIRECT", 2}, 69: {"RESTART", 0},
+	70: {"ANDLOGICAL", 0}, 71: {"ORLOGICAL", 0}, 72: {"ANDBITWISE", 0},
+	73: {"ORBITWISE", 0}, 74: {"EORBITWISE", 0},
+	75: {"NEGATELOGICAL", 0}, 76: {"LSHIFT", 0}, 77: {"RSHIFT", 0},
+	78: {"UNARYMINUS", 0}, 79: {"IFNOTGOTO", 1}, 80: {"LINESIDE", 0},
+	81: {"SCRIPTWAIT", 0}, 82: {"SCRIPTWAITDIRECT", 1},
+	83: {"CLEARLINESPECIAL", 0}, 84: {"CASEGOTO", 2},
+	85: {"BEGINPRINT", 0}, 86: {"ENDPRINT", 0}, 87: {"PRINTSTRING", 0},
+	88: {"PRINTNUMBER", 0}, 89: {"PRINTCHARACTER", 0},
+	90: {"PLAYERCOUNT", 0}, 91: {"GAMETYPE", 0}, 92: {"GAMESKILL", 0},
+	93: {"TIMER", 0}, 94: {"SECTORSOUND", 0}, 95: {"AMBIENTSOUND", 0},
+	96: {"SOUNDSEQUENCE", 0}, 97: {"SETLINETEXTURE", 0},
+	98: {"SETLINEBLOCKING", 0}, 99: {"SETLINESPECIAL", 0},
+	100: {"THINGSOUND", 0}, 101: {"ENDPRINTBOLD", 0},
+	102: {"ACTIVATORSOUND", 0}, 103: {"LOCALAMBIENTSOUND", 0},
+	104: {"SETLINEMONSTERBLOCKING", 0}, 105: {"PLAYERBLUESKULL", 0},
+	106: {"PLAYERREDSKULL", 0}, 107: {"PLAYERYELLOWSKULL", 0},
+	108: {"PLAYERMASTERSKULL", 0}, 109: {"PLAYERBLUECARD", 0},
+	110: {"PLAYERREDCARD", 0}, 111: {"PLAYERYELLOWCARD", 0},
+	112: {"PLAYERMASTERCARD", 0}, 113: {"PLAYERBLACKSKULL", 0},
+	114: {"PLAYERSILVERSKULL", 0}, 115: {"PLAYERGOLDSKULL", 0},
+	116: {"PLAYERBLACKCARD", 0}, 117: {"PLAYERSILVERCARD", 0},
+	118: {"ISNETWORKGAME", 0}, 119: {"PLAYERTEAM", 0},
+	120: {"PLAYERHEALTH", 0}, 121: {"PLAYERARMORPOINTS", 0},
+	122: {"PLAYERFRAGS", 0}, 123: {"PLAYEREXPERT", 0},
+	124: {"BLUETEAMCOUNT", 0}, 125: {"REDTEAMCOUNT", 0},
+	126: {"BLUETEAMSCORE", 0}, 127: {"REDTEAMSCORE", 0},
+	128: {"ISONEFLAGCTF", 0}, 129: {"LSPEC6", 1},
+	130: {"LSPEC6DIRECT", 7}, 131: {"PRINTNAME", 0},
+	132: {"MUSICCHANGE", 0}, 133: {"CONSOLECOMMANDDIRECT", 3},
+	134: {"CONSOLECOMMAND", 0}, 135: {"SINGLEPLAYER", 0},
+	136: {"FIXEDMUL", 0}, 137: {"FIXEDDIV", 0}, 138: {"SETGRAVITY", 0},
+	139: {"SETGRAVITYDIRECT", 1}, 140: {"SETAIRCONTROL", 0},
+	141: {"SETAIRCONTROLDIRECT", 1}, 142: {"CLEARINVENTORY", 0},
+	143: {"GIVEINVENTORY", 0}, 144: {"GIVEINVENTORYDIRECT", 2},
+	145: {"TAKEINVENTORY", 0}, 146: {"TAKEINVENTORYDIRECT", 2},
+	147: {"CHECKINVENTORY", 0}, 148: {"CHECKINVENTORYDIRECT", 1},
+	149: {"SPAWN", 0}, 150: {"SPAWNDIRECT", 6}, 151: {"SPAWNSPOT", 0},
+	152: {"SPAWNSPOTDIRECT", 4}, 153: {"SETMUSIC", 0},
+	154: {"SETMUSICDIRECT", 3}, 155: {"LOCALSETMUSIC", 0},
+	156: {"LOCALSETMUSICDIRECT", 3}, 157: {"PRINTFIXED", 0},
+	158: {"PRINTLOCALIZED", 0}, 159: {"MOREHUDMESSAGE", 0},
+	160: {"OPTHUDMESSAGE", 0}, 161: {"ENDHUDMESSAGE", 0},
+	162: {"ENDHUDMESSAGEBOLD", 0}, 163: {"SETSTYLE", 0},
+	164: {"SETSTYLEDIRECT", 1}, 165: {"SETFONT", 0},
+	166: {"SETFONTDIRECT", 1}, 167: {"PUSHBYTE", 1},
+	168: {"LSPEC1DIRECTB", 2}, 169: {"LSPEC2DIRECTB", 3},
+	170: {"LSPEC3DIRECTB", 4}, 171: {"LSPEC4DIRECTB", 5},
+	172: {"LSPEC5DIRECTB", 6}, 173: {"DELAYDIRECTB", 1},
+	174: {"RANDOMDIRECTB", 2}, 175: {"PUSHBYTES", 1},
+	176: {"PUSH2BYTES", 2}, 177: {"PUSH3BYTES", 3},
+	178: {"PUSH4BYTES", 4}, 179: {"PUSH5BYTES", 5},
+	180: {"SETTHINGSPECIAL", 0}, 181: {"ASSIGNGLOBALVAR", 1},
+	182: {"PUSHGLOBALVAR", 1}, 183: {"ADDGLOBALVAR", 1},
+	184: {"SUBGLOBALVAR", 1}, 185: {"MULGLOBALVAR", 1},
+	186: {"DIVGLOBALVAR", 1}, 187: {"MODGLOBALVAR", 1},
+	188: {"INCGLOBALVAR", 1}, 189: {"DECGLOBALVAR", 1},
+	190: {"FADETO", 0}, 191: {"FADERANGE", 0}, 192: {"CANCELFADE", 0},
+	193: {"PLAYMOVIE", 0}, 194: {"SETFLOORTRIGGER", 0},
+	195: {"SETCEILINGTRIGGER", 0}, 196: {"GETACTORX", 0},
+	197: {"GETACTORY", 0}, 198: {"GETACTORZ", 0},
+	199: {"STARTTRANSLATION", 0}, 200: {"TRANSLATIONRANGE1", 0},
+	201: {"TRANSLATIONRANGE2", 0}, 202: {"ENDTRANSLATION", 0},
+	203: {"CALL", 1}, 204: {"CALLDISCARD", 1}, 205: {"RETURNVOID", 0},
+	206: {"RETURNVAL", 0}, 207: {"PUSHMAPARRAY", 1},
+	208: {"ASSIGNMAPARRAY", 1}, 209: {"ADDMAPARRAY", 1},
+	210: {"SUBMAPARRAY", 1}, 211: {"MULMAPARRAY", 1},
+	212: {"DIVMAPARRAY", 1}, 213: {"MODMAPARRAY", 1},
+	214: {"INCMAPARRAY", 1}, 215: {"DECMAPARRAY", 1}, 216: {"DUP", 0},
+	217: {"SWAP", 0}, 218: {"WRITETOINI", 0}, 219: {"GETFROMINI", 0},
+	220: {"SIN", 0}, 221: {"COS", 0}, 222: {"VECTORANGLE", 0},
+	223: {"CHECKWEAPON", 0}, 224: {"SETWEAPON", 0},
+	225: {"TAGSTRING", 0}, 226: {"PUSHWORLDARRAY", 1},
+	227: {"ASSIGNWORLDARRAY", 1}, 228: {"ADDWORLDARRAY", 1},
+	229: {"SUBWORLDARRAY", 1}, 230: {"MULWORLDARRAY", 1},
+	231: {"DIVWORLDARRAY", 1}, 232: {"MODWORLDARRAY", 1},
+	233: {"INCWORLDARRAY", 1}, 234: {"DECWORLDARRAY", 1},
+	235: {"PUSHGLOBALARRAY", 1}, 236: {"ASSIGNGLOBALARRAY", 1},
+	237: {"ADDGLOBALARRAY", 1}, 238: {"SUBGLOBALARRAY", 1},
+	239: {"MULGLOBALARRAY", 1}, 240: {"DIVGLOBALARRAY", 1},
+	241: {"MODGLOBALARRAY", 1}, 242: {"INCGLOBALARRAY", 1},
+	243: {"DECGLOBALARRAY", 1}, 244: {"SETMARINEWEAPON", 0},
+	245: {"SETACTORPROPERTY", 0}, 246: {"GETACTORPROPERTY", 0},
+	247: {"PLAYERNUMBER", 0}, 248: {"ACTIVATORTID", 0},
+	249: {"SETMARINESPRITE", 0}, 250: {"GETSCREENWIDTH", 0},
+	251: {"GETSCREENHEIGHT", 0}, 252: {"THING_PROJECTILE2", 0},
+	253: {"STRLEN", 0}, 254: {"SETHUDSIZE", 0}, 255: {"GETCVAR", 0},
+	256: {"CASEGOTOSORTED", 0}, 257: {"SETRESULTVALUE", 0},
+	258: {"GETLINEROWOFFSET", 0}, 259: {"GETACTORFLOORZ", 0},
+	260: {"GETACTORANGLE", 0}, 261: {"GETSECTORFLOORZ", 0},
+	262: {"GETSECTORCEILINGZ", 0}, 263: {"LSPEC5RESULT", 1},
+	264: {"GETSIGILPIECES", 0}, 265: {"GETLEVELINFO", 0},
+	266: {"CHANGESKY", 0}, 267: {"PLAYERINGAME", 0},
+	268: {"PLAYERISBOT", 0}, 269: {"SETCAMERATOTEXTURE", 0},
+	270: {"ENDLOG", 0}, 271: {"GETAMMOCAPACITY", 0},
+	272: {"SETAMMOCAPACITY", 0}, 273: {"PRINTMAPCHARARRAY", 0},
+	274: {"PRINTWORLDCHARARRAY", 0}, 275: {"PRINTGLOBALCHARARRAY", 0},
+	276: {"SETACTORANGLE", 0}, 277: {"GRABINPUT", 0},
+	278: {"SETMOUSEPOINTER", 0}, 279: {"MOVEMOUSEPOINTER", 0},
+	280: {"SPAWNPROJECTILE", 0}, 281: {"GETSECTORLIGHTLEVEL", 0},
+	282: {"GETACTORCEILINGZ", 0}, 283: {"SETACTORPOSITION", 0},
+	284: {"CLEARACTORINVENTORY", 0}, 285: {"GIVEACTORINVENTORY", 0},
+	286: {"TAKEACTORINVENTORY", 0}, 287: {"CHECKACTORINVENTORY", 0},
+	288: {"THINGCOUNTNAME", 0}, 289: {"SPAWNSPOTFACING", 0},
+	290: {"PLAYERCLASS", 0}, 291: {"ANDSCRIPTVAR", 1},
+	292: {"ANDMAPVAR", 1}, 293: {"ANDWORLDVAR", 1},
+	294: {"ANDGLOBALVAR", 1}, 295: {"ANDMAPARRAY", 1},
+	296: {"ANDWORLDARRAY", 1}, 297: {"ANDGLOBALARRAY", 1},
+	298: {"EORSCRIPTVAR", 1}, 299: {"EORMAPVAR", 1},
+	300: {"EORWORLDVAR", 1}, 301: {"EORGLOBALVAR", 1},
+	302: {"EORMAPARRAY", 1}, 303: {"EORWORLDARRAY", 1},
+	304: {"EORGLOBALARRAY", 1}, 305: {"ORSCRIPTVAR", 1},
+	306: {"ORMAPVAR", 1}, 307: {"ORWORLDVAR", 1},
+	308: {"ORGLOBALVAR", 1}, 309: {"ORMAPARRAY", 1},
+	310: {"ORWORLDARRAY", 1}, 311: {"ORGLOBALARRAY", 1},
+	312: {"LSSCRIPTVAR", 1}, 313: {"LSMAPVAR", 1},
+	314: {"LSWORLDVAR", 1}, 315: {"LSGLOBALVAR", 1},
+	316: {"LSMAPARRAY", 1}, 317: {"LSWORLDARRAY", 1},
+	318: {"LSGLOBALARRAY", 1}, 319: {"RSSCRIPTVAR", 1},
+	320: {"RSMAPVAR", 1}, 321: {"RSWORLDVAR", 1},
+	322: {"RSGLOBALVAR", 1}, 323: {"RSMAPARRAY", 1},
+	324: {"RSWORLDARRAY", 1}, 325: {"RSGLOBALARRAY", 1},
+	326: {"GETPLAYERINFO", 0}, 327: {"CHANGELEVEL", 0},
+	328: {"SECTORDAMAGE", 0}, 329: {"REPLACETEXTURES", 0},
+	330: {"NEGATEBINARY", 0}, 331: {"GETACTORPITCH", 0},
+	332: {"SETACTORPITCH", 0}, 333: {"PRINTBIND", 0},
+	334: {"SETACTORSTATE", 0}, 335: {"THINGDAMAGE2", 0},
+	336: {"USEINVENTORY", 0}, 337: {"USEACTORINVENTORY", 0},
+	338: {"CHECKACTORCEILINGTEXTURE", 0},
+	339: {"CHECKACTORFLOORTEXTURE", 0}, 340: {"GETACTORLIGHTLEVEL", 0},
+	341: {"SETMUGSHOTSTATE", 0}, 342: {"THINGCOUNTSECTOR", 0},
+	343: {"THINGCOUNTNAMESECTOR", 0}, 344: {"CHECKPLAYERCAMERA", 0},
+	345: {"MORPHACTOR", 0}, 346: {"UNMORPHACTOR", 0},
+	347: {"GETPLAYERINPUT", 0}, 348: {"CLASSIFYACTOR", 0},
+	349: {"PRINTBINARY", 0}, 350: {"PRINTHEX", 0}, 351: {"CALLFUNC", 2},
+}
+
+// acsBytePCodes holds the pcodes whose arguments are single bytes in
+// every format.  PUSHBYTES is followed by as many bytes as its argument.
+var acsBytePCodes = map[int]bool{
+	167: true, 168: true, 169: true, 170: true, 171: true, 172: true,
+	173: true, 174: true, 175: true, 176: true, 177: true, 178: true,
+	179: true,
+}
+
+// acsCompactByteArg returns true if an argument of a pcode is a single
+// byte in the compact ACSe format, where special numbers, function
+// numbers and variable indexes are stored in bytes.
+func acsCompactByteArg(pcode int, arg int) bool {
+	switch {
+	case pcode >= 4 && pcode <= 8, pcode == 129:
+		return true
+	case pcode >= 9 && pcode <= 13, pcode == 130:
+		return arg == 0
+	case pcode >= 25 && pcode <= 51, pcode >= 181 && pcode <= 189:
+		return true
+	case pcode == 203, pcode == 204, pcode >= 207 && pcode <= 215, pcode >= 226 && pcode <= 243:
+		return true
+	}
+
+	return false
+}
+
+// acsScript is a compiled script.  Named scripts have negative
+// numbers, counting down from -1 in the order of their names.
+type acsScript struct {
+	Number  int
+	Name    string
+	Type    int
+	Flags   int
+	Args    int
+	Locals  int
+	Address int
+}
+
+// label returns the number or quoted name of a script.
+func (script *acsScript) label() string {
+	if script.Number < 0 {
+		return strconv.Quote(script.Name)
+	}
+	return strconv.Itoa(script.Number)
+}
+
+// acsFunction is a compiled function.
+type acsFunction struct {
+	Name    string
+	Args    int
+	Locals  int
+	Returns bool
+	Address int
+}
+
+// acsArray is a map array, which takes the place of a map variable.
+// Values is nil if every element starts at zero.
+type acsArray struct {
+	Index  int
+	Size   int
+	Values []int32
+}
+
+// acsObject holds everything needed to write a BEHAVIOR lump.  Code
+// starts with room for the eight byte header, so addresses are offsets
+// into the lump.
+type acsObject struct {
+	Code      []byte
+	Scripts   []acsScript
+	Functions []acsFunction
+	Strings   []string
+	MapVars   []string
+	MapInits  []int32
+	Arrays    []acsArray
+	Library   bool
+}
+
+// acsStringTable returns offsets followed by null-terminated strings,
+// with offsets relative to base plus the start of the table.
+func acsStringTable(strings []string, base int) []byte {
+	var table bytes.Buffer
+	offset := base + len(strings)*4
+	for _, str := range strings {
+		binary.Write(&table, binary.LittleEndian, int32(offset))
+		offset += len(str) + 1
+	}
+	for _, str := range strings {
+		table.WriteString(str)
+		table.WriteByte(0)
+	}
+
+	return table.Bytes()
+}
+
+// writeACSChunk writes a chunk with the passed ID and data.
+func writeACSChunk(w *bytes.Buffer, id string, data []byte) {
+	w.WriteString(id)
+	binary.Write(w, binary.LittleEndian, int32(len(data)))
+	w.Write(data)
+}
+
+// encodeACS0 encodes an object in the ACS0 format.  Strings follow the
+// code, and the directory follows the strings.
+func encodeACS0(obj *acsObject) []byte {
+	var data bytes.Buffer
+	data.Write(obj.Code)
+
+	offsets := make([]int32, len(obj.Strings))
+	for i, str := range obj.Strings {
+		offsets[i] = int32(data.Len())
+		data.WriteString(str)
+		data.WriteByte(0)
+	}
+
+	for data.Len()%4 != 0 {
+		data.WriteByte(0)
+	}
+
+	directory := int32(data.Len())
+	binary.Write(&data, binary.LittleEndian, int32(len(obj.Scripts)))
+	for _, script := range obj.Scripts {
+		binary.Write(&data, binary.LittleEndian, int32(script.Type*1000+script.Number))
+		binary.Write(&data, binary.LittleEndian, int32(script.Address))
+		binary.Write(&data, binary.LittleEndian, int32(script.Args))
+	}
+	binary.Write(&data, binary.LittleEndian, int32(len(obj.Strings)))
+	binary.Write(&data, binary.LittleEndian, offsets)
+
+	lump := data.Bytes()
+	copy(lump, "ACS\x00")
+	binary.LittleEndian.PutUint32(lump[4:], uint32(directory))
+	return lump
+}
+
+// encodeACSE encodes an object in the ACSE format, with chunks
+// following the code.
+func encodeACSE(obj *acsObject) []byte {
+	var data bytes.Buffer
+	data.Write(obj.Code)
+	chunks := int32(data.Len())
+
+	var chunk bytes.Buffer
+	for _, script := range obj.Scripts {
+		binary.Write(&chunk, binary.LittleEndian, int16(script.Number))
+		chunk.WriteByte(byte(script.Type))
+		chunk.WriteByte(byte(script.Args))
+		binary.Write(&chunk, binary.LittleEndian, int32(script.Address))
+	}
+	writeACSChunk(&data, "SPTR", chunk.Bytes())
+
+	// Named scripts are numbered by their index in SNAM
+	names := []string{}
+	for _, script := range obj.Scripts {
+		if script.Number < 0 {
+			names = append(names, script.Name)
+		}
+	}
+	if len(names) > 0 {
+		chunk.Reset()
+		binary.Write(&chunk, binary.LittleEndian, int32(len(names)))
+		chunk.Write(acsStringTable(names, 4))
+		writeACSChunk(&data, "SNAM", chunk.Bytes())
+	}
+
+	chunk.Reset()
+	for _, script := range obj.Scripts {
+		if script.Flags != 0 {
+			binary.Write(&chunk, binary.LittleEndian, int16(script.Number))
+			binary.Write(&chunk, binary.LittleEndian, uint16(script.Flags))
+		}
+	}
+	if chunk.Len() > 0 {
+		writeACSChunk(&data, "SFLG", chunk.Bytes())
+	}
+
+	// Scripts get 20 local variables unless told otherwise
+	chunk.Reset()
+	for _, script := range obj.Scripts {
+		if script.Locals > 20 {
+			binary.Write(&chunk, binary.LittleEndian, int16(script.Number))
+			binary.Write(&chunk, binary.LittleEndian, int16(script.Locals))
+		}
+	}
+	if chunk.Len() > 0 {
+		writeACSChunk(&data, "SVCT", chunk.Bytes())
+	}
+
+	if len(obj.Functions) > 0 {
+		chunk.Reset()
+		names := make([]string, len(obj.Functions))
+		for i, function := range obj.Functions {
+			chunk.WriteByte(byte(function.Args))
+			chunk.WriteByte(byte(function.Locals))
+			if function.Returns {
+				chunk.WriteByte(1)
+			} else {
+				chunk.WriteByte(0)
+			}
+			chunk.WriteByte(0)
+			binary.Write(&chunk, binary.LittleEndian, int32(function.Address))
+			names[i] = function.Name
+		}
+		writeACSChunk(&data, "FUNC", chunk.Bytes())
+
+		chunk.Reset()
+		binary.Write(&chunk, binary.LittleEndian, int32(len(names)))
+		chunk.Write(acsStringTable(names, 4))
+		writeACSChunk(&data, "FNAM", chunk.Bytes())
+	}
+
+	if len(obj.Strings) > 0 {
+		chunk.Reset()
+		binary.Write(&chunk, binary.LittleEndian, []int32{0, int32(len(obj.Strings)), 0})
+		chunk.Write(acsStringTable(obj.Strings, 12))
+		writeACSChunk(&data, "STRL", chunk.Bytes())
+	}
+
+	if len(obj.MapInits) > 0 {
+		chunk.Reset()
+		binary.Write(&chunk, binary.LittleEndian, int32(0))
+		binary.Write(&chunk, binary.LittleEndian, obj.MapInits)
+		writeACSChunk(&data, "MINI", chunk.Bytes())
+	}
+
+	if len(obj.Arrays) > 0 {
+		chunk.Reset()
+		for _, array := range obj.Arrays {
+			binary.Write(&chunk, binary.LittleEndian, []int32{int32(array.Index), int32(array.Size)})
+		}
+		writeACSChunk(&data, "ARAY", chunk.Bytes())
+	}
+
+	// Each initialized array has a chunk of its own
+	for _, array := range obj.Arrays {
+		if array.Values != nil {
+			chunk.Reset()
+			binary.Write(&chunk, binary.LittleEndian, int32(array.Index))
+			binary.Write(&chunk, binary.LittleEndian, array.Values)
+			writeACSChunk(&data, "AINI", chunk.Bytes())
+		}
+	}
+
+	// Libraries export their map variables by name
+	if obj.Library && len(obj.MapVars) > 0 {
+		chunk.Reset()
+		binary.Write(&chunk, binary.LittleEndian, int32(len(obj.MapVars)))
+		chunk.Write(acsStringTable(obj.MapVars, 4))
+		writeACSChunk(&data, "MEXP", chunk.Bytes())
+	}
+
+	lump := data.Bytes()
+	copy(lump, "ACSE")
+	binary.LittleEndian.PutUint32(lump[4:], uint32(chunks))
+	return lump
+}
@@ -0,0 +1,2584 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ACSIncludeFunc loads a file named by #include.  It is passed the name
+// as written in the source and the name of the including file, and
+// returns the resolved name and contents of the file.
+type ACSIncludeFunc func(name string, from string) (string, []byte, error)
+
+// ACSIncludeDirs returns an ACSIncludeFunc that reads files from disk.
+// Files are searched for next to the including file first, then in each
+// of the passed directories in order.
+func ACSIncludeDirs(dirs ...string) ACSIncludeFunc {
+	return acsIncludeReader(ioutil.ReadFile, dirs...)
+}
+
+// acsIncludeReader returns an ACSIncludeFunc that searches for files
+// the same way as ACSIncludeDirs, reading them with the passed
+// function.
+func acsIncludeReader(read func(string) ([]byte, error), dirs ...string) ACSIncludeFunc {
+	return func(name string, from string) (string, []byte, error) {
+		candidates := []string{name}
+		if !filepath.IsAbs(name) {
+			candidates = []string{filepath.Join(filepath.Dir(from), name)}
+			for _, dir := range dirs {
+				candidates = append(candidates, filepath.Join(dir, name))
+			}
+		}
+
+		// Candidates a sandbox refuses are skipped like missing ones,
+		// but the refusal is reported if nothing else is found.
+		var denied error
+		for _, candidate := range candidates {
+			data, err := read(candidate)
+			if err == nil {
+				return candidate, data, nil
+			} else if _, ok := err.(*SandboxError); ok {
+				if denied == nil {
+					denied = err
+				}
+			} else if !os.IsNotExist(err) {
+				return "", nil, err
+			}
+		}
+
+		if denied != nil {
+			return "", nil, fmt.Errorf("cannot find %s (%s)", name, denied.Error())
+		}
+		return "", nil, fmt.Errorf("cannot find %s", name)
+	}
+}
+
+// acsTokenType designates the kind of a token read by acsLexer.
+type acsTokenType int
+
+const (
+	acsEOF acsTokenType = iota
+	acsIdentifier
+	acsNumber
+	acsString
+	acsDirective
+	acsPunct
+)
+
+// acsToken is a single token of ACS source.
+type acsToken struct {
+	Type  acsTokenType
+	Text  string
+	Value int32
+	File  string
+	Line  int
+}
+
+// acsLexer splits a single ACS source file into tokens.
+type acsLexer struct {
+	file string
+	src  string
+	pos  int
+	line int
+}
+
+// acsOperators holds the operators longer than one character, longest
+// first.
+var acsOperators = []string{
+	"<<=", ">>=",
+	"==", "!=", "<=", ">=", "&&", "||", "<<", ">>", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+}
+
+// errorf returns an error prefixed with the file name and line.
+func (lex *acsLexer) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", lex.file, lex.line, fmt.Sprintf(format, a...))
+}
+
+// escape reads the character after a backslash in a string or
+// character literal, returning the characters it stands for.
+func (lex *acsLexer) escape() string {
+	c := lex.src[lex.pos]
+	lex.pos++
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case '\\', '"', '\'':
+		return string(c)
+	}
+
+	// Other escapes, like color codes, are left for the engine
+	return "\\" + string(c)
+}
+
+// next returns the next token, or a token of type acsEOF at the end of
+// the file.
+func (lex *acsLexer) next() (acsToken, error) {
+	for lex.pos < len(lex.src) {
+		c := lex.src[lex.pos]
+		if c == '\n' {
+			lex.line++
+			lex.pos++
+		} else if c == ' ' || c == '\t' || c == '\r' {
+			lex.pos++
+		} else if strings.HasPrefix(lex.src[lex.pos:], "//") {
+			for lex.pos < len(lex.src) && lex.src[lex.pos] != '\n' {
+				lex.pos++
+			}
+		} else if strings.HasPrefix(lex.src[lex.pos:], "/*") {
+			end := strings.Index(lex.src[lex.pos+2:], "*/")
+			if end == -1 {
+				return acsToken{}, lex.errorf("unterminated comment")
+			}
+			lex.line += strings.Count(lex.src[lex.pos:lex.pos+2+end], "\n")
+			lex.pos += end + 4
+		} else {
+			break
+		}
+	}
+
+	tok := acsToken{File: lex.file, Line: lex.line}
+	if lex.pos >= len(lex.src) {
+		return tok, nil
+	}
+
+	start := lex.pos
+	c := lex.src[lex.pos]
+	switch {
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '#':
+		lex.pos++
+		for lex.pos < len(lex.src) && isACSIdentifier(lex.src[lex.pos]) {
+			lex.pos++
+		}
+		tok.Type, tok.Text = acsIdentifier, lex.src[start:lex.pos]
+		if c == '#' {
+			tok.Type, tok.Text = acsDirective, strings.ToLower(tok.Text[1:])
+		}
+	case c >= '0' && c <= '9':
+		for lex.pos < len(lex.src) && (isACSIdentifier(lex.src[lex.pos]) || lex.src[lex.pos] == '.') {
+			lex.pos++
+		}
+		tok.Type, tok.Text = acsNumber, lex.src[start:lex.pos]
+
+		lower := strings.ToLower(tok.Text)
+		if strings.HasPrefix(lower, "0x") {
+			value, err := strconv.ParseUint(lower[2:], 16, 32)
+			if err != nil {
+				return acsToken{}, lex.errorf("invalid number %s", tok.Text)
+			}
+			tok.Value = int32(uint32(value))
+		} else if strings.Contains(lower, ".") {
+			value, err := strconv.ParseFloat(lower, 64)
+			if err != nil || value >= 32768 {
+				return acsToken{}, lex.errorf("invalid fixed point number %s", tok.Text)
+			}
+			tok.Value = int32(math.Round(value * 65536))
+		} else {
+			value, err := strconv.ParseUint(lower, 10, 32)
+			if err != nil {
+				return acsToken{}, lex.errorf("invalid number %s", tok.Text)
+			}
+			tok.Value = int32(uint32(value))
+		}
+	case c == '"':
+		var value strings.Builder
+		lex.pos++
+		for {
+			if lex.pos >= len(lex.src) || lex.src[lex.pos] == '\n' {
+				return acsToken{}, lex.errorf("unterminated string")
+			}
+
+			c = lex.src[lex.pos]
+			lex.pos++
+			if c == '"' {
+				break
+			} else if c == '\\' && lex.pos < len(lex.src) {
+				value.WriteString(lex.escape())
+			} else {
+				value.WriteByte(c)
+			}
+		}
+		tok.Type, tok.Text = acsString, value.String()
+	case c == '\'':
+		lex.pos++
+		value := ""
+		if lex.pos < len(lex.src) && lex.src[lex.pos] == '\\' {
+			lex.pos++
+			if lex.pos < len(lex.src) {
+				value = lex.escape()
+			}
+		} else if lex.pos < len(lex.src) {
+			value = lex.src[lex.pos : lex.pos+1]
+			lex.pos++
+		}
+		if len(value) != 1 || lex.pos >= len(lex.src) || lex.src[lex.pos] != '\'' {
+			return acsToken{}, lex.errorf("invalid character literal")
+		}
+		lex.pos++
+		tok.Type, tok.Text, tok.Value = acsNumber, lex.src[start:lex.pos], int32(value[0])
+	default:
+		tok.Type, tok.Text = acsPunct, string(c)
+		for _, op := range acsOperators {
+			if strings.HasPrefix(lex.src[lex.pos:], op) {
+				tok.Text = op
+				break
+			}
+		}
+		lex.pos += len(tok.Text)
+	}
+
+	return tok, nil
+}
+
+// isACSIdentifier returns true if the passed character can be part of
+// an identifier.
+func isACSIdentifier(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
+
+// acsVarKind designates where a variable is stored.
+type acsVarKind int
+
+const (
+	acsVarLocal acsVarKind = iota
+	acsVarMap
+	acsVarWorld
+	acsVarGlobal
+)
+
+// acsVar is a declared variable.  Arrays have the size of each of
+// their dimensions, which is zero for world and global arrays.
+type acsVar struct {
+	Kind  acsVarKind
+	Index int
+	Dims  []int
+}
+
+// acsVarPCodes holds the pcodes that push or modify each kind of
+// variable, indexed by acsVarKind.
+var acsVarPCodes = map[string][4]int{
+	"push": {28, 29, 30, 182},
+	"=":    {25, 26, 27, 181},
+	"+=":   {31, 32, 33, 183},
+	"-=":   {34, 35, 36, 184},
+	"*=":   {37, 38, 39, 185},
+	"/=":   {40, 41, 42, 186},
+	"%=":   {43, 44, 45, 187},
+	"++":   {46, 47, 48, 188},
+	"--":   {49, 50, 51, 189},
+	"&=":   {291, 292, 293, 294},
+	"^=":   {298, 299, 300, 301},
+	"|=":   {305, 306, 307, 308},
+	"<<=":  {312, 313, 314, 315},
+	">>=":  {319, 320, 321, 322},
+}
+
+// acsArrayPCodes holds the pcodes that push or modify an element of
+// each kind of array, indexed by acsVarKind.  There are no local
+// arrays.
+var acsArrayPCodes = map[string][4]int{
+	"push": {0, 207, 226, 235},
+	"=":    {0, 208, 227, 236},
+	"+=":   {0, 209, 228, 237},
+	"-=":   {0, 210, 229, 238},
+	"*=":   {0, 211, 230, 239},
+	"/=":   {0, 212, 231, 240},
+	"%=":   {0, 213, 232, 241},
+	"++":   {0, 214, 233, 242},
+	"--":   {0, 215, 234, 243},
+	"&=":   {0, 295, 296, 297},
+	"^=":   {0, 302, 303, 304},
+	"|=":   {0, 309, 310, 311},
+	"<<=":  {0, 316, 317, 318},
+	">>=":  {0, 323, 324, 325},
+}
+
+// pcodes returns the pcodes that push or modify the variable.
+func (v acsVar) pcodes(op string) int {
+	if v.Dims != nil {
+		return acsArrayPCodes[op][v.Kind]
+	}
+	return acsVarPCodes[op][v.Kind]
+}
+
+// acsSpecial is a line special declared with the special keyword.
+// Specials with negative IDs are ZDoom extension functions, which are
+// called with CALLFUNC and return a value.
+type acsSpecial struct {
+	ID  int
+	Min int
+	Max int
+}
+
+// acsNamedSpecials holds the extension functions that run named
+// scripts, which are declared without including zspecial.acs.
+var acsNamedSpecials = map[string]acsSpecial{
+	"acs_namedexecute":           {-39, 1, 5},
+	"acs_namedsuspend":           {-40, 1, 2},
+	"acs_namedterminate":         {-41, 1, 2},
+	"acs_namedlockedexecute":     {-42, 5, 5},
+	"acs_namedlockedexecutedoor": {-43, 5, 5},
+	"acs_namedexecutewithresult": {-44, 1, 5},
+	"acs_namedexecutealways":     {-45, 1, 5},
+}
+
+// acsBuiltin is a function implemented by a single pcode.  Arguments
+// past Min are optional and default to zero.
+type acsBuiltin struct {
+	PCode   int
+	Min     int
+	Max     int
+	Returns bool
+}
+
+// acsBuiltins holds the functions that ACC implements as pcodes.
+var acsBuiltins = map[string]acsBuiltin{
+	"delay":                    {55, 1, 1, false},
+	"random":                   {57, 2, 2, true},
+	"thingcount":               {59, 2, 2, true},
+	"tagwait":                  {61, 1, 1, false},
+	"polywait":                 {63, 1, 1, false},
+	"changefloor":              {65, 2, 2, false},
+	"changeceiling":            {67, 2, 2, false},
+	"lineside":                 {80, 0, 0, true},
+	"scriptwait":               {81, 1, 1, false},
+	"clearlinespecial":         {83, 0, 0, false},
+	"playercount":              {90, 0, 0, true},
+	"gametype":                 {91, 0, 0, true},
+	"gameskill":                {92, 0, 0, true},
+	"timer":                    {93, 0, 0, true},
+	"sectorsound":              {94, 2, 2, false},
+	"ambientsound":             {95, 2, 2, false},
+	"soundsequence":            {96, 1, 1, false},
+	"setlinetexture":           {97, 4, 4, false},
+	"setlineblocking":          {98, 2, 2, false},
+	"setlinespecial":           {99, 2, 7, false},
+	"thingsound":               {100, 3, 3, false},
+	"activatorsound":           {102, 2, 2, false},
+	"localambientsound":        {103, 2, 2, false},
+	"setlinemonsterblocking":   {104, 2, 2, false},
+	"isnetworkgame":            {118, 0, 0, true},
+	"playerteam":               {119, 0, 0, true},
+	"playerhealth":             {120, 0, 0, true},
+	"playerarmorpoints":        {121, 0, 0, true},
+	"playerfrags":              {122, 0, 0, true},
+	"blueteamcount":            {124, 0, 0, true},
+	"redteamcount":             {125, 0, 0, true},
+	"blueteamscore":            {126, 0, 0, true},
+	"redteamscore":             {127, 0, 0, true},
+	"isoneflagctf":             {128, 0, 0, true},
+	"consolecommand":           {134, 1, 3, false},
+	"singleplayer":             {135, 0, 0, true},
+	"fixedmul":                 {136, 2, 2, true},
+	"fixeddiv":                 {137, 2, 2, true},
+	"setgravity":               {138, 1, 1, false},
+	"setaircontrol":            {140, 1, 1, false},
+	"clearinventory":           {142, 0, 0, false},
+	"giveinventory":            {143, 2, 2, false},
+	"takeinventory":            {145, 2, 2, false},
+	"checkinventory":           {147, 1, 1, true},
+	"spawn":                    {149, 4, 6, true},
+	"spawnspot":                {151, 2, 4, true},
+	"setmusic":                 {153, 1, 3, false},
+	"localsetmusic":            {155, 1, 3, false},
+	"setstyle":                 {163, 1, 1, false},
+	"setfont":                  {165, 1, 1, false},
+	"setthingspecial":          {180, 2, 7, false},
+	"fadeto":                   {190, 5, 5, false},
+	"faderange":                {191, 9, 9, false},
+	"cancelfade":               {192, 0, 0, false},
+	"playmovie":                {193, 1, 1, true},
+	"setfloortrigger":          {194, 3, 8, false},
+	"setceilingtrigger":        {195, 3, 8, false},
+	"getactorx":                {196, 1, 1, true},
+	"getactory":                {197, 1, 1, true},
+	"getactorz":                {198, 1, 1, true},
+	"sin":                      {220, 1, 1, true},
+	"cos":                      {221, 1, 1, true},
+	"vectorangle":              {222, 2, 2, true},
+	"checkweapon":              {223, 1, 1, true},
+	"setweapon":                {224, 1, 1, true},
+	"setmarineweapon":          {244, 2, 2, false},
+	"setactorproperty":         {245, 3, 3, false},
+	"getactorproperty":         {246, 2, 2, true},
+	"playernumber":             {247, 0, 0, true},
+	"activatortid":             {248, 0, 0, true},
+	"setmarinesprite":          {249, 2, 2, false},
+	"getscreenwidth":           {250, 0, 0, true},
+	"getscreenheight":          {251, 0, 0, true},
+	"thing_projectile2":        {252, 7, 7, false},
+	"strlen":                   {253, 1, 1, true},
+	"sethudsize":               {254, 3, 3, false},
+	"getcvar":                  {255, 1, 1, true},
+	"setresultvalue":           {257, 1, 1, false},
+	"getlinerowoffset":         {258, 0, 0, true},
+	"getactorfloorz":           {259, 1, 1, true},
+	"getactorangle":            {260, 1, 1, true},
+	"getsectorfloorz":          {261, 3, 3, true},
+	"getsectorceilingz":        {262, 3, 3, true},
+	"getsigilpieces":           {264, 0, 0, true},
+	"getlevelinfo":             {265, 1, 1, true},
+	"changesky":                {266, 2, 2, false},
+	"playeringame":             {267, 1, 1, true},
+	"playerisbot":              {268, 1, 1, true},
+	"setcameratotexture":       {269, 3, 3, false},
+	"getammocapacity":          {271, 1, 1, true},
+	"setammocapacity":          {272, 2, 2, false},
+	"setactorangle":            {276, 2, 2, false},
+	"spawnprojectile":          {280, 7, 7, false},
+	"getsectorlightlevel":      {281, 1, 1, true},
+	"getactorceilingz":         {282, 1, 1, true},
+	"setactorposition":         {283, 5, 5, true},
+	"clearactorinventory":      {284, 1, 1, false},
+	"giveactorinventory":       {285, 3, 3, false},
+	"takeactorinventory":       {286, 3, 3, false},
+	"checkactorinventory":      {287, 2, 2, true},
+	"thingcountname":           {288, 2, 2, true},
+	"spawnspotfacing":          {289, 2, 3, true},
+	"playerclass":              {290, 1, 1, true},
+	"getplayerinfo":            {326, 2, 2, true},
+	"changelevel":              {327, 3, 4, false},
+	"sectordamage":             {328, 5, 5, false},
+	"replacetextures":          {329, 2, 3, false},
+	"getactorpitch":            {331, 1, 1, true},
+	"setactorpitch":            {332, 2, 2, false},
+	"setactorstate":            {334, 2, 3, true},
+	"thing_damage2":            {335, 3, 3, true},
+	"useinventory":             {336, 1, 1, true},
+	"useactorinventory":        {337, 2, 2, true},
+	"checkactorceilingtexture": {338, 2, 2, true},
+	"checkactorfloortexture":   {339, 2, 2, true},
+	"getactorlightlevel":       {340, 1, 1, true},
+	"setmugshotstate":          {341, 1, 1, false},
+	"thingcountsector":         {342, 3, 3, true},
+	"thingcountnamesector":     {343, 3, 3, true},
+	"checkplayercamera":        {344, 1, 1, true},
+	"morphactor":               {345, 1, 7, true},
+	"unmorphactor":             {346, 1, 2, true},
+	"getplayerinput":           {347, 2, 2, true},
+	"classifyactor":            {348, 1, 1, true},
+}
+
+// acsScriptTypes maps script type keywords to script types.
+var acsScriptTypes = map[string]int{
+	"open":       acsScriptOpen,
+	"respawn":    acsScriptRespawn,
+	"death":      acsScriptDeath,
+	"enter":      acsScriptEnter,
+	"lightning":  acsScriptLightning,
+	"unloading":  acsScriptUnloading,
+	"disconnect": acsScriptDisconnect,
+	"return":     acsScriptReturn,
+}
+
+// acsExprKind designates the kind of an expression node.
+type acsExprKind int
+
+const (
+	acsExprNumber acsExprKind = iota
+	acsExprString
+	acsExprVar
+	acsExprCall
+	acsExprUnary
+	acsExprBinary
+)
+
+// acsExpr is a node of a parsed expression.  Constant subexpressions
+// are folded into numbers as they are parsed.
+type acsExpr struct {
+	Kind  acsExprKind
+	Tok   acsToken
+	PCode int
+	Value int32
+	Var   acsVar
+	Args  []*acsExpr
+	Left  *acsExpr
+	Right *acsExpr
+}
+
+// acsBinaryOps maps binary operators to pcodes, from lowest to highest
+// precedence.
+var acsBinaryOps = []map[string]int{
+	{"||": pcdOrLogical},
+	{"&&": pcdAndLogical},
+	{"|": pcdOrBitwise},
+	{"^": pcdEorBitwise},
+	{"&": pcdAndBitwise},
+	{"==": pcdEQ, "!=": pcdNE},
+	{"<": pcdLT, "<=": pcdLE, ">": pcdGT, ">=": pcdGE},
+	{"<<": pcdLShift, ">>": pcdRShift},
+	{"+": pcdAdd, "-": pcdSubtract},
+	{"*": pcdMultiply, "/": pcdDivide, "%": pcdModulus},
+}
+
+// acsFunctionDef tracks a function that may be called before it is
+// defined.
+type acsFunctionDef struct {
+	acsFunction
+	Defined bool
+	Tok     acsToken
+}
+
+// acsCall is a call to a function, checked once every function is
+// defined.
+type acsCall struct {
+	Function int
+	Args     int
+	Value    bool
+	Tok      acsToken
+}
+
+// acsCase is a case label of a switch statement.
+type acsCase struct {
+	Value   int32
+	Address int
+}
+
+// acsLoop tracks the jumps out of a loop or switch statement.
+type acsLoop struct {
+	Switch    bool
+	Breaks    []int
+	Continues []int
+	Cases     []acsCase
+	Default   int
+}
+
+// acsCompiler compiles ACS source into an acsObject.
+type acsCompiler struct {
+	include  ACSIncludeFunc
+	lexers   []*acsLexer
+	peeked   []acsToken
+	included map[string]bool
+
+	defines  map[string]*acsExpr
+	specials map[string]acsSpecial
+	vars     map[string]acsVar
+
+	scopes    []map[string]acsVar
+	locals    int
+	maxLocals int
+	function  *acsFunctionDef
+	loops     []*acsLoop
+
+	functions     []*acsFunctionDef
+	functionNames map[string]int
+	calls         []acsCall
+	strings       map[string]int
+
+	// Reason the source needs ACSE, if any
+	needACSE string
+
+	obj acsObject
+}
+
+// errorf returns an error prefixed with the file and line of a token.
+func (c *acsCompiler) errorf(tok acsToken, format string, a ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", tok.File, tok.Line, fmt.Sprintf(format, a...))
+}
+
+// peek returns the token at the passed distance ahead without
+// consuming it.  Included files end at the end of their own tokens.
+func (c *acsCompiler) peek(n int) (acsToken, error) {
+	for len(c.peeked) <= n {
+		lex := c.lexers[len(c.lexers)-1]
+		tok, err := lex.next()
+		if err != nil {
+			return acsToken{}, err
+		}
+
+		if tok.Type == acsEOF && len(c.lexers) > 1 {
+			c.lexers = c.lexers[:len(c.lexers)-1]
+			continue
+		}
+		c.peeked = append(c.peeked, tok)
+	}
+
+	return c.peeked[n], nil
+}
+
+// next consumes the next token.
+func (c *acsCompiler) next() (acsToken, error) {
+	tok, err := c.peek(0)
+	if err != nil {
+		return acsToken{}, err
+	}
+
+	if tok.Type != acsEOF {
+		c.peeked = c.peeked[1:]
+	}
+	return tok, nil
+}
+
+// is returns true if the token is the passed punctuation or
+// case-insensitive keyword.
+func (tok acsToken) is(text string) bool {
+	return (tok.Type == acsPunct || tok.Type == acsIdentifier) && strings.EqualFold(tok.Text, text)
+}
+
+// accept consumes the next token if it is the passed punctuation or
+// keyword, returning true if it did.
+func (c *acsCompiler) accept(text string) (bool, error) {
+	tok, err := c.peek(0)
+	if err != nil {
+		return false, err
+	} else if !tok.is(text) {
+		return false, nil
+	}
+
+	c.next()
+	return true, nil
+}
+
+// expect consumes the next token, failing if it is not the passed
+// punctuation or keyword.
+func (c *acsCompiler) expect(text string) error {
+	tok, err := c.next()
+	if err != nil {
+		return err
+	} else if !tok.is(text) {
+		return c.errorf(tok, "expected %q, got %q", text, tok.Text)
+	}
+
+	return nil
+}
+
+// acsKeywords holds the words that cannot name a variable or function.
+var acsKeywords = map[string]bool{
+	"script": true, "function": true, "special": true, "int": true,
+	"str": true, "bool": true, "void": true, "world": true, "global": true,
+	"if": true, "else": true, "while": true, "until": true, "do": true,
+	"for": true, "switch": true, "case": true, "default": true,
+	"break": true, "continue": true, "return": true, "terminate": true,
+	"suspend": true, "restart": true, "print": true, "printbold": true,
+	"log": true, "hudmessage": true, "hudmessagebold": true,
+}
+
+// name consumes an identifier that is not a keyword.
+func (c *acsCompiler) name() (acsToken, error) {
+	tok, err := c.next()
+	if err != nil {
+		return acsToken{}, err
+	} else if tok.Type != acsIdentifier || acsKeywords[strings.ToLower(tok.Text)] {
+		return acsToken{}, c.errorf(tok, "expected name, got %q", tok.Text)
+	}
+
+	return tok, nil
+}
+
+// isType returns true if the token is a variable type.
+func (tok acsToken) isType() bool {
+	return tok.is("int") || tok.is("str") || tok.is("bool")
+}
+
+// emit appends a pcode and its arguments to the code.
+func (c *acsCompiler) emit(pcode int, args ...int32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(pcode))
+	c.obj.Code = append(c.obj.Code, buf[:]...)
+	for _, arg := range args {
+		binary.LittleEndian.PutUint32(buf[:], uint32(arg))
+		c.obj.Code = append(c.obj.Code, buf[:]...)
+	}
+}
+
+// emitJump appends a jump with a target to be patched later, and
+// returns the position of the target.
+func (c *acsCompiler) emitJump(pcode int) int {
+	c.emit(pcode, 0)
+	return len(c.obj.Code) - 4
+}
+
+// patch sets the target of a jump.
+func (c *acsCompiler) patch(at int, target int) {
+	binary.LittleEndian.PutUint32(c.obj.Code[at:], uint32(target))
+}
+
+// stringIndex returns the index of a string in the string table,
+// adding it if needed.
+func (c *acsCompiler) stringIndex(str string) int32 {
+	index, ok := c.strings[str]
+	if !ok {
+		index = len(c.obj.Strings)
+		c.strings[str] = index
+		c.obj.Strings = append(c.obj.Strings, str)
+	}
+
+	return int32(index)
+}
+
+// lookup finds a variable by name, searching local scopes first.
+func (c *acsCompiler) lookup(name string) (acsVar, bool) {
+	name = strings.ToLower(name)
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if v, ok := c.scopes[i][name]; ok {
+			return v, true
+		}
+	}
+
+	v, ok := c.vars[name]
+	return v, ok
+}
+
+// declareLocal adds a local variable to the innermost scope.
+func (c *acsCompiler) declareLocal(tok acsToken) error {
+	name := strings.ToLower(tok.Text)
+	scope := c.scopes[len(c.scopes)-1]
+	if _, ok := scope[name]; ok {
+		return c.errorf(tok, "%s is already declared", tok.Text)
+	}
+
+	scope[name] = acsVar{acsVarLocal, c.locals, nil}
+	c.locals++
+	if c.locals > c.maxLocals {
+		c.maxLocals = c.locals
+	}
+
+	return nil
+}
+
+// pushScope starts a new block scope.
+func (c *acsCompiler) pushScope() {
+	c.scopes = append(c.scopes, map[string]acsVar{})
+}
+
+// popScope ends a block scope.  Local variable slots are not reused.
+func (c *acsCompiler) popScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// foldBinary combines two constant operands of a binary operator.
+func foldBinary(pcode int, left int32, right int32) (int32, error) {
+	toInt := func(b bool) int32 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	switch pcode {
+	case pcdOrLogical:
+		return toInt(left != 0 || right != 0), nil
+	case pcdAndLogical:
+		return toInt(left != 0 && right != 0), nil
+	case pcdOrBitwise:
+		return left | right, nil
+	case pcdEorBitwise:
+		return left ^ right, nil
+	case pcdAndBitwise:
+		return left & right, nil
+	case pcdEQ:
+		return toInt(left == right), nil
+	case pcdNE:
+		return toInt(left != right), nil
+	case pcdLT:
+		return toInt(left < right), nil
+	case pcdLE:
+		return toInt(left <= right), nil
+	case pcdGT:
+		return toInt(left > right), nil
+	case pcdGE:
+		return toInt(left >= right), nil
+	case pcdLShift:
+		return left << (uint32(right) & 31), nil
+	case pcdRShift:
+		return left >> (uint32(right) & 31), nil
+	case pcdAdd:
+		return left + right, nil
+	case pcdSubtract:
+		return left - right, nil
+	case pcdMultiply:
+		return left * right, nil
+	case pcdDivide:
+		if right == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return left / right, nil
+	case pcdModulus:
+		if right == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return left % right, nil
+	}
+
+	return 0, fmt.Errorf("unknown operator %d", pcode)
+}
+
+// binary returns the expression of a binary operator, folding
+// constant operands into a number.
+func (c *acsCompiler) binary(tok acsToken, pcode int, left *acsExpr, right *acsExpr) (*acsExpr, error) {
+	if left.Kind == acsExprNumber && right.Kind == acsExprNumber {
+		value, err := foldBinary(pcode, left.Value, right.Value)
+		if err != nil {
+			return nil, c.errorf(tok, err.Error())
+		}
+		return &acsExpr{Kind: acsExprNumber, Tok: tok, Value: value}, nil
+	}
+
+	return &acsExpr{Kind: acsExprBinary, Tok: tok, PCode: pcode, Left: left, Right: right}, nil
+}
+
+// expression parses an expression of the passed precedence level or
+// higher.
+func (c *acsCompiler) expression(level int) (*acsExpr, error) {
+	if level == len(acsBinaryOps) {
+		return c.unary()
+	}
+
+	left, err := c.expression(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, err := c.peek(0)
+		if err != nil {
+			return nil, err
+		}
+
+		pcode, ok := acsBinaryOps[level][tok.Text]
+		if tok.Type != acsPunct || !ok {
+			return left, nil
+		}
+		c.next()
+
+		right, err := c.expression(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		left, err = c.binary(tok, pcode, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// unary parses a unary expression.
+func (c *acsCompiler) unary() (*acsExpr, error) {
+	tok, err := c.peek(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Type == acsPunct && (tok.Text == "-" || tok.Text == "!" || tok.Text == "~") {
+		c.next()
+		operand, err := c.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		if operand.Kind == acsExprNumber {
+			switch {
+			case tok.Text == "-":
+				operand.Value = -operand.Value
+			case tok.Text == "~":
+				operand.Value = ^operand.Value
+			case operand.Value == 0:
+				operand.Value = 1
+			default:
+				operand.Value = 0
+			}
+			return operand, nil
+		}
+
+		pcode := pcdUnaryMinus
+		if tok.Text == "!" {
+			pcode = pcdNegateLogical
+		} else if tok.Text == "~" {
+			pcode = pcdNegateBinary
+		}
+		return &acsExpr{Kind: acsExprUnary, Tok: tok, PCode: pcode, Left: operand}, nil
+	}
+
+	return c.primary()
+}
+
+// primary parses a number, string, variable, call or parenthesized
+// expression.
+func (c *acsCompiler) primary() (*acsExpr, error) {
+	tok, err := c.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.Type {
+	case acsNumber:
+		return &acsExpr{Kind: acsExprNumber, Tok: tok, Value: tok.Value}, nil
+	case acsString:
+		return &acsExpr{Kind: acsExprString, Tok: tok}, nil
+	case acsIdentifier:
+		if acsKeywords[strings.ToLower(tok.Text)] {
+			break
+		}
+
+		if call, err := c.accept("("); err != nil {
+			return nil, err
+		} else if call {
+			expr := &acsExpr{Kind: acsExprCall, Tok: tok}
+			if ok, err := c.accept(")"); err != nil {
+				return nil, err
+			} else if ok {
+				return expr, nil
+			}
+
+			for {
+				arg, err := c.expression(0)
+				if err != nil {
+					return nil, err
+				}
+				expr.Args = append(expr.Args, arg)
+
+				if ok, err := c.accept(","); err != nil {
+					return nil, err
+				} else if !ok {
+					break
+				}
+			}
+			return expr, c.expect(")")
+		}
+
+		if v, ok := c.lookup(tok.Text); ok {
+			return c.variable(tok, v)
+		}
+
+		if define, ok := c.defines[strings.ToLower(tok.Text)]; ok {
+			value := *define
+			return &value, nil
+		}
+
+		return nil, c.errorf(tok, "unknown identifier %s", tok.Text)
+	case acsPunct:
+		if tok.Text == "(" {
+			expr, err := c.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			return expr, c.expect(")")
+		}
+	}
+
+	return nil, c.errorf(tok, "expected expression, got %q", tok.Text)
+}
+
+// variable parses the subscripts of an array after its name, and
+// returns an expression that refers to the variable or element.  The
+// index of the element is the Left of the expression.
+func (c *acsCompiler) variable(tok acsToken, v acsVar) (*acsExpr, error) {
+	expr := &acsExpr{Kind: acsExprVar, Tok: tok, Var: v}
+	if v.Dims == nil {
+		if next, err := c.peek(0); err != nil {
+			return nil, err
+		} else if next.is("[") {
+			return nil, c.errorf(next, "%s is not an array", tok.Text)
+		}
+		return expr, nil
+	}
+
+	// Elements are stored in row-major order
+	for i, dim := range v.Dims {
+		next, err := c.peek(0)
+		if err != nil {
+			return nil, err
+		} else if !next.is("[") {
+			return nil, c.errorf(next, "%s needs %d subscripts", tok.Text, len(v.Dims))
+		}
+		c.next()
+
+		index, err := c.expression(0)
+		if err != nil {
+			return nil, err
+		} else if index.Kind == acsExprNumber && (index.Value < 0 || dim > 0 && int(index.Value) >= dim) {
+			return nil, c.errorf(next, "index %d of %s is out of range", index.Value, tok.Text)
+		}
+
+		err = c.expect("]")
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			expr.Left = index
+			continue
+		}
+
+		size := &acsExpr{Kind: acsExprNumber, Tok: next, Value: int32(dim)}
+		expr.Left, err = c.binary(next, pcdMultiply, expr.Left, size)
+		if err != nil {
+			return nil, err
+		}
+		expr.Left, err = c.binary(next, pcdAdd, expr.Left, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return expr, nil
+}
+
+// constant parses an expression that must be a number.
+func (c *acsCompiler) constant() (int32, error) {
+	tok, err := c.peek(0)
+	if err != nil {
+		return 0, err
+	}
+
+	expr, err := c.expression(0)
+	if err != nil {
+		return 0, err
+	} else if expr.Kind != acsExprNumber {
+		return 0, c.errorf(tok, "expected constant expression")
+	}
+
+	return expr.Value, nil
+}
+
+// generate emits the code of an expression, which pushes its value.
+func (c *acsCompiler) generate(expr *acsExpr) error {
+	switch expr.Kind {
+	case acsExprNumber:
+		c.emit(pcdPushNumber, expr.Value)
+	case acsExprString:
+		c.emit(pcdPushNumber, c.stringIndex(expr.Tok.Text))
+		if c.obj.Library {
+			c.emit(pcdTagString)
+		}
+	case acsExprVar:
+		if expr.Var.Dims != nil {
+			err := c.generate(expr.Left)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(expr.Var.pcodes("push"), int32(expr.Var.Index))
+	case acsExprCall:
+		return c.generateCall(expr, true)
+	case acsExprUnary:
+		err := c.generate(expr.Left)
+		if err != nil {
+			return err
+		}
+		c.emit(expr.PCode)
+	case acsExprBinary:
+		err := c.generate(expr.Left)
+		if err != nil {
+			return err
+		}
+		err = c.generate(expr.Right)
+		if err != nil {
+			return err
+		}
+		c.emit(expr.PCode)
+	}
+
+	return nil
+}
+
+// generateArgs emits the code of every argument of a call.
+func (c *acsCompiler) generateArgs(expr *acsExpr) error {
+	for _, arg := range expr.Args {
+		err := c.generate(arg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generateCall emits a call to a builtin, special or function.  If
+// value is false, any returned value is discarded.
+func (c *acsCompiler) generateCall(expr *acsExpr, value bool) error {
+	name := strings.ToLower(expr.Tok.Text)
+
+	if builtin, ok := acsBuiltins[name]; ok {
+		if len(expr.Args) < builtin.Min || len(expr.Args) > builtin.Max {
+			if builtin.Min == builtin.Max {
+				return c.errorf(expr.Tok, "%s takes %d arguments", expr.Tok.Text, builtin.Max)
+			}
+			return c.errorf(expr.Tok, "%s takes %d to %d arguments", expr.Tok.Text, builtin.Min, builtin.Max)
+		} else if value && !builtin.Returns {
+			return c.errorf(expr.Tok, "%s does not return a value", expr.Tok.Text)
+		}
+
+		err := c.generateArgs(expr)
+		if err != nil {
+			return err
+		}
+		for i := len(expr.Args); i < builtin.Max; i++ {
+			c.emit(pcdPushNumber, 0)
+		}
+		c.emit(builtin.PCode)
+		if !value && builtin.Returns {
+			c.emit(pcdDrop)
+		}
+		return nil
+	}
+
+	if special, ok := c.specials[name]; ok {
+		if len(expr.Args) < special.Min || len(expr.Args) > special.Max {
+			return c.errorf(expr.Tok, "%s takes %d to %d arguments", expr.Tok.Text, special.Min, special.Max)
+		} else if special.ID > 0 && len(expr.Args) > 5 {
+			return c.errorf(expr.Tok, "%s takes too many arguments", expr.Tok.Text)
+		} else if special.ID < 0 && (len(expr.Args) > 255 || -special.ID > math.MaxUint16) {
+			return c.errorf(expr.Tok, "%s cannot be called", expr.Tok.Text)
+		}
+
+		err := c.generateArgs(expr)
+		if err != nil {
+			return err
+		}
+
+		// Extension functions always return a value
+		if special.ID < 0 {
+			c.emit(pcdCallFunc)
+			c.obj.Code = append(c.obj.Code, byte(len(expr.Args)), byte(-special.ID), byte(-special.ID>>8))
+			if !value {
+				c.emit(pcdDrop)
+			}
+			return nil
+		}
+
+		// Specials return a value when called with all five arguments
+		args := len(expr.Args)
+		if value {
+			for ; args < 5; args++ {
+				c.emit(pcdPushNumber, 0)
+			}
+			c.emit(pcdLSpec5Result, int32(special.ID))
+			return nil
+		}
+
+		// Specials without arguments are called with a zero
+		if args == 0 {
+			c.emit(pcdPushNumber, 0)
+			args = 1
+		}
+		c.emit(pcdLSpec1+args-1, int32(special.ID))
+		return nil
+	}
+
+	// Functions may be called before they are defined
+	index, ok := c.functionNames[name]
+	if !ok {
+		index = len(c.functions)
+		c.functionNames[name] = index
+		c.functions = append(c.functions, &acsFunctionDef{
+			acsFunction: acsFunction{Name: expr.Tok.Text},
+			Tok:         expr.Tok,
+		})
+	}
+	c.calls = append(c.calls, acsCall{index, len(expr.Args), value, expr.Tok})
+
+	err := c.generateArgs(expr)
+	if err != nil {
+		return err
+	}
+
+	if value {
+		c.emit(pcdCall, int32(index))
+	} else {
+		c.emit(pcdCallDiscard, int32(index))
+	}
+	return nil
+}
+
+// target parses a variable or array element that is assigned to.
+func (c *acsCompiler) target() (*acsExpr, error) {
+	tok, err := c.name()
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := c.lookup(tok.Text)
+	if !ok {
+		return nil, c.errorf(tok, "unknown variable %s", tok.Text)
+	}
+	return c.variable(tok, v)
+}
+
+// assign emits the code that changes a variable or array element with
+// an assignment operator.  The value is nil for increments and
+// decrements.
+func (c *acsCompiler) assign(target *acsExpr, op string, value *acsExpr) error {
+	if target.Var.Dims != nil {
+		err := c.generate(target.Left)
+		if err != nil {
+			return err
+		}
+	}
+
+	if value != nil {
+		err := c.generate(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.emit(target.Var.pcodes(op), int32(target.Var.Index))
+	return nil
+}
+
+// simple parses and emits an assignment, increment, decrement or call.
+func (c *acsCompiler) simple() error {
+	tok, err := c.peek(0)
+	if err != nil {
+		return err
+	}
+
+	// Prefix increment and decrement
+	if tok.Type == acsPunct && (tok.Text == "++" || tok.Text == "--") {
+		c.next()
+		target, err := c.target()
+		if err != nil {
+			return err
+		}
+		return c.assign(target, tok.Text, nil)
+	}
+
+	op, err := c.peek(1)
+	if err != nil {
+		return err
+	}
+
+	_, assignment := acsVarPCodes[op.Text]
+	if tok.Type == acsIdentifier && op.Type == acsPunct && (assignment || op.Text == "[") {
+		target, err := c.target()
+		if err != nil {
+			return err
+		}
+
+		op, err := c.next()
+		if err != nil {
+			return err
+		} else if _, ok := acsVarPCodes[op.Text]; op.Type != acsPunct || !ok {
+			return c.errorf(op, "expected assignment, got %q", op.Text)
+		}
+
+		var value *acsExpr
+		if op.Text != "++" && op.Text != "--" {
+			value, err = c.expression(0)
+			if err != nil {
+				return err
+			}
+		}
+		return c.assign(target, op.Text, value)
+	}
+
+	expr, err := c.expression(0)
+	if err != nil {
+		return err
+	} else if expr.Kind != acsExprCall {
+		return c.errorf(tok, "expression has no effect")
+	}
+
+	return c.generateCall(expr, false)
+}
+
+// declaration parses the names and initializers of local variables,
+// after the type.
+func (c *acsCompiler) declaration() error {
+	for {
+		tok, err := c.name()
+		if err != nil {
+			return err
+		}
+
+		if next, err := c.peek(0); err != nil {
+			return err
+		} else if next.is("[") {
+			return c.errorf(next, "local arrays are not supported")
+		}
+
+		var init *acsExpr
+		if ok, err := c.accept("="); err != nil {
+			return err
+		} else if ok {
+			init, err = c.expression(0)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Declared after the initializer, which cannot refer to it
+		err = c.declareLocal(tok)
+		if err != nil {
+			return err
+		}
+
+		if init != nil {
+			err = c.generate(init)
+			if err != nil {
+				return err
+			}
+			v, _ := c.lookup(tok.Text)
+			c.emit(v.pcodes("="), int32(v.Index))
+		}
+
+		if ok, err := c.accept(","); err != nil {
+			return err
+		} else if !ok {
+			return c.expect(";")
+		}
+	}
+}
+
+// condition parses a parenthesized expression and emits its code.
+func (c *acsCompiler) condition() error {
+	err := c.expect("(")
+	if err != nil {
+		return err
+	}
+
+	expr, err := c.expression(0)
+	if err != nil {
+		return err
+	}
+
+	err = c.expect(")")
+	if err != nil {
+		return err
+	}
+
+	return c.generate(expr)
+}
+
+// loop parses the body of a loop or switch, returning the jumps out of
+// it to be patched.
+func (c *acsCompiler) loop(isSwitch bool) (*acsLoop, error) {
+	loop := &acsLoop{Switch: isSwitch, Default: -1}
+	c.loops = append(c.loops, loop)
+	defer func() { c.loops = c.loops[:len(c.loops)-1] }()
+
+	return loop, c.statement()
+}
+
+// patchLoop points the breaks and continues of a loop at their
+// targets.
+func (c *acsCompiler) patchLoop(loop *acsLoop, breakTo int, continueTo int) {
+	for _, at := range loop.Breaks {
+		c.patch(at, breakTo)
+	}
+	for _, at := range loop.Continues {
+		c.patch(at, continueTo)
+	}
+}
+
+// block parses statements up to a closing brace, in a new scope.
+func (c *acsCompiler) block() error {
+	c.pushScope()
+	defer c.popScope()
+
+	for {
+		tok, err := c.peek(0)
+		if err != nil {
+			return err
+		} else if tok.Type == acsEOF {
+			return c.errorf(tok, "expected \"}\"")
+		} else if tok.is("}") {
+			c.next()
+			return nil
+		}
+
+		err = c.statement()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// acsPrintTypes maps the types of print items to the pcodes that print
+// them.
+var acsPrintTypes = map[string]int{
+	"s": pcdPrintString,
+	"d": pcdPrintNumber,
+	"i": pcdPrintNumber,
+	"c": pcdPrintCharacter,
+	"f": pcdPrintFixed,
+	"n": pcdPrintName,
+	"l": pcdPrintLocalized,
+	"k": pcdPrintBind,
+	"b": pcdPrintBinary,
+	"x": pcdPrintHex,
+}
+
+// printItems parses and emits the items of a print, up to the token
+// that ends them.
+func (c *acsCompiler) printItems() error {
+	for {
+		kind, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		pcode, ok := acsPrintTypes[strings.ToLower(kind.Text)]
+		if kind.Type != acsIdentifier {
+			return c.errorf(kind, "expected print type, got %q", kind.Text)
+		} else if strings.EqualFold(kind.Text, "a") {
+			return c.errorf(kind, "printing character arrays is not supported")
+		} else if !ok {
+			return c.errorf(kind, "unknown print type %q", kind.Text)
+		}
+
+		err = c.expect(":")
+		if err != nil {
+			return err
+		}
+
+		expr, err := c.expression(0)
+		if err != nil {
+			return err
+		}
+		err = c.generate(expr)
+		if err != nil {
+			return err
+		}
+		c.emit(pcode)
+
+		if ok, err := c.accept(","); err != nil {
+			return err
+		} else if !ok {
+			return nil
+		}
+	}
+}
+
+// print parses a print, printbold or log statement, which ends with
+// the passed pcode.
+func (c *acsCompiler) print(end int) error {
+	err := c.expect("(")
+	if err != nil {
+		return err
+	}
+
+	c.emit(pcdBeginPrint)
+	if ok, err := c.accept(")"); err != nil {
+		return err
+	} else if !ok {
+		err = c.printItems()
+		if err != nil {
+			return err
+		}
+
+		err = c.expect(")")
+		if err != nil {
+			return err
+		}
+	}
+
+	c.emit(end)
+	return c.expect(";")
+}
+
+// hudMessage parses a hudmessage or hudmessagebold statement.  The
+// items are followed by the type, id, color, position and hold time of
+// the message, and any arguments that its type takes.
+func (c *acsCompiler) hudMessage(bold bool) error {
+	err := c.expect("(")
+	if err != nil {
+		return err
+	}
+
+	c.emit(pcdBeginPrint)
+	err = c.printItems()
+	if err != nil {
+		return err
+	}
+
+	err = c.expect(";")
+	if err != nil {
+		return err
+	}
+
+	c.emit(pcdMoreHudMessage)
+	for i := 0; ; i++ {
+		if i == 6 {
+			c.emit(pcdOptHudMessage)
+		}
+
+		expr, err := c.expression(0)
+		if err != nil {
+			return err
+		}
+		err = c.generate(expr)
+		if err != nil {
+			return err
+		}
+
+		if ok, err := c.accept(","); err != nil {
+			return err
+		} else if !ok && i < 5 {
+			tok, _ := c.peek(0)
+			return c.errorf(tok, "hud messages take at least 6 arguments")
+		} else if !ok {
+			break
+		}
+	}
+
+	err = c.expect(")")
+	if err != nil {
+		return err
+	}
+
+	if bold {
+		c.emit(pcdEndHudBold)
+	} else {
+		c.emit(pcdEndHudMessage)
+	}
+	return c.expect(";")
+}
+
+// statement parses and emits a single statement.
+func (c *acsCompiler) statement() error {
+	tok, err := c.next()
+	if err != nil {
+		return err
+	}
+
+	keyword := ""
+	if tok.Type == acsIdentifier {
+		keyword = strings.ToLower(tok.Text)
+	}
+
+	switch {
+	case tok.is(";"):
+		return nil
+	case tok.is("{"):
+		return c.block()
+	case tok.isType():
+		return c.declaration()
+	case keyword == "if":
+		err := c.condition()
+		if err != nil {
+			return err
+		}
+
+		jumpElse := c.emitJump(pcdIfNotGoto)
+		err = c.statement()
+		if err != nil {
+			return err
+		}
+
+		if ok, err := c.accept("else"); err != nil {
+			return err
+		} else if ok {
+			jumpEnd := c.emitJump(pcdGoto)
+			c.patch(jumpElse, len(c.obj.Code))
+			err = c.statement()
+			if err != nil {
+				return err
+			}
+			c.patch(jumpEnd, len(c.obj.Code))
+		} else {
+			c.patch(jumpElse, len(c.obj.Code))
+		}
+		return nil
+	case keyword == "while" || keyword == "until":
+		top := len(c.obj.Code)
+		err := c.condition()
+		if err != nil {
+			return err
+		}
+
+		pcode := pcdIfNotGoto
+		if keyword == "until" {
+			pcode = pcdIfGoto
+		}
+		jumpEnd := c.emitJump(pcode)
+
+		loop, err := c.loop(false)
+		if err != nil {
+			return err
+		}
+		c.emit(pcdGoto, int32(top))
+		c.patch(jumpEnd, len(c.obj.Code))
+		c.patchLoop(loop, len(c.obj.Code), top)
+		return nil
+	case keyword == "do":
+		top := len(c.obj.Code)
+		loop, err := c.loop(false)
+		if err != nil {
+			return err
+		}
+
+		cond := len(c.obj.Code)
+		pcode := pcdIfGoto
+		if ok, err := c.accept("until"); err != nil {
+			return err
+		} else if ok {
+			pcode = pcdIfNotGoto
+		} else if err := c.expect("while"); err != nil {
+			return err
+		}
+
+		err = c.condition()
+		if err != nil {
+			return err
+		}
+		c.emit(pcode, int32(top))
+		c.patchLoop(loop, len(c.obj.Code), cond)
+		return c.expect(";")
+	case keyword == "for":
+		c.pushScope()
+		defer c.popScope()
+
+		err := c.expect("(")
+		if err != nil {
+			return err
+		}
+
+		// Initializer, which may declare variables
+		if next, err := c.peek(0); err != nil {
+			return err
+		} else if next.isType() {
+			c.next()
+			err = c.declaration()
+			if err != nil {
+				return err
+			}
+		} else if ok, err := c.accept(";"); err != nil {
+			return err
+		} else if !ok {
+			for {
+				err = c.simple()
+				if err != nil {
+					return err
+				}
+				if ok, err := c.accept(","); err != nil {
+					return err
+				} else if !ok {
+					break
+				}
+			}
+			err = c.expect(";")
+			if err != nil {
+				return err
+			}
+		}
+
+		top := len(c.obj.Code)
+		jumpEnd := -1
+		if ok, err := c.accept(";"); err != nil {
+			return err
+		} else if !ok {
+			expr, err := c.expression(0)
+			if err != nil {
+				return err
+			}
+			err = c.generate(expr)
+			if err != nil {
+				return err
+			}
+			jumpEnd = c.emitJump(pcdIfNotGoto)
+			err = c.expect(";")
+			if err != nil {
+				return err
+			}
+		}
+
+		// The step comes before the body in the source but after it
+		// in the code.  It has no jumps, so it can be moved.
+		stepStart := len(c.obj.Code)
+		if ok, err := c.accept(")"); err != nil {
+			return err
+		} else if !ok {
+			for {
+				err = c.simple()
+				if err != nil {
+					return err
+				}
+				if ok, err := c.accept(","); err != nil {
+					return err
+				} else if !ok {
+					break
+				}
+			}
+			err = c.expect(")")
+			if err != nil {
+				return err
+			}
+		}
+		step := append([]byte{}, c.obj.Code[stepStart:]...)
+		c.obj.Code = c.obj.Code[:stepStart]
+
+		loop, err := c.loop(false)
+		if err != nil {
+			return err
+		}
+
+		cont := len(c.obj.Code)
+		c.obj.Code = append(c.obj.Code, step...)
+		c.emit(pcdGoto, int32(top))
+		if jumpEnd != -1 {
+			c.patch(jumpEnd, len(c.obj.Code))
+		}
+		c.patchLoop(loop, len(c.obj.Code), cont)
+		return nil
+	case keyword == "switch":
+		err := c.condition()
+		if err != nil {
+			return err
+		}
+		jumpDispatch := c.emitJump(pcdGoto)
+
+		loop, err := c.loop(true)
+		if err != nil {
+			return err
+		}
+		loop.Breaks = append(loop.Breaks, c.emitJump(pcdGoto))
+
+		// The dispatch table follows the body
+		c.patch(jumpDispatch, len(c.obj.Code))
+		for _, label := range loop.Cases {
+			c.emit(pcdCaseGoto, label.Value, int32(label.Address))
+		}
+		c.emit(pcdDrop)
+		if loop.Default != -1 {
+			c.emit(pcdGoto, int32(loop.Default))
+		}
+		c.patchLoop(loop, len(c.obj.Code), 0)
+		return nil
+	case keyword == "case" || keyword == "default":
+		if len(c.loops) == 0 || !c.loops[len(c.loops)-1].Switch {
+			return c.errorf(tok, "%s outside of switch", keyword)
+		}
+		loop := c.loops[len(c.loops)-1]
+
+		if keyword == "case" {
+			value, err := c.constant()
+			if err != nil {
+				return err
+			}
+			for _, label := range loop.Cases {
+				if label.Value == value {
+					return c.errorf(tok, "duplicate case %d", value)
+				}
+			}
+			loop.Cases = append(loop.Cases, acsCase{value, len(c.obj.Code)})
+		} else if loop.Default != -1 {
+			return c.errorf(tok, "duplicate default")
+		} else {
+			loop.Default = len(c.obj.Code)
+		}
+		return c.expect(":")
+	case keyword == "break":
+		if len(c.loops) == 0 {
+			return c.errorf(tok, "break outside of loop or switch")
+		}
+		loop := c.loops[len(c.loops)-1]
+		loop.Breaks = append(loop.Breaks, c.emitJump(pcdGoto))
+		return c.expect(";")
+	case keyword == "continue":
+		for i := len(c.loops) - 1; i >= 0; i-- {
+			if !c.loops[i].Switch {
+				c.loops[i].Continues = append(c.loops[i].Continues, c.emitJump(pcdGoto))
+				return c.expect(";")
+			}
+		}
+		return c.errorf(tok, "continue outside of loop")
+	case keyword == "return":
+		if c.function == nil {
+			return c.errorf(tok, "return outside of function")
+		}
+
+		if ok, err := c.accept(";"); err != nil {
+			return err
+		} else if ok {
+			if c.function.Returns {
+				return c.errorf(tok, "function %s must return a value", c.function.Name)
+			}
+			c.emit(pcdReturnVoid)
+			return nil
+		}
+
+		if !c.function.Returns {
+			return c.errorf(tok, "function %s does not return a value", c.function.Name)
+		}
+
+		expr, err := c.expression(0)
+		if err != nil {
+			return err
+		}
+		err = c.generate(expr)
+		if err != nil {
+			return err
+		}
+		c.emit(pcdReturnVal)
+		return c.expect(";")
+	case keyword == "terminate" || keyword == "suspend" || keyword == "restart":
+		if c.function != nil {
+			return c.errorf(tok, "%s inside of function", keyword)
+		}
+
+		switch keyword {
+		case "terminate":
+			c.emit(pcdTerminate)
+		case "suspend":
+			c.emit(pcdSuspend)
+		case "restart":
+			c.emit(pcdRestart)
+		}
+		return c.expect(";")
+	case keyword == "print":
+		return c.print(pcdEndPrint)
+	case keyword == "printbold":
+		return c.print(pcdEndPrintBold)
+	case keyword == "log":
+		return c.print(pcdEndLog)
+	case keyword == "hudmessage" || keyword == "hudmessagebold":
+		return c.hudMessage(keyword == "hudmessagebold")
+	}
+
+	// Assignments and calls
+	c.peeked = append([]acsToken{tok}, c.peeked...)
+	err = c.simple()
+	if err != nil {
+		return err
+	}
+	return c.expect(";")
+}
+
+// parameters parses the parameter list of a script or function,
+// declaring each parameter as a local variable.
+func (c *acsCompiler) parameters() (int, error) {
+	err := c.expect("(")
+	if err != nil {
+		return 0, err
+	}
+
+	if ok, err := c.accept("void"); err != nil {
+		return 0, err
+	} else if ok {
+		return 0, c.expect(")")
+	}
+
+	if ok, err := c.accept(")"); err != nil || ok {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		tok, err := c.next()
+		if err != nil {
+			return 0, err
+		} else if !tok.isType() {
+			return 0, c.errorf(tok, "expected parameter type, got %q", tok.Text)
+		}
+
+		name, err := c.name()
+		if err != nil {
+			return 0, err
+		}
+		err = c.declareLocal(name)
+		if err != nil {
+			return 0, err
+		}
+		count++
+
+		if ok, err := c.accept(","); err != nil {
+			return 0, err
+		} else if !ok {
+			return count, c.expect(")")
+		}
+	}
+}
+
+// startRoutine resets the local variable state for a script or
+// function body.
+func (c *acsCompiler) startRoutine() {
+	c.scopes = []map[string]acsVar{{}}
+	c.locals, c.maxLocals = 0, 0
+}
+
+// script parses a script definition after the script keyword.
+func (c *acsCompiler) script(tok acsToken) error {
+	next, err := c.peek(0)
+	if err != nil {
+		return err
+	}
+
+	// Named scripts are numbered from -1 down, and names ignore case
+	script := acsScript{}
+	if next.Type == acsString {
+		c.next()
+		named := 0
+		for _, other := range c.obj.Scripts {
+			if other.Number < 0 {
+				named++
+			}
+			if other.Number < 0 && strings.EqualFold(other.Name, next.Text) {
+				return c.errorf(tok, "script %q is already defined", next.Text)
+			}
+		}
+
+		if next.Text == "" {
+			return c.errorf(tok, "script name is empty")
+		} else if named == 32768 {
+			return c.errorf(tok, "too many named scripts")
+		}
+		script.Number, script.Name = -1-named, next.Text
+		c.needACSE = "named scripts"
+	} else {
+		number, err := c.constant()
+		if err != nil {
+			return err
+		} else if number < 1 || number > 32767 {
+			return c.errorf(tok, "script number %d out of range", number)
+		}
+
+		for _, other := range c.obj.Scripts {
+			if other.Number == int(number) {
+				return c.errorf(tok, "script %d is already defined", number)
+			}
+		}
+		script.Number = int(number)
+	}
+
+	c.startRoutine()
+
+	// Parameters, type and flags, with the parameters in any place
+	params := false
+	for {
+		next, err := c.peek(0)
+		if err != nil {
+			return err
+		} else if next.is("(") && !params {
+			script.Args, err = c.parameters()
+			if err != nil {
+				return err
+			} else if script.Args > 4 {
+				return c.errorf(tok, "script %s has too many arguments", script.label())
+			}
+			params = true
+			continue
+		} else if next.Type != acsIdentifier {
+			break
+		}
+
+		keyword := strings.ToLower(next.Text)
+		if scriptType, ok := acsScriptTypes[keyword]; ok {
+			if script.Type != acsScriptClosed {
+				return c.errorf(next, "script %s has more than one type", script.label())
+			}
+			script.Type = scriptType
+		} else if keyword == "net" {
+			script.Flags |= acsFlagNet
+		} else if keyword == "clientside" {
+			script.Flags |= acsFlagClientside
+		} else {
+			break
+		}
+		c.next()
+	}
+
+	if script.Args > 0 && script.Type != acsScriptClosed && script.Type != acsScriptDisconnect {
+		return c.errorf(tok, "%s scripts cannot have arguments", acsScriptTypeNames[script.Type])
+	}
+
+	if script.Flags != 0 {
+		c.needACSE = "script flags"
+	}
+
+	err = c.expect("{")
+	if err != nil {
+		return err
+	}
+
+	script.Address = len(c.obj.Code)
+	c.function = nil
+	err = c.block()
+	if err != nil {
+		return err
+	}
+	c.emit(pcdTerminate)
+
+	script.Locals = c.maxLocals
+	if script.Locals > 20 {
+		c.needACSE = "more than 20 local variables"
+	}
+	c.obj.Scripts = append(c.obj.Scripts, script)
+
+	return nil
+}
+
+// functionDef parses a function definition after the function keyword.
+func (c *acsCompiler) functionDef() error {
+	typeTok, err := c.next()
+	if err != nil {
+		return err
+	} else if !typeTok.isType() && !typeTok.is("void") {
+		return c.errorf(typeTok, "expected return type, got %q", typeTok.Text)
+	}
+
+	tok, err := c.name()
+	if err != nil {
+		return err
+	}
+
+	name := strings.ToLower(tok.Text)
+	if _, ok := acsBuiltins[name]; ok {
+		return c.errorf(tok, "%s is a builtin function", tok.Text)
+	} else if _, ok := c.specials[name]; ok {
+		return c.errorf(tok, "%s is a special", tok.Text)
+	}
+
+	index, ok := c.functionNames[name]
+	if !ok {
+		index = len(c.functions)
+		c.functionNames[name] = index
+		c.functions = append(c.functions, &acsFunctionDef{})
+	} else if c.functions[index].Defined {
+		return c.errorf(tok, "function %s is already defined", tok.Text)
+	}
+
+	function := c.functions[index]
+	function.Name = tok.Text
+	function.Tok = tok
+	function.Defined = true
+	function.Returns = !typeTok.is("void")
+
+	c.startRoutine()
+	function.Args, err = c.parameters()
+	if err != nil {
+		return err
+	}
+
+	err = c.expect("{")
+	if err != nil {
+		return err
+	}
+
+	function.Address = len(c.obj.Code)
+	c.function = function
+	err = c.block()
+	c.function = nil
+	if err != nil {
+		return err
+	}
+
+	// Falling off the end returns zero from functions with values
+	if function.Returns {
+		c.emit(pcdPushNumber, 0)
+		c.emit(pcdReturnVal)
+	} else {
+		c.emit(pcdReturnVoid)
+	}
+
+	function.Locals = c.maxLocals
+	if function.Locals > 255 {
+		return c.errorf(tok, "function %s has too many local variables", tok.Text)
+	}
+	c.needACSE = "functions"
+
+	return nil
+}
+
+// special parses a list of special declarations after the special
+// keyword.
+func (c *acsCompiler) special() error {
+	for {
+		id, err := c.constant()
+		if err != nil {
+			return err
+		}
+
+		err = c.expect(":")
+		if err != nil {
+			return err
+		}
+
+		tok, err := c.name()
+		if err != nil {
+			return err
+		}
+
+		err = c.expect("(")
+		if err != nil {
+			return err
+		}
+
+		min, err := c.constant()
+		if err != nil {
+			return err
+		}
+
+		max := min
+		if ok, err := c.accept(","); err != nil {
+			return err
+		} else if ok {
+			max, err = c.constant()
+			if err != nil {
+				return err
+			}
+		}
+
+		err = c.expect(")")
+		if err != nil {
+			return err
+		}
+
+		c.specials[strings.ToLower(tok.Text)] = acsSpecial{int(id), int(min), int(max)}
+
+		if ok, err := c.accept(","); err != nil {
+			return err
+		} else if !ok {
+			return c.expect(";")
+		}
+	}
+}
+
+// initValue parses the initial value of a map variable or array
+// element, which is a number or a string.
+func (c *acsCompiler) initValue() (int32, error) {
+	tok, err := c.peek(0)
+	if err != nil {
+		return 0, err
+	}
+
+	expr, err := c.expression(0)
+	if err != nil {
+		return 0, err
+	}
+
+	switch expr.Kind {
+	case acsExprNumber:
+		return expr.Value, nil
+	case acsExprString:
+		return c.stringIndex(expr.Tok.Text), nil
+	}
+	return 0, c.errorf(tok, "expected constant expression")
+}
+
+// dimensions parses the sizes of the dimensions of an array after its
+// name.  The size of the first dimension may be left out, which is
+// returned as zero.
+func (c *acsCompiler) dimensions() ([]int, error) {
+	dims := []int{}
+	for {
+		if ok, err := c.accept("["); err != nil {
+			return nil, err
+		} else if !ok {
+			return dims, nil
+		}
+
+		if ok, err := c.accept("]"); err != nil {
+			return nil, err
+		} else if ok && len(dims) == 0 {
+			dims = append(dims, 0)
+			continue
+		}
+
+		tok, err := c.peek(0)
+		if err != nil {
+			return nil, err
+		}
+		size, err := c.constant()
+		if err != nil {
+			return nil, err
+		} else if size < 1 || size > 65536 {
+			return nil, c.errorf(tok, "array size %d out of range", size)
+		}
+		dims = append(dims, int(size))
+
+		err = c.expect("]")
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// arrayInit parses the braced initializer of an array or one of its
+// rows, storing the values from an offset.  Returns the number of
+// elements of the outermost dimension that were given.
+func (c *acsCompiler) arrayInit(name acsToken, dims []int, values *[]int32, offset int) (int, error) {
+	err := c.expect("{")
+	if err != nil {
+		return 0, err
+	}
+
+	stride := 1
+	for _, dim := range dims[1:] {
+		stride *= dim
+	}
+
+	count := 0
+	for {
+		if ok, err := c.accept("}"); err != nil || ok {
+			return count, err
+		} else if dims[0] != 0 && count == dims[0] {
+			tok, _ := c.peek(0)
+			return 0, c.errorf(tok, "too many initializers for %s", name.Text)
+		}
+
+		if len(dims) > 1 {
+			_, err = c.arrayInit(name, dims[1:], values, offset+count*stride)
+			if err != nil {
+				return 0, err
+			}
+		} else {
+			value, err := c.initValue()
+			if err != nil {
+				return 0, err
+			}
+			for len(*values) <= offset+count {
+				*values = append(*values, 0)
+			}
+			(*values)[offset+count] = value
+		}
+		count++
+
+		if ok, err := c.accept(","); err != nil {
+			return 0, err
+		} else if !ok {
+			return count, c.expect("}")
+		}
+	}
+}
+
+// mapVariables parses a declaration of map variables and arrays after
+// the type.  Arrays take the place of a single map variable.
+func (c *acsCompiler) mapVariables() error {
+	for {
+		tok, err := c.name()
+		if err != nil {
+			return err
+		}
+
+		name := strings.ToLower(tok.Text)
+		if _, ok := c.vars[name]; ok {
+			return c.errorf(tok, "%s is already declared", tok.Text)
+		} else if len(c.obj.MapVars) >= 128 {
+			return c.errorf(tok, "too many map variables")
+		}
+
+		dims, err := c.dimensions()
+		if err != nil {
+			return err
+		}
+
+		var value int32
+		var values []int32
+		if ok, err := c.accept("="); err != nil {
+			return err
+		} else if ok && len(dims) > 0 {
+			values = []int32{}
+			count, err := c.arrayInit(tok, dims, &values, 0)
+			if err != nil {
+				return err
+			} else if dims[0] == 0 {
+				dims[0] = count
+			}
+		} else if ok {
+			value, err = c.initValue()
+			if err != nil {
+				return err
+			}
+		}
+
+		index := len(c.obj.MapVars)
+		if len(dims) > 0 {
+			if dims[0] == 0 {
+				return c.errorf(tok, "size of %s is unknown", tok.Text)
+			}
+
+			size := 1
+			for _, dim := range dims {
+				size *= dim
+			}
+			if size > 65536 {
+				return c.errorf(tok, "%s is too large", tok.Text)
+			}
+
+			// Arrays that start at zero need no AINI chunk
+			initialized := false
+			for _, value := range values {
+				initialized = initialized || value != 0
+			}
+			if !initialized {
+				values = nil
+			}
+			for values != nil && len(values) < size {
+				values = append(values, 0)
+			}
+
+			c.vars[name] = acsVar{acsVarMap, index, dims}
+			c.obj.Arrays = append(c.obj.Arrays, acsArray{index, size, values})
+			c.needACSE = "arrays"
+		} else {
+			c.vars[name] = acsVar{acsVarMap, index, nil}
+		}
+
+		c.obj.MapVars = append(c.obj.MapVars, tok.Text)
+		c.obj.MapInits = append(c.obj.MapInits, value)
+		if value != 0 {
+			c.needACSE = "map variable initializers"
+		}
+
+		if ok, err := c.accept(","); err != nil {
+			return err
+		} else if !ok {
+			return c.expect(";")
+		}
+	}
+}
+
+// scopedVariables parses a declaration of world or global variables
+// after the world or global keyword.  World and global arrays have no
+// size.
+func (c *acsCompiler) scopedVariables(kind acsVarKind) error {
+	typeTok, err := c.next()
+	if err != nil {
+		return err
+	} else if !typeTok.isType() {
+		return c.errorf(typeTok, "expected variable type, got %q", typeTok.Text)
+	}
+
+	limit := 256
+	if kind == acsVarGlobal {
+		limit = 64
+	}
+
+	for {
+		index, err := c.constant()
+		if err != nil {
+			return err
+		}
+
+		err = c.expect(":")
+		if err != nil {
+			return err
+		}
+
+		tok, err := c.name()
+		if err != nil {
+			return err
+		} else if index < 0 || int(index) >= limit {
+			return c.errorf(tok, "variable index %d out of range", index)
+		}
+
+		name := strings.ToLower(tok.Text)
+		if _, ok := c.vars[name]; ok {
+			return c.errorf(tok, "%s is already declared", tok.Text)
+		}
+
+		var dims []int
+		if ok, err := c.accept("["); err != nil {
+			return err
+		} else if ok {
+			err = c.expect("]")
+			if err != nil {
+				return err
+			}
+			dims = []int{0}
+		}
+		c.vars[name] = acsVar{kind, int(index), dims}
+
+		if ok, err := c.accept(","); err != nil {
+			return err
+		} else if !ok {
+			return c.expect(";")
+		}
+	}
+}
+
+// directive handles a preprocessor directive.
+func (c *acsCompiler) directive(tok acsToken) error {
+	switch tok.Text {
+	case "include":
+		nameTok, err := c.next()
+		if err != nil {
+			return err
+		} else if nameTok.Type != acsString {
+			return c.errorf(nameTok, "expected file name")
+		}
+
+		if c.include == nil {
+			return c.errorf(nameTok, "cannot include %s", nameTok.Text)
+		}
+
+		name, data, err := c.include(nameTok.Text, nameTok.File)
+		if err != nil {
+			return c.errorf(nameTok, err.Error())
+		}
+
+		// Files are only included once
+		if c.included[name] {
+			return nil
+		}
+		c.included[name] = true
+		c.lexers = append(c.lexers, &acsLexer{file: name, src: string(data), line: 1})
+	case "define", "libdefine":
+		nameTok, err := c.name()
+		if err != nil {
+			return err
+		}
+
+		expr, err := c.expression(0)
+		if err != nil {
+			return err
+		} else if expr.Kind != acsExprNumber && expr.Kind != acsExprString {
+			return c.errorf(nameTok, "%s is not constant", nameTok.Text)
+		}
+		c.defines[strings.ToLower(nameTok.Text)] = expr
+	case "library":
+		nameTok, err := c.next()
+		if err != nil {
+			return err
+		} else if nameTok.Type != acsString {
+			return c.errorf(nameTok, "expected library name")
+		}
+		c.obj.Library = true
+		c.needACSE = "libraries"
+	case "nocompact", "wadauthor", "nowadauthor":
+	default:
+		return c.errorf(tok, "#%s is not supported", tok.Text)
+	}
+
+	return nil
+}
+
+// compile parses every top-level definition.
+func (c *acsCompiler) compile() error {
+	for {
+		tok, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case tok.Type == acsEOF:
+			return nil
+		case tok.Type == acsDirective:
+			err = c.directive(tok)
+		case tok.is(";"):
+		case tok.is("script"):
+			err = c.script(tok)
+		case tok.is("function"):
+			err = c.functionDef()
+		case tok.is("special"):
+			err = c.special()
+		case tok.is("world"):
+			err = c.scopedVariables(acsVarWorld)
+		case tok.is("global"):
+			err = c.scopedVariables(acsVarGlobal)
+		case tok.isType():
+			err = c.mapVariables()
+		default:
+			err = c.errorf(tok, "unexpected %q", tok.Text)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// CompileACS compiles ACS source into a BEHAVIOR lump.  The name is
+// used in error messages and passed to include for files included by
+// the source.  Errors are prefixed with the file and line.
+//
+// The language is that of ACC, except for local arrays, printing
+// character arrays with a:, strparam, #import and #encryptstrings,
+// which are reported as errors.  Builtins are always called through the
+// stack, so the code differs from ACC's where it would use the direct
+// form of a pcode.
+func CompileACS(name string, src []byte, include ACSIncludeFunc, format ACSFormat) ([]byte, error) {
+	c := &acsCompiler{
+		include:       include,
+		lexers:        []*acsLexer{{file: name, src: string(src), line: 1}},
+		included:      map[string]bool{name: true},
+		defines:       map[string]*acsExpr{},
+		specials:      map[string]acsSpecial{},
+		vars:          map[string]acsVar{},
+		functionNames: map[string]int{},
+		strings:       map[string]int{},
+	}
+
+	for name, special := range acsNamedSpecials {
+		c.specials[name] = special
+	}
+
+	// Room for the header
+	c.obj.Code = make([]byte, 8)
+
+	err := c.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, call := range c.calls {
+		function := c.functions[call.Function]
+		if !function.Defined {
+			return nil, c.errorf(call.Tok, "unknown function %s", call.Tok.Text)
+		} else if call.Args != function.Args {
+			return nil, c.errorf(call.Tok, "%s takes %d arguments", function.Name, function.Args)
+		} else if call.Value && !function.Returns {
+			return nil, c.errorf(call.Tok, "%s does not return a value", function.Name)
+		}
+	}
+
+	for _, function := range c.functions {
+		c.obj.Functions = append(c.obj.Functions, function.acsFunction)
+	}
+
+	// Map variables that start at zero need no MINI chunk
+	initialized := false
+	for _, value := range c.obj.MapInits {
+		initialized = initialized || value != 0
+	}
+	if !initialized {
+		c.obj.MapInits = nil
+	}
+
+	if format == ACSFormatAuto {
+		format = ACSFormatACS0
+		if c.needACSE != "" {
+			format = ACSFormatACSE
+		}
+	}
+
+	switch format {
+	case ACSFormatACS0:
+		if c.needACSE != "" {
+			return nil, fmt.Errorf("%s: ACS0 does not support %s", name, c.needACSE)
+		}
+		for _, script := range c.obj.Scripts {
+			if script.Number > 999 {
+				return nil, fmt.Errorf("%s: ACS0 does not support script number %d", name, script.Number)
+			}
+		}
+		return encodeACS0(&c.obj), nil
+	case ACSFormatACSE:
+		return encodeACSE(&c.obj), nil
+	}
+
+	return nil, errors.New("unknown ACS format")
+}
@@ -0,0 +1,327 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+// Returns the chunks of an ACSE lump by ID.  Chunks with the same ID
+// are concatenated.
+func acsTestChunks(data []byte) map[string][]byte {
+	chunks := map[string][]byte{}
+	for pos := int(binary.LittleEndian.Uint32(data[4:])); pos < len(data); {
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		chunks[string(data[pos:pos+4])] = append(chunks[string(data[pos:pos+4])], data[pos+8:pos+8+size]...)
+		pos += 8 + size
+	}
+
+	return chunks
+}
+
+// Returns the bytes of code made of pcodes and arguments.
+func acsTestWords(words ...int32) []byte {
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.LittleEndian, words)
+	return buffer.Bytes()
+}
+
+// A script compiles to ACS0 with a directory of scripts and strings
+func TestCompileACS0(t *testing.T) {
+	data, err := CompileACS("test.acs", []byte(`
+		#define WAIT 35
+		script 1 OPEN { delay(WAIT); }
+		script 2 (int tag) { print(s:"Tag ", d:tag); }`), nil, ACSFormatAuto)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(data[:4]) != "ACS\x00" {
+		t.Fatal("incorrect header")
+	}
+
+	words := make([]int32, len(data)/4)
+	binary.Read(bytes.NewReader(data[:len(words)*4]), binary.LittleEndian, words)
+
+	expected := []int32{
+		3, 35, 55, 1, // PUSHNUMBER 35, DELAY, TERMINATE
+		85, 3, 0, 87, 28, 0, 88, 86, 1, // BEGINPRINT ... ENDPRINT, TERMINATE
+	}
+	for i, word := range expected {
+		if words[2+i] != word {
+			t.Fatalf("code word %d: expected %d, got %d", i, word, words[2+i])
+		}
+	}
+
+	directory := binary.LittleEndian.Uint32(data[4:]) / 4
+	scripts := []int32{2, 1001, 8, 0, 2, 24, 1, 1}
+	for i, word := range scripts {
+		if words[int(directory)+i] != word {
+			t.Errorf("directory word %d: expected %d, got %d", i, word, words[int(directory)+i])
+		}
+	}
+
+	str := words[int(directory)+len(scripts)]
+	if string(data[str:str+5]) != "Tag \x00" {
+		t.Error("incorrect string")
+	}
+}
+
+// Functions compile to ACSE with the chunks that describe them
+func TestCompileACSE(t *testing.T) {
+	data, err := CompileACS("test.acs", []byte(`
+		int counter = 5;
+		script 1 (void) {
+			for (int i = 0; i < 3; i++) {
+				counter += double(i);
+			}
+		}
+		function int double(int value) { return value * 2; }`), nil, ACSFormatAuto)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(data[:4]) != "ACSE" {
+		t.Fatal("incorrect header")
+	}
+
+	chunks := acsTestChunks(data)
+	if !bytes.Equal(chunks["SPTR"], []byte{1, 0, 0, 0, 8, 0, 0, 0}) {
+		t.Errorf("incorrect SPTR %v", chunks["SPTR"])
+	}
+
+	if len(chunks["FUNC"]) != 8 || chunks["FUNC"][0] != 1 || chunks["FUNC"][1] != 1 || chunks["FUNC"][2] != 1 {
+		t.Errorf("incorrect FUNC %v", chunks["FUNC"])
+	}
+
+	if !bytes.Equal(chunks["FNAM"], []byte("\x01\x00\x00\x00\x08\x00\x00\x00double\x00")) {
+		t.Errorf("incorrect FNAM %q", chunks["FNAM"])
+	}
+
+	if !bytes.Equal(chunks["MINI"], []byte{0, 0, 0, 0, 5, 0, 0, 0}) {
+		t.Errorf("incorrect MINI %v", chunks["MINI"])
+	}
+
+	_, err = CompileACS("test.acs", []byte(`function void f(void) {}`), nil, ACSFormatACS0)
+	if err == nil || err.Error() != "test.acs: ACS0 does not support functions" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// Included files are found through the include function, and errors
+// name the file and line
+func TestCompileACSErrors(t *testing.T) {
+	include := func(name string, from string) (string, []byte, error) {
+		if name != "defs.acs" || from != "test.acs" {
+			return "", nil, errors.New("file not found")
+		}
+		return "acs/defs.acs", []byte("special 80:ACS_Execute(2, 5);\n#define MAP 1\n\nint broken = ;"), nil
+	}
+
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{`#include "defs.acs"`, `acs/defs.acs:4: expected expression, got ";"`},
+		{`#include "missing.acs"`, `test.acs:1: file not found`},
+		{"script 1 OPEN {\n\tfoo = 1;\n}", `test.acs:2: unknown variable foo`},
+		{"script 1 OPEN {\n\tbar(1);\n}", `test.acs:2: unknown function bar`},
+		{"script 1 OPEN {\n\tbreak;\n}", `test.acs:2: break outside of loop or switch`},
+		{"script 1 OPEN {\n\tint x = delay(1);\n}", `test.acs:2: delay does not return a value`},
+		{"script 1 OPEN { }\nscript 1 { }", `test.acs:2: script 1 is already defined`},
+		{"script \"a\" { }\nscript \"A\" { }", `test.acs:2: script "A" is already defined`},
+		{"script 1 OPEN {\n\tint a[2];\n}", `test.acs:2: local arrays are not supported`},
+		{"script 1 OPEN {\n\tprint(a:x);\n}", `test.acs:2: printing character arrays is not supported`},
+		{"int a[2];\nscript 1 OPEN {\n\ta[2] = 1;\n}", `test.acs:3: index 2 of a is out of range`},
+		{"int a[2];\nscript 1 OPEN {\n\ta = 1;\n}", `test.acs:3: a needs 1 subscripts`},
+		{"int a[2] = {1, 2, 3};", `test.acs:1: too many initializers for a`},
+		{"int a[];", `test.acs:1: size of a is unknown`},
+		{"script 1 OPEN {\n\thudmessage(s:\"x\"; 1, 2);\n}", `test.acs:2: hud messages take at least 6 arguments`},
+		{"#import \"zcommon.acs\"", `test.acs:1: #import is not supported`},
+	}
+
+	for _, test := range tests {
+		_, err := CompileACS("test.acs", []byte(test.src), include, ACSFormatAuto)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("expected %q, got %v", test.expected, err)
+		}
+	}
+}
+
+// Switch statements jump through a table after their body
+func TestCompileACSSwitch(t *testing.T) {
+	data, err := CompileACS("test.acs", []byte(`
+		special 80:ACS_Execute(2, 5);
+		script 1 (int n) {
+			switch (n) {
+			case 1:
+				ACS_Execute(2, 0);
+				break;
+			default:
+				terminate;
+			}
+		}`), nil, ACSFormatACS0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	words := make([]int32, (len(data)-8)/4)
+	binary.Read(bytes.NewReader(data[8:8+len(words)*4]), binary.LittleEndian, words)
+
+	expected := []int32{
+		28, 0, // PUSHSCRIPTVAR 0
+		52, 68, // GOTO dispatch
+		3, 2, 3, 0, 5, 80, // ACS_Execute(2, 0)
+		52, 92, // break
+		1,      // terminate
+		52, 92, // end of body
+		84, 1, 24, // CASEGOTO 1
+		54,     // DROP
+		52, 56, // GOTO default
+		1, // TERMINATE
+	}
+	for i, word := range expected {
+		if words[i] != word {
+			t.Fatalf("code word %d: expected %d, got %d", i, word, words[i])
+		}
+	}
+}
+
+// Features of ACC compile to the same pcodes as ACC
+func TestCompileACSFeatures(t *testing.T) {
+	callFunc := func(args byte, id byte) []byte {
+		return append(acsTestWords(351), args, id, 0)
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		code []byte
+	}{
+		{"map arrays", `int arr[10];
+			script 1 { int i = 2; arr[i] = 5; arr[1] += arr[i]; arr[0]++; }`,
+			acsTestWords(3, 2, 25, 0, 28, 0, 3, 5, 208, 0, 3, 1, 28, 0, 207, 0, 209, 0, 3, 0, 214, 0, 1)},
+		{"multidimensional arrays", `int grid[2][3] = {{1, 2, 3}, {4, 5, 6}};
+			script 1 { int i; delay(grid[1][2] + grid[i][1]); }`,
+			acsTestWords(3, 5, 207, 0, 28, 0, 3, 3, 16, 3, 1, 14, 207, 0, 14, 55, 1)},
+		{"world and global arrays", `world int 1:w[]; global int 2:g[];
+			script 1 { w[3] = g[4]; g[0]--; }`,
+			acsTestWords(3, 3, 3, 4, 235, 2, 227, 1, 3, 0, 243, 2, 1)},
+		{"hudmessage", `script 1 { hudmessage(s:"x"; 0, 1, 2, 3, 4, 5); hudmessagebold(d:1; 0, 1, 2, 3, 4, 5, 6); }`,
+			acsTestWords(85, 3, 0, 87, 159, 3, 0, 3, 1, 3, 2, 3, 3, 3, 4, 3, 5, 161,
+				85, 3, 1, 88, 159, 3, 0, 3, 1, 3, 2, 3, 3, 3, 4, 3, 5, 160, 3, 6, 162, 1)},
+		{"log", `script 1 { int s = 1; log(s:s); }`,
+			acsTestWords(3, 1, 25, 0, 85, 28, 0, 87, 270, 1)},
+		{"print types", `script 1 { print(f:1.0, n:0, l:"X", k:"+use", b:1, x:2); }`,
+			acsTestWords(85, 3, 65536, 157, 3, 0, 131, 3, 0, 158, 3, 1, 333, 3, 1, 349, 3, 2, 350, 86, 1)},
+		{"compound assignment", `script 1 { int x; x <<= 1; x >>= 2; x &= 3; x |= 4; x ^= 5; }`,
+			acsTestWords(3, 1, 312, 0, 3, 2, 319, 0, 3, 3, 291, 0, 3, 4, 305, 0, 3, 5, 298, 0, 1)},
+		{"bitwise not", `script 1 { int x = ~5; x = ~x; }`,
+			acsTestWords(3, -6, 25, 0, 28, 0, 330, 25, 0, 1)},
+		{"named scripts", `script "Intro" OPEN { ACS_NamedExecute("Intro", 0); }`,
+			join(acsTestWords(3, 0, 3, 0), callFunc(2, 39), acsTestWords(54, 1))},
+		{"net scripts", `script 2 net (void) { delay(1); }`,
+			acsTestWords(3, 1, 55, 1)},
+		{"builtins", `script 1 { SetFont("BIGFONT"); SetMusic("D_RUNNIN"); }`,
+			acsTestWords(3, 0, 165, 3, 1, 3, 0, 3, 0, 153, 1)},
+		{"extension functions", `special -44:GetActorX2(1);
+			script 1 { int x = GetActorX2(0); }`,
+			join(acsTestWords(3, 0), callFunc(1, 44), acsTestWords(25, 0, 1))},
+		{"specials with results", `special 80:ACS_Execute(1, 5);
+			script 1 { int r = ACS_Execute(2); }`,
+			acsTestWords(3, 2, 3, 0, 3, 0, 3, 0, 3, 0, 263, 80, 25, 0, 1)},
+	}
+
+	for _, test := range tests {
+		data, err := CompileACS("test.acs", []byte(test.src), nil, ACSFormatAuto)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err.Error())
+			continue
+		}
+
+		if len(data) < 8+len(test.code) || !bytes.Equal(data[8:8+len(test.code)], test.code) {
+			t.Errorf("%s: incorrect code %v", test.name, data[8:])
+		}
+
+		// The code can be disassembled
+		behavior, err := DecodeBehavior(data)
+		if err == nil {
+			err = behavior.Disassemble(ioutil.Discard)
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err.Error())
+		}
+	}
+}
+
+// Arrays, named scripts and script flags are stored in chunks
+func TestCompileACSChunks(t *testing.T) {
+	data, err := CompileACS("test.acs", []byte(`
+		int before;
+		int grid[2][2] = {{1}, {0, 4}};
+		int empty[3];
+		script "Intro" OPEN net { }
+		script 3 { }`), nil, ACSFormatAuto)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	chunks := acsTestChunks(data)
+	if !bytes.Equal(chunks["ARAY"], acsTestWords(1, 4, 2, 3)) {
+		t.Errorf("incorrect ARAY %v", chunks["ARAY"])
+	}
+
+	if !bytes.Equal(chunks["AINI"], acsTestWords(1, 1, 0, 0, 4)) {
+		t.Errorf("incorrect AINI %v", chunks["AINI"])
+	}
+
+	if !bytes.Equal(chunks["SPTR"], []byte{0xff, 0xff, 1, 0, 8, 0, 0, 0, 3, 0, 0, 0, 12, 0, 0, 0}) {
+		t.Errorf("incorrect SPTR %v", chunks["SPTR"])
+	}
+
+	if !bytes.Equal(chunks["SNAM"], []byte("\x01\x00\x00\x00\x08\x00\x00\x00Intro\x00")) {
+		t.Errorf("incorrect SNAM %q", chunks["SNAM"])
+	}
+
+	if !bytes.Equal(chunks["SFLG"], []byte{0xff, 0xff, 1, 0}) {
+		t.Errorf("incorrect SFLG %v", chunks["SFLG"])
+	}
+
+	behavior, err := DecodeBehavior(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if behavior.Scripts[0].Name != "Intro" || len(behavior.MapVars) != 2 ||
+		behavior.MapVars[0].ArraySize != 4 || behavior.MapVars[1].ArraySize != 3 {
+		t.Errorf("chunks do not decode %v", behavior.MapVars)
+	}
+
+	_, err = CompileACS("test.acs", []byte(`script "Intro" { }`), nil, ACSFormatACS0)
+	if err == nil || err.Error() != "test.acs: ACS0 does not support named scripts" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,604 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// ACSScriptInfo describes a script of a BEHAVIOR lump.
+type ACSScriptInfo struct {
+	Number  int
+	Name    string
+	Type    int
+	Flags   int
+	Args    int
+	Locals  int
+	Address int
+}
+
+// TypeName returns the keyword of the script type, such as "open".
+func (script *ACSScriptInfo) TypeName() string {
+	if name, ok := acsScriptTypeNames[script.Type]; ok {
+		return name
+	}
+	return strconv.Itoa(script.Type)
+}
+
+// ACSFunctionInfo describes a function of a BEHAVIOR lump.  Imported
+// functions are defined by a library and have no code.
+type ACSFunctionInfo struct {
+	Name     string
+	Args     int
+	Locals   int
+	Returns  bool
+	Address  int
+	Imported bool
+}
+
+// ACSMapVariable describes a map variable or array of a BEHAVIOR lump.
+// Names are only known for exported and imported variables.
+type ACSMapVariable struct {
+	Index     int
+	Name      string
+	Value     int32
+	ArraySize int
+	Imported  bool
+}
+
+// Behavior is the decoded contents of a BEHAVIOR lump or ACS library.
+type Behavior struct {
+	Format    ACSFormat
+	Scripts   []ACSScriptInfo
+	Functions []ACSFunctionInfo
+	Strings   []string
+	MapVars   []ACSMapVariable
+	Libraries []string
+
+	data    []byte
+	codeEnd int
+}
+
+// acsCString returns the null-terminated string at an offset of data.
+func acsCString(data []byte, offset int) (string, error) {
+	if offset < 0 || offset >= len(data) {
+		return "", errors.New("string offset out of range")
+	}
+
+	end := bytes.IndexByte(data[offset:], 0)
+	if end == -1 {
+		return "", errors.New("string is not terminated")
+	}
+	return string(data[offset : offset+end]), nil
+}
+
+// DecodeBehavior decodes a BEHAVIOR lump in the ACS0, ACSE or ACSe
+// format, including ACSE lumps wrapped in an ACS0 header for older
+// ports.
+func DecodeBehavior(data []byte) (*Behavior, error) {
+	if len(data) < 8 || string(data[:3]) != "ACS" {
+		return nil, errors.New("not an ACS object")
+	}
+
+	behavior := &Behavior{data: data}
+	offset := int(binary.LittleEndian.Uint32(data[4:]))
+	if offset < 8 || offset > len(data) {
+		return nil, errors.New("directory offset out of range")
+	}
+
+	switch data[3] {
+	case 0:
+		behavior.Format = ACSFormatACS0
+
+		// ACSE lumps for older ports hide their tag before the ACS0
+		// directory
+		if offset >= 24 {
+			switch string(data[offset-4 : offset]) {
+			case "ACSE":
+				behavior.Format = ACSFormatACSE
+			case "ACSe":
+				behavior.Format = ACSFormatACSe
+			}
+		}
+
+		if behavior.Format == ACSFormatACS0 {
+			return behavior, behavior.decodeACS0(offset)
+		}
+
+		offset = int(binary.LittleEndian.Uint32(data[offset-8:]))
+		if offset < 8 || offset > len(data) {
+			return nil, errors.New("chunk offset out of range")
+		}
+	case 'E':
+		behavior.Format = ACSFormatACSE
+	case 'e':
+		behavior.Format = ACSFormatACSe
+	default:
+		return nil, fmt.Errorf("unknown ACS format %q", data[:4])
+	}
+
+	return behavior, behavior.decodeChunks(offset)
+}
+
+// decodeACS0 decodes the directory of an ACS0 lump.
+func (behavior *Behavior) decodeACS0(offset int) error {
+	data := behavior.data
+	behavior.codeEnd = offset
+
+	if offset+4 > len(data) {
+		return errors.New("directory is truncated")
+	}
+	count := int(int32(binary.LittleEndian.Uint32(data[offset:])))
+	offset += 4
+	if count < 0 || offset+count*12+4 > len(data) {
+		return errors.New("script count out of range")
+	}
+
+	for i := 0; i < count; i++ {
+		number := int(int32(binary.LittleEndian.Uint32(data[offset:])))
+		behavior.Scripts = append(behavior.Scripts, ACSScriptInfo{
+			Number:  number % 1000,
+			Type:    number / 1000,
+			Args:    int(int32(binary.LittleEndian.Uint32(data[offset+8:]))),
+			Locals:  20,
+			Address: int(binary.LittleEndian.Uint32(data[offset+4:])),
+		})
+		offset += 12
+	}
+
+	count = int(int32(binary.LittleEndian.Uint32(data[offset:])))
+	offset += 4
+	if count < 0 || offset+count*4 > len(data) {
+		return errors.New("string count out of range")
+	}
+
+	for i := 0; i < count; i++ {
+		stringOffset := int(binary.LittleEndian.Uint32(data[offset+i*4:]))
+		str, err := acsCString(data, stringOffset)
+		if err != nil {
+			return err
+		}
+		behavior.Strings = append(behavior.Strings, str)
+
+		// Strings are stored between the code and the directory
+		if stringOffset >= 8 && stringOffset < behavior.codeEnd {
+			behavior.codeEnd = stringOffset
+		}
+	}
+
+	return nil
+}
+
+// decodeChunks decodes the chunks of an ACSE or ACSe lump.
+func (behavior *Behavior) decodeChunks(offset int) error {
+	data := behavior.data
+	behavior.codeEnd = offset
+
+	chunks := map[string][][]byte{}
+	for offset < len(data) {
+		if offset+8 > len(data) {
+			return errors.New("chunk header is truncated")
+		}
+
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		if size < 0 || offset+8+size > len(data) {
+			return fmt.Errorf("chunk %s is truncated", id)
+		}
+
+		chunks[id] = append(chunks[id], data[offset+8:offset+8+size])
+		offset += 8 + size
+	}
+
+	for _, chunk := range chunks["SPTR"] {
+		for i := 0; i+8 <= len(chunk); i += 8 {
+			behavior.Scripts = append(behavior.Scripts, ACSScriptInfo{
+				Number:  int(int16(binary.LittleEndian.Uint16(chunk[i:]))),
+				Type:    int(chunk[i+2]),
+				Args:    int(chunk[i+3]),
+				Locals:  20,
+				Address: int(binary.LittleEndian.Uint32(chunk[i+4:])),
+			})
+		}
+	}
+
+	// Finds a script by number for chunks that modify scripts.
+	script := func(number int) *ACSScriptInfo {
+		for i := range behavior.Scripts {
+			if behavior.Scripts[i].Number == number {
+				return &behavior.Scripts[i]
+			}
+		}
+		return &ACSScriptInfo{}
+	}
+
+	for _, chunk := range chunks["SFLG"] {
+		for i := 0; i+4 <= len(chunk); i += 4 {
+			script(int(int16(binary.LittleEndian.Uint16(chunk[i:])))).Flags = int(binary.LittleEndian.Uint16(chunk[i+2:]))
+		}
+	}
+
+	for _, chunk := range chunks["SVCT"] {
+		for i := 0; i+4 <= len(chunk); i += 4 {
+			script(int(int16(binary.LittleEndian.Uint16(chunk[i:])))).Locals = int(binary.LittleEndian.Uint16(chunk[i+2:]))
+		}
+	}
+
+	// Named scripts have negative numbers
+	for _, chunk := range chunks["SNAM"] {
+		if len(chunk) < 4 {
+			return errors.New("SNAM is truncated")
+		}
+		names, err := acsStringsFrom(chunk, 4, int(int32(binary.LittleEndian.Uint32(chunk))))
+		if err != nil {
+			return fmt.Errorf("SNAM: %s", err.Error())
+		}
+		for i := range behavior.Scripts {
+			if index := -1 - behavior.Scripts[i].Number; index >= 0 && index < len(names) {
+				behavior.Scripts[i].Name = names[index]
+			}
+		}
+	}
+
+	for _, chunk := range chunks["FUNC"] {
+		for i := 0; i+8 <= len(chunk); i += 8 {
+			behavior.Functions = append(behavior.Functions, ACSFunctionInfo{
+				Args:     int(chunk[i]),
+				Locals:   int(chunk[i+1]),
+				Returns:  chunk[i+2] != 0,
+				Imported: chunk[i+3] != 0,
+				Address:  int(binary.LittleEndian.Uint32(chunk[i+4:])),
+			})
+		}
+	}
+
+	for _, chunk := range chunks["FNAM"] {
+		if len(chunk) < 4 {
+			return errors.New("FNAM is truncated")
+		}
+		names, err := acsStringsFrom(chunk, 4, int(int32(binary.LittleEndian.Uint32(chunk))))
+		if err != nil {
+			return fmt.Errorf("FNAM: %s", err.Error())
+		}
+		for i := 0; i < len(names) && i < len(behavior.Functions); i++ {
+			behavior.Functions[i].Name = names[i]
+		}
+	}
+
+	for _, id := range []string{"STRL", "STRE"} {
+		for _, chunk := range chunks[id] {
+			if len(chunk) < 12 {
+				return fmt.Errorf("%s is truncated", id)
+			}
+
+			// Encrypted strings are XORed with a key based on their
+			// offset, so decrypt a copy first
+			if id == "STRE" {
+				chunk = append([]byte{}, chunk...)
+				count := int(int32(binary.LittleEndian.Uint32(chunk[4:])))
+				for i := 0; i < count && 12+i*4+4 <= len(chunk); i++ {
+					offset := int(binary.LittleEndian.Uint32(chunk[12+i*4:]))
+					for j := 0; offset+j < len(chunk); j++ {
+						chunk[offset+j] ^= byte(offset*157135 + j/2)
+						if chunk[offset+j] == 0 {
+							break
+						}
+					}
+				}
+			}
+
+			strings, err := acsStringsFrom(chunk, 12, int(int32(binary.LittleEndian.Uint32(chunk[4:]))))
+			if err != nil {
+				return fmt.Errorf("%s: %s", id, err.Error())
+			}
+			behavior.Strings = append(behavior.Strings, strings...)
+		}
+	}
+
+	vars := map[int]*ACSMapVariable{}
+	mapVar := func(index int) *ACSMapVariable {
+		if v, ok := vars[index]; ok {
+			return v
+		}
+		vars[index] = &ACSMapVariable{Index: index}
+		return vars[index]
+	}
+
+	for _, chunk := range chunks["MINI"] {
+		if len(chunk) < 4 {
+			return errors.New("MINI is truncated")
+		}
+		first := int(int32(binary.LittleEndian.Uint32(chunk)))
+		for i := 4; i+4 <= len(chunk); i += 4 {
+			mapVar(first + i/4 - 1).Value = int32(binary.LittleEndian.Uint32(chunk[i:]))
+		}
+	}
+
+	for _, chunk := range chunks["ARAY"] {
+		for i := 0; i+8 <= len(chunk); i += 8 {
+			mapVar(int(int32(binary.LittleEndian.Uint32(chunk[i:])))).ArraySize = int(int32(binary.LittleEndian.Uint32(chunk[i+4:])))
+		}
+	}
+
+	for _, chunk := range chunks["MEXP"] {
+		if len(chunk) < 4 {
+			return errors.New("MEXP is truncated")
+		}
+		names, err := acsStringsFrom(chunk, 4, int(int32(binary.LittleEndian.Uint32(chunk))))
+		if err != nil {
+			return fmt.Errorf("MEXP: %s", err.Error())
+		}
+		for i, name := range names {
+			mapVar(i).Name = name
+		}
+	}
+
+	for _, chunk := range chunks["MIMP"] {
+		for i := 0; i+4 < len(chunk); {
+			v := mapVar(int(int32(binary.LittleEndian.Uint32(chunk[i:]))))
+			name, err := acsCString(chunk, i+4)
+			if err != nil {
+				return fmt.Errorf("MIMP: %s", err.Error())
+			}
+			v.Name, v.Imported = name, true
+			i += 4 + len(name) + 1
+		}
+	}
+
+	indexes := []int{}
+	for index := range vars {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		behavior.MapVars = append(behavior.MapVars, *vars[index])
+	}
+
+	for _, chunk := range chunks["LOAD"] {
+		for _, name := range bytes.Split(chunk, []byte{0}) {
+			if len(name) > 0 {
+				behavior.Libraries = append(behavior.Libraries, string(name))
+			}
+		}
+	}
+
+	return nil
+}
+
+// acsStringsFrom reads count string offsets starting at start, each
+// relative to the start of data.
+func acsStringsFrom(data []byte, start int, count int) ([]string, error) {
+	if count < 0 || len(data) < start+count*4 {
+		return nil, errors.New("string count out of range")
+	}
+
+	strings := make([]string, count)
+	for i := range strings {
+		var err error
+		strings[i], err = acsCString(data, int(binary.LittleEndian.Uint32(data[start+i*4:])))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return strings, nil
+}
+
+// instruction decodes the instruction at an offset, returning its text
+// and the offset of the next instruction.
+func (behavior *Behavior) instruction(offset int) (string, int, error) {
+	data := behavior.data[:behavior.codeEnd]
+	compact := behavior.Format == ACSFormatACSe
+
+	// Reads an argument of the passed size.
+	read := func(size int) (int32, error) {
+		if offset+size > len(data) {
+			return 0, errors.New("instruction is truncated")
+		}
+
+		var value int32
+		if size == 1 {
+			value = int32(data[offset])
+		} else if size == 2 {
+			value = int32(binary.LittleEndian.Uint16(data[offset:]))
+		} else {
+			value = int32(binary.LittleEndian.Uint32(data[offset:]))
+		}
+		offset += size
+		return value, nil
+	}
+
+	var pcode int
+	if compact {
+		first, err := read(1)
+		if err != nil {
+			return "", 0, err
+		}
+		pcode = int(first)
+		if pcode >= 240 {
+			second, err := read(1)
+			if err != nil {
+				return "", 0, err
+			}
+			pcode = 240 + (pcode-240)*256 + int(second)
+		}
+	} else {
+		value, err := read(4)
+		if err != nil {
+			return "", 0, err
+		}
+		pcode = int(value)
+	}
+
+	info, ok := acsPCodes[pcode]
+	if !ok {
+		return "", 0, fmt.Errorf("unknown pcode %d", pcode)
+	}
+
+	args := info.Args
+	text := info.Name
+	first := int32(-1)
+	for i := 0; i < args; i++ {
+		size := 4
+		if acsBytePCodes[pcode] || (compact && acsCompactByteArg(pcode, i)) {
+			size = 1
+		} else if pcode == pcdCallFunc {
+			size = 1 + i
+		}
+
+		value, err := read(size)
+		if err != nil {
+			return "", 0, err
+		}
+		text += " " + strconv.Itoa(int(value))
+		if i == 0 {
+			first = value
+		}
+
+		// PUSHBYTES is followed by as many bytes as its argument
+		if pcode == 175 && i == 0 {
+			args += int(value)
+		}
+	}
+
+	if (pcode == pcdCall || pcode == pcdCallDiscard) && first >= 0 && int(first) < len(behavior.Functions) {
+		text += " ; " + behavior.Functions[first].Name
+	}
+
+	return text, offset, nil
+}
+
+// Disassemble writes a listing of every script, function, string, map
+// variable and library of the lump.  The code of a script or function
+// ends at the start of the next one.  Disassembly of a script stops at
+// the first unknown pcode.
+func (behavior *Behavior) Disassemble(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	type routine struct {
+		title   string
+		address int
+	}
+
+	routines := []routine{}
+	for i := range behavior.Scripts {
+		script := &behavior.Scripts[i]
+		title := fmt.Sprintf("script %d", script.Number)
+		if script.Name != "" {
+			title = fmt.Sprintf("script %q", script.Name)
+		}
+		title += fmt.Sprintf(" %s, %d args", script.TypeName(), script.Args)
+		if script.Flags&acsFlagNet != 0 {
+			title += ", net"
+		}
+		if script.Flags&acsFlagClientside != 0 {
+			title += ", clientside"
+		}
+		routines = append(routines, routine{title, script.Address})
+	}
+	for _, function := range behavior.Functions {
+		if function.Imported {
+			continue
+		}
+
+		kind := "void"
+		if function.Returns {
+			kind = "int"
+		}
+		title := fmt.Sprintf("function %s %s, %d args, %d locals", kind, function.Name, function.Args, function.Locals)
+		routines = append(routines, routine{title, function.Address})
+	}
+
+	sort.SliceStable(routines, func(a, b int) bool {
+		return routines[a].address < routines[b].address
+	})
+
+	for i, r := range routines {
+		end := behavior.codeEnd
+		for _, next := range routines[i+1:] {
+			if next.address > r.address {
+				end = next.address
+				break
+			}
+		}
+		if end > behavior.codeEnd {
+			end = behavior.codeEnd
+		}
+
+		fmt.Fprintf(bw, "%s\n", r.title)
+		for offset := r.address; offset < end; {
+			text, next, err := behavior.instruction(offset)
+			if err != nil {
+				fmt.Fprintf(bw, "%8d: ; %s\n", offset, err.Error())
+				break
+			}
+			fmt.Fprintf(bw, "%8d: %s\n", offset, text)
+			offset = next
+		}
+		fmt.Fprint(bw, "\n")
+	}
+
+	for _, function := range behavior.Functions {
+		if function.Imported {
+			fmt.Fprintf(bw, "imported function %s\n", function.Name)
+		}
+	}
+
+	if len(behavior.Strings) > 0 {
+		fmt.Fprint(bw, "strings\n")
+		for i, str := range behavior.Strings {
+			fmt.Fprintf(bw, "%8d: %q\n", i, str)
+		}
+		fmt.Fprint(bw, "\n")
+	}
+
+	if len(behavior.MapVars) > 0 {
+		fmt.Fprint(bw, "map variables\n")
+		for _, v := range behavior.MapVars {
+			fmt.Fprintf(bw, "%8d:", v.Index)
+			if v.Imported {
+				fmt.Fprint(bw, " imported")
+			}
+			if v.Name != "" {
+				fmt.Fprintf(bw, " %s", v.Name)
+			}
+			if v.ArraySize > 0 {
+				fmt.Fprintf(bw, "[%d]", v.ArraySize)
+			} else if !v.Imported {
+				fmt.Fprintf(bw, " = %d", v.Value)
+			}
+			fmt.Fprint(bw, "\n")
+		}
+		fmt.Fprint(bw, "\n")
+	}
+
+	for _, library := range behavior.Libraries {
+		fmt.Fprintf(bw, "library %s\n", library)
+	}
+
+	return bw.Flush()
+}
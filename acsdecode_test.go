@@ -0,0 +1,194 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// Compiled ACS0 lumps decode to their scripts and strings
+func TestDecodeBehaviorACS0(t *testing.T) {
+	data, err := CompileACS("test.acs", []byte(`
+		script 1 OPEN { delay(35); }
+		script 2 (int tag) { print(s:"Tag ", d:tag); }`), nil, ACSFormatACS0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	behavior, err := DecodeBehavior(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if behavior.Format != ACSFormatACS0 {
+		t.Errorf("incorrect format %d", behavior.Format)
+	}
+
+	if len(behavior.Scripts) != 2 {
+		t.Fatalf("expected 2 scripts, got %d", len(behavior.Scripts))
+	}
+	open := behavior.Scripts[0]
+	if open.Number != 1 || open.TypeName() != "open" || open.Address != 8 {
+		t.Errorf("incorrect script %+v", open)
+	}
+	if behavior.Scripts[1].Args != 1 || behavior.Scripts[1].TypeName() != "closed" {
+		t.Errorf("incorrect script %+v", behavior.Scripts[1])
+	}
+
+	if len(behavior.Strings) != 1 || behavior.Strings[0] != "Tag " {
+		t.Errorf("incorrect strings %q", behavior.Strings)
+	}
+
+	var buffer bytes.Buffer
+	err = behavior.Disassemble(&buffer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := "script 1 open, 0 args\n" +
+		"       8: PUSHNUMBER 35\n" +
+		"      16: DELAY\n" +
+		"      20: TERMINATE\n"
+	if !strings.HasPrefix(buffer.String(), expected) {
+		t.Errorf("incorrect disassembly\n%s", buffer.String())
+	}
+	if !strings.Contains(buffer.String(), "       0: \"Tag \"\n") {
+		t.Errorf("missing string table\n%s", buffer.String())
+	}
+}
+
+// Compiled ACSE lumps decode to their functions and map variables
+func TestDecodeBehaviorACSE(t *testing.T) {
+	data, err := CompileACS("test.acs", []byte(`
+		int counter = 5;
+		script 1 (void) { counter += double(counter); }
+		function int double(int value) { return value * 2; }`), nil, ACSFormatACSE)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	behavior, err := DecodeBehavior(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if behavior.Format != ACSFormatACSE {
+		t.Errorf("incorrect format %d", behavior.Format)
+	}
+
+	if len(behavior.Functions) != 1 {
+		t.Fatalf("expected 1 function, got %d", len(behavior.Functions))
+	}
+	function := behavior.Functions[0]
+	if function.Name != "double" || function.Args != 1 || !function.Returns || function.Imported {
+		t.Errorf("incorrect function %+v", function)
+	}
+
+	if len(behavior.MapVars) != 1 || behavior.MapVars[0].Value != 5 {
+		t.Errorf("incorrect map variables %+v", behavior.MapVars)
+	}
+
+	var buffer bytes.Buffer
+	err = behavior.Disassemble(&buffer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.Contains(buffer.String(), "CALL 0 ; double\n") {
+		t.Errorf("missing function call\n%s", buffer.String())
+	}
+	if !strings.Contains(buffer.String(), "function int double, 1 args, 1 locals\n") {
+		t.Errorf("missing function\n%s", buffer.String())
+	}
+}
+
+// Compact lumps have byte-sized pcodes, and encrypted string tables are
+// decrypted
+func TestDecodeBehaviorACSe(t *testing.T) {
+	code := []byte{
+		167, 35, // PUSHBYTE 35
+		55, // DELAY
+		1,  // TERMINATE
+	}
+
+	var chunks bytes.Buffer
+	chunk := func(id string, data []byte) {
+		chunks.WriteString(id)
+		binary.Write(&chunks, binary.LittleEndian, uint32(len(data)))
+		chunks.Write(data)
+	}
+	chunk("SPTR", []byte{0xFF, 0xFF, 1, 0, 8, 0, 0, 0})
+	chunk("SNAM", []byte("\x01\x00\x00\x00\x08\x00\x00\x00Intro\x00"))
+	chunk("LOAD", []byte("common\x00"))
+
+	str := []byte("Hi\x00")
+	for j := range str {
+		str[j] ^= byte(16*157135 + j/2)
+	}
+	chunk("STRE", append([]byte{0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 16, 0, 0, 0}, str...))
+
+	data := []byte("ACSe")
+	data = append(data, byte(8+len(code)), 0, 0, 0)
+	data = append(data, code...)
+	data = append(data, chunks.Bytes()...)
+
+	behavior, err := DecodeBehavior(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if behavior.Format != ACSFormatACSe {
+		t.Errorf("incorrect format %d", behavior.Format)
+	}
+
+	if len(behavior.Scripts) != 1 || behavior.Scripts[0].Name != "Intro" || behavior.Scripts[0].TypeName() != "open" {
+		t.Errorf("incorrect scripts %+v", behavior.Scripts)
+	}
+
+	if len(behavior.Strings) != 1 || behavior.Strings[0] != "Hi" {
+		t.Errorf("incorrect strings %q", behavior.Strings)
+	}
+
+	if len(behavior.Libraries) != 1 || behavior.Libraries[0] != "common" {
+		t.Errorf("incorrect libraries %q", behavior.Libraries)
+	}
+
+	var buffer bytes.Buffer
+	err = behavior.Disassemble(&buffer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := "script \"Intro\" open, 0 args\n" +
+		"       8: PUSHBYTE 35\n" +
+		"      10: DELAY\n" +
+		"      11: TERMINATE\n"
+	if !strings.HasPrefix(buffer.String(), expected) {
+		t.Errorf("incorrect disassembly\n%s", buffer.String())
+	}
+
+	_, err = DecodeBehavior([]byte("PWAD\x00\x00\x00\x00"))
+	if err == nil || err.Error() != "not an ACS object" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
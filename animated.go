@@ -0,0 +1,276 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Animation is a single flat or texture animation from a Boom ANIMATED
+// lump.  The animation cycles through every flat or texture between the
+// first and last names in directory order.
+type Animation struct {
+	Texture bool
+	Decals  bool
+	First   string
+	Last    string
+	Speed   int32
+}
+
+// Switch is a single switch texture pair from a Boom SWITCHES lump.
+type Switch struct {
+	Off     string
+	On      string
+	Episode int16
+}
+
+const (
+	animatedSize = 23
+	switchesSize = 20
+)
+
+// DecodeAnimated decodes ANIMATED lump data into a list of animations.
+func DecodeAnimated(data []byte) ([]Animation, error) {
+	animations := []Animation{}
+
+	for offset := 0; ; offset += animatedSize {
+		if offset >= len(data) {
+			return nil, errors.New("ANIMATED is missing terminator")
+		}
+
+		// Terminator is a type of 255, and the rest of the record can
+		// be missing.
+		kind := data[offset]
+		if kind == 0xFF {
+			break
+		} else if offset+animatedSize > len(data) {
+			return nil, errors.New("ANIMATED record is truncated")
+		}
+
+		animations = append(animations, Animation{
+			Texture: kind&1 != 0,
+			Decals:  kind&2 != 0,
+			Last:    strings.ToUpper(nullTerminated(data[offset+1 : offset+10])),
+			First:   strings.ToUpper(nullTerminated(data[offset+10 : offset+19])),
+			Speed:   int32(binary.LittleEndian.Uint32(data[offset+19:])),
+		})
+	}
+
+	return animations, nil
+}
+
+// EncodeAnimated encodes a list of animations into ANIMATED lump data.
+func EncodeAnimated(animations []Animation) ([]byte, error) {
+	data := make([]byte, (len(animations)+1)*animatedSize)
+
+	for i, animation := range animations {
+		record := data[i*animatedSize:]
+
+		if animation.Texture {
+			record[0] = 1
+		}
+		if animation.Decals {
+			if !animation.Texture {
+				return nil, fmt.Errorf("flat %s cannot allow decals", animation.First)
+			}
+			record[0] |= 2
+		}
+
+		// Names are nine bytes but must stay null-terminated.
+		if err := putName(record[1:9], strings.ToUpper(animation.Last)); err != nil {
+			return nil, err
+		}
+		if err := putName(record[10:18], strings.ToUpper(animation.First)); err != nil {
+			return nil, err
+		}
+		binary.LittleEndian.PutUint32(record[19:], uint32(animation.Speed))
+	}
+
+	data[len(animations)*animatedSize] = 0xFF
+	return data, nil
+}
+
+// DecodeSwitches decodes SWITCHES lump data into a list of switches.
+func DecodeSwitches(data []byte) ([]Switch, error) {
+	switches := []Switch{}
+
+	for offset := 0; ; offset += switchesSize {
+		if offset+switchesSize > len(data) {
+			return nil, errors.New("SWITCHES is missing terminator")
+		}
+
+		// Terminator is an episode of zero.
+		episode := int16(binary.LittleEndian.Uint16(data[offset+18:]))
+		if episode == 0 {
+			break
+		}
+
+		switches = append(switches, Switch{
+			Off:     strings.ToUpper(nullTerminated(data[offset : offset+9])),
+			On:      strings.ToUpper(nullTerminated(data[offset+9 : offset+18])),
+			Episode: episode,
+		})
+	}
+
+	return switches, nil
+}
+
+// EncodeSwitches encodes a list of switches into SWITCHES lump data.
+func EncodeSwitches(switches []Switch) ([]byte, error) {
+	data := make([]byte, (len(switches)+1)*switchesSize)
+
+	for i, sw := range switches {
+		record := data[i*switchesSize:]
+
+		if sw.Episode <= 0 {
+			return nil, fmt.Errorf("switch %s has invalid episode %d", sw.Off, sw.Episode)
+		}
+
+		// Names are nine bytes but must stay null-terminated.
+		if err := putName(record[0:8], strings.ToUpper(sw.Off)); err != nil {
+			return nil, err
+		}
+		if err := putName(record[9:17], strings.ToUpper(sw.On)); err != nil {
+			return nil, err
+		}
+		binary.LittleEndian.PutUint16(record[18:], uint16(sw.Episode))
+	}
+
+	return data, nil
+}
+
+// ParseSwanTables parses animation and switch definitions written in
+// the syntax of SWANTBLS' DEFSWANI.DAT.  Definitions are grouped into
+// [FLATS], [TEXTURES] and [SWITCHES] sections.  Animations are written
+// as speed, last and first name, and switches as episode, off and on
+// name.  Lines starting with a hash or semicolon are comments.
+func ParseSwanTables(r io.Reader) ([]Animation, []Switch, error) {
+	animations := []Animation{}
+	switches := []Switch{}
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+
+		if strings.HasPrefix(fields[0], "[") {
+			section = strings.ToUpper(strings.Trim(fields[0], "[]"))
+			if section != "FLATS" && section != "TEXTURES" && section != "SWITCHES" {
+				return nil, nil, fmt.Errorf("line %d: unknown section %q", line, fields[0])
+			}
+			continue
+		}
+
+		if len(fields) < 3 {
+			return nil, nil, fmt.Errorf("line %d: definition needs a number and two names", line)
+		}
+
+		number, err := strconv.ParseInt(fields[0], 10, 32)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: invalid number %q", line, fields[0])
+		}
+
+		switch section {
+		case "FLATS", "TEXTURES":
+			animations = append(animations, Animation{
+				Texture: section == "TEXTURES",
+				Last:    strings.ToUpper(fields[1]),
+				First:   strings.ToUpper(fields[2]),
+				Speed:   int32(number),
+			})
+		case "SWITCHES":
+			if number <= 0 || number > 32767 {
+				return nil, nil, fmt.Errorf("line %d: invalid episode %d", line, number)
+			}
+			switches = append(switches, Switch{
+				Off:     strings.ToUpper(fields[1]),
+				On:      strings.ToUpper(fields[2]),
+				Episode: int16(number),
+			})
+		default:
+			return nil, nil, fmt.Errorf("line %d: definition outside of a section", line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return animations, switches, nil
+}
+
+// WriteSwanTables writes animation and switch definitions in the syntax
+// of SWANTBLS' DEFSWANI.DAT.  The decals flag cannot be represented in
+// this syntax and is dropped.
+func WriteSwanTables(w io.Writer, animations []Animation, switches []Switch) error {
+	sections := []struct {
+		name    string
+		texture bool
+	}{
+		{"FLATS", false},
+		{"TEXTURES", true},
+	}
+
+	for _, section := range sections {
+		if _, err := fmt.Fprintf(w, "[%s]\n", section.name); err != nil {
+			return err
+		}
+
+		for _, animation := range animations {
+			if animation.Texture != section.texture {
+				continue
+			}
+
+			_, err := fmt.Fprintf(w, "%-5d %-8s %s\n", animation.Speed, animation.Last, animation.First)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprint(w, "\n"); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprint(w, "[SWITCHES]\n"); err != nil {
+		return err
+	}
+
+	for _, sw := range switches {
+		_, err := fmt.Fprintf(w, "%-5d %-8s %s\n", sw.Episode, sw.Off, sw.On)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSwanTables = `# Test definitions
+[FLATS]
+8     NUKAGE3  NUKAGE1
+
+[TEXTURES]
+8     BLODGR4  BLODGR1
+
+[SWITCHES]
+1     SW1BRCOM SW2BRCOM
+3     SW1SKULL SW2SKULL
+`
+
+var testAnimations = []Animation{
+	{Texture: false, Last: "NUKAGE3", First: "NUKAGE1", Speed: 8},
+	{Texture: true, Last: "BLODGR4", First: "BLODGR1", Speed: 8},
+}
+
+var testSwitches = []Switch{
+	{Off: "SW1BRCOM", On: "SW2BRCOM", Episode: 1},
+	{Off: "SW1SKULL", On: "SW2SKULL", Episode: 3},
+}
+
+func TestAnimated(t *testing.T) {
+	data, err := EncodeAnimated(testAnimations)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(data) != 3*23 || data[23] != 1 || data[46] != 0xFF {
+		t.Fatalf("incorrect ANIMATED data %v", data)
+	}
+
+	if string(data[24:31]) != "BLODGR4" || data[32] != 0 {
+		t.Error("incorrect last texture name")
+	}
+
+	decoded, err := DecodeAnimated(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(decoded, testAnimations) {
+		t.Errorf("incorrect decoded animations %v", decoded)
+	}
+}
+
+func TestSwitches(t *testing.T) {
+	data, err := EncodeSwitches(testSwitches)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(data) != 3*20 || data[38] != 3 {
+		t.Fatalf("incorrect SWITCHES data %v", data)
+	}
+
+	decoded, err := DecodeSwitches(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(decoded, testSwitches) {
+		t.Errorf("incorrect decoded switches %v", decoded)
+	}
+}
+
+func TestParseSwanTables(t *testing.T) {
+	animations, switches, err := ParseSwanTables(strings.NewReader(testSwanTables))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(animations, testAnimations) {
+		t.Errorf("incorrect parsed animations %v", animations)
+	}
+
+	if !reflect.DeepEqual(switches, testSwitches) {
+		t.Errorf("incorrect parsed switches %v", switches)
+	}
+
+	_, _, err = ParseSwanTables(strings.NewReader("8 NUKAGE3 NUKAGE1\n"))
+	if err == nil {
+		t.Error("definition outside of a section was parsed")
+	}
+}
+
+func TestWriteSwanTables(t *testing.T) {
+	var buffer bytes.Buffer
+	err := WriteSwanTables(&buffer, testAnimations, testSwitches)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if buffer.String() != testSwanTables[strings.Index(testSwanTables, "["):] {
+		t.Errorf("incorrect written tables %q", buffer.String())
+	}
+}
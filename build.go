@@ -0,0 +1,95 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BuildFileName is the name of the build file that declares the
+// targets of a project.
+const BuildFileName = "wadmake.lua"
+
+// BuildTarget is a named step of a build, which runs after the targets
+// it depends on.
+type BuildTarget struct {
+	Name        string
+	Deps        []string
+	Description string
+}
+
+// BuildOrder returns the names of the passed goals and every target
+// they depend on, each listed once and after its dependencies.  Goals
+// are visited in order, as are the dependencies of each target.
+func BuildOrder(targets []BuildTarget, goals []string) ([]string, error) {
+	byName := map[string]*BuildTarget{}
+	for i := range targets {
+		byName[targets[i].Name] = &targets[i]
+	}
+
+	order := []string{}
+	done := map[string]bool{}
+	path := []string{}
+
+	var visit func(name string, from string) error
+	visit = func(name string, from string) error {
+		if done[name] {
+			return nil
+		}
+
+		for i, visiting := range path {
+			if visiting == name {
+				cycle := append(append([]string{}, path[i:]...), name)
+				return fmt.Errorf("dependency cycle %s", strings.Join(cycle, " -> "))
+			}
+		}
+
+		target, ok := byName[name]
+		if !ok {
+			if from == "" {
+				return fmt.Errorf("unknown target %s", name)
+			}
+			return fmt.Errorf("unknown target %s, required by %s", name, from)
+		}
+
+		path = append(path, name)
+		for _, dep := range target.Deps {
+			err := visit(dep, name)
+			if err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+
+	for _, goal := range goals {
+		err := visit(goal, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
@@ -0,0 +1,64 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testTargets = []BuildTarget{
+	{Name: "release", Deps: []string{"maps", "textures"}},
+	{Name: "maps", Deps: []string{"acs"}},
+	{Name: "textures"},
+	{Name: "acs", Deps: []string{"textures"}},
+	{Name: "loop", Deps: []string{"loopback"}},
+	{Name: "loopback", Deps: []string{"loop"}},
+	{Name: "broken", Deps: []string{"missing"}},
+}
+
+// Dependencies are built first and only once
+func TestBuildOrder(t *testing.T) {
+	order, err := BuildOrder(testTargets, []string{"release", "textures"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{"textures", "acs", "maps", "release"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+}
+
+// Cycles and unknown targets are errors
+func TestBuildOrderErrors(t *testing.T) {
+	errors := map[string]string{
+		"loop":    "dependency cycle loop -> loopback -> loop",
+		"broken":  "unknown target missing, required by broken",
+		"nothing": "unknown target nothing",
+	}
+
+	for goal, expected := range errors {
+		_, err := BuildOrder(testTargets, []string{goal})
+		if err == nil || err.Error() != expected {
+			t.Errorf("%s: unexpected error %v", goal, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// CacheDirName is the name of the build cache directory, which lives in
+// the directory of the project.
+const CacheDirName = ".wadmake-cache"
+
+// cacheVersion is hashed into every key, so that changes to how results
+// are stored invalidate old entries.
+const cacheVersion = "1"
+
+// Cache stores the results of conversions on disk, so that builds only
+// redo conversions whose inputs have changed.
+type Cache struct {
+	Dir string
+}
+
+// NewCache creates a cache stored in the passed directory, which is
+// created when the first entry is stored.
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// CacheKey returns the SHA-256 hash of the passed parts, which should
+// include the name of the conversion, every option it takes and the
+// content of its inputs.
+func CacheKey(parts ...[]byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(cacheVersion))
+	for _, part := range parts {
+		// Prefixing lengths keeps ("ab", "c") and ("a", "bc") apart
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(part)))
+		hash.Write(size[:])
+		hash.Write(part)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// path returns the file an entry is stored in.  Entries are spread
+// over subdirectories named after the start of their key.
+func (cache *Cache) path(key string) string {
+	return filepath.Join(cache.Dir, key[:2], key)
+}
+
+// Get returns the data stored under a key, or false if there is none.
+func (cache *Cache) Get(key string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(cache.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Put stores data under a key.  Entries are written to a temporary file
+// first, so that an interrupted build never leaves a partial entry.
+func (cache *Cache) Put(key string, data []byte) error {
+	path := cache.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), key+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// Clean removes the cache directory and every entry in it.
+func (cache *Cache) Clean() error {
+	return os.RemoveAll(cache.Dir)
+}
@@ -0,0 +1,78 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Keys depend on every part and where parts are split
+func TestCacheKey(t *testing.T) {
+	key := CacheKey([]byte("wavtodmx"), []byte("ab"), []byte("c"))
+	if len(key) != 64 {
+		t.Errorf("incorrect key %s", key)
+	}
+
+	if key == CacheKey([]byte("wavtodmx"), []byte("a"), []byte("bc")) {
+		t.Error("keys of differently split parts are equal")
+	}
+
+	if key != CacheKey([]byte("wavtodmx"), []byte("ab"), []byte("c")) {
+		t.Error("keys of equal parts differ")
+	}
+}
+
+// Entries are stored on disk until the cache is cleaned
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wadmake")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	cache := NewCache(filepath.Join(dir, CacheDirName))
+	key := CacheKey([]byte("test"))
+
+	if _, ok := cache.Get(key); ok {
+		t.Error("found entry in empty cache")
+	}
+
+	err = cache.Put(key, []byte("result"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, ok := cache.Get(key)
+	if !ok || string(data) != "result" {
+		t.Errorf("incorrect entry %q", data)
+	}
+
+	err = cache.Clean()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := os.Stat(cache.Dir); !os.IsNotExist(err) {
+		t.Error("cache directory was not removed")
+	}
+}
@@ -0,0 +1,431 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// ConvertFunc is a conversion that needs no Lua state, so that it can
+// run on any goroutine.  Arguments are strings, float64 numbers,
+// booleans or nil for missing optional arguments, as passed from Lua.
+type ConvertFunc func(game *GameProfile, args []interface{}) ([][]byte, error)
+
+// Converters holds the conversions that can run in parallel, by the
+// name of the wad library function they match.
+var Converters = map[string]ConvertFunc{
+	"ansitoendoom":        convertANSIToEndoom,
+	"disassemblebehavior": convertDisassembleBehavior,
+	"dmxtopcspeaker":      convertDMXToPCSpeaker,
+	"dmxtowav":            convertDMXToWAV,
+	"endoomtoansi":        convertEndoomToANSI,
+	"endoomtopng":         convertEndoomToPNG,
+	"miditomus":           convertMIDIToMUS,
+	"mustomidi":           convertMUSToMIDI,
+	"pcmtodmx":            convertPCMToDMX,
+	"pcspeakertowav":      convertPCSpeakerToWAV,
+	"wavtodmx":            convertWAVToDMX,
+}
+
+// Returns the string argument at an index.
+func convertString(args []interface{}, index int) ([]byte, error) {
+	if index < len(args) {
+		if value, ok := args[index].(string); ok {
+			return []byte(value), nil
+		}
+	}
+
+	return nil, fmt.Errorf("argument %d must be a string", index+1)
+}
+
+// Returns the integer argument at an index, or def if it is missing.
+func convertInteger(args []interface{}, index int, def int) (int, error) {
+	if index >= len(args) || args[index] == nil {
+		return def, nil
+	}
+
+	if value, ok := args[index].(float64); ok && value == float64(int(value)) {
+		return int(value), nil
+	}
+	return 0, fmt.Errorf("argument %d must be an integer", index+1)
+}
+
+// Returns the DMX sample rate argument at an index, which defaults to
+// 11025.  Only the rates DMX can reliably play are allowed.
+func convertDMXRate(args []interface{}, index int) (int, error) {
+	rate, err := convertInteger(args, index, 11025)
+	if err != nil {
+		return 0, err
+	} else if rate != 11025 && rate != 22050 {
+		return 0, fmt.Errorf("argument %d: sample rate must be 11025 or 22050", index+1)
+	}
+
+	return rate, nil
+}
+
+// Encodes a sound into DMX data at the passed rate.
+func convertDMX(sound *Sound, rate int) ([][]byte, error) {
+	data, err := EncodeDMX(sound.Resample(rate))
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{data}, nil
+}
+
+// Converts WAV data into DMX data, resampling to an optional rate.
+func convertWAVToDMX(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := convertDMXRate(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	sound, err := DecodeWAV(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertDMX(sound, rate)
+}
+
+// Converts raw PCM data with the given rate, channel count and sample
+// size into DMX data, resampling to an optional rate.
+func convertPCMToDMX(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Input rate, channel count and sample size are required
+	format := make([]int, 3)
+	for i := range format {
+		if i+1 >= len(args) || args[i+1] == nil {
+			return nil, fmt.Errorf("argument %d must be an integer", i+2)
+		}
+		format[i], err = convertInteger(args, i+1, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rate, err := convertDMXRate(args, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	sound, err := DecodePCM(data, format[0], format[1], format[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return convertDMX(sound, rate)
+}
+
+// Converts DMX data into WAV data with an optional sample size, which
+// defaults to the 8 bits DMX itself uses.
+func convertDMXToWAV(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	bits, err := convertInteger(args, 1, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	sound, err := DecodeDMX(data)
+	if err != nil {
+		return nil, err
+	}
+
+	wav, err := EncodeWAV(sound, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{wav}, nil
+}
+
+// Approximates DMX data as PC speaker sound data, if the game plays PC
+// speaker sounds.
+func convertDMXToPCSpeaker(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if !game.PCSpeaker {
+		return nil, fmt.Errorf("%s has no PC speaker sounds", game.Name)
+	}
+
+	sound, err := DecodeDMX(data)
+	if err != nil {
+		return nil, err
+	}
+
+	speaker, err := EncodePCSpeaker(SoundToPCSpeaker(sound))
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{speaker}, nil
+}
+
+// Renders PC speaker sound data into WAV data for previewing, with an
+// optional sample rate.
+func convertPCSpeakerToWAV(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := convertInteger(args, 1, 11025)
+	if err != nil {
+		return nil, err
+	} else if rate < PCSpeakerRate {
+		return nil, errors.New("argument 2: sample rate is too low")
+	}
+
+	tones, err := DecodePCSpeaker(data)
+	if err != nil {
+		return nil, err
+	}
+
+	wav, err := EncodeWAV(RenderPCSpeaker(tones, rate), 8)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{wav}, nil
+}
+
+// Converts MIDI data into MUS data.  If the result is too large for
+// vanilla Doom to play, a warning message is returned as well.
+func convertMIDIToMUS(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	mus, err := MIDIToMUS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mus) > MUSSizeLimit {
+		warning := fmt.Sprintf("MUS is %d bytes, larger than the %d bytes vanilla Doom can play",
+			len(mus), MUSSizeLimit)
+		return [][]byte{mus, []byte(warning)}, nil
+	}
+	return [][]byte{mus}, nil
+}
+
+// Converts MUS data into MIDI data.
+func convertMUSToMIDI(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	midi, err := MUSToMIDI(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{midi}, nil
+}
+
+// Converts text with ANSI escape sequences into ENDOOM data.
+func convertANSIToEndoom(game *GameProfile, args []interface{}) ([][]byte, error) {
+	text, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	screen, err := DecodeANSI(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{EncodeEndoom(screen)}, nil
+}
+
+// Converts ENDOOM data into UTF-8 text with ANSI escape sequences.
+func convertEndoomToANSI(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	screen, err := DecodeEndoom(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{EncodeANSI(screen)}, nil
+}
+
+// Renders ENDOOM data into a PNG image.
+func convertEndoomToPNG(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	screen, err := DecodeEndoom(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	err = png.Encode(&buffer, RenderEndoom(screen))
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{buffer.Bytes()}, nil
+}
+
+// Disassembles a BEHAVIOR lump into a text listing.
+func convertDisassembleBehavior(game *GameProfile, args []interface{}) ([][]byte, error) {
+	data, err := convertString(args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	behavior, err := DecodeBehavior(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	err = behavior.Disassemble(&buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{buffer.Bytes()}, nil
+}
+
+// ConvertJob is a conversion to run on a worker pool.
+type ConvertJob struct {
+	Name string
+	Game *GameProfile
+	Args []interface{}
+}
+
+// ConvertResult holds the results of a job, or the error it failed
+// with.
+type ConvertResult struct {
+	Results [][]byte
+	Err     error
+}
+
+// Run runs the job on the calling goroutine.  Jobs without a game are
+// run for Doom.  A conversion that panics fails with the value it
+// panicked with, so that a worker cannot bring down the program.
+func (job *ConvertJob) Run() (result ConvertResult) {
+	defer func() {
+		if value := recover(); value != nil {
+			result = ConvertResult{Err: fmt.Errorf("%s: %v", job.Name, value)}
+		}
+	}()
+
+	fn, ok := Converters[job.Name]
+	if !ok {
+		return ConvertResult{Err: fmt.Errorf("unknown conversion %s", job.Name)}
+	}
+
+	game := job.Game
+	if game == nil {
+		game = GameDoom
+	}
+
+	results, err := fn(game, job.Args)
+	if err != nil {
+		return ConvertResult{Err: fmt.Errorf("%s: %s", job.Name, err.Error())}
+	}
+	return ConvertResult{Results: results}
+}
+
+// CacheKey returns the key the results of the job are cached under,
+// made from the name of the conversion, the game and the arguments.
+// Arguments of other types than a ConvertFunc takes are ignored.
+func (job *ConvertJob) CacheKey() string {
+	game := job.Game
+	if game == nil {
+		game = GameDoom
+	}
+
+	parts := [][]byte{[]byte(job.Name), []byte(game.Name)}
+	for _, arg := range job.Args {
+		switch value := arg.(type) {
+		case string:
+			parts = append(parts, []byte("s"+value))
+		case float64:
+			parts = append(parts, []byte("n"+strconv.FormatFloat(value, 'g', -1, 64)))
+		case bool:
+			parts = append(parts, []byte("b"+strconv.FormatBool(value)))
+		case nil:
+			parts = append(parts, []byte("x"))
+		}
+	}
+
+	return CacheKey(parts...)
+}
+
+// RunConversions runs jobs on a pool of goroutines, one per CPU if
+// workers is not positive, and returns their results in job order.
+func RunConversions(jobs []ConvertJob, workers int) []ConvertResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]ConvertResult, len(jobs))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index] = jobs[index].Run()
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
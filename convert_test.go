@@ -0,0 +1,117 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// A silent DMX sound of 20 samples at 11025 Hz
+var convertTestDMX = append([]byte("\x03\x00\x11\x2b\x04\x00\x00\x00"), bytes.Repeat([]byte{0x80}, 20)...)
+
+// Conversions match the results of the functions they wrap
+func TestConverters(t *testing.T) {
+	results, err := Converters["dmxtowav"](GameDoom, []interface{}{string(convertTestDMX), nil})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sound, _ := DecodeDMX(convertTestDMX)
+	wav, _ := EncodeWAV(sound, 8)
+	if len(results) != 1 || !bytes.Equal(results[0], wav) {
+		t.Error("incorrect WAV data")
+	}
+
+	_, err = Converters["wavtodmx"](GameDoom, []interface{}{string(wav), float64(44100)})
+	if err == nil || !strings.Contains(err.Error(), "11025 or 22050") {
+		t.Errorf("incorrect rate error %v", err)
+	}
+
+	_, err = Converters["dmxtopcspeaker"](GameHexen, []interface{}{string(convertTestDMX)})
+	if err == nil || err.Error() != "hexen has no PC speaker sounds" {
+		t.Errorf("incorrect game error %v", err)
+	}
+
+	_, err = Converters["mustomidi"](GameDoom, []interface{}{float64(1)})
+	if err == nil || err.Error() != "argument 1 must be a string" {
+		t.Errorf("incorrect argument error %v", err)
+	}
+}
+
+// Results are returned in job order, with errors kept per job
+func TestRunConversions(t *testing.T) {
+	jobs := []ConvertJob{}
+	for i := 0; i < 20; i++ {
+		jobs = append(jobs, ConvertJob{Name: "dmxtowav", Args: []interface{}{string(convertTestDMX), float64(8 + i%2*8)}})
+	}
+	jobs = append(jobs, ConvertJob{Name: "mustomidi", Args: []interface{}{"bad"}})
+	jobs = append(jobs, ConvertJob{Name: "nothing"})
+	jobs = append(jobs, ConvertJob{Name: "testpanic"})
+
+	Converters["testpanic"] = func(game *GameProfile, args []interface{}) ([][]byte, error) {
+		panic("out of range")
+	}
+	defer delete(Converters, "testpanic")
+
+	results := RunConversions(jobs, 3)
+	if len(results) != len(jobs) {
+		t.Fatalf("incorrect result count %d", len(results))
+	}
+
+	for i, result := range results[:20] {
+		expected := jobs[i].Run()
+		if result.Err != nil || !bytes.Equal(result.Results[0], expected.Results[0]) {
+			t.Errorf("incorrect result of job %d", i+1)
+		}
+	}
+
+	if results[20].Err == nil || !strings.HasPrefix(results[20].Err.Error(), "mustomidi: ") {
+		t.Errorf("incorrect conversion error %v", results[20].Err)
+	}
+
+	if results[21].Err == nil || results[21].Err.Error() != "unknown conversion nothing" {
+		t.Errorf("incorrect unknown conversion error %v", results[21].Err)
+	}
+
+	if results[22].Err == nil || results[22].Err.Error() != "testpanic: out of range" {
+		t.Errorf("incorrect panic error %v", results[22].Err)
+	}
+}
+
+// Cache keys depend on the game and the types of arguments
+func TestConvertJobCacheKey(t *testing.T) {
+	job := ConvertJob{Name: "endoomtoansi", Args: []interface{}{"fake"}}
+	if job.CacheKey() != CacheKey([]byte("endoomtoansi"), []byte("doom"), []byte("sfake")) {
+		t.Error("incorrect key")
+	}
+
+	other := ConvertJob{Name: "endoomtoansi", Game: GameHeretic, Args: []interface{}{"fake"}}
+	if job.CacheKey() == other.CacheKey() {
+		t.Error("keys of different games are equal")
+	}
+
+	number := ConvertJob{Name: "dmxtowav", Args: []interface{}{float64(8)}}
+	text := ConvertJob{Name: "dmxtowav", Args: []interface{}{"8"}}
+	if number.CacheKey() == text.CacheKey() {
+		t.Error("keys of different argument types are equal")
+	}
+}
@@ -0,0 +1,320 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+// cp437Runes maps each character of code page 437, the character set
+// of the VGA text mode, to Unicode.  Control characters map to the
+// symbols the VGA displays for them rather than their Unicode control
+// codes, except for NUL.
+var cp437Runes = [256]rune{
+	'\u0000', '☺', '☻', '♥', '♦', '♣', '♠', '•',
+	'◘', '○', '◙', '♂', '♀', '♪', '♫', '☼',
+	'►', '◄', '↕', '‼', '¶', '§', '▬', '↨',
+	'↑', '↓', '→', '←', '∟', '↔', '▲', '▼',
+	' ', '!', '"', '#', '$', '%', '&', '\'',
+	'(', ')', '*', '+', ',', '-', '.', '/',
+	'0', '1', '2', '3', '4', '5', '6', '7',
+	'8', '9', ':', ';', '<', '=', '>', '?',
+	'@', 'A', 'B', 'C', 'D', 'E', 'F', 'G',
+	'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O',
+	'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W',
+	'X', 'Y', 'Z', '[', '\\', ']', '^', '_',
+	'`', 'a', 'b', 'c', 'd', 'e', 'f', 'g',
+	'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o',
+	'p', 'q', 'r', 's', 't', 'u', 'v', 'w',
+	'x', 'y', 'z', '{', '|', '}', '~', '⌂',
+	'Ç', 'ü', 'é', 'â', 'ä', 'à', 'å', 'ç',
+	'ê', 'ë', 'è', 'ï', 'î', 'ì', 'Ä', 'Å',
+	'É', 'æ', 'Æ', 'ô', 'ö', 'ò', 'û', 'ù',
+	'ÿ', 'Ö', 'Ü', '¢', '£', '¥', '₧', 'ƒ',
+	'á', 'í', 'ó', 'ú', 'ñ', 'Ñ', 'ª', 'º',
+	'¿', '⌐', '¬', '½', '¼', '¡', '«', '»',
+	'░', '▒', '▓', '│', '┤', '╡', '╢', '╖',
+	'╕', '╣', '║', '╗', '╝', '╜', '╛', '┐',
+	'└', '┴', '┬', '├', '─', '┼', '╞', '╟',
+	'╚', '╔', '╩', '╦', '╠', '═', '╬', '╧',
+	'╨', '╤', '╥', '╙', '╘', '╒', '╓', '╫',
+	'╪', '┘', '┌', '█', '▄', '▌', '▐', '▀',
+	'α', 'ß', 'Γ', 'π', 'Σ', 'σ', 'µ', 'τ',
+	'Φ', 'Θ', 'Ω', 'δ', '∞', 'φ', 'ε', '∩',
+	'≡', '±', '≥', '≤', '⌠', '⌡', '÷', '≈',
+	'°', '∙', '·', '√', 'ⁿ', '²', '■', '\u00a0',
+}
+
+// cp437Font holds an 8x16 glyph for each character of code page 437,
+// with one byte per row and the leftmost pixel in the high bit.
+var cp437Font = [256][16]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x7E, 0x7E, 0x81, 0x81, 0xA5, 0xA5, 0x81, 0x81, 0xBD, 0xBD, 0x99, 0x99, 0x81, 0x81, 0x7E, 0x7E},
+	{0x7E, 0x7E, 0xFF, 0xFF, 0xDB, 0xDB, 0xFF, 0xFF, 0xC3, 0xC3, 0xE7, 0xE7, 0xFF, 0xFF, 0x7E, 0x7E},
+	{0x6C, 0x6C, 0xFE, 0xFE, 0xFE, 0xFE, 0xFE, 0xFE, 0x7C, 0x7C, 0x38, 0x38, 0x10, 0x10, 0x00, 0x00},
+	{0x10, 0x10, 0x38, 0x38, 0x7C, 0x7C, 0xFE, 0xFE, 0x7C, 0x7C, 0x38, 0x38, 0x10, 0x10, 0x00, 0x00},
+	{0x38, 0x38, 0x7C, 0x7C, 0x38, 0x38, 0xFE, 0xFE, 0xFE, 0xFE, 0x28, 0x28, 0x10, 0x10, 0x38, 0x38},
+	{0x10, 0x10, 0x10, 0x10, 0x38, 0x38, 0x7C, 0x7C, 0xFE, 0xFE, 0x7C, 0x7C, 0x10, 0x10, 0x38, 0x38},
+	{0x00, 0x00, 0x00, 0x00, 0x18, 0x18, 0x3C, 0x3C, 0x3C, 0x3C, 0x18, 0x18, 0x00, 0x00, 0x00, 0x00},
+	{0xFF, 0xFF, 0xFF, 0xFF, 0xE7, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0xE7, 0xFF, 0xFF, 0xFF, 0xFF},
+	{0x00, 0x00, 0x3C, 0x3C, 0x66, 0x66, 0x42, 0x42, 0x42, 0x42, 0x66, 0x66, 0x3C, 0x3C, 0x00, 0x00},
+	{0xFF, 0xFF, 0xC3, 0xC3, 0x99, 0x99, 0xBD, 0xBD, 0xBD, 0xBD, 0x99, 0x99, 0xC3, 0xC3, 0xFF, 0xFF},
+	{0x0F, 0x0F, 0x03, 0x03, 0x05, 0x05, 0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x30, 0x30, 0xFC, 0xFC, 0x30, 0x30, 0x00, 0x00},
+	{0x1E, 0x1E, 0x1B, 0x1B, 0x18, 0x18, 0x18, 0x18, 0x70, 0x70, 0xF0, 0xF0, 0x60, 0x60, 0x00, 0x00},
+	{0x7F, 0x7F, 0x63, 0x63, 0x7F, 0x7F, 0x63, 0x63, 0x63, 0x63, 0xE7, 0xE7, 0xC6, 0xC6, 0x00, 0x00},
+	{0x99, 0x99, 0x5A, 0x5A, 0x3C, 0x3C, 0xE7, 0xE7, 0xE7, 0xE7, 0x3C, 0x3C, 0x5A, 0x5A, 0x99, 0x99},
+	{0x80, 0x80, 0xE0, 0xE0, 0xF8, 0xF8, 0xFE, 0xFE, 0xF8, 0xF8, 0xE0, 0xE0, 0x80, 0x80, 0x00, 0x00},
+	{0x02, 0x02, 0x0E, 0x0E, 0x3E, 0x3E, 0xFE, 0xFE, 0x3E, 0x3E, 0x0E, 0x0E, 0x02, 0x02, 0x00, 0x00},
+	{0x30, 0x30, 0x78, 0x78, 0xFC, 0xFC, 0x30, 0x30, 0xFC, 0xFC, 0x78, 0x78, 0x30, 0x30, 0x00, 0x00},
+	{0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x00, 0x00, 0x66, 0x66, 0x00, 0x00},
+	{0x7F, 0x7F, 0xDB, 0xDB, 0xDB, 0xDB, 0x7B, 0x7B, 0x1B, 0x1B, 0x1B, 0x1B, 0x1B, 0x1B, 0x00, 0x00},
+	{0x78, 0x78, 0xC0, 0xC0, 0x78, 0x78, 0xCC, 0xCC, 0x78, 0x78, 0x0C, 0x0C, 0x78, 0x78, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFE, 0xFE, 0xFE, 0xFE, 0xFE, 0xFE, 0x00, 0x00},
+	{0x30, 0x30, 0x78, 0x78, 0xFC, 0xFC, 0x30, 0x30, 0xFC, 0xFC, 0x78, 0x78, 0x30, 0x30, 0xFC, 0xFC},
+	{0x30, 0x30, 0x78, 0x78, 0xFC, 0xFC, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x00, 0x00},
+	{0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0xFC, 0xFC, 0x78, 0x78, 0x30, 0x30, 0x00, 0x00},
+	{0x00, 0x00, 0x18, 0x18, 0x0C, 0x0C, 0xFE, 0xFE, 0x0C, 0x0C, 0x18, 0x18, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x30, 0x30, 0x60, 0x60, 0xFE, 0xFE, 0x60, 0x60, 0x30, 0x30, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xFE, 0xFE, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x24, 0x24, 0x66, 0x66, 0xFF, 0xFF, 0x66, 0x66, 0x24, 0x24, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x10, 0x10, 0x38, 0x38, 0x7C, 0x7C, 0xFE, 0xFE, 0xFE, 0xFE, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0xFE, 0xFE, 0xFE, 0xFE, 0x7C, 0x7C, 0x38, 0x38, 0x10, 0x10, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x18, 0x18, 0x3C, 0x3C, 0x3C, 0x3C, 0x18, 0x18, 0x18, 0x18, 0x00, 0x00, 0x18, 0x18, 0x00, 0x00},
+	{0x6C, 0x6C, 0x6C, 0x6C, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x6C, 0x6C, 0x6C, 0x6C, 0xFE, 0xFE, 0x6C, 0x6C, 0xFE, 0xFE, 0x6C, 0x6C, 0x6C, 0x6C, 0x00, 0x00},
+	{0x30, 0x30, 0x7C, 0x7C, 0xC0, 0xC0, 0x78, 0x78, 0x0C, 0x0C, 0xF8, 0xF8, 0x30, 0x30, 0x00, 0x00},
+	{0x00, 0x00, 0xC6, 0xC6, 0xCC, 0xCC, 0x18, 0x18, 0x30, 0x30, 0x66, 0x66, 0xC6, 0xC6, 0x00, 0x00},
+	{0x38, 0x38, 0x6C, 0x6C, 0x38, 0x38, 0x76, 0x76, 0xDC, 0xDC, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x60, 0x60, 0x60, 0x60, 0xC0, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x18, 0x18, 0x30, 0x30, 0x60, 0x60, 0x60, 0x60, 0x60, 0x60, 0x30, 0x30, 0x18, 0x18, 0x00, 0x00},
+	{0x60, 0x60, 0x30, 0x30, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x30, 0x30, 0x60, 0x60, 0x00, 0x00},
+	{0x00, 0x00, 0x66, 0x66, 0x3C, 0x3C, 0xFF, 0xFF, 0x3C, 0x3C, 0x66, 0x66, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x30, 0x30, 0x30, 0x30, 0xFC, 0xFC, 0x30, 0x30, 0x30, 0x30, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x30, 0x30, 0x30, 0x30, 0x60, 0x60},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x30, 0x30, 0x30, 0x30, 0x00, 0x00},
+	{0x06, 0x06, 0x0C, 0x0C, 0x18, 0x18, 0x30, 0x30, 0x60, 0x60, 0xC0, 0xC0, 0x80, 0x80, 0x00, 0x00},
+	{0x7C, 0x7C, 0xC6, 0xC6, 0xCE, 0xCE, 0xDE, 0xDE, 0xF6, 0xF6, 0xE6, 0xE6, 0x7C, 0x7C, 0x00, 0x00},
+	{0x30, 0x30, 0x70, 0x70, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0xFC, 0xFC, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0x0C, 0x0C, 0x38, 0x38, 0x60, 0x60, 0xCC, 0xCC, 0xFC, 0xFC, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0x0C, 0x0C, 0x38, 0x38, 0x0C, 0x0C, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x1C, 0x1C, 0x3C, 0x3C, 0x6C, 0x6C, 0xCC, 0xCC, 0xFE, 0xFE, 0x0C, 0x0C, 0x1E, 0x1E, 0x00, 0x00},
+	{0xFC, 0xFC, 0xC0, 0xC0, 0xF8, 0xF8, 0x0C, 0x0C, 0x0C, 0x0C, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x38, 0x38, 0x60, 0x60, 0xC0, 0xC0, 0xF8, 0xF8, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0xFC, 0xFC, 0xCC, 0xCC, 0x0C, 0x0C, 0x18, 0x18, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0x7C, 0x7C, 0x0C, 0x0C, 0x18, 0x18, 0x70, 0x70, 0x00, 0x00},
+	{0x00, 0x00, 0x30, 0x30, 0x30, 0x30, 0x00, 0x00, 0x00, 0x00, 0x30, 0x30, 0x30, 0x30, 0x00, 0x00},
+	{0x00, 0x00, 0x30, 0x30, 0x30, 0x30, 0x00, 0x00, 0x00, 0x00, 0x30, 0x30, 0x30, 0x30, 0x60, 0x60},
+	{0x18, 0x18, 0x30, 0x30, 0x60, 0x60, 0xC0, 0xC0, 0x60, 0x60, 0x30, 0x30, 0x18, 0x18, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00, 0x00, 0x00},
+	{0x60, 0x60, 0x30, 0x30, 0x18, 0x18, 0x0C, 0x0C, 0x18, 0x18, 0x30, 0x30, 0x60, 0x60, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0x0C, 0x0C, 0x18, 0x18, 0x30, 0x30, 0x00, 0x00, 0x30, 0x30, 0x00, 0x00},
+	{0x7C, 0x7C, 0xC6, 0xC6, 0xDE, 0xDE, 0xDE, 0xDE, 0xDE, 0xDE, 0xC0, 0xC0, 0x78, 0x78, 0x00, 0x00},
+	{0x30, 0x30, 0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xFC, 0xFC, 0xCC, 0xCC, 0xCC, 0xCC, 0x00, 0x00},
+	{0xFC, 0xFC, 0x66, 0x66, 0x66, 0x66, 0x7C, 0x7C, 0x66, 0x66, 0x66, 0x66, 0xFC, 0xFC, 0x00, 0x00},
+	{0x3C, 0x3C, 0x66, 0x66, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0x66, 0x66, 0x3C, 0x3C, 0x00, 0x00},
+	{0xF8, 0xF8, 0x6C, 0x6C, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x6C, 0x6C, 0xF8, 0xF8, 0x00, 0x00},
+	{0xFE, 0xFE, 0x62, 0x62, 0x68, 0x68, 0x78, 0x78, 0x68, 0x68, 0x62, 0x62, 0xFE, 0xFE, 0x00, 0x00},
+	{0xFE, 0xFE, 0x62, 0x62, 0x68, 0x68, 0x78, 0x78, 0x68, 0x68, 0x60, 0x60, 0xF0, 0xF0, 0x00, 0x00},
+	{0x3C, 0x3C, 0x66, 0x66, 0xC0, 0xC0, 0xC0, 0xC0, 0xCE, 0xCE, 0x66, 0x66, 0x3E, 0x3E, 0x00, 0x00},
+	{0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xFC, 0xFC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x00, 0x00},
+	{0x78, 0x78, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00},
+	{0x1E, 0x1E, 0x0C, 0x0C, 0x0C, 0x0C, 0x0C, 0x0C, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0xE6, 0xE6, 0x66, 0x66, 0x6C, 0x6C, 0x78, 0x78, 0x6C, 0x6C, 0x66, 0x66, 0xE6, 0xE6, 0x00, 0x00},
+	{0xF0, 0xF0, 0x60, 0x60, 0x60, 0x60, 0x60, 0x60, 0x62, 0x62, 0x66, 0x66, 0xFE, 0xFE, 0x00, 0x00},
+	{0xC6, 0xC6, 0xEE, 0xEE, 0xFE, 0xFE, 0xFE, 0xFE, 0xD6, 0xD6, 0xC6, 0xC6, 0xC6, 0xC6, 0x00, 0x00},
+	{0xC6, 0xC6, 0xE6, 0xE6, 0xF6, 0xF6, 0xDE, 0xDE, 0xCE, 0xCE, 0xC6, 0xC6, 0xC6, 0xC6, 0x00, 0x00},
+	{0x38, 0x38, 0x6C, 0x6C, 0xC6, 0xC6, 0xC6, 0xC6, 0xC6, 0xC6, 0x6C, 0x6C, 0x38, 0x38, 0x00, 0x00},
+	{0xFC, 0xFC, 0x66, 0x66, 0x66, 0x66, 0x7C, 0x7C, 0x60, 0x60, 0x60, 0x60, 0xF0, 0xF0, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xDC, 0xDC, 0x78, 0x78, 0x1C, 0x1C, 0x00, 0x00},
+	{0xFC, 0xFC, 0x66, 0x66, 0x66, 0x66, 0x7C, 0x7C, 0x6C, 0x6C, 0x66, 0x66, 0xE6, 0xE6, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0xE0, 0xE0, 0x70, 0x70, 0x1C, 0x1C, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0xFC, 0xFC, 0xB4, 0xB4, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00},
+	{0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xFC, 0xFC, 0x00, 0x00},
+	{0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x30, 0x30, 0x00, 0x00},
+	{0xC6, 0xC6, 0xC6, 0xC6, 0xC6, 0xC6, 0xD6, 0xD6, 0xFE, 0xFE, 0xEE, 0xEE, 0xC6, 0xC6, 0x00, 0x00},
+	{0xC6, 0xC6, 0xC6, 0xC6, 0x6C, 0x6C, 0x38, 0x38, 0x38, 0x38, 0x6C, 0x6C, 0xC6, 0xC6, 0x00, 0x00},
+	{0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00},
+	{0xFE, 0xFE, 0xC6, 0xC6, 0x8C, 0x8C, 0x18, 0x18, 0x32, 0x32, 0x66, 0x66, 0xFE, 0xFE, 0x00, 0x00},
+	{0x78, 0x78, 0x60, 0x60, 0x60, 0x60, 0x60, 0x60, 0x60, 0x60, 0x60, 0x60, 0x78, 0x78, 0x00, 0x00},
+	{0xC0, 0xC0, 0x60, 0x60, 0x30, 0x30, 0x18, 0x18, 0x0C, 0x0C, 0x06, 0x06, 0x02, 0x02, 0x00, 0x00},
+	{0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x78, 0x78, 0x00, 0x00},
+	{0x10, 0x10, 0x38, 0x38, 0x6C, 0x6C, 0xC6, 0xC6, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF},
+	{0x30, 0x30, 0x30, 0x30, 0x18, 0x18, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x78, 0x78, 0x0C, 0x0C, 0x7C, 0x7C, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0xE0, 0xE0, 0x60, 0x60, 0x60, 0x60, 0x7C, 0x7C, 0x66, 0x66, 0x66, 0x66, 0xDC, 0xDC, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x78, 0x78, 0xCC, 0xCC, 0xC0, 0xC0, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x1C, 0x1C, 0x0C, 0x0C, 0x0C, 0x0C, 0x7C, 0x7C, 0xCC, 0xCC, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x78, 0x78, 0xCC, 0xCC, 0xFC, 0xFC, 0xC0, 0xC0, 0x78, 0x78, 0x00, 0x00},
+	{0x38, 0x38, 0x6C, 0x6C, 0x60, 0x60, 0xF0, 0xF0, 0x60, 0x60, 0x60, 0x60, 0xF0, 0xF0, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x76, 0x76, 0xCC, 0xCC, 0xCC, 0xCC, 0x7C, 0x7C, 0x0C, 0x0C, 0xF8, 0xF8},
+	{0xE0, 0xE0, 0x60, 0x60, 0x6C, 0x6C, 0x76, 0x76, 0x66, 0x66, 0x66, 0x66, 0xE6, 0xE6, 0x00, 0x00},
+	{0x30, 0x30, 0x00, 0x00, 0x70, 0x70, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00},
+	{0x0C, 0x0C, 0x00, 0x00, 0x0C, 0x0C, 0x0C, 0x0C, 0x0C, 0x0C, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78},
+	{0xE0, 0xE0, 0x60, 0x60, 0x66, 0x66, 0x6C, 0x6C, 0x78, 0x78, 0x6C, 0x6C, 0xE6, 0xE6, 0x00, 0x00},
+	{0x70, 0x70, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xCC, 0xCC, 0xFE, 0xFE, 0xFE, 0xFE, 0xD6, 0xD6, 0xC6, 0xC6, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xF8, 0xF8, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xDC, 0xDC, 0x66, 0x66, 0x66, 0x66, 0x7C, 0x7C, 0x60, 0x60, 0xF0, 0xF0},
+	{0x00, 0x00, 0x00, 0x00, 0x76, 0x76, 0xCC, 0xCC, 0xCC, 0xCC, 0x7C, 0x7C, 0x0C, 0x0C, 0x1E, 0x1E},
+	{0x00, 0x00, 0x00, 0x00, 0xDC, 0xDC, 0x76, 0x76, 0x66, 0x66, 0x60, 0x60, 0xF0, 0xF0, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x7C, 0x7C, 0xC0, 0xC0, 0x78, 0x78, 0x0C, 0x0C, 0xF8, 0xF8, 0x00, 0x00},
+	{0x10, 0x10, 0x30, 0x30, 0x7C, 0x7C, 0x30, 0x30, 0x30, 0x30, 0x34, 0x34, 0x18, 0x18, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x30, 0x30, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xC6, 0xC6, 0xD6, 0xD6, 0xFE, 0xFE, 0xFE, 0xFE, 0x6C, 0x6C, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xC6, 0xC6, 0x6C, 0x6C, 0x38, 0x38, 0x6C, 0x6C, 0xC6, 0xC6, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x7C, 0x7C, 0x0C, 0x0C, 0xF8, 0xF8},
+	{0x00, 0x00, 0x00, 0x00, 0xFC, 0xFC, 0x98, 0x98, 0x30, 0x30, 0x64, 0x64, 0xFC, 0xFC, 0x00, 0x00},
+	{0x1C, 0x1C, 0x30, 0x30, 0x30, 0x30, 0xE0, 0xE0, 0x30, 0x30, 0x30, 0x30, 0x1C, 0x1C, 0x00, 0x00},
+	{0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x00, 0x00, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x00, 0x00},
+	{0xE0, 0xE0, 0x30, 0x30, 0x30, 0x30, 0x1C, 0x1C, 0x30, 0x30, 0x30, 0x30, 0xE0, 0xE0, 0x00, 0x00},
+	{0x76, 0x76, 0xDC, 0xDC, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x10, 0x10, 0x38, 0x38, 0x6C, 0x6C, 0xC6, 0xC6, 0xC6, 0xC6, 0xFE, 0xFE, 0x00, 0x00, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0xC0, 0xC0, 0xC0, 0xC0, 0xCC, 0xCC, 0x78, 0x78, 0x18, 0x18, 0x70, 0x70},
+	{0xCC, 0xCC, 0x00, 0x00, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x0C, 0x0C, 0x18, 0x18, 0x78, 0x78, 0xCC, 0xCC, 0xFC, 0xFC, 0xC0, 0xC0, 0x78, 0x78, 0x00, 0x00},
+	{0x30, 0x30, 0x48, 0x48, 0x78, 0x78, 0x0C, 0x0C, 0x7C, 0x7C, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0xCC, 0xCC, 0x00, 0x00, 0x78, 0x78, 0x0C, 0x0C, 0x7C, 0x7C, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x60, 0x60, 0x30, 0x30, 0x78, 0x78, 0x0C, 0x0C, 0x7C, 0x7C, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x30, 0x30, 0x48, 0x48, 0x78, 0x78, 0x0C, 0x0C, 0x7C, 0x7C, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x78, 0x78, 0xCC, 0xCC, 0xC0, 0xC0, 0xCC, 0xCC, 0x78, 0x78, 0x30, 0x30},
+	{0x30, 0x30, 0x48, 0x48, 0x78, 0x78, 0xCC, 0xCC, 0xFC, 0xFC, 0xC0, 0xC0, 0x78, 0x78, 0x00, 0x00},
+	{0xCC, 0xCC, 0x00, 0x00, 0x78, 0x78, 0xCC, 0xCC, 0xFC, 0xFC, 0xC0, 0xC0, 0x78, 0x78, 0x00, 0x00},
+	{0x60, 0x60, 0x30, 0x30, 0x78, 0x78, 0xCC, 0xCC, 0xFC, 0xFC, 0xC0, 0xC0, 0x78, 0x78, 0x00, 0x00},
+	{0xCC, 0xCC, 0x00, 0x00, 0x70, 0x70, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00},
+	{0x30, 0x30, 0x48, 0x48, 0x70, 0x70, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00},
+	{0x60, 0x60, 0x30, 0x30, 0x70, 0x70, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00},
+	{0xCC, 0xCC, 0x30, 0x30, 0x78, 0x78, 0xCC, 0xCC, 0xFC, 0xFC, 0xCC, 0xCC, 0xCC, 0xCC, 0x00, 0x00},
+	{0x30, 0x30, 0x48, 0x48, 0x30, 0x30, 0x78, 0x78, 0xCC, 0xCC, 0xFC, 0xFC, 0xCC, 0xCC, 0x00, 0x00},
+	{0x18, 0x18, 0xFE, 0xFE, 0x62, 0x62, 0x78, 0x78, 0x60, 0x60, 0x62, 0x62, 0xFE, 0xFE, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x7E, 0x7E, 0x1B, 0x1B, 0x7F, 0x7F, 0xD8, 0xD8, 0x7E, 0x7E, 0x00, 0x00},
+	{0x3E, 0x3E, 0x6C, 0x6C, 0xCC, 0xCC, 0xFE, 0xFE, 0xCC, 0xCC, 0xCC, 0xCC, 0xCE, 0xCE, 0x00, 0x00},
+	{0x30, 0x30, 0x48, 0x48, 0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0xCC, 0xCC, 0x00, 0x00, 0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x60, 0x60, 0x30, 0x30, 0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x30, 0x30, 0x48, 0x48, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x60, 0x60, 0x30, 0x30, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0xCC, 0xCC, 0x00, 0x00, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x7C, 0x7C, 0x0C, 0x0C, 0xF8, 0xF8},
+	{0xC6, 0xC6, 0x38, 0x38, 0x6C, 0x6C, 0xC6, 0xC6, 0xC6, 0xC6, 0x6C, 0x6C, 0x38, 0x38, 0x00, 0x00},
+	{0xCC, 0xCC, 0x00, 0x00, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0xC0, 0xC0, 0xC0, 0xC0, 0x78, 0x78, 0x30, 0x30, 0x30, 0x30},
+	{0x38, 0x38, 0x6C, 0x6C, 0x60, 0x60, 0xF0, 0xF0, 0x60, 0x60, 0x62, 0x62, 0xFE, 0xFE, 0x00, 0x00},
+	{0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0xFC, 0xFC, 0x30, 0x30, 0xFC, 0xFC, 0x30, 0x30, 0x00, 0x00},
+	{0xE0, 0xE0, 0x90, 0x90, 0x94, 0x94, 0xEE, 0xEE, 0x84, 0x84, 0x85, 0x85, 0x82, 0x82, 0x00, 0x00},
+	{0x0E, 0x0E, 0x1B, 0x1B, 0x18, 0x18, 0x7E, 0x7E, 0x18, 0x18, 0x18, 0x18, 0xD8, 0xD8, 0x70, 0x70},
+	{0x0C, 0x0C, 0x18, 0x18, 0x78, 0x78, 0x0C, 0x0C, 0x7C, 0x7C, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x0C, 0x0C, 0x18, 0x18, 0x70, 0x70, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00},
+	{0x0C, 0x0C, 0x18, 0x18, 0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x0C, 0x0C, 0x18, 0x18, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x76, 0x76, 0x00, 0x00},
+	{0x64, 0x64, 0x98, 0x98, 0xF8, 0xF8, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x00, 0x00},
+	{0xF8, 0xF8, 0x00, 0x00, 0xCC, 0xCC, 0xEC, 0xEC, 0xFC, 0xFC, 0xDC, 0xDC, 0xCC, 0xCC, 0x00, 0x00},
+	{0x78, 0x78, 0x0C, 0x0C, 0x7C, 0x7C, 0xCC, 0xCC, 0x7C, 0x7C, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00},
+	{0x30, 0x30, 0x00, 0x00, 0x30, 0x30, 0x60, 0x60, 0xC0, 0xC0, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFC, 0xFC, 0xC0, 0xC0, 0xC0, 0xC0, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFC, 0xFC, 0x0C, 0x0C, 0x0C, 0x0C, 0x00, 0x00, 0x00, 0x00},
+	{0x82, 0x82, 0x84, 0x84, 0x88, 0x88, 0x16, 0x16, 0x22, 0x22, 0x44, 0x44, 0x8E, 0x8E, 0x00, 0x00},
+	{0x82, 0x82, 0x84, 0x84, 0x88, 0x88, 0x14, 0x14, 0x2C, 0x2C, 0x5E, 0x5E, 0x84, 0x84, 0x00, 0x00},
+	{0x30, 0x30, 0x00, 0x00, 0x30, 0x30, 0x30, 0x30, 0x78, 0x78, 0x78, 0x78, 0x30, 0x30, 0x00, 0x00},
+	{0x00, 0x00, 0x33, 0x33, 0x66, 0x66, 0xCC, 0xCC, 0x66, 0x66, 0x33, 0x33, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0xCC, 0xCC, 0x66, 0x66, 0x33, 0x33, 0x66, 0x66, 0xCC, 0xCC, 0x00, 0x00, 0x00, 0x00},
+	{0x22, 0x88, 0x22, 0x88, 0x22, 0x88, 0x22, 0x88, 0x22, 0x88, 0x22, 0x88, 0x22, 0x88, 0x22, 0x88},
+	{0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA},
+	{0xDD, 0x77, 0xDD, 0x77, 0xDD, 0x77, 0xDD, 0x77, 0xDD, 0x77, 0xDD, 0x77, 0xDD, 0x77, 0xDD, 0x77},
+	{0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0xF8, 0xF8, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x18, 0x18, 0x18, 0x18, 0xF8, 0xF8, 0x18, 0x18, 0xF8, 0xF8, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0xE6, 0xE6, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFE, 0xFE, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x00, 0x00, 0x00, 0x00, 0xF8, 0xF8, 0x18, 0x18, 0xF8, 0xF8, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x66, 0x66, 0x66, 0x66, 0xE6, 0xE6, 0x06, 0x06, 0xE6, 0xE6, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x00, 0x00, 0x00, 0x00, 0xFE, 0xFE, 0x06, 0x06, 0xE6, 0xE6, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x66, 0x66, 0x66, 0x66, 0xE6, 0xE6, 0x06, 0x06, 0xFE, 0xFE, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0xFE, 0xFE, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x18, 0x18, 0x18, 0x18, 0xF8, 0xF8, 0x18, 0x18, 0xF8, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF8, 0xF8, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x1F, 0x1F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x1F, 0x1F, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x18, 0x18, 0x18, 0x18, 0x1F, 0x1F, 0x18, 0x18, 0x1F, 0x1F, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x67, 0x67, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x66, 0x66, 0x66, 0x66, 0x67, 0x67, 0x60, 0x60, 0x7F, 0x7F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x7F, 0x7F, 0x60, 0x60, 0x67, 0x67, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x66, 0x66, 0x66, 0x66, 0xE7, 0xE7, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0xE7, 0xE7, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x66, 0x66, 0x66, 0x66, 0x67, 0x67, 0x60, 0x60, 0x67, 0x67, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x66, 0x66, 0x66, 0x66, 0xE7, 0xE7, 0x00, 0x00, 0xE7, 0xE7, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0xFF, 0xFF, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x7F, 0x7F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x18, 0x18, 0x18, 0x18, 0x1F, 0x1F, 0x18, 0x18, 0x1F, 0x1F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x1F, 0x1F, 0x18, 0x18, 0x1F, 0x1F, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x7F, 0x7F, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0xFF, 0xFF, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66},
+	{0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, 0x18, 0x18, 0xFF, 0xFF, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0xF8, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F, 0x1F, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+	{0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0},
+	{0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F},
+	{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x76, 0x76, 0xDC, 0xDC, 0xC8, 0xC8, 0xDC, 0xDC, 0x76, 0x76, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xF8, 0xF8, 0xCC, 0xCC, 0xCC, 0xCC, 0xF8, 0xF8, 0xC0, 0xC0},
+	{0xFC, 0xFC, 0xCC, 0xCC, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0xC0, 0x00, 0x00},
+	{0x00, 0x00, 0xFE, 0xFE, 0x6C, 0x6C, 0x6C, 0x6C, 0x6C, 0x6C, 0x6C, 0x6C, 0x6C, 0x6C, 0x00, 0x00},
+	{0xFC, 0xFC, 0xCC, 0xCC, 0x60, 0x60, 0x30, 0x30, 0x60, 0x60, 0xCC, 0xCC, 0xFC, 0xFC, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x7E, 0x7E, 0xD8, 0xD8, 0xD8, 0xD8, 0xD8, 0xD8, 0x70, 0x70, 0x00, 0x00},
+	{0x00, 0x00, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x7C, 0x7C, 0x60, 0x60, 0xC0, 0xC0},
+	{0x00, 0x00, 0x76, 0x76, 0xDC, 0xDC, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x00, 0x00},
+	{0xFC, 0xFC, 0x30, 0x30, 0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x30, 0x30, 0xFC, 0xFC},
+	{0x38, 0x38, 0x6C, 0x6C, 0xC6, 0xC6, 0xFE, 0xFE, 0xC6, 0xC6, 0x6C, 0x6C, 0x38, 0x38, 0x00, 0x00},
+	{0x38, 0x38, 0x6C, 0x6C, 0xC6, 0xC6, 0xC6, 0xC6, 0x6C, 0x6C, 0x6C, 0x6C, 0xEE, 0xEE, 0x00, 0x00},
+	{0x1C, 0x1C, 0x30, 0x30, 0x18, 0x18, 0x7C, 0x7C, 0xCC, 0xCC, 0xCC, 0xCC, 0x78, 0x78, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x7E, 0x7E, 0xDB, 0xDB, 0xDB, 0xDB, 0x7E, 0x7E, 0x00, 0x00, 0x00, 0x00},
+	{0x06, 0x06, 0x0C, 0x0C, 0x7E, 0x7E, 0xDB, 0xDB, 0xDB, 0xDB, 0x7E, 0x7E, 0x60, 0x60, 0xC0, 0xC0},
+	{0x38, 0x38, 0x60, 0x60, 0xC0, 0xC0, 0xF8, 0xF8, 0xC0, 0xC0, 0x60, 0x60, 0x38, 0x38, 0x00, 0x00},
+	{0x78, 0x78, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0x00, 0x00},
+	{0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00, 0x00, 0x00},
+	{0x30, 0x30, 0x30, 0x30, 0xFC, 0xFC, 0x30, 0x30, 0x30, 0x30, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00},
+	{0x60, 0x60, 0x30, 0x30, 0x18, 0x18, 0x30, 0x30, 0x60, 0x60, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00},
+	{0x18, 0x18, 0x30, 0x30, 0x60, 0x60, 0x30, 0x30, 0x18, 0x18, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00},
+	{0x0E, 0x0E, 0x1B, 0x1B, 0x1B, 0x1B, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},
+	{0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0xD8, 0xD8, 0xD8, 0xD8, 0x70, 0x70, 0x00, 0x00},
+	{0x00, 0x00, 0x30, 0x30, 0x00, 0x00, 0xFC, 0xFC, 0x00, 0x00, 0x30, 0x30, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x76, 0x76, 0xDC, 0xDC, 0x00, 0x00, 0x76, 0x76, 0xDC, 0xDC, 0x00, 0x00, 0x00, 0x00},
+	{0x38, 0x38, 0x6C, 0x6C, 0x6C, 0x6C, 0x38, 0x38, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x18, 0x18, 0x18, 0x18, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x18, 0x18, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x0F, 0x0F, 0x0C, 0x0C, 0x0C, 0x0C, 0x0C, 0x0C, 0xEC, 0xEC, 0x6C, 0x6C, 0x3C, 0x3C, 0x1C, 0x1C},
+	{0xF0, 0xF0, 0x6C, 0x6C, 0x6C, 0x6C, 0x6C, 0x6C, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x70, 0x70, 0x18, 0x18, 0x30, 0x30, 0x78, 0x78, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x3C, 0x3C, 0x3C, 0x3C, 0x3C, 0x3C, 0x3C, 0x3C, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+}
@@ -0,0 +1,724 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DehackedField is a single "key = value" line of a DeHackEd patch.
+type DehackedField struct {
+	Key   string
+	Value string
+}
+
+// DehackedBlock is a numbered block of fields in a DeHackEd patch, such
+// as a Thing or a Frame.
+type DehackedBlock struct {
+	Index  int
+	Name   string
+	Line   int
+	Fields []DehackedField
+}
+
+// Get returns the value of a field, matching its key case-insensitively.
+func (block *DehackedBlock) Get(key string) (string, bool) {
+	for _, field := range block.Fields {
+		if strings.EqualFold(field.Key, key) {
+			return field.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Set changes the value of a field, adding it if it does not exist.
+func (block *DehackedBlock) Set(key string, value string) {
+	for i := range block.Fields {
+		if strings.EqualFold(block.Fields[i].Key, key) {
+			block.Fields[i].Value = value
+			return
+		}
+	}
+
+	block.Fields = append(block.Fields, DehackedField{key, value})
+}
+
+// DehackedText replaces one string of the executable with another.
+type DehackedText struct {
+	Old  string
+	New  string
+	Line int
+}
+
+// DehackedPar is a BEX par time.  Episode is zero for Doom II maps.
+type DehackedPar struct {
+	Episode int
+	Map     int
+	Par     int
+	Line    int
+}
+
+// DehackedCodePointer is a BEX code pointer assignment to a frame.
+type DehackedCodePointer struct {
+	Frame int
+	Name  string
+	Line  int
+}
+
+// DehackedLine is a line of a DeHackEd patch that could not be parsed.
+type DehackedLine struct {
+	Line int
+	Text string
+}
+
+// DehackedPatch is a parsed DeHackEd or BEX patch.
+type DehackedPatch struct {
+	DoomVersion int
+	PatchFormat int
+
+	Things   []DehackedBlock
+	Frames   []DehackedBlock
+	Pointers []DehackedBlock
+	Weapons  []DehackedBlock
+	Ammo     []DehackedBlock
+	Sounds   []DehackedBlock
+	Sprites  []DehackedBlock
+	Misc     []DehackedBlock
+	Cheats   []DehackedBlock
+	Texts    []DehackedText
+
+	Strings      []DehackedField
+	Pars         []DehackedPar
+	CodePointers []DehackedCodePointer
+	SpriteNames  []DehackedField
+	SoundNames   []DehackedField
+	MusicNames   []DehackedField
+	Helper       []DehackedField
+	Includes     []string
+
+	Unknown []DehackedLine
+}
+
+var (
+	dehackedBlockRegexp   = regexp.MustCompile(`^(?i)(thing|frame|pointer|weapon|ammo|sound|sprite|misc|cheat)\s+(-?\d+)\s*(?:\((.*)\))?\s*$`)
+	dehackedTextRegexp    = regexp.MustCompile(`^(?i)text\s+(\d+)\s+(\d+)\s*$`)
+	dehackedPointerRegexp = regexp.MustCompile(`^(?i)frame\s+(\d+)\s*$`)
+	dehackedIncludeRegexp = regexp.MustCompile(`^(?i)include\s+(?:notext\s+)?(.+)$`)
+)
+
+// blocks returns the list of blocks for a block keyword.
+func (patch *DehackedPatch) blocks(keyword string) *[]DehackedBlock {
+	switch strings.ToLower(keyword) {
+	case "thing":
+		return &patch.Things
+	case "frame":
+		return &patch.Frames
+	case "pointer":
+		return &patch.Pointers
+	case "weapon":
+		return &patch.Weapons
+	case "ammo":
+		return &patch.Ammo
+	case "sound":
+		return &patch.Sounds
+	case "sprite":
+		return &patch.Sprites
+	case "misc":
+		return &patch.Misc
+	case "cheat":
+		return &patch.Cheats
+	}
+
+	return nil
+}
+
+// bexFields returns the list of fields for a BEX section.
+func (patch *DehackedPatch) bexFields(section string) *[]DehackedField {
+	switch section {
+	case "[STRINGS]":
+		return &patch.Strings
+	case "[SPRITES]":
+		return &patch.SpriteNames
+	case "[SOUNDS]":
+		return &patch.SoundNames
+	case "[MUSIC]":
+		return &patch.MusicNames
+	case "[HELPER]":
+		return &patch.Helper
+	}
+
+	return nil
+}
+
+// splitField splits a "key = value" line, returning false if there is
+// no equals sign.
+func splitField(line string) (DehackedField, bool) {
+	index := strings.IndexByte(line, '=')
+	if index == -1 {
+		return DehackedField{}, false
+	}
+
+	return DehackedField{
+		Key:   strings.TrimSpace(line[:index]),
+		Value: strings.TrimSpace(line[index+1:]),
+	}, true
+}
+
+// ParseDehacked parses a DeHackEd patch, including BEX extensions.
+// Lines that cannot be parsed are kept in the Unknown field of the
+// patch, and are reported by Validate.
+func ParseDehacked(r io.Reader) (*DehackedPatch, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := &DehackedPatch{}
+	text := strings.Replace(string(src), "\r\n", "\n", -1)
+
+	var block *DehackedBlock
+	section := ""
+
+	lineNumber := 0
+	for len(text) > 0 {
+		var line string
+		if index := strings.IndexByte(text, '\n'); index != -1 {
+			line, text = text[:index], text[index+1:]
+		} else {
+			line, text = text, ""
+		}
+		lineNumber++
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// BEX strings can continue over multiple lines.
+		for section == "[STRINGS]" && strings.HasSuffix(trimmed, "\\") && len(text) > 0 {
+			var next string
+			if index := strings.IndexByte(text, '\n'); index != -1 {
+				next, text = text[:index], text[index+1:]
+			} else {
+				next, text = text, ""
+			}
+			lineNumber++
+			trimmed = strings.TrimSuffix(trimmed, "\\") + strings.TrimSpace(next)
+		}
+
+		// Section headers
+		if strings.HasPrefix(trimmed, "[") {
+			section = strings.ToUpper(trimmed)
+			block = nil
+			switch section {
+			case "[STRINGS]", "[PARS]", "[CODEPTR]", "[SPRITES]", "[SOUNDS]", "[MUSIC]", "[HELPER]":
+			default:
+				patch.Unknown = append(patch.Unknown, DehackedLine{lineNumber, trimmed})
+				section = "unknown"
+			}
+			continue
+		}
+
+		if match := dehackedBlockRegexp.FindStringSubmatch(trimmed); match != nil {
+			index, _ := strconv.Atoi(match[2])
+			blocks := patch.blocks(match[1])
+			*blocks = append(*blocks, DehackedBlock{
+				Index:  index,
+				Name:   strings.TrimSpace(match[3]),
+				Line:   lineNumber,
+				Fields: []DehackedField{},
+			})
+			block = &(*blocks)[len(*blocks)-1]
+			section = ""
+			continue
+		}
+
+		if match := dehackedTextRegexp.FindStringSubmatch(trimmed); match != nil {
+			oldLen, _ := strconv.Atoi(match[1])
+			newLen, _ := strconv.Atoi(match[2])
+			if oldLen+newLen > len(text) {
+				return nil, fmt.Errorf("line %d: text is truncated", lineNumber)
+			}
+
+			patch.Texts = append(patch.Texts, DehackedText{
+				Old:  text[:oldLen],
+				New:  text[oldLen : oldLen+newLen],
+				Line: lineNumber,
+			})
+			lineNumber += strings.Count(text[:oldLen+newLen], "\n")
+			text = text[oldLen+newLen:]
+			block = nil
+			section = ""
+			continue
+		}
+
+		if match := dehackedIncludeRegexp.FindStringSubmatch(trimmed); match != nil && section == "" {
+			patch.Includes = append(patch.Includes, strings.TrimSpace(match[1]))
+			block = nil
+			continue
+		}
+
+		switch section {
+		case "[PARS]":
+			fields := strings.Fields(trimmed)
+			numbers := []int{}
+			for _, field := range fields[1:] {
+				number, err := strconv.Atoi(field)
+				if err != nil {
+					break
+				}
+				numbers = append(numbers, number)
+			}
+
+			if !strings.EqualFold(fields[0], "par") || len(numbers) != len(fields)-1 {
+				patch.Unknown = append(patch.Unknown, DehackedLine{lineNumber, trimmed})
+			} else if len(numbers) == 3 {
+				patch.Pars = append(patch.Pars, DehackedPar{numbers[0], numbers[1], numbers[2], lineNumber})
+			} else if len(numbers) == 2 {
+				patch.Pars = append(patch.Pars, DehackedPar{0, numbers[0], numbers[1], lineNumber})
+			} else {
+				patch.Unknown = append(patch.Unknown, DehackedLine{lineNumber, trimmed})
+			}
+			continue
+		case "[CODEPTR]":
+			field, ok := splitField(trimmed)
+			match := dehackedPointerRegexp.FindStringSubmatch(field.Key)
+			if !ok || match == nil {
+				patch.Unknown = append(patch.Unknown, DehackedLine{lineNumber, trimmed})
+				continue
+			}
+
+			frame, _ := strconv.Atoi(match[1])
+			patch.CodePointers = append(patch.CodePointers, DehackedCodePointer{frame, field.Value, lineNumber})
+			continue
+		case "[STRINGS]", "[SPRITES]", "[SOUNDS]", "[MUSIC]", "[HELPER]":
+			field, ok := splitField(trimmed)
+			if !ok {
+				patch.Unknown = append(patch.Unknown, DehackedLine{lineNumber, trimmed})
+				continue
+			}
+
+			fields := patch.bexFields(section)
+			*fields = append(*fields, field)
+			continue
+		case "unknown":
+			patch.Unknown = append(patch.Unknown, DehackedLine{lineNumber, trimmed})
+			continue
+		}
+
+		field, ok := splitField(trimmed)
+		if !ok {
+			if lineNumber == 1 || strings.HasPrefix(trimmed, "Patch File for DeHackEd") {
+				// Header line
+				continue
+			}
+
+			patch.Unknown = append(patch.Unknown, DehackedLine{lineNumber, trimmed})
+			continue
+		}
+
+		if block != nil {
+			block.Fields = append(block.Fields, field)
+		} else if strings.EqualFold(field.Key, "Doom version") {
+			patch.DoomVersion, _ = strconv.Atoi(field.Value)
+		} else if strings.EqualFold(field.Key, "Patch format") {
+			patch.PatchFormat, _ = strconv.Atoi(field.Value)
+		} else {
+			patch.Unknown = append(patch.Unknown, DehackedLine{lineNumber, trimmed})
+		}
+	}
+
+	return patch, nil
+}
+
+// dehackedLimits holds the number of each kind of table entry a patch
+// can refer to.
+type dehackedLimits struct {
+	things  int
+	frames  int
+	sprites int
+	sounds  int
+}
+
+var (
+	// Tables of the vanilla executable.
+	dehackedVanillaLimits = dehackedLimits{things: 137, frames: 967, sprites: 138, sounds: 109}
+
+	// Boom and MBF extend the tables, up to where DEHEXTRA's free
+	// entries begin.
+	dehackedBoomLimits = dehackedLimits{things: 150, frames: 1089, sprites: 145, sounds: 500}
+
+	// MBF21 includes DEHEXTRA's free entries.
+	dehackedMBF21Limits = dehackedLimits{things: 250, frames: 4000, sprites: 245, sounds: 700}
+)
+
+// dehackedCodePointers is every code pointer known to vanilla, MBF and
+// MBF21, without the A_ prefix.
+var dehackedCodePointers = map[string]bool{}
+
+func init() {
+	names := []string{
+		// Vanilla
+		"Light0", "WeaponReady", "Lower", "Raise", "Punch", "ReFire",
+		"FirePistol", "Light1", "FireShotgun", "Light2", "FireShotgun2",
+		"CheckReload", "OpenShotgun2", "LoadShotgun2", "CloseShotgun2",
+		"FireCGun", "GunFlash", "FireMissile", "Saw", "FirePlasma",
+		"BFGsound", "FireBFG", "BFGSpray", "Explode", "Pain",
+		"PlayerScream", "Fall", "XScream", "Look", "Chase", "FaceTarget",
+		"PosAttack", "Scream", "SPosAttack", "VileChase", "VileStart",
+		"VileTarget", "VileAttack", "StartFire", "Fire", "FireCrackle",
+		"Tracer", "SkelWhoosh", "SkelFist", "SkelMissile", "FatRaise",
+		"FatAttack1", "FatAttack2", "FatAttack3", "BossDeath", "CPosAttack",
+		"CPosRefire", "TroopAttack", "SargAttack", "HeadAttack",
+		"BruisAttack", "SkullAttack", "Metal", "SpidRefire", "BabyMetal",
+		"BspiAttack", "Hoof", "CyberAttack", "PainAttack", "PainDie",
+		"KeenDie", "BrainPain", "BrainScream", "BrainDie", "BrainAwake",
+		"BrainSpit", "SpawnSound", "SpawnFly", "BrainExplode",
+		// MBF
+		"Detonate", "Mushroom", "Die", "Spawn", "Turn", "Face", "Scratch",
+		"PlaySound", "RandomJump", "LineEffect", "FireOldBFG",
+		"BetaSkullAttack", "Stop",
+		// MBF21
+		"SpawnObject", "MonsterProjectile", "MonsterBulletAttack",
+		"MonsterMeleeAttack", "RadiusDamage", "NoiseAlert", "HealChase",
+		"SeekTracer", "FindTracer", "ClearTracer", "JumpIfHealthBelow",
+		"JumpIfTargetInSight", "JumpIfTargetCloser", "JumpIfTracerInSight",
+		"JumpIfTracerCloser", "JumpIfFlagsSet", "AddFlags", "RemoveFlags",
+		"WeaponProjectile", "WeaponBulletAttack", "WeaponMeleeAttack",
+		"WeaponSound", "WeaponAlert", "WeaponJump", "ConsumeAmmo",
+		"CheckAmmo", "RefireTo", "GunFlashTo",
+	}
+
+	for _, name := range names {
+		dehackedCodePointers[strings.ToUpper(name)] = true
+	}
+}
+
+// limits returns the table sizes the patch can refer to.
+func (patch *DehackedPatch) limits() dehackedLimits {
+	if patch.DoomVersion == 2021 {
+		return dehackedMBF21Limits
+	}
+
+	if len(patch.Strings) > 0 || len(patch.Pars) > 0 || len(patch.CodePointers) > 0 ||
+		len(patch.SpriteNames) > 0 || len(patch.SoundNames) > 0 ||
+		len(patch.MusicNames) > 0 || len(patch.Helper) > 0 {
+		return dehackedBoomLimits
+	}
+
+	return dehackedVanillaLimits
+}
+
+// Fields of each block type that refer to frames or sounds.
+var (
+	dehackedThingFrames = []string{
+		"Initial frame", "First moving frame", "Injury frame",
+		"Close attack frame", "Far attack frame", "Death frame",
+		"Exploding death frame", "Respawn frame",
+	}
+	dehackedThingSounds = []string{
+		"Alert sound", "Attack sound", "Pain sound", "Death sound",
+		"Action sound", "Rip sound",
+	}
+	dehackedWeaponFrames = []string{
+		"Deselect frame", "Select frame", "Bobbing frame", "Shooting frame",
+		"Firing frame",
+	}
+)
+
+// Validate checks that every block and reference of the patch is within
+// the limits of the target port, and that every code pointer exists.
+func (patch *DehackedPatch) Validate() []error {
+	errs := []error{}
+	limits := patch.limits()
+
+	report := func(line int, format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, a...)))
+	}
+
+	// Checks that a field, if present, is a number within a range.
+	checkField := func(block *DehackedBlock, key string, min int, max int, what string) {
+		value, ok := block.Get(key)
+		if !ok {
+			return
+		}
+
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			report(block.Line, "%s is not a number (%s)", key, value)
+		} else if number < min || number >= max {
+			report(block.Line, "%s refers to invalid %s %d", key, what, number)
+		}
+	}
+
+	for _, line := range patch.Unknown {
+		report(line.Line, "could not parse %q", line.Text)
+	}
+
+	for i := range patch.Things {
+		thing := &patch.Things[i]
+		if thing.Index < 1 || thing.Index > limits.things {
+			report(thing.Line, "invalid thing %d", thing.Index)
+		}
+		for _, key := range dehackedThingFrames {
+			checkField(thing, key, 0, limits.frames, "frame")
+		}
+		for _, key := range dehackedThingSounds {
+			checkField(thing, key, 0, limits.sounds, "sound")
+		}
+	}
+
+	for i := range patch.Frames {
+		frame := &patch.Frames[i]
+		if frame.Index < 0 || frame.Index >= limits.frames {
+			report(frame.Line, "invalid frame %d", frame.Index)
+		}
+		checkField(frame, "Sprite number", 0, limits.sprites, "sprite")
+		checkField(frame, "Next frame", 0, limits.frames, "frame")
+
+		if value, ok := frame.Get("Sprite subnumber"); ok {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				report(frame.Line, "Sprite subnumber is not a number (%s)", value)
+			} else if number < 0 || number&0x7FFF > 28 {
+				report(frame.Line, "Sprite subnumber %d is out of range", number)
+			}
+		}
+	}
+
+	for i := range patch.Pointers {
+		pointer := &patch.Pointers[i]
+		checkField(pointer, "Codep Frame", 0, limits.frames, "frame")
+
+		// The frame of the pointer is in the block name
+		frame := strings.TrimSpace(strings.TrimPrefix(strings.ToLower(pointer.Name), "frame"))
+		if number, err := strconv.Atoi(frame); err == nil && (number < 0 || number >= limits.frames) {
+			report(pointer.Line, "pointer refers to invalid frame %d", number)
+		}
+	}
+
+	for i := range patch.Weapons {
+		weapon := &patch.Weapons[i]
+		if weapon.Index < 0 || weapon.Index > 8 {
+			report(weapon.Line, "invalid weapon %d", weapon.Index)
+		}
+		for _, key := range dehackedWeaponFrames {
+			checkField(weapon, key, 0, limits.frames, "frame")
+		}
+
+		if value, ok := weapon.Get("Ammo type"); ok {
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 0 || (number > 3 && number != 5) {
+				report(weapon.Line, "Ammo type refers to invalid ammo %s", value)
+			}
+		}
+	}
+
+	for i := range patch.Ammo {
+		if patch.Ammo[i].Index < 0 || patch.Ammo[i].Index > 3 {
+			report(patch.Ammo[i].Line, "invalid ammo %d", patch.Ammo[i].Index)
+		}
+	}
+
+	for i := range patch.Sounds {
+		if patch.Sounds[i].Index < 0 || patch.Sounds[i].Index >= limits.sounds {
+			report(patch.Sounds[i].Line, "invalid sound %d", patch.Sounds[i].Index)
+		}
+	}
+
+	for i := range patch.Sprites {
+		if patch.Sprites[i].Index < 0 || patch.Sprites[i].Index >= limits.sprites {
+			report(patch.Sprites[i].Line, "invalid sprite %d", patch.Sprites[i].Index)
+		}
+	}
+
+	for _, pointer := range patch.CodePointers {
+		if pointer.Frame < 0 || pointer.Frame >= limits.frames {
+			report(pointer.Line, "code pointer refers to invalid frame %d", pointer.Frame)
+		}
+
+		name := strings.ToUpper(strings.TrimPrefix(strings.TrimPrefix(pointer.Name, "A_"), "a_"))
+		if name != "NULL" && !dehackedCodePointers[name] {
+			report(pointer.Line, "unknown code pointer %s", pointer.Name)
+		}
+	}
+
+	for _, par := range patch.Pars {
+		if par.Episode == 0 && (par.Map < 1 || par.Map > 99) {
+			report(par.Line, "par refers to invalid map %d", par.Map)
+		} else if par.Episode != 0 && (par.Episode < 1 || par.Episode > 9 || par.Map < 1 || par.Map > 9) {
+			report(par.Line, "par refers to invalid map E%dM%d", par.Episode, par.Map)
+		} else if par.Par < 0 {
+			report(par.Line, "par time %d is negative", par.Par)
+		}
+	}
+
+	return errs
+}
+
+// WriteDehacked writes a patch in a normalized format.  Blocks and text
+// replacements keep the order of their lines in the source, followed by
+// those without a line in the order of their kind, and BEX sections
+// follow all of them.  Lines that could not be parsed are kept as
+// comments at the end, so that nothing is lost without notice.
+func WriteDehacked(w io.Writer, patch *DehackedPatch) error {
+	bw := bufio.NewWriter(w)
+
+	doomVersion, patchFormat := patch.DoomVersion, patch.PatchFormat
+	if doomVersion == 0 {
+		doomVersion = 21
+	}
+	if patchFormat == 0 {
+		patchFormat = 6
+	}
+
+	fmt.Fprint(bw, "Patch File for DeHackEd v3.0\n\n")
+	fmt.Fprintf(bw, "Doom version = %d\nPatch format = %d\n", doomVersion, patchFormat)
+
+	for _, include := range patch.Includes {
+		fmt.Fprintf(bw, "\nInclude %s\n", include)
+	}
+
+	kinds := []struct {
+		keyword string
+		blocks  []DehackedBlock
+	}{
+		{"Thing", patch.Things},
+		{"Frame", patch.Frames},
+		{"Pointer", patch.Pointers},
+		{"Weapon", patch.Weapons},
+		{"Ammo", patch.Ammo},
+		{"Sound", patch.Sounds},
+		{"Sprite", patch.Sprites},
+		{"Misc", patch.Misc},
+		{"Cheat", patch.Cheats},
+	}
+
+	// Each entry is a block or a text replacement
+	type dehackedEntry struct {
+		line    int
+		keyword string
+		block   *DehackedBlock
+		text    *DehackedText
+	}
+
+	entries := []dehackedEntry{}
+	for _, kind := range kinds {
+		for i := range kind.blocks {
+			entries = append(entries, dehackedEntry{kind.blocks[i].Line, kind.keyword, &kind.blocks[i], nil})
+		}
+	}
+	for i := range patch.Texts {
+		entries = append(entries, dehackedEntry{patch.Texts[i].Line, "Text", nil, &patch.Texts[i]})
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].line != 0 && (entries[b].line == 0 || entries[a].line < entries[b].line)
+	})
+
+	for _, entry := range entries {
+		if text := entry.text; text != nil {
+			fmt.Fprintf(bw, "\nText %d %d\n%s%s\n", len(text.Old), len(text.New), text.Old, text.New)
+			continue
+		}
+
+		block := entry.block
+		if block.Name != "" {
+			fmt.Fprintf(bw, "\n%s %d (%s)\n", entry.keyword, block.Index, block.Name)
+		} else {
+			fmt.Fprintf(bw, "\n%s %d\n", entry.keyword, block.Index)
+		}
+
+		for _, field := range block.Fields {
+			fmt.Fprintf(bw, "%s = %s\n", field.Key, field.Value)
+		}
+	}
+
+	sections := []struct {
+		name   string
+		fields []DehackedField
+	}{
+		{"[STRINGS]", patch.Strings},
+	}
+	for _, section := range sections {
+		if len(section.fields) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(bw, "\n%s\n", section.name)
+		for _, field := range section.fields {
+			fmt.Fprintf(bw, "%s = %s\n", field.Key, field.Value)
+		}
+	}
+
+	if len(patch.Pars) > 0 {
+		fmt.Fprint(bw, "\n[PARS]\n")
+		for _, par := range patch.Pars {
+			if par.Episode == 0 {
+				fmt.Fprintf(bw, "par %d %d\n", par.Map, par.Par)
+			} else {
+				fmt.Fprintf(bw, "par %d %d %d\n", par.Episode, par.Map, par.Par)
+			}
+		}
+	}
+
+	if len(patch.CodePointers) > 0 {
+		fmt.Fprint(bw, "\n[CODEPTR]\n")
+		for _, pointer := range patch.CodePointers {
+			fmt.Fprintf(bw, "FRAME %d = %s\n", pointer.Frame, pointer.Name)
+		}
+	}
+
+	sections = []struct {
+		name   string
+		fields []DehackedField
+	}{
+		{"[SPRITES]", patch.SpriteNames},
+		{"[SOUNDS]", patch.SoundNames},
+		{"[MUSIC]", patch.MusicNames},
+		{"[HELPER]", patch.Helper},
+	}
+	for _, section := range sections {
+		if len(section.fields) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(bw, "\n%s\n", section.name)
+		for _, field := range section.fields {
+			fmt.Fprintf(bw, "%s = %s\n", field.Key, field.Value)
+		}
+	}
+
+	if len(patch.Unknown) > 0 {
+		fmt.Fprint(bw, "\n# Lines that could not be parsed\n")
+		for _, line := range patch.Unknown {
+			fmt.Fprintf(bw, "# line %d: %s\n", line.Line, line.Text)
+		}
+	}
+
+	return bw.Flush()
+}
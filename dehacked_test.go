@@ -0,0 +1,228 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testDehacked = `Patch File for DeHackEd v3.0
+# A comment
+
+Doom version = 21
+Patch format = 6
+
+Frame 12
+Sprite number = 1
+Next frame = 13
+
+Thing 1 (Player)
+Hit points = 200
+Initial frame = 149
+
+Text 6 6
+IMP   IMP  X
+[CODEPTR]
+FRAME 12 = Look
+
+[PARS]
+par 1 1 30
+par 7 120
+
+[STRINGS]
+GOTARMOR = Picked up \
+the armor.
+`
+
+// A patch parses into a structured model
+func TestParseDehacked(t *testing.T) {
+	patch, err := ParseDehacked(strings.NewReader(testDehacked))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if patch.DoomVersion != 21 || patch.PatchFormat != 6 {
+		t.Error("incorrect header")
+	}
+
+	if len(patch.Things) != 1 || patch.Things[0].Name != "Player" || patch.Things[0].Line != 11 {
+		t.Fatal("incorrect things")
+	}
+
+	if value, _ := patch.Things[0].Get("hit points"); value != "200" {
+		t.Error("incorrect thing field")
+	}
+
+	if len(patch.Texts) != 1 || patch.Texts[0].Old != "IMP   " || patch.Texts[0].New != "IMP  X" {
+		t.Error("incorrect text")
+	}
+
+	if len(patch.CodePointers) != 1 || patch.CodePointers[0].Frame != 12 || patch.CodePointers[0].Line != 18 {
+		t.Error("incorrect code pointers")
+	}
+
+	if len(patch.Pars) != 2 || patch.Pars[1] != (DehackedPar{0, 7, 120, 22}) {
+		t.Error("incorrect pars")
+	}
+
+	if len(patch.Strings) != 1 || patch.Strings[0].Value != "Picked up the armor." {
+		t.Error("incorrect strings")
+	}
+
+	if errs := patch.Validate(); len(errs) != 0 {
+		t.Errorf("unexpected problems: %v", errs)
+	}
+}
+
+// Invalid references are reported with their line numbers
+func TestValidateDehacked(t *testing.T) {
+	patch, err := ParseDehacked(strings.NewReader(`Doom version = 21
+Thing 1
+Death sound = 600
+Frame 1100
+Sprite subnumber = 29
+Weapon 1
+Ammo type = 4
+Bogus line
+[CODEPTR]
+FRAME 1 = FireRailgun
+`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		"line 8: could not parse \"Bogus line\"",
+		"line 2: Death sound refers to invalid sound 600",
+		"line 4: invalid frame 1100",
+		"line 4: Sprite subnumber 29 is out of range",
+		"line 6: Ammo type refers to invalid ammo 4",
+		"line 10: unknown code pointer FireRailgun",
+	}
+
+	errs := patch.Validate()
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], err.Error())
+		}
+	}
+
+	// MBF21 patches may use DEHEXTRA frames
+	patch.DoomVersion = 2021
+	if len(patch.Validate()) != 4 {
+		t.Error("MBF21 limits not applied")
+	}
+}
+
+// Normalized output parses back into the same patch
+func TestWriteDehacked(t *testing.T) {
+	patch, err := ParseDehacked(strings.NewReader(testDehacked))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var buffer bytes.Buffer
+	err = WriteDehacked(&buffer, patch)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.Contains(buffer.String(), "\nThing 1 (Player)\nHit points = 200\n") {
+		t.Error("incorrect thing block")
+	}
+
+	if strings.Index(buffer.String(), "Frame 12") > strings.Index(buffer.String(), "Thing 1") ||
+		strings.Index(buffer.String(), "Thing 1") > strings.Index(buffer.String(), "Text 6 6") {
+		t.Error("blocks are not in source order")
+	}
+
+	again, err := ParseDehacked(&buffer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(again.Texts) != 1 || again.Texts[0].New != "IMP  X" || len(again.Pars) != 2 ||
+		len(again.Strings) != 1 || len(again.Unknown) != 0 {
+		t.Error("normalized patch does not round-trip")
+	}
+}
+
+// Blocks keep their source order and unparsed lines are not dropped
+func TestWriteDehackedOrder(t *testing.T) {
+	patch, err := ParseDehacked(strings.NewReader(`Doom version = 21
+Thing 5
+Speed = 8
+Frame 3
+Duration = 2
+Thing 2
+Bogus line
+Speed = 4
+[CODEPTR]
+FRAME 9 = Look
+FRAME 1 = Chase
+[UNKNOWN]
+Something = 1
+`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	patch.Things = append(patch.Things, DehackedBlock{Index: 1, Fields: []DehackedField{}})
+
+	var buffer bytes.Buffer
+	err = WriteDehacked(&buffer, patch)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := `Patch File for DeHackEd v3.0
+
+Doom version = 21
+Patch format = 6
+
+Thing 5
+Speed = 8
+
+Frame 3
+Duration = 2
+
+Thing 2
+Speed = 4
+
+Thing 1
+
+[CODEPTR]
+FRAME 9 = Look
+FRAME 1 = Chase
+
+# Lines that could not be parsed
+# line 7: Bogus line
+# line 12: [UNKNOWN]
+# line 13: Something = 1
+`
+	if buffer.String() != expected {
+		t.Errorf("incorrect patch %q", buffer.String())
+	}
+}
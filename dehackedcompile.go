@@ -0,0 +1,341 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DehackedState is a single state of a compiled thing or weapon.
+type DehackedState struct {
+	// Label names this state so other states can jump to it.  Labels
+	// like "spawn" or "fire" also set the matching thing or weapon frame.
+	Label string
+
+	Sprite string
+	Frame  int
+	Bright bool
+	Tics   int
+	Action string
+
+	// Args are the MBF21 action arguments.  An argument that is not a
+	// number is the label of a state.
+	Args []string
+
+	// Next is the label of the next state, a frame number, "stop" or
+	// "loop".  If empty, the next state is the following one, or "stop"
+	// at the end of the list.
+	Next string
+}
+
+// DehackedThingDef is the source of a thing to compile.
+type DehackedThingDef struct {
+	Name string
+
+	// Number is the dehacked number of an existing thing to replace.
+	// If zero, a new DEHEXTRA thing is allocated.
+	Number int
+
+	// ID is the editor number of the thing, if any.
+	ID int
+
+	Fields []DehackedField
+	States []DehackedState
+}
+
+// DehackedWeaponDef is the source of a weapon to compile.  There are no
+// free weapon slots, so every weapon replaces an existing one.
+type DehackedWeaponDef struct {
+	Name   string
+	Number int
+	Fields []DehackedField
+	States []DehackedState
+}
+
+// DehackedSource is the source of an MBF21 patch.
+type DehackedSource struct {
+	Things  []DehackedThingDef
+	Weapons []DehackedWeaponDef
+	Strings []DehackedField
+}
+
+// First entries of each DEHEXTRA table that are free for new content.
+const (
+	dehextraFirstThing  = 151
+	dehextraFirstFrame  = 1089
+	dehextraFirstSprite = 145
+)
+
+// dehackedSpriteNames is every sprite name of MBF, in order.
+var dehackedSpriteNames = strings.Fields(`
+	TROO SHTG PUNG PISG PISF SHTF SHT2 CHGG CHGF MISG MISF SAWG PLSG PLSF
+	BFGG BFGF BLUD PUFF BAL1 BAL2 PLSS PLSE MISL BFS1 BFE1 BFE2 TFOG IFOG
+	PLAY POSS SPOS VILE FIRE FATB FBXP SKEL MANF FATT CPOS SARG HEAD BAL7
+	BOSS BOS2 SKUL SPID BSPI APLS APBX CYBR PAIN SSWV KEEN BBRN BOSF ARM1
+	ARM2 BAR1 BEXP FCAN BON1 BON2 BKEY RKEY YKEY BSKU RSKU YSKU STIM MEDI
+	SOUL PINV PSTR PINS MEGA SUIT PMAP PVIS CLIP AMMO ROCK BROK CELL CELP
+	SHEL SBOX BPAK BFUG MGUN CSAW LAUN PLAS SHOT SGN2 COLU SMT2 GOR1 POL2
+	POL5 POL4 POL3 POL1 POL6 GOR2 GOR3 GOR4 GOR5 SMIT COL1 COL2 COL3 COL4
+	CAND CBRA COL6 TRE1 TRE2 ELEC CEYE FSKU COL5 TBLU TGRN TRED SMBT SMGT
+	SMRT HDB1 HDB2 HDB3 HDB4 HDB5 HDB6 POB1 POB2 BRS1 TLMP TLP2 TNT1 DOGS
+	PLS1 PLS2 BON3 BON4 BLD2`)
+
+// Labels of thing and weapon states that set a frame field.
+var (
+	dehackedThingLabels = [][2]string{
+		{"spawn", "Initial frame"},
+		{"see", "First moving frame"},
+		{"pain", "Injury frame"},
+		{"melee", "Close attack frame"},
+		{"missile", "Far attack frame"},
+		{"death", "Death frame"},
+		{"xdeath", "Exploding death frame"},
+		{"raise", "Respawn frame"},
+	}
+	dehackedWeaponLabels = [][2]string{
+		{"deselect", "Deselect frame"},
+		{"select", "Select frame"},
+		{"ready", "Bobbing frame"},
+		{"fire", "Shooting frame"},
+		{"flash", "Firing frame"},
+	}
+)
+
+// dehackedCompiler holds the allocation state of a compilation.
+type dehackedCompiler struct {
+	patch      *DehackedPatch
+	nextThing  int
+	nextFrame  int
+	sprites    map[string]int
+	nextSprite int
+}
+
+// sprite returns the index of a sprite, allocating a new sprite if it
+// does not already exist.
+func (c *dehackedCompiler) sprite(name string) (int, error) {
+	name = strings.ToUpper(name)
+	if len(name) != 4 {
+		return 0, fmt.Errorf("sprite name %q must be four characters", name)
+	}
+
+	if index, ok := c.sprites[name]; ok {
+		return index, nil
+	}
+
+	if c.nextSprite >= dehackedMBF21Limits.sprites {
+		return 0, fmt.Errorf("too many sprites")
+	}
+
+	// DEHEXTRA sprites are named SP00 through SP99 until renamed.
+	index := c.nextSprite
+	c.nextSprite++
+	c.sprites[name] = index
+	c.patch.SpriteNames = append(c.patch.SpriteNames, DehackedField{
+		Key:   fmt.Sprintf("SP%02d", index-dehextraFirstSprite),
+		Value: name,
+	})
+
+	return index, nil
+}
+
+// states allocates and compiles a list of states, returning the frame
+// of each label.
+func (c *dehackedCompiler) states(owner string, states []DehackedState) (map[string]int, error) {
+	if c.nextFrame+len(states) > dehackedMBF21Limits.frames {
+		return nil, fmt.Errorf("%s: too many frames", owner)
+	}
+
+	first := c.nextFrame
+	c.nextFrame += len(states)
+
+	labels := map[string]int{}
+	for i, state := range states {
+		if state.Label == "" {
+			continue
+		}
+
+		label := strings.ToLower(state.Label)
+		if _, ok := labels[label]; ok {
+			return nil, fmt.Errorf("%s: duplicate label %s", owner, state.Label)
+		}
+		labels[label] = first + i
+	}
+
+	// Resolves a label or frame number.
+	resolve := func(target string) (int, error) {
+		if number, err := strconv.Atoi(target); err == nil {
+			return number, nil
+		}
+
+		frame, ok := labels[strings.ToLower(target)]
+		if !ok {
+			return 0, fmt.Errorf("%s: unknown label %s", owner, target)
+		}
+		return frame, nil
+	}
+
+	loop := first
+	for i, state := range states {
+		frame := first + i
+		if state.Label != "" {
+			loop = frame
+		}
+
+		sprite, err := c.sprite(state.Sprite)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", owner, err.Error())
+		}
+
+		if state.Frame < 0 || state.Frame > 28 {
+			return nil, fmt.Errorf("%s: frame %d of sprite %s is out of range", owner, state.Frame, state.Sprite)
+		}
+
+		subnumber := state.Frame
+		if state.Bright {
+			subnumber |= 0x8000
+		}
+
+		var next int
+		switch strings.ToLower(state.Next) {
+		case "":
+			if i+1 < len(states) {
+				next = frame + 1
+			}
+		case "stop":
+			next = 0
+		case "loop":
+			next = loop
+		default:
+			next, err = resolve(state.Next)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		block := DehackedBlock{Index: frame}
+		block.Set("Sprite number", strconv.Itoa(sprite))
+		block.Set("Sprite subnumber", strconv.Itoa(subnumber))
+		block.Set("Duration", strconv.Itoa(state.Tics))
+		block.Set("Next frame", strconv.Itoa(next))
+
+		if len(state.Args) > 8 {
+			return nil, fmt.Errorf("%s: too many arguments to %s", owner, state.Action)
+		}
+		for a, arg := range state.Args {
+			value, err := resolve(arg)
+			if err != nil {
+				return nil, err
+			}
+			block.Set(fmt.Sprintf("Args%d", a+1), strconv.Itoa(value))
+		}
+		c.patch.Frames = append(c.patch.Frames, block)
+
+		if state.Action != "" {
+			action := strings.TrimPrefix(state.Action, "A_")
+			if !dehackedCodePointers[strings.ToUpper(action)] {
+				return nil, fmt.Errorf("%s: unknown action %s", owner, state.Action)
+			}
+			c.patch.CodePointers = append(c.patch.CodePointers, DehackedCodePointer{Frame: frame, Name: action})
+		}
+	}
+
+	return labels, nil
+}
+
+// CompileDehacked compiles things and weapons into an MBF21 patch.
+// New frames, sprites and things are allocated from the DEHEXTRA free
+// ranges, and state labels are resolved into frame numbers.
+func CompileDehacked(source *DehackedSource) (*DehackedPatch, error) {
+	c := dehackedCompiler{
+		patch: &DehackedPatch{
+			DoomVersion: 2021,
+			PatchFormat: 6,
+			Strings:     source.Strings,
+		},
+		nextThing:  dehextraFirstThing,
+		nextFrame:  dehextraFirstFrame,
+		sprites:    map[string]int{},
+		nextSprite: dehextraFirstSprite,
+	}
+
+	for i, name := range dehackedSpriteNames {
+		c.sprites[name] = i
+	}
+
+	for _, def := range source.Things {
+		number := def.Number
+		if number == 0 {
+			if c.nextThing > dehackedMBF21Limits.things {
+				return nil, fmt.Errorf("thing %s: too many things", def.Name)
+			}
+			number = c.nextThing
+			c.nextThing++
+		} else if number < 1 || number > dehackedMBF21Limits.things {
+			return nil, fmt.Errorf("thing %s: invalid thing %d", def.Name, number)
+		}
+
+		owner := fmt.Sprintf("thing %s", def.Name)
+		labels, err := c.states(owner, def.States)
+		if err != nil {
+			return nil, err
+		}
+
+		block := DehackedBlock{Index: number, Name: def.Name}
+		if def.ID != 0 {
+			block.Set("ID #", strconv.Itoa(def.ID))
+		}
+		for _, label := range dehackedThingLabels {
+			if frame, ok := labels[label[0]]; ok {
+				block.Set(label[1], strconv.Itoa(frame))
+			}
+		}
+		for _, field := range def.Fields {
+			block.Set(field.Key, field.Value)
+		}
+		c.patch.Things = append(c.patch.Things, block)
+	}
+
+	for _, def := range source.Weapons {
+		if def.Number < 0 || def.Number > 8 {
+			return nil, fmt.Errorf("weapon %s: invalid weapon %d", def.Name, def.Number)
+		}
+
+		owner := fmt.Sprintf("weapon %s", def.Name)
+		labels, err := c.states(owner, def.States)
+		if err != nil {
+			return nil, err
+		}
+
+		block := DehackedBlock{Index: def.Number, Name: def.Name}
+		for _, label := range dehackedWeaponLabels {
+			if frame, ok := labels[label[0]]; ok {
+				block.Set(label[1], strconv.Itoa(frame))
+			}
+		}
+		for _, field := range def.Fields {
+			block.Set(field.Key, field.Value)
+		}
+		c.patch.Weapons = append(c.patch.Weapons, block)
+	}
+
+	return c.patch, nil
+}
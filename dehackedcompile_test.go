@@ -0,0 +1,124 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+)
+
+func testDehackedSource() *DehackedSource {
+	return &DehackedSource{
+		Things: []DehackedThingDef{{
+			Name:   "Lamp",
+			ID:     20000,
+			Fields: []DehackedField{{"Hit points", "50"}},
+			States: []DehackedState{
+				{Label: "spawn", Sprite: "LAMP", Frame: 0, Bright: true, Tics: 4},
+				{Sprite: "LAMP", Frame: 1, Bright: true, Tics: 4, Action: "A_RandomJump", Args: []string{"death", "128"}, Next: "loop"},
+				{Label: "death", Sprite: "BEXP", Frame: 2, Tics: -1},
+			},
+		}},
+		Weapons: []DehackedWeaponDef{{
+			Name:   "Pistol",
+			Number: 1,
+			States: []DehackedState{
+				{Label: "ready", Sprite: "PISG", Tics: 1, Action: "WeaponReady", Next: "ready"},
+			},
+		}},
+	}
+}
+
+// Things, frames and sprites are allocated from the DEHEXTRA ranges
+func TestCompileDehacked(t *testing.T) {
+	if len(dehackedSpriteNames) != dehextraFirstSprite {
+		t.Fatalf("expected %d sprite names, got %d", dehextraFirstSprite, len(dehackedSpriteNames))
+	}
+
+	patch, err := CompileDehacked(testDehackedSource())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(patch.Things) != 1 || patch.Things[0].Index != dehextraFirstThing {
+		t.Fatal("thing not allocated")
+	}
+
+	thing := &patch.Things[0]
+	if value, _ := thing.Get("Initial frame"); value != "1089" {
+		t.Error("incorrect spawn frame")
+	}
+	if value, _ := thing.Get("Death frame"); value != "1091" {
+		t.Error("incorrect death frame")
+	}
+
+	if len(patch.SpriteNames) != 1 || patch.SpriteNames[0] != (DehackedField{"SP00", "LAMP"}) {
+		t.Errorf("incorrect sprite names: %v", patch.SpriteNames)
+	}
+
+	frame := &patch.Frames[1]
+	expected := map[string]string{
+		"Sprite number":    "145",
+		"Sprite subnumber": "32769",
+		"Next frame":       "1089",
+		"Args1":            "1091",
+		"Args2":            "128",
+	}
+	for key, value := range expected {
+		if actual, _ := frame.Get(key); actual != value {
+			t.Errorf("expected %s = %s, got %s", key, value, actual)
+		}
+	}
+
+	if value, _ := patch.Frames[2].Get("Sprite number"); value != "58" {
+		t.Error("existing sprite not reused")
+	}
+	if value, _ := patch.Frames[2].Get("Next frame"); value != "0" {
+		t.Error("last state does not stop")
+	}
+
+	if value, _ := patch.Weapons[0].Get("Bobbing frame"); value != "1092" {
+		t.Error("incorrect weapon frame")
+	}
+
+	if len(patch.CodePointers) != 2 || patch.CodePointers[0].Name != "RandomJump" {
+		t.Errorf("incorrect code pointers: %v", patch.CodePointers)
+	}
+
+	if errs := patch.Validate(); len(errs) != 0 {
+		t.Errorf("compiled patch is invalid: %v", errs)
+	}
+}
+
+// Bad labels and actions are errors
+func TestCompileDehackedErrors(t *testing.T) {
+	source := testDehackedSource()
+	source.Things[0].States[1].Next = "nowhere"
+	_, err := CompileDehacked(source)
+	if err == nil || err.Error() != "thing Lamp: unknown label nowhere" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	source = testDehackedSource()
+	source.Things[0].States[0].Action = "A_Teleport"
+	_, err = CompileDehacked(source)
+	if err == nil || err.Error() != "thing Lamp: unknown action A_Teleport" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
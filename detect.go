@@ -0,0 +1,347 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+)
+
+// LumpType designates what a lump holds, as detected by DetectType.
+type LumpType int
+
+// The types of lumps DetectType tells apart.  LumpMapHeader is the lump
+// naming a map and LumpMapData any lump of a map following it.
+const (
+	LumpUnknown LumpType = iota
+	LumpMarker
+	LumpMapHeader
+	LumpMapData
+	LumpPicture
+	LumpFlat
+	LumpPNG
+	LumpDMXSound
+	LumpPCSpeaker
+	LumpWAV
+	LumpMUS
+	LumpMIDI
+	LumpOgg
+	LumpFLAC
+	LumpMP3
+	LumpPlaypal
+	LumpColormap
+	LumpTextures
+	LumpPNames
+	LumpAnimated
+	LumpSwitches
+	LumpGENMIDI
+	LumpEndoom
+	LumpBehavior
+	LumpDehacked
+	LumpText
+	LumpWAD
+	LumpZip
+)
+
+var lumpTypeNames = []string{
+	"unknown", "marker", "map", "maplump", "picture", "flat", "png",
+	"dmx", "pcspeaker", "wav", "mus", "midi", "ogg", "flac", "mp3",
+	"playpal", "colormap", "textures", "pnames", "animated", "switches",
+	"genmidi", "endoom", "behavior", "dehacked", "text", "wad", "zip",
+}
+
+// String returns the short name of a lump type, as used in manifests
+// and by lumps:type.
+func (t LumpType) String() string {
+	if int(t) < 0 || int(t) >= len(lumpTypeNames) {
+		return lumpTypeNames[LumpUnknown]
+	}
+	return lumpTypeNames[t]
+}
+
+// DetectContext holds what is known about where a lump is in its
+// directory, which some types can only be told apart by.
+type DetectContext struct {
+	// Name of the namespace the lump is in, or an empty string.
+	Namespace string
+
+	// MapHeader is true for the header lump naming a map, and MapData
+	// is true for the map lumps following it.
+	MapHeader bool
+	MapData   bool
+
+	// Game is the game the directory is for, which is Doom if nil.
+	Game *GameProfile
+}
+
+// LumpContexts returns the context of every lump of a directory for the
+// passed game, which is Doom if nil.
+func LumpContexts(dir Directory, game *GameProfile) []DetectContext {
+	contexts := make([]DetectContext, len(dir))
+	for i, namespace := range LumpNamespaces(dir) {
+		contexts[i].Namespace = namespace
+		contexts[i].Game = game
+	}
+
+	for _, m := range FindMaps(dir) {
+		contexts[m.Index].MapHeader = true
+		for i := m.Index + 1; i < m.Index+m.Count; i++ {
+			contexts[i].MapData = true
+		}
+	}
+
+	return contexts
+}
+
+// Magic numbers at the start of lump data, checked in order.
+var lumpMagic = []struct {
+	magic string
+	kind  LumpType
+}{
+	{"\x89PNG\r\n\x1a\n", LumpPNG},
+	{"MUS\x1a", LumpMUS},
+	{"MThd", LumpMIDI},
+	{"OggS", LumpOgg},
+	{"fLaC", LumpFLAC},
+	{"ID3", LumpMP3},
+	{genmidiHeader, LumpGENMIDI},
+	{"IWAD", LumpWAD},
+	{"PWAD", LumpWAD},
+	{"PK\x03\x04", LumpZip},
+	{"PK\x05\x06", LumpZip},
+}
+
+// Lumps that vanilla engines look up by name, and the check their data
+// must pass to be taken as that type.
+var namedLumps = map[string]struct {
+	kind  LumpType
+	check func([]byte) bool
+}{
+	"PLAYPAL":  {LumpPlaypal, func(data []byte) bool { return len(data) > 0 && len(data)%768 == 0 }},
+	"COLORMAP": {LumpColormap, func(data []byte) bool { return len(data) > 0 && len(data)%256 == 0 }},
+	"TEXTURE1": {LumpTextures, isTextureLump},
+	"TEXTURE2": {LumpTextures, isTextureLump},
+	"PNAMES": {LumpPNames, func(data []byte) bool {
+		pnames, err := DecodePNames(data)
+		return err == nil && len(data) == 4+len(pnames)*8
+	}},
+	"ANIMATED": {LumpAnimated, func(data []byte) bool { _, err := DecodeAnimated(data); return err == nil }},
+	"SWITCHES": {LumpSwitches, func(data []byte) bool { _, err := DecodeSwitches(data); return err == nil }},
+	"DEHACKED": {LumpDehacked, isText},
+}
+
+// DetectType detects what a lump holds from its name, its data and its
+// place in the directory.  Magic numbers are trusted first, then lumps
+// known by name and structural checks of headerless formats, and
+// finally text.  Data that fails every check is LumpUnknown.
+func DetectType(name string, data []byte, context DetectContext) LumpType {
+	name = strings.ToUpper(name)
+
+	game := context.Game
+	if game == nil {
+		game = GameDoom
+	}
+
+	if context.MapHeader {
+		return LumpMapHeader
+	} else if context.MapData {
+		if name == "BEHAVIOR" && isBehavior(data) {
+			return LumpBehavior
+		}
+		return LumpMapData
+	}
+
+	if len(data) == 0 {
+		if IsMarker(name) {
+			return LumpMarker
+		}
+		return LumpUnknown
+	}
+
+	for _, magic := range lumpMagic {
+		if bytes.HasPrefix(data, []byte(magic.magic)) {
+			return magic.kind
+		}
+	}
+
+	if len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WAVE" {
+		return LumpWAV
+	} else if isBehavior(data) {
+		return LumpBehavior
+	}
+
+	if named, ok := namedLumps[name]; ok && named.check(data) {
+		return named.kind
+	} else if name == game.EndText && isEndoom(data) {
+		return LumpEndoom
+	}
+
+	switch context.Namespace {
+	case "flats":
+		if isFlat(data, game) {
+			return LumpFlat
+		}
+	case "colormaps":
+		if len(data)%256 == 0 {
+			return LumpColormap
+		}
+	case "voices":
+		if game.Voices && isDMXSound(data) {
+			return LumpDMXSound
+		}
+	}
+
+	// Sounds are only told apart from other data by their name
+	if strings.HasPrefix(name, "DS") && isDMXSound(data) {
+		return LumpDMXSound
+	} else if strings.HasPrefix(name, "DP") && isPCSpeaker(data) {
+		return LumpPCSpeaker
+	}
+
+	if isPicture(data) {
+		return LumpPicture
+	} else if isText(data) {
+		return LumpText
+	}
+
+	return LumpUnknown
+}
+
+// Returns true if the data is the size of a square flat, or of a tall
+// flat if the game has them.
+func isFlat(data []byte, game *GameProfile) bool {
+	switch len(data) {
+	case 64 * 64, 128 * 128, 256 * 256:
+		return true
+	case 64 * 128:
+		return game.TallFlats
+	}
+	return false
+}
+
+// Returns true if the data is an ACS object file.
+func isBehavior(data []byte) bool {
+	if len(data) < 8 || string(data[:3]) != "ACS" {
+		return false
+	}
+
+	switch data[3] {
+	case 0, 'E', 'e':
+		return true
+	}
+	return false
+}
+
+// Returns true if the data is a DMX sound.
+func isDMXSound(data []byte) bool {
+	_, err := DecodeDMX(data)
+	return err == nil
+}
+
+// Returns true if the data is a PC speaker sound with nothing after its
+// tones.
+func isPCSpeaker(data []byte) bool {
+	tones, err := DecodePCSpeaker(data)
+	return err == nil && len(data) == 4+len(tones)
+}
+
+// Returns true if the data is an 80x25 text screen.
+func isEndoom(data []byte) bool {
+	_, err := DecodeEndoom(data)
+	return err == nil
+}
+
+// Returns true if the data is a TEXTURE1 or TEXTURE2 lump, whose
+// texture offsets all point inside of it.
+func isTextureLump(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+
+	count := int64(int32(binary.LittleEndian.Uint32(data)))
+	if count < 0 || 4+count*4 > int64(len(data)) {
+		return false
+	}
+
+	for i := int64(0); i < count; i++ {
+		offset := int64(binary.LittleEndian.Uint32(data[4+i*4:]))
+		if offset < 4+count*4 || offset >= int64(len(data)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns true if the data is a picture in Doom's column format.  The
+// header must be sane and every column must be a list of posts ending
+// inside the data.
+func isPicture(data []byte) bool {
+	if len(data) < 8 {
+		return false
+	}
+
+	width := int(int16(binary.LittleEndian.Uint16(data)))
+	height := int(int16(binary.LittleEndian.Uint16(data[2:])))
+	if width <= 0 || width > 4096 || height <= 0 || height > 4096 || 8+width*4 > len(data) {
+		return false
+	}
+
+	for x := 0; x < width; x++ {
+		offset := int(binary.LittleEndian.Uint32(data[8+x*4:]))
+		if offset < 8+width*4 || offset >= len(data) {
+			return false
+		}
+
+		// Posts are a top row, a length, a padding byte, the pixels and
+		// another padding byte, until a top row of 255.
+		for {
+			if offset >= len(data) {
+				return false
+			} else if data[offset] == 0xFF {
+				break
+			} else if offset+1 >= len(data) {
+				return false
+			}
+
+			offset += int(data[offset+1]) + 4
+		}
+	}
+
+	return true
+}
+
+// Returns true if the data is text, which must be valid UTF-8 without
+// control characters other than whitespace and the DOS end of file.
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+
+	for _, c := range data {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != 0x1A {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,104 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+)
+
+// Types are detected from magic numbers, names, structure and context
+func TestDetectType(t *testing.T) {
+	// A 1x1 picture with a single post
+	picture := []byte{1, 0, 1, 0, 0, 0, 0, 0, 12, 0, 0, 0, 0, 1, 0, 42, 0, 0xFF}
+	broken := append([]byte{}, picture[:len(picture)-1]...)
+
+	textures := []byte{1, 0, 0, 0, 8, 0, 0, 0, 'W', 'A', 'L', 'L'}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		context  DetectContext
+		expected LumpType
+	}{
+		{"MAP01", nil, DetectContext{MapHeader: true}, LumpMapHeader},
+		{"THINGS", []byte{1, 2}, DetectContext{MapData: true}, LumpMapData},
+		{"BEHAVIOR", []byte("ACS\x00\x08\x00\x00\x00"), DetectContext{MapData: true}, LumpBehavior},
+		{"S_START", nil, DetectContext{}, LumpMarker},
+		{"EMPTY", nil, DetectContext{}, LumpUnknown},
+		{"TITLEPIC", []byte("\x89PNG\r\n\x1a\n...."), DetectContext{}, LumpPNG},
+		{"D_RUNNIN", []byte("MUS\x1a...."), DetectContext{}, LumpMUS},
+		{"D_RUNNIN", []byte("MThd...."), DetectContext{}, LumpMIDI},
+		{"D_RUNNIN", []byte("OggS...."), DetectContext{}, LumpOgg},
+		{"DSPISTOL", []byte("RIFF\x00\x00\x00\x00WAVE"), DetectContext{}, LumpWAV},
+		{"DSPISTOL", convertTestDMX, DetectContext{}, LumpDMXSound},
+		{"PISTOL", convertTestDMX, DetectContext{}, LumpUnknown},
+		{"DPPISTOL", []byte{0, 0, 2, 0, 10, 20}, DetectContext{}, LumpPCSpeaker},
+		{"PLAYPAL", make([]byte, 768*14), DetectContext{}, LumpPlaypal},
+		{"PLAYPAL", make([]byte, 100), DetectContext{}, LumpUnknown},
+		{"COLORMAP", make([]byte, 256*34), DetectContext{}, LumpColormap},
+		{"TEXTURE1", textures, DetectContext{}, LumpTextures},
+		{"PNAMES", []byte("\x01\x00\x00\x00WALL\x00\x00\x00\x00"), DetectContext{}, LumpPNames},
+		{"GENMIDI", []byte("#OPL_II#...."), DetectContext{}, LumpGENMIDI},
+		{"ENDOOM", make([]byte, 4000), DetectContext{}, LumpEndoom},
+		{"ENDTEXT", make([]byte, 4000), DetectContext{Game: GameHeretic}, LumpEndoom},
+		{"ENDTEXT", make([]byte, 4000), DetectContext{}, LumpUnknown},
+		{"DEHACKED", []byte("Patch File for DeHackEd v3.0\n"), DetectContext{}, LumpDehacked},
+		{"FLOOR0_1", make([]byte, 4096), DetectContext{Namespace: "flats"}, LumpFlat},
+		{"FLOOR0_1", make([]byte, 4096), DetectContext{}, LumpUnknown},
+		{"FLTWAWA1", make([]byte, 8192), DetectContext{Namespace: "flats", Game: GameHeretic}, LumpFlat},
+		{"FLTWAWA1", make([]byte, 8192), DetectContext{Namespace: "flats"}, LumpUnknown},
+		{"VOC1", convertTestDMX, DetectContext{Namespace: "voices", Game: GameStrife}, LumpDMXSound},
+		{"VOC1", convertTestDMX, DetectContext{Namespace: "voices"}, LumpUnknown},
+		{"TROOA1", picture, DetectContext{Namespace: "sprites"}, LumpPicture},
+		{"TROOA1", broken, DetectContext{Namespace: "sprites"}, LumpUnknown},
+		{"MAPINFO", []byte("map MAP01 \"Entryway\"\r\n{\n}\n"), DetectContext{}, LumpText},
+		{"MAPINFO", []byte("map\x00"), DetectContext{}, LumpUnknown},
+		{"EMBEDDED", []byte("PWAD\x00\x00\x00\x00\x0c\x00\x00\x00"), DetectContext{}, LumpWAD},
+	}
+
+	for _, test := range tests {
+		kind := DetectType(test.name, test.data, test.context)
+		if kind != test.expected {
+			t.Errorf("%s %q detected as %s instead of %s", test.name, test.data, kind, test.expected)
+		}
+	}
+}
+
+// Contexts come from namespaces and maps
+func TestLumpContexts(t *testing.T) {
+	dir := namedDirectory("MAP01", "THINGS", "F_START", "FLOOR0_1", "F_END")
+
+	expected := []DetectContext{
+		{MapHeader: true, Game: GameHexen},
+		{MapData: true, Game: GameHexen},
+		{Game: GameHexen},
+		{Namespace: "flats", Game: GameHexen},
+		{Game: GameHexen},
+	}
+	for i, context := range LumpContexts(dir, GameHexen) {
+		if context != expected[i] {
+			t.Errorf("incorrect context %d %v", i+1, context)
+		}
+	}
+
+	if LumpType(-1).String() != "unknown" || LumpFlat.String() != "flat" {
+		t.Error("incorrect type names")
+	}
+}
@@ -0,0 +1,369 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// EndoomWidth is the number of columns of an ENDOOM screen.
+	EndoomWidth = 80
+
+	// EndoomHeight is the number of rows of an ENDOOM screen.
+	EndoomHeight = 25
+)
+
+// EndoomCell is a single character of an ENDOOM screen.  Attr is a VGA
+// text attribute, with the foreground color in the low four bits, the
+// background color in the next three and blinking in the high bit.
+type EndoomCell struct {
+	Char byte
+	Attr byte
+}
+
+// Endoom is an ENDOOM text screen, stored as rows of cells.
+type Endoom [EndoomHeight][EndoomWidth]EndoomCell
+
+// vgaPalette holds the 16 colors of the VGA text mode.
+var vgaPalette = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xFF}, color.RGBA{0x00, 0x00, 0xAA, 0xFF},
+	color.RGBA{0x00, 0xAA, 0x00, 0xFF}, color.RGBA{0x00, 0xAA, 0xAA, 0xFF},
+	color.RGBA{0xAA, 0x00, 0x00, 0xFF}, color.RGBA{0xAA, 0x00, 0xAA, 0xFF},
+	color.RGBA{0xAA, 0x55, 0x00, 0xFF}, color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+	color.RGBA{0x55, 0x55, 0x55, 0xFF}, color.RGBA{0x55, 0x55, 0xFF, 0xFF},
+	color.RGBA{0x55, 0xFF, 0x55, 0xFF}, color.RGBA{0x55, 0xFF, 0xFF, 0xFF},
+	color.RGBA{0xFF, 0x55, 0x55, 0xFF}, color.RGBA{0xFF, 0x55, 0xFF, 0xFF},
+	color.RGBA{0xFF, 0xFF, 0x55, 0xFF}, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+}
+
+// ANSI colors are ordered red, green, blue while VGA colors are ordered
+// blue, green, red, so the mapping is the same in both directions.
+var ansiColors = [8]byte{0, 4, 2, 6, 1, 5, 3, 7}
+
+// DecodeEndoom decodes an ENDOOM lump.
+func DecodeEndoom(data []byte) (*Endoom, error) {
+	if len(data) != EndoomWidth*EndoomHeight*2 {
+		return nil, fmt.Errorf("ENDOOM must be %d bytes, got %d", EndoomWidth*EndoomHeight*2, len(data))
+	}
+
+	screen := &Endoom{}
+	for row := range screen {
+		for col := range screen[row] {
+			offset := (row*EndoomWidth + col) * 2
+			screen[row][col] = EndoomCell{data[offset], data[offset+1]}
+		}
+	}
+
+	return screen, nil
+}
+
+// EncodeEndoom encodes an ENDOOM lump.
+func EncodeEndoom(screen *Endoom) []byte {
+	data := make([]byte, 0, EndoomWidth*EndoomHeight*2)
+	for row := range screen {
+		for _, cell := range screen[row] {
+			data = append(data, cell.Char, cell.Attr)
+		}
+	}
+
+	return data
+}
+
+// ansiAttribute returns the escape sequence that selects an attribute.
+func ansiAttribute(attr byte) string {
+	codes := []string{"0"}
+	if attr&0x08 != 0 {
+		codes = append(codes, "1")
+	}
+	if attr&0x80 != 0 {
+		codes = append(codes, "5")
+	}
+	codes = append(codes,
+		strconv.Itoa(30+int(ansiColors[attr&0x07])),
+		strconv.Itoa(40+int(ansiColors[(attr>>4)&0x07])))
+
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+// EncodeANSI converts a screen to UTF-8 text with ANSI escape sequences
+// for colors, which displays as the screen on modern terminals.  Every
+// row ends with a newline.  NUL characters are written as spaces.
+func EncodeANSI(screen *Endoom) []byte {
+	var buffer bytes.Buffer
+	for row := range screen {
+		attr := -1
+		for _, cell := range screen[row] {
+			if int(cell.Attr) != attr {
+				buffer.WriteString(ansiAttribute(cell.Attr))
+				attr = int(cell.Attr)
+			}
+
+			if cell.Char == 0 {
+				buffer.WriteByte(' ')
+			} else {
+				buffer.WriteRune(cp437Runes[cell.Char])
+			}
+		}
+		buffer.WriteString("\x1b[0m\n")
+	}
+
+	return buffer.Bytes()
+}
+
+// clampInt limits a value to a range.
+func clampInt(value int, low int, high int) int {
+	if value < low {
+		return low
+	} else if value > high {
+		return high
+	}
+	return value
+}
+
+// DecodeANSI converts text with ANSI escape sequences to a screen.  The
+// text may be UTF-8, or code page 437 as written by DOS-era ANSI
+// editors.  Colors, cursor movement and clearing sequences are
+// understood, lines longer than the screen wrap, and text after an EOF
+// character, such as a SAUCE record, is ignored.  Cells that are not
+// written hold light gray spaces.
+func DecodeANSI(data []byte) (*Endoom, error) {
+	cp437 := map[rune]byte{}
+	for i, r := range cp437Runes {
+		cp437[r] = byte(i)
+	}
+
+	// Control characters are only interpreted as such when they are
+	// written as ASCII, as the glyphs of code page 437 share their
+	// values.
+	var text []rune
+	if utf8.Valid(data) {
+		text = []rune(string(data))
+	} else {
+		text = make([]rune, len(data))
+		for i, b := range data {
+			if b < 0x20 && b != 0x08 && b != 0x09 && b != 0x0A && b != 0x0D && b != 0x1A && b != 0x1B {
+				text[i] = cp437Runes[b]
+			} else if b < 0x80 {
+				text[i] = rune(b)
+			} else {
+				text[i] = cp437Runes[b]
+			}
+		}
+	}
+
+	screen := &Endoom{}
+	for row := range screen {
+		for col := range screen[row] {
+			screen[row][col] = EndoomCell{' ', 0x07}
+		}
+	}
+
+	row, col, line := 0, 0, 1
+	savedRow, savedCol := 0, 0
+	fg, bg, bold, blink := byte(7), byte(0), false, false
+
+	for i := 0; i < len(text); i++ {
+		r := text[i]
+		switch r {
+		case 0x1A:
+			return screen, nil
+		case '\n':
+			row, col = row+1, 0
+			line++
+			continue
+		case '\r':
+			col = 0
+			continue
+		case '\t':
+			col = (col/8 + 1) * 8
+			if col > EndoomWidth {
+				col = EndoomWidth
+			}
+			continue
+		case 0x08:
+			if col > 0 {
+				col--
+			}
+			continue
+		case 0x1B:
+			if i+1 >= len(text) || text[i+1] != '[' {
+				return nil, fmt.Errorf("line %d: escape without [", line)
+			}
+
+			// Parameters run up to the final character of the sequence
+			end := i + 2
+			for end < len(text) && (text[end] < 0x40 || text[end] > 0x7E) {
+				end++
+			}
+			if end >= len(text) {
+				return nil, fmt.Errorf("line %d: unterminated escape sequence", line)
+			}
+
+			params := []int{}
+			for _, param := range strings.Split(strings.TrimLeft(string(text[i+2:end]), "?"), ";") {
+				value, err := strconv.Atoi(param)
+				if err != nil && param != "" {
+					return nil, fmt.Errorf("line %d: invalid escape sequence parameter %q", line, param)
+				}
+				params = append(params, value)
+			}
+
+			// Returns a parameter, where zero means the default of one.
+			count := func(index int) int {
+				if index < len(params) && params[index] > 0 {
+					return params[index]
+				}
+				return 1
+			}
+
+			switch text[end] {
+			case 'm':
+				for _, param := range params {
+					switch {
+					case param == 0:
+						fg, bg, bold, blink = 7, 0, false, false
+					case param == 1:
+						bold = true
+					case param == 22:
+						bold = false
+					case param == 5 || param == 6:
+						blink = true
+					case param == 25:
+						blink = false
+					case param >= 30 && param <= 37:
+						fg = ansiColors[param-30]
+					case param == 39:
+						fg = 7
+					case param >= 40 && param <= 47:
+						bg = ansiColors[param-40]
+					case param == 49:
+						bg = 0
+					case param >= 90 && param <= 97:
+						fg, bold = ansiColors[param-90], true
+					case param >= 100 && param <= 107:
+						// Bright backgrounds share the blink bit
+						bg, blink = ansiColors[param-100], true
+					}
+				}
+			case 'H', 'f':
+				row, col = count(0)-1, count(1)-1
+			case 'A':
+				row -= count(0)
+			case 'B':
+				row += count(0)
+			case 'C':
+				col += count(0)
+			case 'D':
+				col -= count(0)
+			case 'J':
+				if params[0] == 2 {
+					for y := range screen {
+						for x := range screen[y] {
+							screen[y][x] = EndoomCell{' ', 0x07}
+						}
+					}
+					row, col = 0, 0
+				}
+			case 'K':
+				if row < EndoomHeight {
+					for x := col; x < EndoomWidth; x++ {
+						screen[row][x] = EndoomCell{' ', 0x07}
+					}
+				}
+			case 's':
+				savedRow, savedCol = row, col
+			case 'u':
+				row, col = savedRow, savedCol
+			}
+
+			// Cursor movement stays on the screen, but the cursor may
+			// be left just past the end of a line by text.
+			if text[end] != 'm' {
+				row = clampInt(row, 0, EndoomHeight-1)
+				col = clampInt(col, 0, EndoomWidth-1)
+			}
+			i = end
+			continue
+		}
+
+		if r < 0x20 {
+			continue
+		}
+
+		char, ok := cp437[r]
+		if !ok {
+			return nil, fmt.Errorf("line %d: %q is not in code page 437", line, r)
+		}
+
+		// A full line wraps only once something is written past it,
+		// so that a newline right after it does not skip a row.
+		if col >= EndoomWidth {
+			row, col = row+1, 0
+		}
+		if row >= EndoomHeight {
+			return nil, fmt.Errorf("line %d: text does not fit in %d rows", line, EndoomHeight)
+		}
+
+		attr := fg | bg<<4
+		if bold {
+			attr |= 0x08
+		}
+		if blink {
+			attr |= 0x80
+		}
+		screen[row][col] = EndoomCell{char, attr}
+		col++
+	}
+
+	return screen, nil
+}
+
+// RenderEndoom draws a screen as it appears in the VGA text mode, using
+// 8x16 character cells for a 640x400 image.  Blinking characters are
+// drawn as visible.
+func RenderEndoom(screen *Endoom) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, EndoomWidth*8, EndoomHeight*16), vgaPalette)
+
+	for row := range screen {
+		for col, cell := range screen[row] {
+			glyph := &cp437Font[cell.Char]
+			fg, bg := cell.Attr&0x0F, (cell.Attr>>4)&0x07
+			for y := 0; y < 16; y++ {
+				offset := img.PixOffset(col*8, row*16+y)
+				for x := 0; x < 8; x++ {
+					if glyph[y]&(0x80>>uint(x)) != 0 {
+						img.Pix[offset+x] = fg
+					} else {
+						img.Pix[offset+x] = bg
+					}
+				}
+			}
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,139 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Builds a screen of light gray spaces with the passed text on the
+// first row.
+func testEndoom(text string, attr byte) *Endoom {
+	screen := &Endoom{}
+	for row := range screen {
+		for col := range screen[row] {
+			screen[row][col] = EndoomCell{' ', 0x07}
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		screen[0][i] = EndoomCell{text[i], attr}
+	}
+
+	return screen
+}
+
+// ENDOOM lumps decode and encode without changes
+func TestEndoom(t *testing.T) {
+	screen := testEndoom("DOOM\xDB", 0x4E)
+	data := EncodeEndoom(screen)
+	if len(data) != 4000 || !bytes.Equal(data[:4], []byte{'D', 0x4E, 'O', 0x4E}) {
+		t.Fatalf("incorrect ENDOOM %v", data[:8])
+	}
+
+	decoded, err := DecodeEndoom(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *decoded != *screen {
+		t.Error("decoded screen differs")
+	}
+
+	_, err = DecodeEndoom(data[:100])
+	if err == nil || err.Error() != "ENDOOM must be 4000 bytes, got 100" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// Screens convert to UTF-8 ANSI text and back
+func TestEndoomANSI(t *testing.T) {
+	screen := testEndoom("Thanks \xDB\xB0 \x01", 0x9E)
+	screen[24][79] = EndoomCell{'!', 0x07}
+
+	text := EncodeANSI(screen)
+	if !strings.HasPrefix(string(text), "\x1b[0;1;5;33;44mThanks █░ ☺\x1b[0;37;40m ") {
+		t.Errorf("incorrect ANSI %q", text[:40])
+	}
+
+	decoded, err := DecodeANSI(text)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if *decoded != *screen {
+		t.Error("decoded screen differs")
+	}
+}
+
+// ANSI text written by DOS-era editors is code page 437, and may move
+// the cursor around
+func TestDecodeANSI(t *testing.T) {
+	text := "\x1b[2J\x1b[1;31mA\x1b[0m\xB1\r\n\x1b[3;79HXYZ\x1b[1;1H\x1b[CB\x1a\x1b[0mSAUCE"
+
+	screen, err := DecodeANSI([]byte(text))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := map[[2]int]EndoomCell{
+		{0, 0}:  {'A', 0x0C},
+		{0, 1}:  {'B', 0x07},
+		{2, 78}: {'X', 0x07},
+		{2, 79}: {'Y', 0x07},
+		{3, 0}:  {'Z', 0x07},
+		{1, 0}:  {' ', 0x07},
+	}
+	for pos, cell := range expected {
+		if screen[pos[0]][pos[1]] != cell {
+			t.Errorf("cell %v: expected %v, got %v", pos, cell, screen[pos[0]][pos[1]])
+		}
+	}
+
+	_, err = DecodeANSI([]byte(strings.Repeat("\n", 25) + "x"))
+	if err == nil || err.Error() != "line 26: text does not fit in 25 rows" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, err = DecodeANSI([]byte("€"))
+	if err == nil || err.Error() != "line 1: '€' is not in code page 437" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// Screens render with the VGA font and palette
+func TestRenderEndoom(t *testing.T) {
+	img := RenderEndoom(testEndoom("\xDB\xDF", 0x1E))
+
+	if img.Bounds().Dx() != 640 || img.Bounds().Dy() != 400 {
+		t.Fatalf("incorrect size %v", img.Bounds())
+	}
+
+	// A full block is all foreground, the upper half block is half
+	// background
+	if img.ColorIndexAt(0, 0) != 14 || img.ColorIndexAt(7, 15) != 14 {
+		t.Error("full block is not yellow")
+	}
+	if img.ColorIndexAt(8, 0) != 14 || img.ColorIndexAt(8, 15) != 1 {
+		t.Error("half block is not yellow on blue")
+	}
+	if img.ColorIndexAt(16, 0) != 0 || img.ColorIndexAt(639, 399) != 0 {
+		t.Error("background is not black")
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import "strings"
+
+// GameProfile describes the lump formats one of the id Tech 1 games
+// expects, for conversions to consult when producing lumps for it and
+// for DetectType to consult when telling lumps apart.  Sounds and music
+// are stored the same way by every game, so their codecs take no
+// profile.
+type GameProfile struct {
+	Name string
+
+	// TextureFormat is the layout of TEXTURE1 and TEXTURE2.
+	TextureFormat TextureFormat
+
+	// ACSFormat is the format BEHAVIOR lumps are compiled to.  Only
+	// Hexen itself is limited to ACS0.
+	ACSFormat ACSFormat
+
+	// PCSpeaker is true if the game plays DP* PC speaker sounds.
+	PCSpeaker bool
+
+	// EndText is the name of the text screen shown on exit, or empty
+	// if the game has none.
+	EndText string
+
+	// TallFlats is true if flats may also be 64x128, as some of the
+	// scrolling flats of Heretic and Hexen are.
+	TallFlats bool
+
+	// Voices is true if the game plays DMX sounds found between
+	// V_START and V_END as voices, whatever their names.
+	Voices bool
+}
+
+// The profiles of each game.
+var (
+	GameDoom = &GameProfile{
+		Name:          "doom",
+		TextureFormat: TextureFormatDoom,
+		ACSFormat:     ACSFormatAuto,
+		PCSpeaker:     true,
+		EndText:       "ENDOOM",
+	}
+
+	GameHeretic = &GameProfile{
+		Name:          "heretic",
+		TextureFormat: TextureFormatDoom,
+		ACSFormat:     ACSFormatAuto,
+		EndText:       "ENDTEXT",
+		TallFlats:     true,
+	}
+
+	GameHexen = &GameProfile{
+		Name:          "hexen",
+		TextureFormat: TextureFormatDoom,
+		ACSFormat:     ACSFormatACS0,
+		TallFlats:     true,
+	}
+
+	GameStrife = &GameProfile{
+		Name:          "strife",
+		TextureFormat: TextureFormatStrife,
+		ACSFormat:     ACSFormatAuto,
+		EndText:       "ENDSTRF",
+		Voices:        true,
+	}
+)
+
+// Games holds every game profile in release order.
+var Games = []*GameProfile{GameDoom, GameHeretic, GameHexen, GameStrife}
+
+// LookupGame returns the profile of a game by its case-insensitive
+// name, or false if there is no such game.
+func LookupGame(name string) (*GameProfile, bool) {
+	for _, game := range Games {
+		if strings.EqualFold(game.Name, name) {
+			return game, true
+		}
+	}
+
+	return nil, false
+}
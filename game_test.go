@@ -0,0 +1,41 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+)
+
+// Games are found by case-insensitive name
+func TestLookupGame(t *testing.T) {
+	game, ok := LookupGame("Strife")
+	if !ok || game != GameStrife || game.TextureFormat != TextureFormatStrife {
+		t.Errorf("incorrect game %+v", game)
+	}
+
+	game, ok = LookupGame("hexen")
+	if !ok || !game.TallFlats || game.ACSFormat != ACSFormatACS0 {
+		t.Errorf("incorrect game %+v", game)
+	}
+
+	if _, ok = LookupGame("quake"); ok {
+		t.Error("found unknown game")
+	}
+}
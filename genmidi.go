@@ -0,0 +1,331 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OPLOperator holds the register values of a single OPL operator.
+type OPLOperator struct {
+	Characteristic uint8 // Tremolo, vibrato, sustain, KSR and multiplier
+	Attack         uint8 // Attack and decay rates
+	Sustain        uint8 // Sustain level and release rate
+	Waveform       uint8
+	Scale          uint8 // Key scale level, in the top two bits
+	Level          uint8 // Output level, in the bottom six bits
+}
+
+// OPLVoice is a pair of OPL operators along with their feedback and
+// connection, and how far to offset the note they play.
+type OPLVoice struct {
+	Modulator      OPLOperator
+	Carrier        OPLOperator
+	Feedback       uint8
+	BaseNoteOffset int16
+}
+
+// Instrument is a single GENMIDI instrument.  Instruments only use the
+// second voice if they have the double voice flag.
+type Instrument struct {
+	Name      string
+	Flags     uint16
+	FineTune  uint8
+	FixedNote uint8
+	Voices    [2]OPLVoice
+}
+
+const (
+	// InstrumentFixedPitch causes an instrument to always play its
+	// fixed note, used for percussion.
+	InstrumentFixedPitch = 0x0001
+
+	// InstrumentDoubleVoice causes an instrument to play both voices.
+	InstrumentDoubleVoice = 0x0004
+)
+
+const (
+	// GENMIDIInstruments is the number of instruments in GENMIDI, 128
+	// melodic instruments followed by percussion.
+	GENMIDIInstruments = 175
+
+	// GENMIDIPercussionFirst is the MIDI note of the first percussion
+	// instrument.
+	GENMIDIPercussionFirst = 35
+)
+
+const genmidiHeader = "#OPL_II#"
+
+// decodeOPLVoice decodes a voice in the 16 byte GENMIDI layout.
+func decodeOPLVoice(data []byte) OPLVoice {
+	operator := func(data []byte) OPLOperator {
+		return OPLOperator{data[0], data[1], data[2], data[3], data[4], data[5]}
+	}
+
+	return OPLVoice{
+		Modulator:      operator(data[0:6]),
+		Feedback:       data[6],
+		Carrier:        operator(data[7:13]),
+		BaseNoteOffset: int16(binary.LittleEndian.Uint16(data[14:])),
+	}
+}
+
+// encodeOPLVoice encodes a voice in the 16 byte GENMIDI layout.
+func encodeOPLVoice(data []byte, voice *OPLVoice) {
+	operator := func(data []byte, op *OPLOperator) {
+		copy(data, []byte{op.Characteristic, op.Attack, op.Sustain, op.Waveform, op.Scale, op.Level})
+	}
+
+	operator(data[0:6], &voice.Modulator)
+	data[6] = voice.Feedback
+	operator(data[7:13], &voice.Carrier)
+	data[13] = 0
+	binary.LittleEndian.PutUint16(data[14:], uint16(voice.BaseNoteOffset))
+}
+
+// DecodeGENMIDI decodes GENMIDI lump data, which is the same as a DMX
+// .op2 instrument bank, into a list of instruments.
+func DecodeGENMIDI(data []byte) ([]Instrument, error) {
+	if len(data) < len(genmidiHeader) || string(data[:len(genmidiHeader)]) != genmidiHeader {
+		return nil, errors.New("invalid GENMIDI header")
+	}
+
+	if len(data) < len(genmidiHeader)+GENMIDIInstruments*(36+32) {
+		return nil, errors.New("GENMIDI is too short")
+	}
+
+	instruments := make([]Instrument, GENMIDIInstruments)
+	names := data[len(genmidiHeader)+GENMIDIInstruments*36:]
+	for i := range instruments {
+		record := data[len(genmidiHeader)+i*36:]
+		instruments[i] = Instrument{
+			Name:      nullTerminated(names[i*32 : i*32+32]),
+			Flags:     binary.LittleEndian.Uint16(record),
+			FineTune:  record[2],
+			FixedNote: record[3],
+			Voices:    [2]OPLVoice{decodeOPLVoice(record[4:20]), decodeOPLVoice(record[20:36])},
+		}
+	}
+
+	return instruments, nil
+}
+
+// EncodeGENMIDI encodes a list of instruments into GENMIDI lump data.
+func EncodeGENMIDI(instruments []Instrument) ([]byte, error) {
+	if len(instruments) != GENMIDIInstruments {
+		return nil, fmt.Errorf("GENMIDI needs %d instruments, not %d", GENMIDIInstruments, len(instruments))
+	}
+
+	data := make([]byte, len(genmidiHeader)+GENMIDIInstruments*(36+32))
+	copy(data, genmidiHeader)
+
+	names := data[len(genmidiHeader)+GENMIDIInstruments*36:]
+	for i := range instruments {
+		instrument := &instruments[i]
+		record := data[len(genmidiHeader)+i*36:]
+		binary.LittleEndian.PutUint16(record, instrument.Flags)
+		record[2] = instrument.FineTune
+		record[3] = instrument.FixedNote
+		encodeOPLVoice(record[4:20], &instrument.Voices[0])
+		encodeOPLVoice(record[20:36], &instrument.Voices[1])
+
+		// Names must stay null-terminated
+		if err := putName(names[i*32:i*32+31], instrument.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// splitOPLLevel splits a key scale level and output level register into
+// the separate fields GENMIDI uses.
+func splitOPLLevel(op *OPLOperator, value byte) {
+	op.Scale = value & 0xC0
+	op.Level = value & 0x3F
+}
+
+// DecodeTMB decodes an Apogee Sound System .tmb timbre bank into a list
+// of GENMIDI instruments.
+func DecodeTMB(data []byte) ([]Instrument, error) {
+	if len(data) != 256*13 {
+		return nil, errors.New("TMB bank must hold 256 timbres")
+	}
+
+	instruments := make([]Instrument, GENMIDIInstruments)
+	for i := range instruments {
+		// Percussion timbres are indexed by note, starting at 128.
+		index := i
+		if i >= 128 {
+			index = 128 + GENMIDIPercussionFirst + i - 128
+		}
+		timbre := data[index*13 : index*13+13]
+
+		instrument := &instruments[i]
+		instrument.FineTune = 128
+		voice := &instrument.Voices[0]
+		voice.Modulator.Characteristic = timbre[0]
+		voice.Carrier.Characteristic = timbre[1]
+		splitOPLLevel(&voice.Modulator, timbre[2])
+		splitOPLLevel(&voice.Carrier, timbre[3])
+		voice.Modulator.Attack = timbre[4]
+		voice.Carrier.Attack = timbre[5]
+		voice.Modulator.Sustain = timbre[6]
+		voice.Carrier.Sustain = timbre[7]
+		voice.Modulator.Waveform = timbre[8]
+		voice.Carrier.Waveform = timbre[9]
+		voice.Feedback = timbre[10]
+
+		// Transpose is the note to play for percussion, and an offset
+		// for everything else.
+		if i >= 128 {
+			instrument.Flags = InstrumentFixedPitch
+			instrument.FixedNote = timbre[11]
+		} else {
+			voice.BaseNoteOffset = int16(int8(timbre[11]))
+		}
+	}
+
+	return instruments, nil
+}
+
+const woplHeader = "WOPL3-BANK\x00"
+
+// DecodeWOPL decodes a .wopl OPL3 instrument bank into a list of
+// GENMIDI instruments.  Only the first melodic and percussion banks are
+// used, and four operator instruments are approximated as double voice
+// ones.
+func DecodeWOPL(data []byte) ([]Instrument, error) {
+	if len(data) < len(woplHeader)+8 || string(data[:len(woplHeader)]) != woplHeader {
+		return nil, errors.New("invalid WOPL header")
+	}
+
+	pos := len(woplHeader)
+	version := binary.LittleEndian.Uint16(data[pos:])
+	melodicBanks := int(binary.BigEndian.Uint16(data[pos+2:]))
+	percussionBanks := int(binary.BigEndian.Uint16(data[pos+4:]))
+	pos += 8
+
+	if version >= 2 {
+		// Skip bank names
+		pos += (melodicBanks + percussionBanks) * 34
+	}
+
+	size := 62
+	if version >= 3 {
+		size = 66
+	}
+
+	instruments := make([]Instrument, GENMIDIInstruments)
+	decode := func(bank int, index int, instrument *Instrument, percussion bool) error {
+		offset := pos + (bank*128+index)*size
+		if offset+size > len(data) {
+			return errors.New("WOPL bank is too short")
+		}
+		record := data[offset : offset+size]
+
+		instrument.Name = nullTerminated(record[0:32])
+		instrument.FineTune = uint8(int(int8(record[37])) + 128)
+
+		flags := record[39]
+		if flags&0x04 != 0 {
+			// Blank instrument
+			return nil
+		} else if flags&0x03 != 0 {
+			instrument.Flags |= InstrumentDoubleVoice
+		}
+		if percussion {
+			instrument.Flags |= InstrumentFixedPitch
+			instrument.FixedNote = record[38]
+		}
+
+		// Operators are stored carrier first.
+		for v := range instrument.Voices {
+			voice := &instrument.Voices[v]
+			voice.BaseNoteOffset = int16(binary.BigEndian.Uint16(record[32+v*2:]))
+			voice.Feedback = record[40+v]
+
+			operator := func(op *OPLOperator, data []byte) {
+				op.Characteristic = data[0]
+				splitOPLLevel(op, data[1])
+				op.Attack = data[2]
+				op.Sustain = data[3]
+				op.Waveform = data[4]
+			}
+			operator(&voice.Carrier, record[42+v*10:])
+			operator(&voice.Modulator, record[42+v*10+5:])
+		}
+
+		return nil
+	}
+
+	if melodicBanks > 0 {
+		for i := 0; i < 128; i++ {
+			if err := decode(0, i, &instruments[i], false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if percussionBanks > 0 {
+		for i := 128; i < GENMIDIInstruments; i++ {
+			note := GENMIDIPercussionFirst + i - 128
+			if err := decode(melodicBanks, note, &instruments[i], true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return instruments, nil
+}
+
+// ImportOPLBank decodes an OPL instrument bank in .op2, .wopl or .tmb
+// format into a list of GENMIDI instruments.  An empty format detects
+// the format from the data.
+func ImportOPLBank(data []byte, format string) ([]Instrument, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		switch {
+		case bytes.HasPrefix(data, []byte(genmidiHeader)):
+			format = "op2"
+		case bytes.HasPrefix(data, []byte(woplHeader)):
+			format = "wopl"
+		case len(data) == 256*13:
+			format = "tmb"
+		default:
+			return nil, errors.New("unknown OPL bank format")
+		}
+	}
+
+	switch format {
+	case "op2", "genmidi":
+		return DecodeGENMIDI(data)
+	case "wopl":
+		return DecodeWOPL(data)
+	case "tmb":
+		return DecodeTMB(data)
+	}
+
+	return nil, fmt.Errorf("unknown OPL bank format %q", format)
+}
@@ -0,0 +1,148 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func testInstruments() []Instrument {
+	instruments := make([]Instrument, GENMIDIInstruments)
+	for i := range instruments {
+		instruments[i] = Instrument{
+			Name:     "Instrument",
+			FineTune: 128,
+			Voices: [2]OPLVoice{{
+				Modulator:      OPLOperator{0x01, 0xF2, 0x53, 0x00, 0x40, 0x1A},
+				Carrier:        OPLOperator{0x21, 0xF1, 0x73, 0x01, 0x00, 0x00},
+				Feedback:       0x0E,
+				BaseNoteOffset: int16(i - 12),
+			}},
+		}
+	}
+	instruments[128].Flags = InstrumentFixedPitch
+	instruments[128].FixedNote = 60
+
+	return instruments
+}
+
+func TestGENMIDI(t *testing.T) {
+	instruments := testInstruments()
+
+	data, err := EncodeGENMIDI(instruments)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(data) != 11908 || string(data[:8]) != "#OPL_II#" {
+		t.Fatalf("incorrect GENMIDI data (%d bytes)", len(data))
+	}
+
+	// First voice of the first instrument
+	expected := []byte{0x01, 0xF2, 0x53, 0x00, 0x40, 0x1A, 0x0E, 0x21, 0xF1, 0x73, 0x01, 0x00, 0x00, 0x00, 0xF4, 0xFF}
+	if !bytes.Equal(data[12:28], expected) {
+		t.Errorf("incorrect voice data %v", data[12:28])
+	}
+
+	decoded, err := DecodeGENMIDI(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(decoded, instruments) {
+		t.Error("GENMIDI did not survive a round trip")
+	}
+
+	_, err = EncodeGENMIDI(instruments[:128])
+	if err == nil {
+		t.Error("GENMIDI with too few instruments was encoded")
+	}
+}
+
+func TestDecodeTMB(t *testing.T) {
+	data := make([]byte, 256*13)
+	copy(data[0:], []byte{0x01, 0x21, 0x5A, 0x00, 0xF2, 0xF1, 0x53, 0x73, 0x00, 0x01, 0x0E, 0xF4, 0x00})
+	// Bass drum is note 35
+	copy(data[(128+35)*13:], []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x24, 0x00})
+
+	instruments, err := ImportOPLBank(data, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	voice := instruments[0].Voices[0]
+	if voice.Modulator.Scale != 0x40 || voice.Modulator.Level != 0x1A || voice.BaseNoteOffset != -12 {
+		t.Errorf("incorrect melodic voice %v", voice)
+	}
+
+	if instruments[128].Flags != InstrumentFixedPitch || instruments[128].FixedNote != 0x24 {
+		t.Errorf("incorrect percussion instrument %v", instruments[128])
+	}
+}
+
+func TestDecodeWOPL(t *testing.T) {
+	var data bytes.Buffer
+	data.WriteString("WOPL3-BANK\x00")
+	binary.Write(&data, binary.LittleEndian, uint16(3))
+	binary.Write(&data, binary.BigEndian, []uint16{1, 1})
+	data.Write([]byte{0, 0})
+	data.Write(make([]byte, 2*34))
+
+	instrument := make([]byte, 66)
+	copy(instrument, "Piano")
+	binary.BigEndian.PutUint16(instrument[32:], uint16(0xFFF4))
+	instrument[37] = 0xFE
+	instrument[40] = 0x0E
+	// Carrier, then modulator
+	copy(instrument[42:], []byte{0x21, 0x00, 0xF1, 0x73, 0x01})
+	copy(instrument[47:], []byte{0x01, 0x5A, 0xF2, 0x53, 0x00})
+	data.Write(instrument)
+	data.Write(make([]byte, 127*66))
+
+	// Percussion bank, with a pseudo four operator bass drum
+	data.Write(make([]byte, 35*66))
+	drum := make([]byte, 66)
+	drum[38] = 36
+	drum[39] = 0x02
+	data.Write(drum)
+	data.Write(make([]byte, 92*66))
+
+	instruments, err := ImportOPLBank(data.Bytes(), "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := OPLVoice{
+		Modulator:      OPLOperator{0x01, 0xF2, 0x53, 0x00, 0x40, 0x1A},
+		Carrier:        OPLOperator{0x21, 0xF1, 0x73, 0x01, 0x00, 0x00},
+		Feedback:       0x0E,
+		BaseNoteOffset: -12,
+	}
+	if instruments[0].Name != "Piano" || instruments[0].FineTune != 126 || instruments[0].Voices[0] != expected {
+		t.Errorf("incorrect melodic instrument %v", instruments[0])
+	}
+
+	if instruments[128].Flags != InstrumentFixedPitch|InstrumentDoubleVoice || instruments[128].FixedNote != 36 {
+		t.Errorf("incorrect percussion instrument %v", instruments[128])
+	}
+}
@@ -28,18 +28,38 @@ import (
 func wadOpen(l *lua.State) int {
 	l.NewTable()
 	WadLumpsOpen(l)
+	WadTexturesOpen(l)
+	WadAnimatedOpen(l)
+	WadSoundOpen(l)
+	WadMusicOpen(l)
+	WadGENMIDIOpen(l)
+	WadDehackedOpen(l)
+	WadMapInfoOpen(l)
+	WadACSOpen(l)
+	WadEndoomOpen(l)
+	WadGameOpen(l)
+	WadBuildOpen(l)
+	WadCacheOpen(l)
+	WadConvertOpen(l)
+	WadManifestOpen(l)
+	WadHelpOpen(l)
 
 	return 1
 }
 
 // NewLuaEnvironment creates a new lua.State with all standard
-// libraries available, in addition to the wad library.
+// libraries available, in addition to the wad library and the global
+// target function used by build files.
 func NewLuaEnvironment() *lua.State {
 	l := lua.NewState()
 
 	lua.OpenLibraries(l)
 
 	lua.Require(l, "wad", wadOpen, true)
+
+	// Build files declare targets with a global function, like Premake
+	l.Field(-1, "target")
+	l.SetGlobal("target")
 	l.Pop(1)
 
 	return l
@@ -58,3 +78,93 @@ func LuaDebugStack(state *lua.State) {
 		fmt.Printf("%s: %s\n", typename, meta)
 	}
 }
+
+// Returns the string stored in a field of the table at the given
+// index, or def if the field is nil.
+func tableString(l *lua.State, index int, key string, def string) string {
+	l.Field(index, key)
+	defer l.Pop(1)
+
+	if l.IsNil(-1) {
+		return def
+	}
+
+	value, ok := l.ToString(-1)
+	if !ok {
+		lua.Errorf(l, "field %s must be a string", key)
+	}
+	return value
+}
+
+// Returns the integer stored in a field of the table at the given
+// index, or def if the field is nil.
+func tableInteger(l *lua.State, index int, key string, def int) int {
+	l.Field(index, key)
+	defer l.Pop(1)
+
+	if l.IsNil(-1) {
+		return def
+	}
+
+	value, ok := l.ToInteger(-1)
+	if !ok {
+		lua.Errorf(l, "field %s must be a number", key)
+	}
+	return value
+}
+
+// Returns the integer stored in a field of the table at the given
+// index, or def if the field is nil.  The integer must be between min
+// and max, so that it fits the field of a lump it is stored in.
+func tableIntegerRange(l *lua.State, index int, key string, def int, min int, max int) int {
+	value := tableInteger(l, index, key, def)
+	if value < min || value > max {
+		lua.Errorf(l, "field %s must be between %d and %d", key, min, max)
+	}
+	return value
+}
+
+// Pushes a Lua array containing the passed strings.
+func pushStrings(l *lua.State, values []string) {
+	l.CreateTable(len(values), 0)
+	for i, value := range values {
+		l.PushString(value)
+		l.RawSetInt(-2, i+1)
+	}
+}
+
+// Checks for an array of strings at a specific stack index.
+func checkStrings(l *lua.State, index int) []string {
+	index = l.AbsIndex(index)
+	lua.CheckType(l, index, lua.TypeTable)
+
+	length := lua.LengthEx(l, index)
+	values := make([]string, length)
+	for i := range values {
+		l.RawGetInt(index, i+1)
+		value, ok := l.ToString(-1)
+		if !ok {
+			lua.Errorf(l, "element %d must be a string", i+1)
+		}
+		values[i] = value
+		l.Pop(1)
+	}
+
+	return values
+}
+
+// Checks for an optional string argument at a specific stack index
+// that must be one of the passed options, and returns the position of
+// the option in the list.  go-lua's CheckOption does not honor its
+// default, so we roll our own.
+func checkOption(l *lua.State, index int, def string, list []string) int {
+	name := lua.OptString(l, index, def)
+	for i, option := range list {
+		if name == option {
+			return i
+		}
+	}
+
+	lua.ArgumentError(l, index, "invalid option '"+name+"'")
+	panic("unreachable")
+}
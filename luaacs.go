@@ -0,0 +1,163 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	lua "github.com/Shopify/go-lua"
+)
+
+var acsMethods = []lua.RegistryFunction{
+	{"compileacs", wadCompileACS},
+	{"decodebehavior", wadDecodeBehavior},
+	{"disassemblebehavior", converter("disassemblebehavior")},
+}
+
+var acsFormatNames = []string{"auto", "acs0", "acse"}
+
+// Compile ACS source into a BEHAVIOR lump.  The optional arguments are
+// the file name of the source, an array of directories to search for
+// included files, and the format to compile to, which defaults to the
+// format of the current game.
+func wadCompileACS(l *lua.State) int {
+	src := lua.CheckString(l, 1)
+	name := lua.OptString(l, 2, "SCRIPTS")
+
+	dirs := []string{}
+	if !l.IsNoneOrNil(3) {
+		dirs = checkStrings(l, 3)
+	}
+
+	def := acsFormatNames[currentGame(l).ACSFormat]
+	format := ACSFormat(checkOption(l, 4, def, acsFormatNames))
+
+	include := ACSIncludeDirs(dirs...)
+	if sandbox := LuaSandbox(l); sandbox != nil {
+		include = acsIncludeReader(sandbox.ReadFile, dirs...)
+	}
+
+	data, err := CompileACS(name, []byte(src), include, format)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(string(data))
+	return 1
+}
+
+// The tag of each decoded format, as found in the lump header.
+var behaviorFormatNames = map[ACSFormat]string{
+	ACSFormatACS0: "ACS0",
+	ACSFormatACSE: "ACSE",
+	ACSFormatACSe: "ACSe",
+}
+
+// Decode a BEHAVIOR lump into a table of its scripts, functions,
+// strings, map variables and libraries.
+func wadDecodeBehavior(l *lua.State) int {
+	data := lua.CheckString(l, 1)
+
+	behavior, err := DecodeBehavior([]byte(data))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.NewTable()
+	l.PushString(behaviorFormatNames[behavior.Format])
+	l.SetField(-2, "format")
+
+	l.CreateTable(len(behavior.Scripts), 0)
+	for i, script := range behavior.Scripts {
+		l.CreateTable(0, 7)
+		l.PushInteger(script.Number)
+		l.SetField(-2, "number")
+		if script.Name != "" {
+			l.PushString(script.Name)
+			l.SetField(-2, "name")
+		}
+		l.PushString(script.TypeName())
+		l.SetField(-2, "type")
+		l.PushInteger(script.Flags)
+		l.SetField(-2, "flags")
+		l.PushInteger(script.Args)
+		l.SetField(-2, "args")
+		l.PushInteger(script.Locals)
+		l.SetField(-2, "locals")
+		l.PushInteger(script.Address)
+		l.SetField(-2, "address")
+		l.RawSetInt(-2, i+1)
+	}
+	l.SetField(-2, "scripts")
+
+	l.CreateTable(len(behavior.Functions), 0)
+	for i, function := range behavior.Functions {
+		l.CreateTable(0, 6)
+		l.PushString(function.Name)
+		l.SetField(-2, "name")
+		l.PushInteger(function.Args)
+		l.SetField(-2, "args")
+		l.PushInteger(function.Locals)
+		l.SetField(-2, "locals")
+		l.PushBoolean(function.Returns)
+		l.SetField(-2, "returns")
+		l.PushInteger(function.Address)
+		l.SetField(-2, "address")
+		l.PushBoolean(function.Imported)
+		l.SetField(-2, "imported")
+		l.RawSetInt(-2, i+1)
+	}
+	l.SetField(-2, "functions")
+
+	pushStrings(l, behavior.Strings)
+	l.SetField(-2, "strings")
+
+	l.CreateTable(len(behavior.MapVars), 0)
+	for i, v := range behavior.MapVars {
+		l.CreateTable(0, 5)
+		l.PushInteger(v.Index)
+		l.SetField(-2, "index")
+		if v.Name != "" {
+			l.PushString(v.Name)
+			l.SetField(-2, "name")
+		}
+		l.PushInteger(int(v.Value))
+		l.SetField(-2, "value")
+		if v.ArraySize > 0 {
+			l.PushInteger(v.ArraySize)
+			l.SetField(-2, "arraysize")
+		}
+		l.PushBoolean(v.Imported)
+		l.SetField(-2, "imported")
+		l.RawSetInt(-2, i+1)
+	}
+	l.SetField(-2, "mapvars")
+
+	pushStrings(l, behavior.Libraries)
+	l.SetField(-2, "libraries")
+
+	return 1
+}
+
+// WadACSOpen adds all ACS-related functions to the table located at the
+// top of the stack of the passed lua state.
+func WadACSOpen(l *lua.State) error {
+	lua.SetFunctions(l, acsMethods, 0)
+
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// ACS compiles with includes found in the passed directories
+func TestLuaCompileACS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wadmake")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "defs.acs"), []byte("#define TICS 35\n"), 0644)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	l := NewLuaEnvironment()
+	l.PushString(dir)
+	l.SetGlobal("dir")
+
+	err = lua.DoString(l, `
+		local behavior = wad.compileacs('#include "defs.acs"\nscript 1 OPEN { delay(TICS); }', "map01.acs", {dir})
+		local ok, err = pcall(wad.compileacs, 'script 1 OPEN { delay(TICS); }', "map01.acs")
+		return behavior, err`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	behavior := lua.CheckString(l, -2)
+	if len(behavior) != 44 || behavior[:4] != "ACS\x00" {
+		t.Errorf("incorrect BEHAVIOR %q", behavior)
+	}
+
+	if lua.CheckString(l, -1) != "map01.acs:1: unknown identifier TICS" {
+		t.Errorf("unexpected error %q", lua.CheckString(l, -1))
+	}
+}
+
+// BEHAVIOR lumps decode to tables and disassemble to text
+func TestLuaDecodeBehavior(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local behavior = wad.compileacs('script 1 ENTER { print(s:"Hello"); }')
+		local info = wad.decodebehavior(behavior)
+		return info.format, info.scripts[1].type, info.strings[1], wad.disassemblebehavior(behavior)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if format := lua.CheckString(l, -4); format != "ACS0" {
+		t.Errorf("incorrect format %s", format)
+	}
+
+	if kind := lua.CheckString(l, -3); kind != "enter" {
+		t.Errorf("incorrect script type %s", kind)
+	}
+
+	if str := lua.CheckString(l, -2); str != "Hello" {
+		t.Errorf("incorrect string %s", str)
+	}
+
+	if listing := lua.CheckString(l, -1); !strings.HasPrefix(listing, "script 1 enter, 0 args\n") {
+		t.Errorf("incorrect disassembly\n%s", listing)
+	}
+}
@@ -0,0 +1,246 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"strings"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+var animatedMethods = []lua.RegistryFunction{
+	{"compileswantbls", cached("compileswantbls", wadCompileSwanTables)},
+	{"decodeanimated", wadDecodeAnimated},
+	{"decodeswitches", wadDecodeSwitches},
+	{"decompileswantbls", cached("decompileswantbls", wadDecompileSwanTables)},
+	{"encodeanimated", wadEncodeAnimated},
+	{"encodeswitches", wadEncodeSwitches},
+}
+
+// Pushes an array of animation tables.
+func pushAnimations(l *lua.State, animations []Animation) {
+	l.CreateTable(len(animations), 0)
+	for i, animation := range animations {
+		l.CreateTable(0, 5)
+		if animation.Texture {
+			l.PushString("texture")
+		} else {
+			l.PushString("flat")
+		}
+		l.SetField(-2, "type")
+		l.PushString(animation.First)
+		l.SetField(-2, "first")
+		l.PushString(animation.Last)
+		l.SetField(-2, "last")
+		l.PushInteger(int(animation.Speed))
+		l.SetField(-2, "speed")
+		l.PushBoolean(animation.Decals)
+		l.SetField(-2, "decals")
+		l.RawSetInt(-2, i+1)
+	}
+}
+
+// Checks for an array of animation tables at a specific stack index.
+func checkAnimations(l *lua.State, index int) []Animation {
+	index = l.AbsIndex(index)
+	lua.CheckType(l, index, lua.TypeTable)
+
+	animations := make([]Animation, lua.LengthEx(l, index))
+	for i := range animations {
+		l.RawGetInt(index, i+1)
+		if !l.IsTable(-1) {
+			lua.Errorf(l, "animation %d must be a table", i+1)
+		}
+
+		kind := tableString(l, -1, "type", "texture")
+		if kind != "flat" && kind != "texture" {
+			lua.Errorf(l, "animation %d has invalid type '%s'", i+1, kind)
+		}
+
+		l.Field(-1, "decals")
+		decals := l.ToBoolean(-1)
+		l.Pop(1)
+
+		animations[i] = Animation{
+			Texture: kind == "texture",
+			Decals:  decals,
+			First:   tableString(l, -1, "first", ""),
+			Last:    tableString(l, -1, "last", ""),
+			Speed:   int32(tableInteger(l, -1, "speed", 8)),
+		}
+		l.Pop(1)
+	}
+
+	return animations
+}
+
+// Pushes an array of switch tables.
+func pushSwitches(l *lua.State, switches []Switch) {
+	l.CreateTable(len(switches), 0)
+	for i, sw := range switches {
+		l.CreateTable(0, 3)
+		l.PushString(sw.Off)
+		l.SetField(-2, "off")
+		l.PushString(sw.On)
+		l.SetField(-2, "on")
+		l.PushInteger(int(sw.Episode))
+		l.SetField(-2, "episode")
+		l.RawSetInt(-2, i+1)
+	}
+}
+
+// Checks for an array of switch tables at a specific stack index.
+func checkSwitches(l *lua.State, index int) []Switch {
+	index = l.AbsIndex(index)
+	lua.CheckType(l, index, lua.TypeTable)
+
+	switches := make([]Switch, lua.LengthEx(l, index))
+	for i := range switches {
+		l.RawGetInt(index, i+1)
+		if !l.IsTable(-1) {
+			lua.Errorf(l, "switch %d must be a table", i+1)
+		}
+
+		switches[i] = Switch{
+			Off:     tableString(l, -1, "off", ""),
+			On:      tableString(l, -1, "on", ""),
+			Episode: int16(tableInteger(l, -1, "episode", 1)),
+		}
+		l.Pop(1)
+	}
+
+	return switches
+}
+
+// Decode ANIMATED data into an array of animations.
+func wadDecodeAnimated(l *lua.State) int {
+	data := lua.CheckString(l, 1)
+
+	animations, err := DecodeAnimated([]byte(data))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	pushAnimations(l, animations)
+	return 1
+}
+
+// Encode an array of animations into ANIMATED data.
+func wadEncodeAnimated(l *lua.State) int {
+	animations := checkAnimations(l, 1)
+
+	data, err := EncodeAnimated(animations)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(string(data))
+	return 1
+}
+
+// Decode SWITCHES data into an array of switches.
+func wadDecodeSwitches(l *lua.State) int {
+	data := lua.CheckString(l, 1)
+
+	switches, err := DecodeSwitches([]byte(data))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	pushSwitches(l, switches)
+	return 1
+}
+
+// Encode an array of switches into SWITCHES data.
+func wadEncodeSwitches(l *lua.State) int {
+	switches := checkSwitches(l, 1)
+
+	data, err := EncodeSwitches(switches)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(string(data))
+	return 1
+}
+
+// Compile DEFSWANI.DAT text into ANIMATED and SWITCHES data.
+func wadCompileSwanTables(l *lua.State) int {
+	text := lua.CheckString(l, 1)
+
+	animations, switches, err := ParseSwanTables(strings.NewReader(text))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	animated, err := EncodeAnimated(animations)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	switchesData, err := EncodeSwitches(switches)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(string(animated))
+	l.PushString(string(switchesData))
+	return 2
+}
+
+// Decompile ANIMATED and SWITCHES data into DEFSWANI.DAT text.  Either
+// lump can be nil.
+func wadDecompileSwanTables(l *lua.State) int {
+	animations := []Animation{}
+	switches := []Switch{}
+	var err error
+
+	if !l.IsNoneOrNil(1) {
+		animations, err = DecodeAnimated([]byte(lua.CheckString(l, 1)))
+		if err != nil {
+			lua.Errorf(l, err.Error())
+		}
+	}
+
+	if !l.IsNoneOrNil(2) {
+		switches, err = DecodeSwitches([]byte(lua.CheckString(l, 2)))
+		if err != nil {
+			lua.Errorf(l, err.Error())
+		}
+	}
+
+	var buffer bytes.Buffer
+	err = WriteSwanTables(&buffer, animations, switches)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(buffer.String())
+	return 1
+}
+
+// WadAnimatedOpen adds all ANIMATED and SWITCHES related functions to
+// the table located at the top of the stack of the passed lua state.
+func WadAnimatedOpen(l *lua.State) error {
+	lua.SetFunctions(l, animatedMethods, 0)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// DEFSWANI.DAT text compiles into lumps that decode into tables
+func TestLuaCompileSwanTables(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local animated, switches = wad.compileswantbls([[
+[TEXTURES]
+8 BLODGR4 BLODGR1
+[SWITCHES]
+2 SW1BRN1 SW2BRN1
+]])
+		local anims = wad.decodeanimated(animated)
+		local sws = wad.decodeswitches(switches)
+		return anims[1].type, anims[1].first, sws[1].on, sws[1].episode`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckString(l, -4) != "texture" || lua.CheckString(l, -3) != "BLODGR1" {
+		t.Error("incorrect animation")
+	}
+
+	if lua.CheckString(l, -2) != "SW2BRN1" || lua.CheckInteger(l, -1) != 2 {
+		t.Error("incorrect switch")
+	}
+}
+
+// Tables encode into lumps that decompile into DEFSWANI.DAT text
+func TestLuaDecompileSwanTables(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local animated = wad.encodeanimated({{type = "flat", first = "FWATER1", last = "FWATER4"}})
+		return wad.decompileswantbls(animated, nil)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := "[FLATS]\n8     FWATER4  FWATER1\n\n[TEXTURES]\n\n[SWITCHES]\n"
+	if lua.CheckString(l, -1) != expected {
+		t.Errorf("incorrect decompiled text %q", lua.CheckString(l, -1))
+	}
+}
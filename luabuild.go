@@ -0,0 +1,175 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"fmt"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+var buildMethods = []lua.RegistryFunction{
+	{"target", wadTarget},
+}
+
+// The registry field holding the array of declared targets.
+const targetsRegistryKey = "wadmake.targets"
+
+// Pushes the array of declared targets, creating it if needed.
+func pushTargets(l *lua.State) {
+	l.Field(lua.RegistryIndex, targetsRegistryKey)
+	if l.IsNil(-1) {
+		l.Pop(1)
+		l.NewTable()
+		l.PushValue(-1)
+		l.SetField(lua.RegistryIndex, targetsRegistryKey)
+	}
+}
+
+// Declare a build target with a name, an optional table of options and
+// an optional function that builds it.  The deps option is an array of
+// the names of targets to build first, and the description option is
+// shown when listing targets.
+func wadTarget(l *lua.State) int {
+	name := lua.CheckString(l, 1)
+
+	target := BuildTarget{Name: name, Deps: []string{}}
+	fnIndex := 2
+	if l.IsTable(2) {
+		l.Field(2, "deps")
+		if !l.IsNil(-1) {
+			target.Deps = checkStrings(l, -1)
+		}
+		l.Pop(1)
+
+		target.Description = tableString(l, 2, "description", "")
+		fnIndex = 3
+	}
+
+	if !l.IsNoneOrNil(fnIndex) {
+		lua.CheckType(l, fnIndex, lua.TypeFunction)
+	}
+
+	for _, existing := range LuaBuildTargets(l) {
+		if existing.Name == name {
+			lua.Errorf(l, "target %s is already defined", name)
+		}
+	}
+
+	pushTargets(l)
+	count := lua.LengthEx(l, -1)
+
+	l.CreateTable(0, 4)
+	l.PushString(target.Name)
+	l.SetField(-2, "name")
+	pushStrings(l, target.Deps)
+	l.SetField(-2, "deps")
+	l.PushString(target.Description)
+	l.SetField(-2, "description")
+	if !l.IsNoneOrNil(fnIndex) {
+		l.PushValue(fnIndex)
+		l.SetField(-2, "fn")
+	}
+	l.RawSetInt(-2, count+1)
+
+	l.Pop(1)
+	return 0
+}
+
+// LuaBuildTargets returns the targets declared with wad.target in the
+// passed state, in the order they were declared.
+func LuaBuildTargets(l *lua.State) []BuildTarget {
+	pushTargets(l)
+	defer l.Pop(1)
+
+	targets := make([]BuildTarget, lua.LengthEx(l, -1))
+	for i := range targets {
+		l.RawGetInt(-1, i+1)
+		targets[i].Name = tableString(l, -1, "name", "")
+		targets[i].Description = tableString(l, -1, "description", "")
+		l.Field(-1, "deps")
+		targets[i].Deps = checkStrings(l, -1)
+		l.Pop(2)
+	}
+
+	return targets
+}
+
+// RunBuildTargets builds the passed goals declared in the passed state
+// along with their dependencies, calling the function of each target
+// in build order.  If no goals are passed, the first declared target is
+// built.  If report is not nil, it is called with the name of each
+// target before the target is built.
+func RunBuildTargets(l *lua.State, goals []string, report func(name string)) error {
+	targets := LuaBuildTargets(l)
+	if len(goals) == 0 {
+		if len(targets) == 0 {
+			return fmt.Errorf("no targets are defined")
+		}
+		goals = []string{targets[0].Name}
+	}
+
+	order, err := BuildOrder(targets, goals)
+	if err != nil {
+		return err
+	}
+
+	index := map[string]int{}
+	for i, target := range targets {
+		index[target.Name] = i + 1
+	}
+
+	for _, name := range order {
+		if report != nil {
+			report(name)
+		}
+
+		pushTargets(l)
+		l.RawGetInt(-1, index[name])
+		l.Field(-1, "fn")
+		l.Remove(-2)
+		l.Remove(-2)
+
+		if !l.IsFunction(-1) {
+			l.Pop(1)
+			continue
+		}
+
+		err := l.ProtectedCall(0, 0, 0)
+		if err != nil {
+			msg, ok := l.ToString(-1)
+			if !ok {
+				msg = err.Error()
+			}
+			l.Pop(1)
+			return fmt.Errorf("target %s: %s", name, msg)
+		}
+	}
+
+	return nil
+}
+
+// WadBuildOpen adds all build-related functions to the table located
+// at the top of the stack of the passed lua state.
+func WadBuildOpen(l *lua.State) error {
+	lua.SetFunctions(l, buildMethods, 0)
+
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"reflect"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// Targets run after their dependencies, each once
+func TestLuaBuildTargets(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		built = {}
+		target("release", {deps = {"maps", "textures"}, description = "Everything"}, function()
+			table.insert(built, "release")
+		end)
+		target("maps", {deps = {"textures"}}, function() table.insert(built, "maps") end)
+		target("textures", function() table.insert(built, "textures") end)
+		wad.target("all", {deps = {"release"}})`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	targets := LuaBuildTargets(l)
+	if len(targets) != 4 || targets[0].Description != "Everything" || len(targets[3].Deps) != 1 {
+		t.Fatalf("incorrect targets %+v", targets)
+	}
+
+	reported := []string{}
+	err = RunBuildTargets(l, []string{"all"}, func(name string) {
+		reported = append(reported, name)
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(reported, []string{"textures", "maps", "release", "all"}) {
+		t.Errorf("incorrect build order %v", reported)
+	}
+
+	err = lua.DoString(l, `return table.concat(built, " ")`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if built := lua.CheckString(l, -1); built != "textures maps release" {
+		t.Errorf("incorrect targets built %q", built)
+	}
+}
+
+// Failing targets stop the build
+func TestLuaBuildTargetErrors(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		target("default", {deps = {"broken"}}, function() error("not reached") end)
+		target("broken", function() error("no textures", 0) end)
+		return pcall(target, "broken")`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if msg := lua.CheckString(l, -1); msg != "target broken is already defined" {
+		t.Errorf("unexpected error %q", msg)
+	}
+
+	err = RunBuildTargets(l, nil, nil)
+	if err == nil || err.Error() != "target broken: no textures" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"encoding/binary"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+var cacheMethods = []lua.RegistryFunction{
+	{"getcache", wadGetCache},
+	{"setcache", wadSetCache},
+}
+
+// The registry field holding the directory of the build cache.
+const cacheRegistryKey = "wadmake.cache"
+
+// SetLuaCache makes the conversions of the passed state consult a
+// cache, or stop consulting one if the cache is nil.
+func SetLuaCache(l *lua.State, cache *Cache) {
+	if cache == nil {
+		l.PushNil()
+	} else {
+		l.PushString(cache.Dir)
+	}
+	l.SetField(lua.RegistryIndex, cacheRegistryKey)
+}
+
+// Returns the cache conversions consult, or nil if there is none.
+func luaCache(l *lua.State) *Cache {
+	l.Field(lua.RegistryIndex, cacheRegistryKey)
+	defer l.Pop(1)
+
+	dir, ok := l.ToString(-1)
+	if !ok {
+		return nil
+	}
+	return NewCache(dir)
+}
+
+// Packs the results of a conversion into a single cache entry.
+func encodeCacheResults(results [][]byte) []byte {
+	data := binary.AppendUvarint(nil, uint64(len(results)))
+	for _, result := range results {
+		data = binary.AppendUvarint(data, uint64(len(result)))
+		data = append(data, result...)
+	}
+
+	return data
+}
+
+// Unpacks the results of a conversion from a cache entry, or returns
+// false if the entry is damaged.
+func decodeCacheResults(data []byte) ([][]byte, bool) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, false
+	}
+	data = data[n:]
+
+	results := [][]byte{}
+	for i := uint64(0); i < count; i++ {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			return nil, false
+		}
+		results = append(results, data[n:n+int(size)])
+		data = data[n+int(size):]
+	}
+
+	return results, true
+}
+
+// cached wraps a conversion so that its results are stored in the cache
+// of the state, keyed by the name of the conversion, its arguments and
+// the current game, which some conversions take defaults from.  Calls
+// with arguments other than strings, numbers, booleans and nil are not
+// cached, and neither are results other than strings.
+func cached(name string, fn lua.Function) lua.Function {
+	return func(l *lua.State) int {
+		cache := luaCache(l)
+		if cache == nil {
+			return fn(l)
+		}
+
+		args, ok := luaConvertArgs(l, 1, l.Top())
+		if !ok {
+			return fn(l)
+		}
+		job := ConvertJob{Name: name, Game: currentGame(l), Args: args}
+		key := job.CacheKey()
+
+		if data, ok := cache.Get(key); ok {
+			if results, ok := decodeCacheResults(data); ok {
+				for _, result := range results {
+					l.PushString(string(result))
+				}
+				return len(results)
+			}
+		}
+
+		count := fn(l)
+
+		results := make([][]byte, count)
+		for i := range results {
+			index := l.Top() - count + 1 + i
+			if l.TypeOf(index) != lua.TypeString {
+				return count
+			}
+			value, _ := l.ToString(index)
+			results[i] = []byte(value)
+		}
+
+		// The cache only saves time, so failing to store an entry is
+		// not worth failing the build over.
+		cache.Put(key, encodeCacheResults(results))
+
+		return count
+	}
+}
+
+// Make conversions consult a build cache stored in the passed
+// directory, or stop consulting one if the directory is nil.
+func wadSetCache(l *lua.State) int {
+	if l.IsNoneOrNil(1) {
+		SetLuaCache(l, nil)
+	} else {
+		SetLuaCache(l, NewCache(checkWritePath(l, 1)))
+	}
+
+	return 0
+}
+
+// Return the directory of the build cache, or nil if there is none.
+func wadGetCache(l *lua.State) int {
+	l.Field(lua.RegistryIndex, cacheRegistryKey)
+	return 1
+}
+
+// WadCacheOpen adds all cache-related functions to the table located at
+// the top of the stack of the passed lua state.
+func WadCacheOpen(l *lua.State) error {
+	lua.SetFunctions(l, cacheMethods, 0)
+
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// Conversions and both packers return results stored in the cache
+func TestLuaCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wadmake")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// Plant results that only the cache could return
+	cache := NewCache(dir)
+	key := CacheKey([]byte("endoomtoansi"), []byte("doom"), []byte("sfake"))
+	cache.Put(key, encodeCacheResults([][]byte{[]byte("cached")}))
+	key = CacheKey([]byte("packwad"), []byte("LUMP"), []byte("data"))
+	cache.Put(key, []byte("cached wad"))
+	key = CacheKey([]byte("packzip"), []byte("LUMP"), []byte("data"))
+	cache.Put(key, []byte("cached zip"))
+
+	l := NewLuaEnvironment()
+	l.PushString(dir)
+	l.SetGlobal("dir")
+
+	err = lua.DoString(l, `
+		local ok, uncached = pcall(wad.endoomtoansi, "fake")
+		wad.setcache(dir)
+		local lumps = wad.createLumps()
+		lumps:insert("LUMP", "data")
+		local cached = wad.endoomtoansi("fake")
+		local packed = lumps:packwad()
+		local dmx = wad.wavtodmx(wad.dmxtowav("\3\0\17\43\4\0\0\0" .. string.rep("\128", 20)))
+		local again = wad.wavtodmx(wad.dmxtowav("\3\0\17\43\4\0\0\0" .. string.rep("\128", 20)))
+		local zipped = lumps:packzip()
+		return ok, cached, packed, dmx == again, wad.getcache(), zipped`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if l.ToBoolean(-6) {
+		t.Error("conversion consulted the cache before it was set")
+	}
+
+	if lua.CheckString(l, -5) != "cached" {
+		t.Errorf("conversion did not return the cached result %q", lua.CheckString(l, -5))
+	}
+
+	if lua.CheckString(l, -4) != "cached wad" {
+		t.Error("packwad did not return the cached result")
+	}
+
+	if !l.ToBoolean(-3) {
+		t.Error("cached conversion returned a different result")
+	}
+
+	if lua.CheckString(l, -2) != dir {
+		t.Error("incorrect cache directory")
+	}
+
+	if lua.CheckString(l, -1) != "cached zip" {
+		t.Error("packzip did not return the cached result")
+	}
+}
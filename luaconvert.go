@@ -0,0 +1,315 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"errors"
+	"runtime"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+const futureHandle = "Future"
+
+var convertMethods = []lua.RegistryFunction{
+	{"async", wadAsync},
+	{"convertall", wadConvertAll},
+}
+
+var futureMethods = []lua.RegistryFunction{
+	{"ready", futureReady},
+	{"wait", futureWait},
+	{"__tostring", futureToString},
+}
+
+// Limits the number of conversions started with wad.async that run at
+// the same time, across every Lua state.
+var convertSlots = make(chan struct{}, runtime.NumCPU())
+
+// convertFuture is a conversion running in the background.  Its result
+// must only be read once done is closed.
+type convertFuture struct {
+	name   string
+	done   chan struct{}
+	result ConvertResult
+}
+
+// Returns the arguments of a conversion located between two stack
+// indexes, or false if one of them is not a string, number, boolean or
+// nil.
+func luaConvertArgs(l *lua.State, first int, last int) ([]interface{}, bool) {
+	args := make([]interface{}, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		switch l.TypeOf(i) {
+		case lua.TypeString:
+			value, _ := l.ToString(i)
+			args = append(args, value)
+		case lua.TypeNumber:
+			value, _ := l.ToNumber(i)
+			args = append(args, value)
+		case lua.TypeBoolean:
+			args = append(args, l.ToBoolean(i))
+		case lua.TypeNil:
+			args = append(args, nil)
+		default:
+			return nil, false
+		}
+	}
+
+	return args, true
+}
+
+// Checks for the name of a conversion that can run in the background at
+// a specific stack index.
+func checkConversion(l *lua.State, index int) string {
+	name := lua.CheckString(l, index)
+	if _, ok := Converters[name]; !ok {
+		lua.ArgumentError(l, index, "unknown conversion '"+name+"'")
+	}
+
+	return name
+}
+
+// Returns the results of a job from the cache of the state, or false if
+// there is no cache or the job has not been run before.
+func cachedConversion(l *lua.State, job *ConvertJob) ([][]byte, bool) {
+	cache := luaCache(l)
+	if cache == nil {
+		return nil, false
+	}
+
+	data, ok := cache.Get(job.CacheKey())
+	if !ok {
+		return nil, false
+	}
+	return decodeCacheResults(data)
+}
+
+// Stores the results of a successful job in the passed cache, if any.
+// This does not touch the Lua state, so that workers can call it.
+func cacheConversion(cache *Cache, job *ConvertJob, result *ConvertResult) {
+	if cache == nil || result.Err != nil {
+		return
+	}
+
+	// The cache only saves time, so failing to store an entry is not
+	// worth failing the build over.
+	cache.Put(job.CacheKey(), encodeCacheResults(result.Results))
+}
+
+// Pushes the results of a job, raising its error if it failed.
+func pushConvertResult(l *lua.State, result *ConvertResult) int {
+	if result.Err != nil {
+		lua.Errorf(l, result.Err.Error())
+	}
+
+	for _, data := range result.Results {
+		l.PushString(string(data))
+	}
+	return len(result.Results)
+}
+
+// converter returns the wad library function of a conversion in
+// Converters, which runs it on the calling goroutine for the current
+// game.  Results are stored in the cache of the state, if any.
+func converter(name string) lua.Function {
+	return func(l *lua.State) int {
+		args, ok := luaConvertArgs(l, 1, l.Top())
+		if !ok {
+			lua.Errorf(l, "arguments of %s must be strings, numbers, booleans or nil", name)
+		}
+
+		job := &ConvertJob{Name: name, Game: currentGame(l), Args: args}
+		if results, ok := cachedConversion(l, job); ok {
+			return pushConvertResult(l, &ConvertResult{Results: results})
+		}
+
+		results, err := Converters[name](job.Game, args)
+		result := ConvertResult{Results: results, Err: err}
+		cacheConversion(luaCache(l), job, &result)
+		return pushConvertResult(l, &result)
+	}
+}
+
+// Checks for Future userdata at a specific stack index.
+func checkFuture(l *lua.State, index int) *convertFuture {
+	future, ok := lua.CheckUserData(l, index, futureHandle).(*convertFuture)
+	if !ok {
+		lua.ArgumentError(l, index, "future expected")
+	}
+
+	return future
+}
+
+// Start a conversion on a worker in the background, and return a Future
+// to wait on its results with.  The arguments are the name of a wad
+// library conversion followed by its own arguments, which are converted
+// for the current game.
+func wadAsync(l *lua.State) int {
+	name := checkConversion(l, 1)
+	args, ok := luaConvertArgs(l, 2, l.Top())
+	if !ok {
+		lua.Errorf(l, "arguments of %s must be strings, numbers, booleans or nil", name)
+	}
+
+	job := &ConvertJob{Name: name, Game: currentGame(l), Args: args}
+	future := &convertFuture{name: name, done: make(chan struct{})}
+
+	if results, ok := cachedConversion(l, job); ok {
+		future.result = ConvertResult{Results: results}
+		close(future.done)
+	} else {
+		cache := luaCache(l)
+		go func() {
+			convertSlots <- struct{}{}
+			future.result = job.Run()
+			<-convertSlots
+
+			cacheConversion(cache, job, &future.result)
+			close(future.done)
+		}()
+	}
+
+	l.PushUserData(future)
+	lua.SetMetaTableNamed(l, futureHandle)
+	return 1
+}
+
+// Run a list of conversions on a pool of workers and wait for all of
+// them.  Each element of the list is an array holding the name of a wad
+// library conversion followed by its arguments.  Returns an array of
+// the results of every conversion, in list order, each of which is an
+// array itself.
+func wadConvertAll(l *lua.State) int {
+	lua.CheckType(l, 1, lua.TypeTable)
+
+	jobs := make([]ConvertJob, lua.LengthEx(l, 1))
+	results := make([]ConvertResult, len(jobs))
+	pending := []int{}
+	for i := range jobs {
+		l.RawGetInt(1, i+1)
+		if !l.IsTable(-1) {
+			lua.Errorf(l, "job %d must be a table", i+1)
+		}
+
+		l.RawGetInt(-1, 1)
+		name, ok := l.ToString(-1)
+		if !ok {
+			lua.Errorf(l, "job %d: name must be a string", i+1)
+		} else if _, ok := Converters[name]; !ok {
+			lua.Errorf(l, "job %d: unknown conversion '%s'", i+1, name)
+		}
+		l.Pop(1)
+
+		// Push the arguments so that they can be read off the stack
+		count := lua.LengthEx(l, -1)
+		for j := 2; j <= count; j++ {
+			l.RawGetInt(-j+1, j)
+		}
+		args, ok := luaConvertArgs(l, l.Top()-count+2, l.Top())
+		if !ok {
+			lua.Errorf(l, "job %d: arguments of %s must be strings, numbers, booleans or nil", i+1, name)
+		}
+		l.Pop(count)
+
+		jobs[i] = ConvertJob{Name: name, Game: currentGame(l), Args: args}
+		if cached, ok := cachedConversion(l, &jobs[i]); ok {
+			results[i] = ConvertResult{Results: cached}
+		} else {
+			pending = append(pending, i)
+		}
+	}
+
+	run := make([]ConvertJob, len(pending))
+	for i, index := range pending {
+		run[i] = jobs[index]
+	}
+
+	cache := luaCache(l)
+	for i, result := range RunConversions(run, 0) {
+		results[pending[i]] = result
+		cacheConversion(cache, &run[i], &result)
+	}
+
+	l.CreateTable(len(results), 0)
+	for i, result := range results {
+		if result.Err != nil {
+			lua.Errorf(l, "job %d: %s", i+1, result.Err.Error())
+		}
+
+		l.CreateTable(len(result.Results), 0)
+		for j, data := range result.Results {
+			l.PushString(string(data))
+			l.RawSetInt(-2, j+1)
+		}
+		l.RawSetInt(-2, i+1)
+	}
+
+	return 1
+}
+
+// Return true if the conversion of a Future has finished.
+func futureReady(l *lua.State) int {
+	future := checkFuture(l, 1)
+
+	select {
+	case <-future.done:
+		l.PushBoolean(true)
+	default:
+		l.PushBoolean(false)
+	}
+	return 1
+}
+
+// Wait for the conversion of a Future to finish, and return its results
+// or raise its error.  A Future can be waited on any number of times.
+func futureWait(l *lua.State) int {
+	future := checkFuture(l, 1)
+
+	<-future.done
+	return pushConvertResult(l, &future.result)
+}
+
+// A nice way of printing the Future userdata.
+func futureToString(l *lua.State) int {
+	future := checkFuture(l, 1)
+
+	l.PushFString("%s: %p, %s", futureHandle, future, future.name)
+	return 1
+}
+
+// WadConvertOpen adds all functions for running conversions in parallel
+// to the table located at the top of the stack of the passed lua state.
+func WadConvertOpen(l *lua.State) error {
+	lua.SetFunctions(l, convertMethods, 0)
+
+	// Create the Future userdata with associated functions.
+	ok := lua.NewMetaTable(l, futureHandle)
+	if !ok {
+		return errors.New("could not create Future metatable")
+	}
+
+	l.PushValue(-1)
+	l.SetField(-2, "__index")
+	lua.SetFunctions(l, futureMethods, 0)
+	l.Pop(1)
+
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// Futures return the same results as calling the conversion directly
+func TestLuaAsync(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local dmx = "\3\0\17\43\4\0\0\0" .. string.rep("\128", 20)
+		local futures = {}
+		for i = 1, 10 do
+			futures[i] = wad.async("dmxtowav", dmx, 16)
+		end
+		for i = 1, 10 do
+			if futures[i]:wait() ~= wad.dmxtowav(dmx, 16) then
+				return false
+			end
+		end
+		return futures[1]:ready(), tostring(futures[1])`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !l.ToBoolean(-2) {
+		t.Error("future returned a different result")
+	}
+
+	if !strings.HasSuffix(lua.CheckString(l, -1), ", dmxtowav") {
+		t.Errorf("incorrect string %q", lua.CheckString(l, -1))
+	}
+
+	// Errors are raised when waiting
+	err = lua.DoString(l, `wad.async("mustomidi", "bad"):wait()`)
+	if err == nil || !strings.Contains(err.Error(), "mustomidi: ") {
+		t.Errorf("incorrect conversion error %v", err)
+	}
+
+	err = lua.DoString(l, `wad.async("readwad", "file.wad")`)
+	if err == nil || !strings.Contains(err.Error(), "unknown conversion 'readwad'") {
+		t.Errorf("incorrect unknown conversion error %v", err)
+	}
+}
+
+// Every conversion in Converters is a wad library function that runs
+// the same implementation and fails with the same errors
+func TestLuaConverter(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	for name := range Converters {
+		l.Global("wad")
+		l.Field(-1, name)
+		if !l.IsFunction(-1) {
+			t.Errorf("wad.%s is not a function", name)
+		}
+		l.Pop(2)
+	}
+
+	err := lua.DoString(l, `
+		local wav = wad.dmxtowav("\3\0\17\43\4\0\0\0" .. string.rep("\128", 20))
+		local ok, sync = pcall(wad.wavtodmx, wav, 44100)
+		local _, async = pcall(function()
+			return wad.async("wavtodmx", wav, 44100):wait()
+		end)
+		return ok, sync, async`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if l.ToBoolean(-3) {
+		t.Error("converted to an unplayable rate")
+	}
+
+	sync, async := lua.CheckString(l, -2), lua.CheckString(l, -1)
+	if !strings.Contains(sync, "sample rate must be 11025 or 22050") || !strings.HasSuffix(async, sync) {
+		t.Errorf("incorrect errors %q and %q", sync, async)
+	}
+}
+
+// Batches return the results of every job in order and fail on any
+// failed job
+func TestLuaConvertAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wadmake")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// Plant a result that only the cache could return
+	cache := NewCache(dir)
+	key := CacheKey([]byte("endoomtoansi"), []byte("doom"), []byte("sfake"))
+	cache.Put(key, encodeCacheResults([][]byte{[]byte("cached")}))
+
+	l := NewLuaEnvironment()
+	l.PushString(dir)
+	l.SetGlobal("dir")
+
+	err = lua.DoString(l, `
+		wad.setcache(dir)
+		local dmx = "\3\0\17\43\4\0\0\0" .. string.rep("\128", 20)
+		local results = wad.convertall({
+			{"dmxtowav", dmx},
+			{"endoomtoansi", "fake"},
+			{"dmxtowav", dmx, 16},
+		})
+		return #results, #results[1] == 1 and results[1][1] == wad.dmxtowav(dmx),
+			results[2][1], results[3][1] == wad.dmxtowav(dmx, 16)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if count, _ := l.ToInteger(-4); count != 3 {
+		t.Errorf("incorrect result count %d", count)
+	}
+
+	if !l.ToBoolean(-3) || !l.ToBoolean(-1) {
+		t.Error("batch returned different results")
+	}
+
+	if lua.CheckString(l, -2) != "cached" {
+		t.Error("batch did not consult the cache")
+	}
+
+	err = lua.DoString(l, `wad.convertall({{"dmxtowav", "\3\0"}, {"mustomidi", "bad"}})`)
+	if err == nil || !strings.Contains(err.Error(), "job 1: dmxtowav: ") {
+		t.Errorf("incorrect job error %v", err)
+	}
+
+	err = lua.DoString(l, `wad.convertall({{"dmxtowav", {}}})`)
+	if err == nil || !strings.Contains(err.Error(), "job 1: arguments of dmxtowav") {
+		t.Errorf("incorrect argument error %v", err)
+	}
+
+	// Every result of a job is returned
+	Converters["testsplit"] = func(game *GameProfile, args []interface{}) ([][]byte, error) {
+		return [][]byte{[]byte("first"), []byte("second")}, nil
+	}
+	defer delete(Converters, "testsplit")
+
+	err = lua.DoString(l, `
+		local results = wad.convertall({{"testsplit"}})
+		return table.concat(results[1], ",")`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if lua.CheckString(l, -1) != "first,second" {
+		t.Errorf("incorrect results %q", lua.CheckString(l, -1))
+	}
+}
+
+// Panicking conversions fail like any other instead of crashing
+func TestLuaConvertPanic(t *testing.T) {
+	Converters["testpanic"] = func(game *GameProfile, args []interface{}) ([][]byte, error) {
+		panic("out of range")
+	}
+	defer delete(Converters, "testpanic")
+
+	l := NewLuaEnvironment()
+	for _, script := range []string{`wad.async("testpanic"):wait()`, `wad.convertall({{"testpanic"}})`} {
+		err := lua.DoString(l, script)
+		if err == nil || !strings.Contains(err.Error(), "testpanic: out of range") {
+			t.Errorf("%s: incorrect panic error %v", script, err)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+var dehackedMethods = []lua.RegistryFunction{
+	{"checkdehacked", wadCheckDehacked},
+	{"compiledehacked", wadCompileDehacked},
+	{"formatdehacked", wadFormatDehacked},
+	{"parsedehacked", wadParseDehacked},
+}
+
+// Pushes a dehacked value, as an integer if it is one.
+func pushDehackedValue(l *lua.State, value string) {
+	if number, err := strconv.Atoi(value); err == nil {
+		l.PushInteger(number)
+	} else {
+		l.PushString(value)
+	}
+}
+
+// Pushes a table of fields keyed by name.
+func pushDehackedFields(l *lua.State, fields []DehackedField) {
+	l.CreateTable(0, len(fields))
+	for _, field := range fields {
+		pushDehackedValue(l, field.Value)
+		l.SetField(-2, field.Key)
+	}
+}
+
+// Checks for a table of fields in a field of the table at the passed
+// index.  Fields are sorted by name, since table order is undefined.
+func checkDehackedFields(l *lua.State, index int, key string) []DehackedField {
+	index = l.AbsIndex(index)
+	l.Field(index, key)
+	defer l.Pop(1)
+
+	fields := []DehackedField{}
+	if l.IsNil(-1) {
+		return fields
+	} else if !l.IsTable(-1) {
+		lua.Errorf(l, "field %s must be a table", key)
+	}
+
+	l.PushNil()
+	for l.Next(-2) {
+		if l.TypeOf(-2) != lua.TypeString {
+			lua.Errorf(l, "field %s must only have string keys", key)
+		}
+		name, _ := l.ToString(-2)
+		value, ok := l.ToString(-1)
+		if !ok {
+			lua.Errorf(l, "field %s value %s must be a string or number", key, name)
+		}
+		fields = append(fields, DehackedField{name, value})
+		l.Pop(1)
+	}
+
+	sort.Slice(fields, func(a, b int) bool {
+		return fields[a].Key < fields[b].Key
+	})
+
+	return fields
+}
+
+// Pushes an array of block tables.
+func pushDehackedBlocks(l *lua.State, blocks []DehackedBlock) {
+	l.CreateTable(len(blocks), 0)
+	for i := range blocks {
+		l.CreateTable(0, 3)
+		l.PushInteger(blocks[i].Index)
+		l.SetField(-2, "index")
+		if blocks[i].Name != "" {
+			l.PushString(blocks[i].Name)
+			l.SetField(-2, "name")
+		}
+		pushDehackedFields(l, blocks[i].Fields)
+		l.SetField(-2, "fields")
+		l.RawSetInt(-2, i+1)
+	}
+}
+
+// Checks for an array of block tables in a field of the table at the
+// passed index.
+func checkDehackedBlocks(l *lua.State, index int, key string) []DehackedBlock {
+	index = l.AbsIndex(index)
+	l.Field(index, key)
+	defer l.Pop(1)
+
+	if l.IsNil(-1) {
+		return nil
+	} else if !l.IsTable(-1) {
+		lua.Errorf(l, "field %s must be a table", key)
+	}
+
+	blocks := make([]DehackedBlock, lua.LengthEx(l, -1))
+	for i := range blocks {
+		l.RawGetInt(-1, i+1)
+		if !l.IsTable(-1) {
+			lua.Errorf(l, "%s %d must be a table", key, i+1)
+		}
+
+		blocks[i] = DehackedBlock{
+			Index:  tableInteger(l, -1, "index", 0),
+			Name:   tableString(l, -1, "name", ""),
+			Fields: checkDehackedFields(l, -1, "fields"),
+		}
+		l.Pop(1)
+	}
+
+	return blocks
+}
+
+// Pushes a table representation of a dehacked patch.
+func pushDehacked(l *lua.State, patch *DehackedPatch) {
+	l.CreateTable(0, 20)
+	l.PushInteger(patch.DoomVersion)
+	l.SetField(-2, "doomversion")
+	l.PushInteger(patch.PatchFormat)
+	l.SetField(-2, "patchformat")
+
+	blocks := []struct {
+		name   string
+		blocks []DehackedBlock
+	}{
+		{"things", patch.Things},
+		{"frames", patch.Frames},
+		{"pointers", patch.Pointers},
+		{"weapons", patch.Weapons},
+		{"ammo", patch.Ammo},
+		{"sounds", patch.Sounds},
+		{"sprites", patch.Sprites},
+		{"misc", patch.Misc},
+		{"cheats", patch.Cheats},
+	}
+	for _, kind := range blocks {
+		pushDehackedBlocks(l, kind.blocks)
+		l.SetField(-2, kind.name)
+	}
+
+	l.CreateTable(len(patch.Texts), 0)
+	for i, text := range patch.Texts {
+		l.CreateTable(0, 2)
+		l.PushString(text.Old)
+		l.SetField(-2, "old")
+		l.PushString(text.New)
+		l.SetField(-2, "new")
+		l.RawSetInt(-2, i+1)
+	}
+	l.SetField(-2, "texts")
+
+	l.CreateTable(len(patch.Pars), 0)
+	for i, par := range patch.Pars {
+		l.CreateTable(0, 3)
+		l.PushInteger(par.Episode)
+		l.SetField(-2, "episode")
+		l.PushInteger(par.Map)
+		l.SetField(-2, "map")
+		l.PushInteger(par.Par)
+		l.SetField(-2, "par")
+		l.RawSetInt(-2, i+1)
+	}
+	l.SetField(-2, "pars")
+
+	l.CreateTable(0, len(patch.CodePointers))
+	for _, pointer := range patch.CodePointers {
+		l.PushString(pointer.Name)
+		l.RawSetInt(-2, pointer.Frame)
+	}
+	l.SetField(-2, "codepointers")
+
+	sections := []struct {
+		name   string
+		fields []DehackedField
+	}{
+		{"strings", patch.Strings},
+		{"spritenames", patch.SpriteNames},
+		{"soundnames", patch.SoundNames},
+		{"musicnames", patch.MusicNames},
+		{"helper", patch.Helper},
+	}
+	for _, section := range sections {
+		pushDehackedFields(l, section.fields)
+		l.SetField(-2, section.name)
+	}
+
+	pushStrings(l, patch.Includes)
+	l.SetField(-2, "includes")
+}
+
+// Checks for a table representation of a dehacked patch at a specific
+// stack index.
+func checkDehacked(l *lua.State, index int) *DehackedPatch {
+	index = l.AbsIndex(index)
+	lua.CheckType(l, index, lua.TypeTable)
+
+	patch := &DehackedPatch{
+		DoomVersion: tableInteger(l, index, "doomversion", 21),
+		PatchFormat: tableInteger(l, index, "patchformat", 6),
+		Things:      checkDehackedBlocks(l, index, "things"),
+		Frames:      checkDehackedBlocks(l, index, "frames"),
+		Pointers:    checkDehackedBlocks(l, index, "pointers"),
+		Weapons:     checkDehackedBlocks(l, index, "weapons"),
+		Ammo:        checkDehackedBlocks(l, index, "ammo"),
+		Sounds:      checkDehackedBlocks(l, index, "sounds"),
+		Sprites:     checkDehackedBlocks(l, index, "sprites"),
+		Misc:        checkDehackedBlocks(l, index, "misc"),
+		Cheats:      checkDehackedBlocks(l, index, "cheats"),
+		Strings:     checkDehackedFields(l, index, "strings"),
+		SpriteNames: checkDehackedFields(l, index, "spritenames"),
+		SoundNames:  checkDehackedFields(l, index, "soundnames"),
+		MusicNames:  checkDehackedFields(l, index, "musicnames"),
+		Helper:      checkDehackedFields(l, index, "helper"),
+	}
+
+	l.Field(index, "texts")
+	if l.IsTable(-1) {
+		for i := 1; i <= lua.LengthEx(l, -1); i++ {
+			l.RawGetInt(-1, i)
+			if !l.IsTable(-1) {
+				lua.Errorf(l, "text %d must be a table", i)
+			}
+			patch.Texts = append(patch.Texts, DehackedText{
+				Old: tableString(l, -1, "old", ""),
+				New: tableString(l, -1, "new", ""),
+			})
+			l.Pop(1)
+		}
+	}
+	l.Pop(1)
+
+	l.Field(index, "pars")
+	if l.IsTable(-1) {
+		for i := 1; i <= lua.LengthEx(l, -1); i++ {
+			l.RawGetInt(-1, i)
+			if !l.IsTable(-1) {
+				lua.Errorf(l, "par %d must be a table", i)
+			}
+			patch.Pars = append(patch.Pars, DehackedPar{
+				Episode: tableInteger(l, -1, "episode", 0),
+				Map:     tableInteger(l, -1, "map", 0),
+				Par:     tableInteger(l, -1, "par", 0),
+			})
+			l.Pop(1)
+		}
+	}
+	l.Pop(1)
+
+	l.Field(index, "codepointers")
+	if l.IsTable(-1) {
+		l.PushNil()
+		for l.Next(-2) {
+			if l.TypeOf(-2) != lua.TypeNumber {
+				lua.Errorf(l, "codepointers must be keyed by frame number")
+			}
+			frame, _ := l.ToInteger(-2)
+			name, ok := l.ToString(-1)
+			if !ok {
+				lua.Errorf(l, "code pointer for frame %d must be a string", frame)
+			}
+			patch.CodePointers = append(patch.CodePointers, DehackedCodePointer{Frame: frame, Name: name})
+			l.Pop(1)
+		}
+	}
+	l.Pop(1)
+
+	l.Field(index, "includes")
+	if l.IsTable(-1) {
+		patch.Includes = checkStrings(l, -1)
+	}
+	l.Pop(1)
+
+	return patch
+}
+
+// Checks for either dehacked text or a dehacked table at a specific
+// stack index.
+func checkDehackedArg(l *lua.State, index int) *DehackedPatch {
+	if l.TypeOf(index) != lua.TypeString {
+		return checkDehacked(l, index)
+	}
+
+	text, _ := l.ToString(index)
+	patch, err := ParseDehacked(strings.NewReader(text))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	return patch
+}
+
+// Checks for an array of state tables in a field of the table at the
+// passed index.
+func checkDehackedStates(l *lua.State, index int, owner string) []DehackedState {
+	index = l.AbsIndex(index)
+	l.Field(index, "states")
+	defer l.Pop(1)
+
+	if !l.IsTable(-1) {
+		lua.Errorf(l, "%s states must be a table", owner)
+	}
+
+	states := make([]DehackedState, lua.LengthEx(l, -1))
+	for i := range states {
+		l.RawGetInt(-1, i+1)
+		if !l.IsTable(-1) {
+			lua.Errorf(l, "%s state %d must be a table", owner, i+1)
+		}
+
+		state := &states[i]
+		state.Label = tableString(l, -1, "label", "")
+		state.Sprite = tableString(l, -1, "sprite", "")
+		state.Tics = tableInteger(l, -1, "tics", -1)
+		state.Action = tableString(l, -1, "action", "")
+		state.Next = tableString(l, -1, "next", "")
+
+		// Frames are either letters or numbers
+		l.Field(-1, "frame")
+		if l.TypeOf(-1) == lua.TypeString {
+			letter, _ := l.ToString(-1)
+			if len(letter) != 1 {
+				lua.Errorf(l, "%s state %d frame must be a single letter", owner, i+1)
+			}
+			state.Frame = int(strings.ToUpper(letter)[0]) - 'A'
+		} else {
+			state.Frame, _ = l.ToInteger(-1)
+		}
+		l.Pop(1)
+
+		l.Field(-1, "bright")
+		state.Bright = l.ToBoolean(-1)
+		l.Pop(1)
+
+		l.Field(-1, "args")
+		if l.IsTable(-1) {
+			state.Args = checkStrings(l, -1)
+		}
+		l.Pop(2)
+	}
+
+	return states
+}
+
+// Checks for the source of a dehacked patch at a specific stack index.
+func checkDehackedSource(l *lua.State, index int) *DehackedSource {
+	index = l.AbsIndex(index)
+	lua.CheckType(l, index, lua.TypeTable)
+
+	source := &DehackedSource{
+		Strings: checkDehackedFields(l, index, "strings"),
+	}
+
+	l.Field(index, "things")
+	if l.IsTable(-1) {
+		for i := 1; i <= lua.LengthEx(l, -1); i++ {
+			l.RawGetInt(-1, i)
+			if !l.IsTable(-1) {
+				lua.Errorf(l, "thing %d must be a table", i)
+			}
+			name := tableString(l, -1, "name", fmt.Sprintf("%d", i))
+			source.Things = append(source.Things, DehackedThingDef{
+				Name:   name,
+				Number: tableInteger(l, -1, "number", 0),
+				ID:     tableInteger(l, -1, "id", 0),
+				Fields: checkDehackedFields(l, -1, "fields"),
+				States: checkDehackedStates(l, -1, "thing "+name),
+			})
+			l.Pop(1)
+		}
+	}
+	l.Pop(1)
+
+	l.Field(index, "weapons")
+	if l.IsTable(-1) {
+		for i := 1; i <= lua.LengthEx(l, -1); i++ {
+			l.RawGetInt(-1, i)
+			if !l.IsTable(-1) {
+				lua.Errorf(l, "weapon %d must be a table", i)
+			}
+			name := tableString(l, -1, "name", fmt.Sprintf("%d", i))
+			source.Weapons = append(source.Weapons, DehackedWeaponDef{
+				Name:   name,
+				Number: tableInteger(l, -1, "number", -1),
+				Fields: checkDehackedFields(l, -1, "fields"),
+				States: checkDehackedStates(l, -1, "weapon "+name),
+			})
+			l.Pop(1)
+		}
+	}
+	l.Pop(1)
+
+	return source
+}
+
+// Compile a table of things and weapons into MBF21 dehacked text.
+func wadCompileDehacked(l *lua.State) int {
+	source := checkDehackedSource(l, 1)
+
+	patch, err := CompileDehacked(source)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	var buffer bytes.Buffer
+	err = WriteDehacked(&buffer, patch)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(buffer.String())
+	return 1
+}
+
+// Parse dehacked text into a table.
+func wadParseDehacked(l *lua.State) int {
+	text := lua.CheckString(l, 1)
+
+	patch, err := ParseDehacked(strings.NewReader(text))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	pushDehacked(l, patch)
+	return 1
+}
+
+// Format dehacked text or a dehacked table as normalized dehacked text.
+func wadFormatDehacked(l *lua.State) int {
+	patch := checkDehackedArg(l, 1)
+
+	var buffer bytes.Buffer
+	err := WriteDehacked(&buffer, patch)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(buffer.String())
+	return 1
+}
+
+// Validate dehacked text or a dehacked table, returning an array of
+// problems that is empty if the patch is valid.
+func wadCheckDehacked(l *lua.State) int {
+	patch := checkDehackedArg(l, 1)
+
+	problems := []string{}
+	for _, err := range patch.Validate() {
+		problems = append(problems, err.Error())
+	}
+
+	pushStrings(l, problems)
+	return 1
+}
+
+// WadDehackedOpen adds all dehacked-related functions to the table
+// located at the top of the stack of the passed lua state.
+func WadDehackedOpen(l *lua.State) error {
+	lua.SetFunctions(l, dehackedMethods, 0)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"strings"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// Dehacked text parses into tables that can be edited and formatted
+func TestLuaDehacked(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	l.PushString(testDehacked)
+	l.SetGlobal("dehacked")
+
+	err := lua.DoString(l, `
+		local patch = wad.parsedehacked(dehacked)
+		patch.things[1].fields["Hit points"] = 150
+		patch.codepointers[13] = "NotAPointer"
+		return patch.things[1].name, wad.formatdehacked(patch), wad.checkdehacked(patch)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckString(l, -3) != "Player" {
+		t.Error("incorrect thing name")
+	}
+
+	if !strings.Contains(lua.CheckString(l, -2), "Hit points = 150\n") {
+		t.Error("thing edit was lost")
+	}
+
+	problems := checkStrings(l, -1)
+	if len(problems) != 1 || !strings.Contains(problems[0], "NotAPointer") {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
+
+// A table of things compiles into dehacked text
+func TestLuaCompileDehacked(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local text = wad.compiledehacked({
+			things = {{
+				name = "Lamp", id = 20000, fields = {["Hit points"] = 50},
+				states = {
+					{label = "spawn", sprite = "LAMP", frame = "A", tics = 4},
+					{sprite = "LAMP", frame = "B", tics = 4, next = "spawn"},
+				},
+			}},
+		})
+		return text, wad.checkdehacked(text)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	text := lua.CheckString(l, -2)
+	for _, expected := range []string{"Thing 151 (Lamp)\n", "Frame 1090\n", "Next frame = 1089\n", "SP00 = LAMP\n"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("missing %q", expected)
+		}
+	}
+
+	if problems := checkStrings(l, -1); len(problems) != 0 {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// ENDOOM converts between ANSI text and lumps, and renders to PNG
+func TestLuaEndoom(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local endoom = wad.ansitoendoom("\27[1;32mHello\27[0m")
+		return #endoom, wad.endoomtoansi(endoom), wad.endoomtopng(endoom)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if size, _ := l.ToInteger(-3); size != 4000 {
+		t.Errorf("incorrect ENDOOM size %d", size)
+	}
+
+	if text := lua.CheckString(l, -2); !strings.HasPrefix(text, "\x1b[0;1;32;40mHello\x1b[0;37;40m ") {
+		t.Errorf("incorrect ANSI %q", text[:30])
+	}
+
+	img, err := png.Decode(bytes.NewReader([]byte(lua.CheckString(l, -1))))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if img.Bounds().Dx() != 640 {
+		t.Errorf("incorrect PNG width %d", img.Bounds().Dx())
+	}
+}
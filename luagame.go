@@ -0,0 +1,92 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	lua "github.com/Shopify/go-lua"
+)
+
+var gameMethods = []lua.RegistryFunction{
+	{"getgame", wadGetGame},
+	{"setgame", wadSetGame},
+}
+
+// The registry field holding the name of the current game.
+const gameRegistryKey = "wadmake.game"
+
+// Returns the profile of the game selected with wad.setgame, which is
+// Doom unless another game was selected.
+func currentGame(l *lua.State) *GameProfile {
+	l.Field(lua.RegistryIndex, gameRegistryKey)
+	name, _ := l.ToString(-1)
+	l.Pop(1)
+
+	if game, ok := LookupGame(name); ok {
+		return game
+	}
+	return GameDoom
+}
+
+// Select the game that conversions produce lumps for by name.
+func wadSetGame(l *lua.State) int {
+	name := lua.CheckString(l, 1)
+
+	game, ok := LookupGame(name)
+	if !ok {
+		lua.ArgumentError(l, 1, "unknown game '"+name+"'")
+	}
+
+	l.PushString(game.Name)
+	l.SetField(lua.RegistryIndex, gameRegistryKey)
+	return 0
+}
+
+// Return a table describing the profile of the current game.
+func wadGetGame(l *lua.State) int {
+	game := currentGame(l)
+
+	l.CreateTable(0, 7)
+	l.PushString(game.Name)
+	l.SetField(-2, "name")
+	l.PushString(textureFormatNames[game.TextureFormat])
+	l.SetField(-2, "textureformat")
+	l.PushString(acsFormatNames[game.ACSFormat])
+	l.SetField(-2, "acsformat")
+	l.PushBoolean(game.PCSpeaker)
+	l.SetField(-2, "pcspeaker")
+	l.PushBoolean(game.TallFlats)
+	l.SetField(-2, "tallflats")
+	l.PushBoolean(game.Voices)
+	l.SetField(-2, "voices")
+	if game.EndText != "" {
+		l.PushString(game.EndText)
+		l.SetField(-2, "endtext")
+	}
+
+	return 1
+}
+
+// WadGameOpen adds all game profile functions to the table located at
+// the top of the stack of the passed lua state.
+func WadGameOpen(l *lua.State) error {
+	lua.SetFunctions(l, gameMethods, 0)
+
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// The current game is Doom until another is selected
+func TestLuaGetGame(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local doom = wad.getgame()
+		wad.setgame("Heretic")
+		local heretic = wad.getgame()
+		local ok, err = pcall(wad.setgame, "quake")
+		return doom.name, doom.endtext, heretic.name, heretic.pcspeaker, err`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckString(l, -5) != "doom" || lua.CheckString(l, -4) != "ENDOOM" {
+		t.Error("incorrect default game")
+	}
+
+	if lua.CheckString(l, -3) != "heretic" || l.ToBoolean(-2) {
+		t.Error("incorrect selected game")
+	}
+
+	if lua.CheckString(l, -1) != "bad argument #1 to '?' (unknown game 'quake')" {
+		t.Errorf("unexpected error %q", lua.CheckString(l, -1))
+	}
+}
+
+// Conversions produce lumps for the current game
+func TestLuaSetGame(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local textures = {{name = "DOOR", width = 64, height = 64, patches = {{name = "DOOR1", x = 0, y = 0}}}}
+		local doom = wad.encodetextures(textures)
+
+		wad.setgame("strife")
+		local strife = wad.encodetextures(textures)
+		local ok, speaker = pcall(wad.encodepcspeaker, {1, 2, 3})
+
+		wad.setgame("hexen")
+		local ok, acs = pcall(wad.compileacs, "function void f(void) {}")
+
+		return #doom, #strife, speaker, acs`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckInteger(l, -4) != 40 || lua.CheckInteger(l, -3) != 32 {
+		t.Error("incorrect texture formats")
+	}
+
+	if lua.CheckString(l, -2) != "strife has no PC speaker sounds" {
+		t.Errorf("unexpected error %q", lua.CheckString(l, -2))
+	}
+
+	if lua.CheckString(l, -1) != "SCRIPTS: ACS0 does not support functions" {
+		t.Errorf("unexpected error %q", lua.CheckString(l, -1))
+	}
+}
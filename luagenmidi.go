@@ -0,0 +1,197 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	lua "github.com/Shopify/go-lua"
+)
+
+var genmidiMethods = []lua.RegistryFunction{
+	{"decodegenmidi", wadDecodeGENMIDI},
+	{"encodegenmidi", wadEncodeGENMIDI},
+	{"importoplbank", wadImportOPLBank},
+}
+
+// Pushes a table representation of an OPL operator.
+func pushOPLOperator(l *lua.State, op *OPLOperator) {
+	l.CreateTable(0, 6)
+	fields := []struct {
+		name  string
+		value uint8
+	}{
+		{"characteristic", op.Characteristic},
+		{"attack", op.Attack},
+		{"sustain", op.Sustain},
+		{"waveform", op.Waveform},
+		{"scale", op.Scale},
+		{"level", op.Level},
+	}
+	for _, field := range fields {
+		l.PushInteger(int(field.value))
+		l.SetField(-2, field.name)
+	}
+}
+
+// Checks for an OPL operator table in a field of the table at the
+// passed index.
+func checkOPLOperator(l *lua.State, index int, key string) OPLOperator {
+	l.Field(index, key)
+	defer l.Pop(1)
+
+	if !l.IsTable(-1) {
+		lua.Errorf(l, "field %s must be a table", key)
+	}
+
+	return OPLOperator{
+		Characteristic: uint8(tableInteger(l, -1, "characteristic", 0)),
+		Attack:         uint8(tableInteger(l, -1, "attack", 0)),
+		Sustain:        uint8(tableInteger(l, -1, "sustain", 0)),
+		Waveform:       uint8(tableInteger(l, -1, "waveform", 0)),
+		Scale:          uint8(tableInteger(l, -1, "scale", 0)),
+		Level:          uint8(tableInteger(l, -1, "level", 0)),
+	}
+}
+
+// Pushes an array of instrument tables.
+func pushInstruments(l *lua.State, instruments []Instrument) {
+	l.CreateTable(len(instruments), 0)
+	for i := range instruments {
+		instrument := &instruments[i]
+
+		l.CreateTable(0, 5)
+		l.PushString(instrument.Name)
+		l.SetField(-2, "name")
+		l.PushInteger(int(instrument.Flags))
+		l.SetField(-2, "flags")
+		l.PushInteger(int(instrument.FineTune))
+		l.SetField(-2, "finetune")
+		l.PushInteger(int(instrument.FixedNote))
+		l.SetField(-2, "fixednote")
+
+		l.CreateTable(len(instrument.Voices), 0)
+		for v := range instrument.Voices {
+			voice := &instrument.Voices[v]
+
+			l.CreateTable(0, 4)
+			pushOPLOperator(l, &voice.Modulator)
+			l.SetField(-2, "modulator")
+			pushOPLOperator(l, &voice.Carrier)
+			l.SetField(-2, "carrier")
+			l.PushInteger(int(voice.Feedback))
+			l.SetField(-2, "feedback")
+			l.PushInteger(int(voice.BaseNoteOffset))
+			l.SetField(-2, "basenote")
+			l.RawSetInt(-2, v+1)
+		}
+		l.SetField(-2, "voices")
+
+		l.RawSetInt(-2, i+1)
+	}
+}
+
+// Checks for an array of instrument tables at a specific stack index.
+func checkInstruments(l *lua.State, index int) []Instrument {
+	index = l.AbsIndex(index)
+	lua.CheckType(l, index, lua.TypeTable)
+
+	instruments := make([]Instrument, lua.LengthEx(l, index))
+	for i := range instruments {
+		l.RawGetInt(index, i+1)
+		if !l.IsTable(-1) {
+			lua.Errorf(l, "instrument %d must be a table", i+1)
+		}
+
+		instrument := &instruments[i]
+		instrument.Name = tableString(l, -1, "name", "")
+		instrument.Flags = uint16(tableInteger(l, -1, "flags", 0))
+		instrument.FineTune = uint8(tableInteger(l, -1, "finetune", 128))
+		instrument.FixedNote = uint8(tableInteger(l, -1, "fixednote", 0))
+
+		l.Field(-1, "voices")
+		if !l.IsTable(-1) {
+			lua.Errorf(l, "instrument %d voices must be a table", i+1)
+		}
+		for v := range instrument.Voices {
+			l.RawGetInt(-1, v+1)
+			if l.IsTable(-1) {
+				voice := &instrument.Voices[v]
+				voice.Modulator = checkOPLOperator(l, -1, "modulator")
+				voice.Carrier = checkOPLOperator(l, -1, "carrier")
+				voice.Feedback = uint8(tableInteger(l, -1, "feedback", 0))
+				voice.BaseNoteOffset = int16(tableInteger(l, -1, "basenote", 0))
+			} else if !l.IsNil(-1) {
+				lua.Errorf(l, "instrument %d voice %d must be a table", i+1, v+1)
+			}
+			l.Pop(1)
+		}
+		l.Pop(2)
+	}
+
+	return instruments
+}
+
+// Decode GENMIDI data into an array of instruments.
+func wadDecodeGENMIDI(l *lua.State) int {
+	data := lua.CheckString(l, 1)
+
+	instruments, err := DecodeGENMIDI([]byte(data))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	pushInstruments(l, instruments)
+	return 1
+}
+
+// Encode an array of instruments into GENMIDI data.
+func wadEncodeGENMIDI(l *lua.State) int {
+	instruments := checkInstruments(l, 1)
+
+	data, err := EncodeGENMIDI(instruments)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(string(data))
+	return 1
+}
+
+// Import an OPL instrument bank as an array of instruments.  The
+// format is detected from the data if not passed.
+func wadImportOPLBank(l *lua.State) int {
+	data := lua.CheckString(l, 1)
+	format := lua.OptString(l, 2, "")
+
+	instruments, err := ImportOPLBank([]byte(data), format)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	pushInstruments(l, instruments)
+	return 1
+}
+
+// WadGENMIDIOpen adds all GENMIDI-related functions to the table
+// located at the top of the stack of the passed lua state.
+func WadGENMIDIOpen(l *lua.State) error {
+	lua.SetFunctions(l, genmidiMethods, 0)
+
+	return nil
+}
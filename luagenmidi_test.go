@@ -0,0 +1,60 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// GENMIDI decodes into instrument tables that can be edited and encoded
+func TestLuaGENMIDI(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	data, err := EncodeGENMIDI(testInstruments())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	l.PushString(string(data))
+	l.SetGlobal("genmidi")
+
+	err = lua.DoString(l, `
+		local instruments = wad.importoplbank(genmidi)
+		instruments[1].name = "Tuned Piano"
+		instruments[1].voices[1].carrier.level = 4
+		local edited = wad.decodegenmidi(wad.encodegenmidi(instruments))
+		return #edited, edited[1].name, edited[1].voices[1].carrier.level, edited[129].fixednote`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckInteger(l, -4) != GENMIDIInstruments {
+		t.Error("incorrect instrument count")
+	}
+
+	if lua.CheckString(l, -3) != "Tuned Piano" || lua.CheckInteger(l, -2) != 4 {
+		t.Error("instrument edits were lost")
+	}
+
+	if lua.CheckInteger(l, -1) != 60 {
+		t.Error("incorrect percussion note")
+	}
+}
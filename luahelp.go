@@ -0,0 +1,344 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+var helpMethods = []lua.RegistryFunction{
+	{"help", wadHelp},
+}
+
+// luaDoc documents a function of the wad library or a method of one of
+// its userdata types.
+type luaDoc struct {
+	signature   string
+	description string
+}
+
+// luaDocs holds the documentation shown by wad.help, by the name the
+// function is reached with.  Methods are named after the handle of
+// their userdata type, such as Lumps:insert.
+var luaDocs = map[string]luaDoc{
+	"wad.ansitoendoom":        {"wad.ansitoendoom(text) -> data", "Convert text with ANSI escape sequences, in UTF-8 or code page 437, into ENDOOM data."},
+	"wad.async":               {"wad.async(name, ...) -> future", "Start the named conversion with the passed arguments on a background worker, returning a Future to wait on."},
+	"wad.checkdehacked":       {"wad.checkdehacked(patch) -> problems", "Validate dehacked text or a dehacked table, returning an array of problems that is empty if the patch is valid."},
+	"wad.checkmapinfo":        {"wad.checkmapinfo(text, lumps, ...) -> problems", "Check that every lump referenced by MAPINFO text exists in one of the passed Lumps."},
+	"wad.compileacs":          {"wad.compileacs(source, [name], [includedirs], [format]) -> data", "Compile ACS source into a BEHAVIOR lump.  The format is auto, acs0 or acse, and defaults to the format of the current game."},
+	"wad.compiledehacked":     {"wad.compiledehacked(source) -> text", "Compile a table of states, things and weapons into MBF21 dehacked text."},
+	"wad.compileswantbls":     {"wad.compileswantbls(text) -> animated, switches", "Compile DEFSWANI.DAT text into ANIMATED and SWITCHES data."},
+	"wad.compiletextures":     {"wad.compiletextures(text1, [text2], [syntax], [format]) -> texture1, [texture2], pnames", "Compile deutex or zdoom texture definition text into TEXTURE1, TEXTURE2 and PNAMES data."},
+	"wad.convertall":          {"wad.convertall(jobs) -> results", "Run an array of conversions on a pool of workers, each written as {name, ...}, returning an array of the results of each."},
+	"wad.createLumps":         {"wad.createLumps() -> lumps", "Create an empty Lumps."},
+	"wad.decodeanimated":      {"wad.decodeanimated(data) -> animations", "Decode ANIMATED data into an array of animations."},
+	"wad.decodebehavior":      {"wad.decodebehavior(data) -> behavior", "Decode a BEHAVIOR lump into a table of its scripts, functions, strings, map variables and libraries."},
+	"wad.decodegenmidi":       {"wad.decodegenmidi(data) -> instruments", "Decode GENMIDI data into an array of instruments."},
+	"wad.decodepcspeaker":     {"wad.decodepcspeaker(data) -> tones", "Decode PC speaker sound data into an array of tones."},
+	"wad.decodepnames":        {"wad.decodepnames(data) -> names", "Decode PNAMES data into an array of patch names."},
+	"wad.decodeswitches":      {"wad.decodeswitches(data) -> switches", "Decode SWITCHES data into an array of switches."},
+	"wad.decodetextures":      {"wad.decodetextures(data, pnames, [format]) -> textures", "Decode TEXTURE1 or TEXTURE2 data into an array of textures, using PNAMES data to resolve patch names."},
+	"wad.decompileswantbls":   {"wad.decompileswantbls(animated, switches) -> text", "Decompile ANIMATED and SWITCHES data into DEFSWANI.DAT text.  Either lump can be nil."},
+	"wad.decompiletextures":   {"wad.decompiletextures(data, pnames, [syntax], [format]) -> text", "Decompile TEXTURE1 or TEXTURE2 data into texture definition text."},
+	"wad.disassemblebehavior": {"wad.disassemblebehavior(data) -> text", "Disassemble a BEHAVIOR lump into a text listing."},
+	"wad.dmxtopcspeaker":      {"wad.dmxtopcspeaker(data) -> data", "Approximate DMX sound data as PC speaker sound data, if the current game plays PC speaker sounds."},
+	"wad.dmxtowav":            {"wad.dmxtowav(data, [bits]) -> wav", "Convert DMX sound data into WAV data with 8 or 16 bit samples."},
+	"wad.encodeanimated":      {"wad.encodeanimated(animations) -> data", "Encode an array of animations into ANIMATED data."},
+	"wad.encodegenmidi":       {"wad.encodegenmidi(instruments) -> data", "Encode an array of instruments into GENMIDI data."},
+	"wad.encodepcspeaker":     {"wad.encodepcspeaker(tones) -> data", "Encode an array of tones into PC speaker sound data, if the current game plays PC speaker sounds."},
+	"wad.encodepnames":        {"wad.encodepnames(names) -> data", "Encode an array of patch names into PNAMES data."},
+	"wad.encodeswitches":      {"wad.encodeswitches(switches) -> data", "Encode an array of switches into SWITCHES data."},
+	"wad.encodetextures":      {"wad.encodetextures(textures1, [textures2], [format]) -> texture1, [texture2], pnames", "Encode arrays of textures into TEXTURE1, TEXTURE2 and PNAMES data."},
+	"wad.endoomtoansi":        {"wad.endoomtoansi(data) -> text", "Convert ENDOOM data into UTF-8 text with ANSI escape sequences."},
+	"wad.endoomtopng":         {"wad.endoomtopng(data) -> png", "Render ENDOOM data into a PNG image."},
+	"wad.formatdehacked":      {"wad.formatdehacked(patch) -> text", "Format dehacked text or a dehacked table as normalized dehacked text."},
+	"wad.formattextures":      {"wad.formattextures(textures, [syntax]) -> text", "Format an array of textures as deutex or zdoom texture definition text."},
+	"wad.fromjson":            {"wad.fromjson(text, [dir]) -> lumps, type", "Rebuild Lumps from a JSON manifest, reading sidecar files from a directory that defaults to the current one."},
+	"wad.fromyaml":            {"wad.fromyaml(text, [dir]) -> lumps, type", "Rebuild Lumps from a YAML manifest, reading sidecar files from a directory that defaults to the current one."},
+	"wad.getcache":            {"wad.getcache() -> dir", "Return the directory of the build cache, or nil if there is none."},
+	"wad.getgame":             {"wad.getgame() -> game", "Return a table describing the profile of the current game."},
+	"wad.help":                {"wad.help([value])", "Print the documentation of a wad function or method, or list every function without a value."},
+	"wad.importoplbank":       {"wad.importoplbank(data, [format]) -> instruments", "Import an op2, wopl or tmb OPL instrument bank as an array of instruments."},
+	"wad.mergetextures":       {"wad.mergetextures(lumps, ..., [policy]) -> lumps", "Merge the textures of any number of Lumps, resolving conflicts with the first, last or error policy."},
+	"wad.miditomus":           {"wad.miditomus(data) -> mus, [warning]", "Convert MIDI data into MUS data, with a warning if it is too large for vanilla Doom."},
+	"wad.mustomidi":           {"wad.mustomidi(data) -> midi", "Convert MUS data into MIDI data."},
+	"wad.parsedehacked":       {"wad.parsedehacked(text) -> patch", "Parse DeHackEd or BEX text into a table."},
+	"wad.parsemapinfo":        {"wad.parsemapinfo(text, [name]) -> maps", "Parse UMAPINFO, MAPINFO or ZMAPINFO text into a table."},
+	"wad.parsetextures":       {"wad.parsetextures(text, [syntax]) -> textures", "Parse deutex or zdoom texture definition text into an array of textures."},
+	"wad.pcmtodmx":            {"wad.pcmtodmx(data, rate, channels, bits, [dmxrate]) -> data", "Convert raw PCM data into DMX sound data at 11025 or 22050 Hz."},
+	"wad.pcspeakertowav":      {"wad.pcspeakertowav(data, [rate]) -> wav", "Render PC speaker sound data into WAV data for previewing."},
+	"wad.readwad":             {"wad.readwad(filename) -> type, lumps", "Read a WAD file from disk, returning its type and its Lumps."},
+	"wad.setcache":            {"wad.setcache(dir)", "Make conversions and lump packers consult a build cache stored in the passed directory, or stop consulting one if it is nil."},
+	"wad.setgame":             {"wad.setgame(name)", "Select the game that conversions produce lumps for: doom, heretic, hexen or strife."},
+	"wad.target":              {"wad.target(name, [options], [fn])", "Declare a build target, with an array of target names to build first in options.deps."},
+	"wad.unpackwad":           {"wad.unpackwad(data) -> type, lumps", "Read WAD data from a string, returning its type and its Lumps."},
+	"wad.unpackzip":           {"wad.unpackzip(data) -> lumps", "Read ZIP data from a string, returning Lumps named by the path of each file."},
+	"wad.wavtodmx":            {"wad.wavtodmx(data, [rate]) -> data", "Convert WAV data into DMX sound data at 11025 or 22050 Hz."},
+
+	"Lumps:find":     {"lumps:find(name, [start]) -> index", "Find a lump by name, optionally starting at an index.  Returns nil if not found."},
+	"Lumps:get":      {"lumps:get(index) -> name, data", "Return the name and data of the lump at an index, or nil if there is none."},
+	"Lumps:insert":   {"lumps:insert([index], name, data)", "Insert a lump at an index, or at the end without one."},
+	"Lumps:packwad":  {"lumps:packwad() -> data", "Pack the lumps into WAD data."},
+	"Lumps:packzip":  {"lumps:packzip() -> data", "Pack the lumps into ZIP data, using their names as paths."},
+	"Lumps:remove":   {"lumps:remove(index)", "Remove the lump at an index."},
+	"Lumps:set":      {"lumps:set(index, [name], [data])", "Replace the name, data or both of the lump at an index."},
+	"Lumps:tojson":   {"lumps:tojson([data]) -> text, [files]", "Describe the lumps as a JSON manifest, keeping data as hashes, base64 or sidecar files returned by name."},
+	"Lumps:toyaml":   {"lumps:toyaml([data]) -> text, [files]", "Describe the lumps as a YAML manifest, keeping data as hashes, base64 or sidecar files returned by name."},
+	"Lumps:type":     {"lumps:type(index) -> type", "Detect the type of the lump at an index from its name, data and place, such as picture, flat, png, dmx, mus or text."},
+	"Lumps:writewad": {"lumps:writewad(filename)", "Write the lumps to a WAD file, leaving a file that already holds the same data untouched."},
+	"Lumps:writezip": {"lumps:writezip(filename)", "Write the lumps to a ZIP file, leaving a file that already holds the same data untouched."},
+
+	"Future:ready": {"future:ready() -> done", "Return true if the conversion has finished."},
+	"Future:wait":  {"future:wait() -> ...", "Wait for the conversion to finish and return its results, or raise its error."},
+}
+
+// The handles of the userdata types that have documented methods.
+var luaDocHandles = []string{lumpsHandle, futureHandle}
+
+// Returns the name of a documented function at a specific stack index,
+// found by looking for it in the wad library and the metatables of its
+// userdata types.
+func luaDocName(l *lua.State, index int) (string, bool) {
+	index = l.AbsIndex(index)
+
+	tables := []string{"wad"}
+	l.Global("wad")
+	for _, handle := range luaDocHandles {
+		tables = append(tables, handle)
+		lua.MetaTableNamed(l, handle)
+	}
+	defer l.Pop(len(tables))
+
+	for i, table := range tables {
+		tableIndex := l.Top() - len(tables) + 1 + i
+		if !l.IsTable(tableIndex) {
+			continue
+		}
+
+		l.PushNil()
+		for l.Next(tableIndex) {
+			if l.TypeOf(-2) == lua.TypeString && l.RawEqual(-1, index) {
+				key, _ := l.ToString(-2)
+				l.Pop(2)
+				if table == "wad" {
+					return "wad." + key, true
+				}
+				return table + ":" + key, true
+			}
+			l.Pop(1)
+		}
+	}
+
+	return "", false
+}
+
+// Returns the sorted names of every documented function starting with
+// the passed prefix.
+func luaDocNames(prefix string) []string {
+	names := []string{}
+	for name := range luaDocs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Formats the documentation of the value at a specific stack index.
+// Functions show their signature and description, names of functions
+// are looked up in the wad library and then as methods, and the wad
+// library, userdata or nothing at all list the functions available.
+func luaHelp(l *lua.State, index int) (string, bool) {
+	var names []string
+	switch l.TypeOf(index) {
+	case lua.TypeNone, lua.TypeNil:
+		names = luaDocNames("")
+	case lua.TypeString:
+		name, _ := l.ToString(index)
+		candidates := []string{name, "wad." + name}
+		for _, handle := range luaDocHandles {
+			candidates = append(candidates, handle+":"+name)
+		}
+		for _, candidate := range candidates {
+			if _, ok := luaDocs[candidate]; ok {
+				names = []string{candidate}
+				break
+			}
+		}
+	case lua.TypeTable:
+		l.Global("wad")
+		if l.RawEqual(-1, index) {
+			names = luaDocNames("wad.")
+		}
+		l.Pop(1)
+	case lua.TypeUserData:
+		for _, handle := range luaDocHandles {
+			if lua.TestUserData(l, index, handle) != nil {
+				names = luaDocNames(handle + ":")
+			}
+		}
+	case lua.TypeFunction:
+		if name, ok := luaDocName(l, index); ok {
+			names = []string{name}
+		}
+	}
+
+	if len(names) == 0 {
+		return "", false
+	}
+
+	// A single function is shown in full, and lists of functions only
+	// show signatures.
+	if len(names) == 1 {
+		doc := luaDocs[names[0]]
+		return fmt.Sprintf("%s\n  %s\n", doc.signature, doc.description), true
+	}
+
+	var text strings.Builder
+	for _, name := range names {
+		text.WriteString(luaDocs[name].signature + "\n")
+	}
+	return text.String(), true
+}
+
+// Print the documentation of a wad library function or method, or list
+// the functions of the wad library or of userdata.
+func wadHelp(l *lua.State) int {
+	text, ok := luaHelp(l, 1)
+	if !ok {
+		lua.Errorf(l, "no help for %s", lua.TypeNameOf(l, 1))
+	}
+
+	fmt.Fprint(os.Stdout, text)
+	return 0
+}
+
+// Returns true if the passed character can be part of a Lua name.
+func isLuaName(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
+
+// Pushes the table holding the fields of the value at the top of the
+// stack, replacing it.  Tables hold their own fields, while userdata
+// and strings hold them in the __index table of their metatable.
+// Pushes nil if there are no fields.
+func luaFields(l *lua.State) {
+	if l.IsTable(-1) {
+		return
+	}
+
+	if l.MetaTable(-1) {
+		l.Field(-1, "__index")
+		l.Remove(-2)
+		if l.IsTable(-1) {
+			l.Remove(-2)
+			return
+		}
+		l.Pop(1)
+	}
+
+	l.Pop(1)
+	l.PushNil()
+}
+
+// LuaCompletions returns the sorted names that can complete the global
+// name, field or method being typed at the end of text, along with the
+// part of the name already typed.  Only tables and the metatables of
+// userdata are looked into, so that completing never runs Lua code.
+func LuaCompletions(l *lua.State, text string) ([]string, string) {
+	start := len(text)
+	for start > 0 {
+		c := text[start-1]
+		if !isLuaName(c) && c != '.' && c != ':' {
+			break
+		}
+		start--
+	}
+
+	word := text[start:]
+	if word != "" && word[0] >= '0' && word[0] <= '9' {
+		return nil, ""
+	}
+
+	top := l.Top()
+	defer l.SetTop(top)
+
+	// Follow the path of fields leading up to the name being typed
+	l.PushGlobalTable()
+	methods := false
+	for {
+		sep := strings.IndexAny(word, ".:")
+		if sep == -1 {
+			break
+		} else if methods {
+			// Methods cannot have fields of their own
+			return nil, ""
+		}
+
+		luaFields(l)
+		if l.IsNil(-1) {
+			return nil, ""
+		}
+
+		l.PushString(word[:sep])
+		l.RawGet(-2)
+		l.Remove(-2)
+
+		methods = word[sep] == ':'
+		word = word[sep+1:]
+	}
+
+	luaFields(l)
+	if l.IsNil(-1) {
+		return nil, ""
+	}
+
+	names := []string{}
+	l.PushNil()
+	for l.Next(-2) {
+		if l.TypeOf(-2) == lua.TypeString {
+			name, _ := l.ToString(-2)
+			if strings.HasPrefix(name, word) && !strings.HasPrefix(name, "__") &&
+				(!methods || l.IsFunction(-1)) {
+				names = append(names, name)
+			}
+		}
+		l.Pop(1)
+	}
+	sort.Strings(names)
+
+	return names, word
+}
+
+// WadHelpOpen adds the help function to the table located at the top
+// of the stack of the passed lua state.
+func WadHelpOpen(l *lua.State) error {
+	lua.SetFunctions(l, helpMethods, 0)
+
+	return nil
+}
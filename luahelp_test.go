@@ -0,0 +1,145 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// Every function of the wad library and its userdata is documented
+func TestLuaDocs(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	found := map[string]bool{}
+	check := func(prefix string) {
+		l.PushNil()
+		for l.Next(-2) {
+			name, _ := l.ToString(-2)
+			if l.IsFunction(-1) && !strings.HasPrefix(name, "__") {
+				found[prefix+name] = true
+				if _, ok := luaDocs[prefix+name]; !ok {
+					t.Errorf("%s%s is not documented", prefix, name)
+				}
+			}
+			l.Pop(1)
+		}
+		l.Pop(1)
+	}
+
+	l.Global("wad")
+	check("wad.")
+	for _, handle := range luaDocHandles {
+		lua.MetaTableNamed(l, handle)
+		check(handle + ":")
+	}
+
+	for name := range luaDocs {
+		if !found[name] {
+			t.Errorf("%s is documented but does not exist", name)
+		}
+	}
+}
+
+// Help is found by function value, by name and for whole libraries
+func TestLuaHelp(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"return wad.readwad", "wad.readwad(filename) -> type, lumps\n  Read a WAD file"},
+		{"return target", "wad.target(name, [options], [fn])\n"},
+		{"return 'insert'", "lumps:insert([index], name, data)\n"},
+		{"return wad.createLumps()", "lumps:find(name, [start]) -> index\nlumps:get(index)"},
+		{"return wad", "wad.ansitoendoom(text) -> data\nwad.async("},
+		{"return nil", "Future:"},
+	}
+
+	for _, test := range tests {
+		err := lua.DoString(l, test.code)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		text, ok := luaHelp(l, -1)
+		if !ok {
+			t.Errorf("no help for %q", test.code)
+		} else if test.expected == "Future:" {
+			if !strings.Contains(text, "future:wait()") || !strings.Contains(text, "lumps:set(") {
+				t.Errorf("incomplete list of functions %q", text)
+			}
+		} else if !strings.HasPrefix(text, test.expected) {
+			t.Errorf("incorrect help for %q: %q", test.code, text)
+		}
+		l.SetTop(0)
+	}
+
+	l.Global("print")
+	if _, ok := luaHelp(l, -1); ok {
+		t.Error("found help for an undocumented function")
+	}
+}
+
+// Completions follow fields of tables and methods of userdata
+func TestLuaCompletions(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `lumps = wad.createLumps() name = "MAP01" level = {map = 1}`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		text     string
+		expected []string
+		prefix   string
+	}{
+		{"x = wad.rea", []string{"readwad"}, "rea"},
+		{"wad.dmxto", []string{"dmxtopcspeaker", "dmxtowav"}, "dmxto"},
+		{"lumps:in", []string{"insert"}, "in"},
+		{"print(lumps:", []string{"find", "get", "insert", "packwad", "packzip", "remove", "set", "tojson", "toyaml", "type", "writewad", "writezip"}, ""},
+		{"le", []string{"level"}, "le"},
+		{"level.", []string{"map"}, ""},
+		{"name:up", []string{"upper"}, "up"},
+		{"string.fo", []string{"format"}, "fo"},
+		{"lumps.x.", nil, ""},
+		{"lumps:get:", nil, ""},
+		{"1.", nil, ""},
+	}
+
+	for _, test := range tests {
+		names, prefix := LuaCompletions(l, test.text)
+		if len(names) != len(test.expected) || len(names) > 0 && !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("incorrect completions of %q: %v", test.text, names)
+		}
+		if prefix != test.prefix {
+			t.Errorf("incorrect prefix of %q: %q", test.text, prefix)
+		}
+	}
+
+	if l.Top() != 0 {
+		t.Errorf("completion left %d values on the stack", l.Top())
+	}
+}
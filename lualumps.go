@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"io/ioutil"
 	"os"
 	"strings"
 
@@ -35,7 +36,7 @@ var wadMethods = []lua.RegistryFunction{
 	{"createLumps", wadCreateLumps},
 	{"readwad", wadReadWAD},
 	{"unpackwad", wadUnpackWAD},
-	{"unpackzip", nil},
+	{"unpackzip", wadUnpackZip},
 }
 
 // Create empty Lumps userdata
@@ -49,7 +50,7 @@ func wadCreateLumps(l *lua.State) int {
 // Load WAD file from disk and return the WAD type and lumps
 func wadReadWAD(l *lua.State) int {
 	// Read WAD data from filename parameter
-	filename := lua.CheckString(l, 1)
+	filename := checkReadPath(l, 1)
 
 	file, err := os.Open(filename)
 	if err != nil {
@@ -92,16 +93,34 @@ func commonUnpackWAD(l *lua.State, r io.ReadSeeker) int {
 	return 2
 }
 
+// Read ZIP file data and return the files in it as lumps named by path
+func wadUnpackZip(l *lua.State) int {
+	buffer := lua.CheckString(l, 1)
+
+	dir, err := DecodeZip(strings.NewReader(buffer), int64(len(buffer)))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushUserData(&dir)
+	lua.SetMetaTableNamed(l, lumpsHandle)
+
+	return 1
+}
+
 var lumpsMethods = []lua.RegistryFunction{
 	{"find", lumpsFind},
 	{"get", lumpsGet},
 	{"insert", lumpsInsert},
 	{"packwad", lumpsPackWAD},
-	{"packzip", nil},
+	{"packzip", lumpsPackZip},
 	{"remove", lumpsRemove},
 	{"set", lumpsSet},
+	{"tojson", lumpsToJSON},
+	{"toyaml", lumpsToYAML},
+	{"type", lumpsType},
 	{"writewad", lumpsWriteWAD},
-	{"writezip", nil},
+	{"writezip", lumpsWriteZip},
 	{"__len", lumpsLen},
 	{"__tostring", lumpsToString},
 }
@@ -172,6 +191,23 @@ func lumpsGet(l *lua.State) int {
 	return 2
 }
 
+// Returns the type of the lump at an index as detected from its name,
+// data and place in the directory, or nil if nothing was found.
+func lumpsType(l *lua.State) int {
+	data := checkLumps(l, 1)
+	index := lua.CheckInteger(l, 2)
+	if index < 1 || index > len(*data) {
+		l.PushNil()
+		return 1
+	}
+
+	lump := (*data)[index-1]
+	context := LumpContexts(*data, currentGame(l))[index-1]
+	l.PushString(DetectType(lump.Name, lump.Data, context).String())
+
+	return 1
+}
+
 // Insert lump data into the directory.
 func lumpsInsert(l *lua.State) int {
 	data := checkLumps(l, 1)
@@ -203,23 +239,51 @@ func lumpsInsert(l *lua.State) int {
 	return 0
 }
 
-// Pack WAD file into string.
-func lumpsPackWAD(l *lua.State) int {
-	data := checkLumps(l, 1)
+// Returns the data a directory is packed into by the passed function,
+// consulting the build cache of the state if there is one.  The name of
+// the packer keeps the entries of different formats apart.
+func cachedPack(l *lua.State, packer string, data *Directory, pack func(*bytes.Buffer) error) []byte {
+	cache := luaCache(l)
+	var key string
+	if cache != nil {
+		parts := [][]byte{[]byte(packer)}
+		for _, lump := range *data {
+			parts = append(parts, []byte(lump.Name), lump.Data)
+		}
+		key = CacheKey(parts...)
 
-	// Create WAD structure
-	wad := NewWad(WadTypePWAD)
-	wad.Lumps = *data
+		if packed, ok := cache.Get(key); ok {
+			return packed
+		}
+	}
 
-	// Write into bytebuffer
 	buffer := bytes.Buffer{}
-	err := Encode(&buffer, wad)
+	err := pack(&buffer)
 	if err != nil {
 		lua.Errorf(l, "could not encode data (%s)", err.Error())
 	}
 
-	// Return bytebuffer
-	l.PushString(buffer.String())
+	if cache != nil {
+		cache.Put(key, buffer.Bytes())
+	}
+
+	return buffer.Bytes()
+}
+
+// Encodes a directory as PWAD data.
+func encodeLumps(l *lua.State, data *Directory) []byte {
+	return cachedPack(l, "packwad", data, func(buffer *bytes.Buffer) error {
+		wad := NewWad(WadTypePWAD)
+		wad.Lumps = *data
+		return Encode(buffer, wad)
+	})
+}
+
+// Pack WAD file into string.
+func lumpsPackWAD(l *lua.State) int {
+	data := checkLumps(l, 1)
+
+	l.PushString(string(encodeLumps(l, data)))
 
 	return 1
 }
@@ -290,30 +354,60 @@ func lumpsSet(l *lua.State) int {
 	return 0
 }
 
-// Write WAD file to disk.
+// Write WAD file to disk.  A file that already holds the same data is
+// left untouched, so that its modification time only changes when its
+// contents do.
 func lumpsWriteWAD(l *lua.State) int {
 	data := checkLumps(l, 1)
-	filename := lua.CheckString(l, 2)
+	filename := checkWritePath(l, 2)
 
-	// Create WAD structure
-	wad := NewWad(WadTypePWAD)
-	wad.Lumps = *data
-
-	// Open file
-	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	err := writeChanged(filename, encodeLumps(l, data))
 	if err != nil {
-		lua.Errorf(l, "could not open file (%s)", err.Error())
+		lua.Errorf(l, "could not write file (%s)", err.Error())
 	}
 
-	// Write into file
-	err = Encode(file, wad)
+	return 0
+}
+
+// Encodes a directory as ZIP data, using lump names as paths.
+func encodeZipLumps(l *lua.State, data *Directory) []byte {
+	return cachedPack(l, "packzip", data, func(buffer *bytes.Buffer) error {
+		return EncodeZip(buffer, *data)
+	})
+}
+
+// Pack ZIP file into string.
+func lumpsPackZip(l *lua.State) int {
+	data := checkLumps(l, 1)
+
+	l.PushString(string(encodeZipLumps(l, data)))
+
+	return 1
+}
+
+// Write ZIP file to disk, leaving a file that already holds the same
+// data untouched.
+func lumpsWriteZip(l *lua.State) int {
+	data := checkLumps(l, 1)
+	filename := checkWritePath(l, 2)
+
+	err := writeChanged(filename, encodeZipLumps(l, data))
 	if err != nil {
-		lua.Errorf(l, "could not encode data (%s)", err.Error())
+		lua.Errorf(l, "could not write file (%s)", err.Error())
 	}
 
 	return 0
 }
 
+// Writes data to a file, unless the file already holds the same data.
+func writeChanged(filename string, data []byte) error {
+	if existing, err := ioutil.ReadFile(filename); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	return ioutil.WriteFile(filename, data, 0666)
+}
+
 // Length of directory.
 func lumpsLen(l *lua.State) int {
 	data := checkLumps(l, 1)
@@ -387,3 +387,64 @@ func TestLumpsWriteWAD(t *testing.T) {
 		t.Error("incorrect wad data")
 	}
 }
+
+// Lumps survive a round trip through ZIP data
+func TestPackZip(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local lumps = wad.createLumps()
+		lumps:insert("sprites/TROOA1.lmp", "imp")
+		lumps:insert("DEHACKED.lmp", "patch")
+		local unpacked = wad.unpackzip(lumps:packzip())
+		local name1, data1 = unpacked:get(1)
+		local name2, data2 = unpacked:get(2)
+		return #unpacked, name1, data1, name2, data2`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if count, _ := l.ToInteger(1); count != 2 {
+		t.Fatalf("incorrect lump count %d", count)
+	}
+
+	expected := []string{"sprites/TROOA1.lmp", "imp", "DEHACKED.lmp", "patch"}
+	for i, value := range expected {
+		if lua.CheckString(l, i+2) != value {
+			t.Errorf("incorrect value %d %q", i+1, lua.CheckString(l, i+2))
+		}
+	}
+}
+
+// Lump types are detected in the context of the whole directory
+func TestLumpsType(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local lumps = wad.createLumps()
+		lumps:insert("MAP01", "")
+		lumps:insert("THINGS", "\0\0")
+		lumps:insert("F_START", "")
+		lumps:insert("FLOOR0_1", string.rep("\0", 4096))
+		lumps:insert("FLTWAWA1", string.rep("\0", 8192))
+		lumps:insert("F_END", "")
+		lumps:insert("README", "Hello")
+		local doom = lumps:type(5)
+		wad.setgame("heretic")
+		return lumps:type(1), lumps:type(2), lumps:type(3), lumps:type(4),
+			lumps:type(5), doom, lumps:type(7), lumps:type(8)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{"map", "maplump", "marker", "flat", "flat", "unknown", "text"}
+	for i, kind := range expected {
+		if lua.CheckString(l, i+1) != kind {
+			t.Errorf("incorrect type of lump %d %q", i+1, lua.CheckString(l, i+1))
+		}
+	}
+
+	if !l.IsNil(8) {
+		t.Error("type of a missing lump is not nil")
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"io"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+var manifestMethods = []lua.RegistryFunction{
+	{"fromjson", wadFromJSON},
+	{"fromyaml", wadFromYAML},
+}
+
+var manifestDataNames = []string{"hashes", "base64", "sidecar"}
+
+// Encodes the Lumps at the first stack index into a manifest with the
+// passed encoder, holding data as named by the optional second
+// argument.  Pushes the manifest, and for sidecars a table of the files
+// to write next to it by file name.
+func pushManifest(l *lua.State, encode func(io.Writer, *Manifest) error) int {
+	data := checkLumps(l, 1)
+	mode := ManifestData(checkOption(l, 2, "base64", manifestDataNames))
+
+	manifest, sidecars := NewManifest(&Wad{WadType: WadTypePWAD, Lumps: *data}, mode, currentGame(l))
+
+	var buffer bytes.Buffer
+	err := encode(&buffer, manifest)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+	l.PushString(buffer.String())
+
+	if mode != ManifestSidecar {
+		return 1
+	}
+
+	l.CreateTable(0, len(sidecars))
+	for _, file := range sidecars {
+		l.PushString(string(file.Data))
+		l.SetField(-2, file.Name)
+	}
+	return 2
+}
+
+// Builds the WAD described by a manifest decoded from the text at the
+// first stack index, reading sidecar files from the directory named by
+// the optional second argument.  Pushes the lumps and the WAD type.
+func pushManifestWad(l *lua.State, decode func([]byte) (*Manifest, error)) int {
+	text := lua.CheckString(l, 1)
+	dir := lua.OptString(l, 2, ".")
+
+	manifest, err := decode([]byte(text))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	sidecar := SidecarLoader(dir)
+	if sandbox := LuaSandbox(l); sandbox != nil {
+		sidecar = sidecarReader(dir, sandbox.ReadFile)
+	}
+
+	wad, err := manifest.Build(sidecar)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushUserData(&wad.Lumps)
+	lua.SetMetaTableNamed(l, lumpsHandle)
+	l.PushString(manifest.Type)
+	return 2
+}
+
+// Describe the lumps as a JSON manifest.  The optional argument is
+// hashes, base64 or sidecar, and decides how lump data is kept.
+func lumpsToJSON(l *lua.State) int {
+	return pushManifest(l, EncodeManifestJSON)
+}
+
+// Describe the lumps as a YAML manifest.  The optional argument is
+// hashes, base64 or sidecar, and decides how lump data is kept.
+func lumpsToYAML(l *lua.State) int {
+	return pushManifest(l, EncodeManifestYAML)
+}
+
+// Rebuild lumps from a JSON manifest, returning the lumps and WAD type.
+func wadFromJSON(l *lua.State) int {
+	return pushManifestWad(l, DecodeManifestJSON)
+}
+
+// Rebuild lumps from a YAML manifest, returning the lumps and WAD type.
+func wadFromYAML(l *lua.State) int {
+	return pushManifestWad(l, DecodeManifestYAML)
+}
+
+// WadManifestOpen adds all manifest-related functions to the table
+// located at the top of the stack of the passed lua state.
+func WadManifestOpen(l *lua.State) error {
+	lua.SetFunctions(l, manifestMethods, 0)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"strings"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// Lumps survive a round trip through JSON and YAML manifests
+func TestLuaManifest(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local lumps = wad.createLumps()
+		lumps:insert("DEHACKED", "patch")
+		lumps:insert("S_START", "")
+		lumps:insert("TROOA1", "imp")
+		lumps:insert("S_END", "")
+		local packed = lumps:packwad()
+
+		local fromjson, wadtype = wad.fromjson(lumps:tojson())
+		local fromyaml = wad.fromyaml(lumps:toyaml("base64"))
+		local text, files = lumps:tojson("sidecar")
+		return fromjson:packwad() == packed, fromyaml:packwad() == packed, wadtype,
+			files["sprites/TROOA1.lmp"], text`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !l.ToBoolean(1) || !l.ToBoolean(2) {
+		t.Error("rebuilt lumps differ")
+	}
+
+	if lua.CheckString(l, 3) != "pwad" {
+		t.Error("incorrect wad type")
+	}
+
+	if lua.CheckString(l, 4) != "imp" {
+		t.Error("incorrect sidecar file")
+	}
+
+	if !strings.Contains(lua.CheckString(l, 5), `"file": "sprites/TROOA1.lmp"`) {
+		t.Errorf("incorrect manifest %s", lua.CheckString(l, 5))
+	}
+
+	err = lua.DoString(l, `wad.fromjson('{"type": "zwad", "lumps": []}')`)
+	if err == nil || !strings.Contains(err.Error(), "unknown wad type zwad") {
+		t.Errorf("incorrect type error %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	lua "github.com/Shopify/go-lua"
+)
+
+var mapinfoMethods = []lua.RegistryFunction{
+	{"checkmapinfo", wadCheckMapInfo},
+	{"parsemapinfo", wadParseMapInfo},
+}
+
+// Pushes a table of properties, mapping each key to an array of values.
+// Later properties replace earlier ones of the same key.
+func pushMapInfoProperties(l *lua.State, props []MapInfoProperty) {
+	l.CreateTable(0, len(props))
+	for _, prop := range props {
+		pushStrings(l, prop.Values)
+		l.SetField(-2, prop.Key)
+	}
+}
+
+// Pushes a table representation of a MAPINFO lump.
+func pushMapInfo(l *lua.State, info *MapInfo) {
+	l.CreateTable(0, 4)
+
+	l.CreateTable(len(info.Maps), 0)
+	for i := range info.Maps {
+		def := &info.Maps[i]
+		l.CreateTable(0, 18)
+		fields := []struct {
+			name  string
+			value string
+		}{
+			{"map", def.Map},
+			{"title", def.Title},
+			{"next", def.Next},
+			{"secretnext", def.SecretNext},
+			{"music", def.Music},
+			{"sky1", def.Sky1},
+			{"sky2", def.Sky2},
+			{"titlepatch", def.TitlePatch},
+			{"exitpic", def.ExitPic},
+			{"enterpic", def.EnterPic},
+			{"endpic", def.EndPic},
+			{"intermusic", def.InterMusic},
+			{"interbackdrop", def.InterBackdrop},
+		}
+		for _, field := range fields {
+			if field.value != "" {
+				l.PushString(field.value)
+				l.SetField(-2, field.name)
+			}
+		}
+		l.PushBoolean(def.TitleLookup)
+		l.SetField(-2, "titlelookup")
+		l.PushInteger(def.Cluster)
+		l.SetField(-2, "cluster")
+		l.PushInteger(def.Par)
+		l.SetField(-2, "par")
+		pushMapInfoProperties(l, def.Properties)
+		l.SetField(-2, "properties")
+		l.RawSetInt(-2, i+1)
+	}
+	l.SetField(-2, "maps")
+
+	l.CreateTable(len(info.Episodes), 0)
+	for i, episode := range info.Episodes {
+		l.CreateTable(0, 4)
+		l.PushString(episode.Map)
+		l.SetField(-2, "map")
+		l.PushString(episode.Name)
+		l.SetField(-2, "name")
+		l.PushString(episode.PicName)
+		l.SetField(-2, "picname")
+		l.PushString(episode.Key)
+		l.SetField(-2, "key")
+		l.RawSetInt(-2, i+1)
+	}
+	l.SetField(-2, "episodes")
+
+	l.CreateTable(len(info.Clusters), 0)
+	for i := range info.Clusters {
+		cluster := &info.Clusters[i]
+		l.CreateTable(0, 5)
+		l.PushInteger(cluster.Cluster)
+		l.SetField(-2, "cluster")
+		l.PushString(cluster.Music)
+		l.SetField(-2, "music")
+		l.PushString(cluster.Flat)
+		l.SetField(-2, "flat")
+		l.PushString(cluster.Pic)
+		l.SetField(-2, "pic")
+		pushMapInfoProperties(l, cluster.Properties)
+		l.SetField(-2, "properties")
+		l.RawSetInt(-2, i+1)
+	}
+	l.SetField(-2, "clusters")
+
+	pushStrings(l, info.Includes)
+	l.SetField(-2, "includes")
+}
+
+// Parse UMAPINFO, MAPINFO or ZMAPINFO text into a table.  The optional
+// second argument is the name used in error messages.
+func wadParseMapInfo(l *lua.State) int {
+	text := lua.CheckString(l, 1)
+	name := lua.OptString(l, 2, "MAPINFO")
+
+	info, err := ParseMapInfo(name, text)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	pushMapInfo(l, info)
+	return 1
+}
+
+// Check that every lump referenced by MAPINFO text exists in one of the
+// passed Lumps, returning an array of problems that is empty if every
+// reference was found.
+func wadCheckMapInfo(l *lua.State) int {
+	text := lua.CheckString(l, 1)
+
+	dirs := []*Directory{}
+	for i := 2; i <= l.Top(); i++ {
+		dirs = append(dirs, checkLumps(l, i))
+	}
+
+	info, err := ParseMapInfo("MAPINFO", text)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	problems := []string{}
+	for _, err := range CheckMapInfo(info, currentGame(l).TextureFormat, dirs...) {
+		problems = append(problems, err.Error())
+	}
+
+	pushStrings(l, problems)
+	return 1
+}
+
+// WadMapInfoOpen adds all MAPINFO-related functions to the table
+// located at the top of the stack of the passed lua state.
+func WadMapInfoOpen(l *lua.State) error {
+	lua.SetFunctions(l, mapinfoMethods, 0)
+
+	return nil
+}
@@ -0,0 +1,55 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"strings"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// MAPINFO parses into tables and is checked against Lumps
+func TestLuaMapInfo(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	l.PushString(testUMapInfo)
+	l.SetGlobal("umapinfo")
+
+	err := lua.DoString(l, `
+		local info = wad.parsemapinfo(umapinfo, "UMAPINFO")
+		local lumps = wad.createLumps()
+		lumps:insert("MAP01", "")
+		lumps:insert("MAP02", "")
+		lumps:insert("D_RUNNIN", "")
+		return info.maps[1].title, info.maps[1].properties.episode[2], wad.checkmapinfo(umapinfo, lumps)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckString(l, -3) != "Entryway" || lua.CheckString(l, -2) != "Knee Deep" {
+		t.Error("incorrect map table")
+	}
+
+	problems := checkStrings(l, -1)
+	if len(problems) != 3 || !strings.Contains(problems[0], "sky texture SKY1") {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	lua "github.com/Shopify/go-lua"
+)
+
+var musicMethods = []lua.RegistryFunction{
+	{"miditomus", converter("miditomus")},
+	{"mustomidi", converter("mustomidi")},
+}
+
+// WadMusicOpen adds all music-related functions to the table located
+// at the top of the stack of the passed lua state.
+func WadMusicOpen(l *lua.State) error {
+	lua.SetFunctions(l, musicMethods, 0)
+
+	return nil
+}
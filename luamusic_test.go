@@ -0,0 +1,57 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// MIDI converts to MUS and back
+func TestLuaMIDIToMUS(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	l.PushString(string(testMIDI(96, []byte{
+		0x00, 0x90, 0x3C, 0x64,
+		0x60, 0x80, 0x3C, 0x40,
+		0x00, 0xFF, 0x2F, 0x00,
+	})))
+	l.SetGlobal("midi")
+
+	err := lua.DoString(l, `
+		local mus, warning = wad.miditomus(midi)
+		return mus:sub(1, 4), warning, wad.mustomidi(mus):sub(1, 4)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckString(l, -3) != "MUS\x1a" {
+		t.Error("incorrect MUS header")
+	}
+
+	if !l.IsNil(-2) {
+		t.Error("small MUS has a size warning")
+	}
+
+	if lua.CheckString(l, -1) != "MThd" {
+		t.Error("incorrect MIDI header")
+	}
+}
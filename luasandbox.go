@@ -0,0 +1,215 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wadmake
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shopify/go-lua"
+)
+
+// The registry field holding the sandbox of the environment.
+const sandboxRegistryKey = "wadmake.sandbox"
+
+// NewSandboxedLuaEnvironment creates a new lua.State like
+// NewLuaEnvironment, except that every function that accesses files
+// is confined to the passed sandbox.  The debug library, temporary
+// files and, unless the sandbox allows it, os.execute are removed.
+func NewSandboxedLuaEnvironment(sandbox *Sandbox) *lua.State {
+	l := NewLuaEnvironment()
+
+	l.PushUserData(sandbox)
+	l.SetField(lua.RegistryIndex, sandboxRegistryKey)
+
+	// The debug library could reach the original functions through
+	// the upvalues of their replacements, or the registry.
+	l.PushNil()
+	l.SetGlobal("debug")
+	l.Field(lua.RegistryIndex, "_LOADED")
+	l.PushNil()
+	l.SetField(-2, "debug")
+	l.Pop(1)
+
+	l.PushGlobalTable()
+	sandboxField(l, "dofile", func(l *lua.State) { replacePath(l, 1, checkReadPath) })
+	sandboxField(l, "loadfile", func(l *lua.State) { replacePath(l, 1, checkReadPath) })
+	l.Pop(1)
+
+	l.Global("io")
+	sandboxField(l, "open", func(l *lua.State) {
+		if strings.ContainsAny(lua.OptString(l, 2, "r"), "wa+") {
+			replacePath(l, 1, checkWritePath)
+		} else {
+			replacePath(l, 1, checkReadPath)
+		}
+	})
+	sandboxField(l, "lines", func(l *lua.State) { replacePath(l, 1, checkReadPath) })
+	sandboxField(l, "input", func(l *lua.State) { replacePath(l, 1, checkReadPath) })
+	sandboxField(l, "output", func(l *lua.State) { replacePath(l, 1, checkWritePath) })
+	removeField(l, "popen")
+	removeField(l, "tmpfile")
+	l.Pop(1)
+
+	l.Global("os")
+	sandboxField(l, "remove", func(l *lua.State) { replacePath(l, 1, checkWritePath) })
+	sandboxField(l, "rename", func(l *lua.State) {
+		replacePath(l, 1, checkWritePath)
+		replacePath(l, 2, checkWritePath)
+	})
+	removeField(l, "tmpname")
+	if !sandbox.Execute {
+		removeField(l, "execute")
+	}
+	l.Pop(1)
+
+	// require finds modules with our own searcher in place of the
+	// standard one, which would read files anywhere along package.path.
+	l.Global("package")
+	removeField(l, "searchpath")
+	l.Field(-1, "searchers")
+	l.PushValue(-2)
+	l.PushGoClosure(sandboxSearcher, 1)
+	l.RawSetInt(-2, 2)
+	l.Pop(2)
+
+	return l
+}
+
+// LuaSandbox returns the sandbox the passed environment is confined to,
+// or nil if it has none.
+func LuaSandbox(l *lua.State) *Sandbox {
+	l.Field(lua.RegistryIndex, sandboxRegistryKey)
+	defer l.Pop(1)
+
+	sandbox, _ := l.ToUserData(-1).(*Sandbox)
+	return sandbox
+}
+
+// Checks for a file name at a specific stack index that may be read,
+// and returns the path to read it from.
+func checkReadPath(l *lua.State, index int) string {
+	name := lua.CheckString(l, index)
+
+	sandbox := LuaSandbox(l)
+	if sandbox == nil {
+		return name
+	}
+
+	path, err := sandbox.CheckRead(name)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+	return path
+}
+
+// Checks for a file name at a specific stack index that may be
+// written, and returns the path to write it to.
+func checkWritePath(l *lua.State, index int) string {
+	name := lua.CheckString(l, index)
+
+	sandbox := LuaSandbox(l)
+	if sandbox == nil {
+		return name
+	}
+
+	path, err := sandbox.CheckWrite(name)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+	return path
+}
+
+// Replaces the file name at a specific stack index with the path
+// returned by the passed check.  Other values, such as files or nil
+// for stdin, are left for the original function to deal with.
+func replacePath(l *lua.State, index int, check func(*lua.State, int) string) {
+	if l.IsString(index) {
+		l.PushString(check(l, index))
+		l.Replace(index)
+	}
+}
+
+// Replaces a function in the table at the top of the stack with one
+// that checks its arguments before calling the original, which is kept
+// as its upvalue.
+func sandboxField(l *lua.State, name string, check func(*lua.State)) {
+	l.Field(-1, name)
+	l.PushGoClosure(func(l *lua.State) int {
+		check(l)
+		l.PushValue(lua.UpValueIndex(1))
+		l.Insert(1)
+		l.Call(l.Top()-1, lua.MultipleReturns)
+		return l.Top()
+	}, 1)
+	l.SetField(-2, name)
+}
+
+// Removes a field from the table at the top of the stack.
+func removeField(l *lua.State, name string) {
+	l.PushNil()
+	l.SetField(-2, name)
+}
+
+// Finds a module along package.path like the standard searcher, but
+// skips files that may not be read.  The package table is the upvalue.
+func sandboxSearcher(l *lua.State) int {
+	name := lua.CheckString(l, 1)
+
+	l.Field(lua.UpValueIndex(1), "path")
+	path, ok := l.ToString(-1)
+	if !ok {
+		lua.Errorf(l, "'package.path' must be a string")
+	}
+
+	sandbox := LuaSandbox(l)
+	file := strings.Replace(name, ".", string(filepath.Separator), -1)
+
+	msg := ""
+	for _, template := range strings.Split(path, ";") {
+		if template == "" {
+			continue
+		}
+
+		filename := strings.Replace(template, "?", file, -1)
+		resolved, err := sandbox.CheckRead(filename)
+		if err != nil {
+			continue
+		}
+
+		f, err := os.Open(resolved)
+		if err != nil {
+			msg += fmt.Sprintf("\n\tno file '%s'", filename)
+			continue
+		}
+		f.Close()
+
+		if lua.LoadFile(l, resolved, "") != nil {
+			lua.Errorf(l, "error loading module '%s' from file '%s':\n\t%s",
+				name, filename, lua.CheckString(l, -1))
+		}
+		l.PushString(filename)
+		return 2
+	}
+
+	l.PushString(msg)
+	return 1
+}
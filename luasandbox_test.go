@@ -0,0 +1,201 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wadmake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// Creates a sandboxed environment with the directory holding the root
+// in the global dir and the root in the global root.
+func sandboxTestEnvironment(t *testing.T, sandbox *Sandbox, dir string) *lua.State {
+	l := NewSandboxedLuaEnvironment(sandbox)
+	l.PushString(dir)
+	l.SetGlobal("dir")
+	l.PushString(filepath.Join(dir, "root"))
+	l.SetGlobal("root")
+	return l
+}
+
+// Each call must fail with an error mentioning the sandbox
+func TestLuaSandboxDenied(t *testing.T) {
+	dir, sandbox := sandboxTestRoot(t)
+	defer os.RemoveAll(dir)
+
+	calls := []string{
+		`io.open(dir .. "/secret")`,
+		`io.open(root .. "/src/link")`,
+		`io.open(root .. "/src/new.txt", "w")`,
+		`io.open(root .. "/src/map.wad", "r+")`,
+		`io.lines(dir .. "/secret")`,
+		`io.input(dir .. "/secret")`,
+		`io.output(root .. "/out.txt")`,
+		`os.remove(root .. "/src/map.wad")`,
+		`os.rename(root .. "/src/map.wad", root .. "/build/map.wad")`,
+		`dofile(dir .. "/secret")`,
+		`loadfile(dir .. "/secret")`,
+		`wad.readwad(dir .. "/secret")`,
+		`wad.createLumps():writewad(root .. "/src/map.wad")`,
+		`wad.createLumps():writezip(dir .. "/out.pk3")`,
+		`wad.setcache(root .. "/.wadmake-cache")`,
+		`wad.compileacs('#include "secret"', dir .. "/SCRIPTS")`,
+		`wad.fromjson('{"type":"pwad","lumps":[{"name":"A","file":"secret"}]}', dir)`,
+	}
+	for _, call := range calls {
+		l := sandboxTestEnvironment(t, sandbox, dir)
+		err := lua.DoString(l, call)
+		if err == nil {
+			t.Errorf("%s did not fail", call)
+			continue
+		}
+
+		msg, _ := l.ToString(-1)
+		if !strings.Contains(msg, "outside of the project root") && !strings.Contains(msg, "not in an output directory") {
+			t.Errorf("%s failed with %q", call, msg)
+		}
+	}
+}
+
+// Files in the root can be read and files in output directories written
+func TestLuaSandboxAllowed(t *testing.T) {
+	dir, sandbox := sandboxTestRoot(t)
+	defer os.RemoveAll(dir)
+
+	l := sandboxTestEnvironment(t, sandbox, dir)
+	err := lua.DoString(l, `
+		local file = io.open(root .. "/build/out.txt", "w")
+		file:write("written")
+		file:close()
+		io.open(root .. "/src/map.wad"):close()
+		local lumps = wad.createLumps()
+		lumps:insert("LUMP", "data")
+		lumps:writewad(root .. "/build/out.wad")
+		local _, data = wad.readwad(root .. "/build/out.wad"):get(1)
+		return data`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckString(l, -1) != "data" {
+		t.Errorf("incorrect lump data %q", lua.CheckString(l, -1))
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "root", "build", "out.txt"))
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if string(data) != "written" {
+		t.Errorf("incorrect file contents %q", data)
+	}
+}
+
+// Include directories outside of the root are skipped
+func TestLuaSandboxACSInclude(t *testing.T) {
+	dir, sandbox := sandboxTestRoot(t)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{filepath.Join(dir, "defs.acs"), filepath.Join(dir, "root", "src", "defs.acs")} {
+		err := ioutil.WriteFile(name, []byte("#define DELAY 35\n"), 0666)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	l := sandboxTestEnvironment(t, sandbox, dir)
+	err := lua.DoString(l, `
+		return wad.compileacs('#include "defs.acs"\nscript 1 OPEN { delay(DELAY); }',
+			dir .. "/SCRIPTS", {dir, root .. "/src"})`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.HasPrefix(lua.CheckString(l, -1), "ACS") {
+		t.Error("incorrect BEHAVIOR lump")
+	}
+}
+
+// Modules are only found in the root
+func TestLuaSandboxRequire(t *testing.T) {
+	dir, sandbox := sandboxTestRoot(t)
+	defer os.RemoveAll(dir)
+
+	modules := map[string]string{
+		filepath.Join(dir, "root", "src", "inside.lua"): "return 'inside'",
+		filepath.Join(dir, "outside.lua"):               "return 'outside'",
+	}
+	for name, src := range modules {
+		err := ioutil.WriteFile(name, []byte(src), 0666)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	l := sandboxTestEnvironment(t, sandbox, dir)
+	err := lua.DoString(l, `
+		package.path = dir .. "/?.lua;" .. root .. "/src/?.lua"
+		local ok = pcall(require, "outside")
+		return ok, require("inside")`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if l.ToBoolean(-2) {
+		t.Error("required a module outside of the root")
+	}
+
+	if lua.CheckString(l, -1) != "inside" {
+		t.Errorf("incorrect module %q", lua.CheckString(l, -1))
+	}
+}
+
+// Commands, temporary files and the debug library are unavailable
+// unless commands are allowed
+func TestLuaSandboxRemoved(t *testing.T) {
+	dir, sandbox := sandboxTestRoot(t)
+	defer os.RemoveAll(dir)
+
+	l := sandboxTestEnvironment(t, sandbox, dir)
+	err := lua.DoString(l, `
+		return os.execute, io.popen, io.tmpfile, os.tmpname, debug, package.loaded.debug`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 1; i <= 6; i++ {
+		if !l.IsNil(i) {
+			t.Errorf("result %d is not nil", i)
+		}
+	}
+
+	sandbox.Execute = true
+	l = sandboxTestEnvironment(t, sandbox, dir)
+	err = lua.DoString(l, "return os.execute")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !l.IsFunction(-1) {
+		t.Error("os.execute is unavailable although it is allowed")
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	lua "github.com/Shopify/go-lua"
+)
+
+var soundMethods = []lua.RegistryFunction{
+	{"decodepcspeaker", wadDecodePCSpeaker},
+	{"dmxtopcspeaker", converter("dmxtopcspeaker")},
+	{"dmxtowav", converter("dmxtowav")},
+	{"encodepcspeaker", wadEncodePCSpeaker},
+	{"pcmtodmx", converter("pcmtodmx")},
+	{"pcspeakertowav", converter("pcspeakertowav")},
+	{"wavtodmx", converter("wavtodmx")},
+}
+
+// Raises an error if the current game does not play PC speaker sounds.
+func checkPCSpeaker(l *lua.State) {
+	if game := currentGame(l); !game.PCSpeaker {
+		lua.Errorf(l, "%s has no PC speaker sounds", game.Name)
+	}
+}
+
+// Decode PC speaker sound data into an array of tones.
+func wadDecodePCSpeaker(l *lua.State) int {
+	data := lua.CheckString(l, 1)
+
+	tones, err := DecodePCSpeaker([]byte(data))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.CreateTable(len(tones), 0)
+	for i, tone := range tones {
+		l.PushInteger(int(tone))
+		l.RawSetInt(-2, i+1)
+	}
+
+	return 1
+}
+
+// Encode an array of tones into PC speaker sound data, if the current
+// game plays PC speaker sounds.
+func wadEncodePCSpeaker(l *lua.State) int {
+	lua.CheckType(l, 1, lua.TypeTable)
+	checkPCSpeaker(l)
+
+	tones := make([]byte, lua.LengthEx(l, 1))
+	for i := range tones {
+		l.RawGetInt(1, i+1)
+		tone, ok := l.ToInteger(-1)
+		if !ok || tone < 0 || tone > 127 {
+			lua.Errorf(l, "tone %d must be a number between 0 and 127", i+1)
+		}
+		tones[i] = byte(tone)
+		l.Pop(1)
+	}
+
+	data, err := EncodePCSpeaker(tones)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(string(data))
+	return 1
+}
+
+// WadSoundOpen adds all sound-related functions to the table located
+// at the top of the stack of the passed lua state.
+func WadSoundOpen(l *lua.State) error {
+	lua.SetFunctions(l, soundMethods, 0)
+
+	return nil
+}
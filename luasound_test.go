@@ -0,0 +1,97 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// 44.1 kHz stereo WAV converts to an 11025 Hz DMX sound
+func TestLuaWAVToDMX(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	l.PushString(string(testStereoWAV(4410)))
+	l.SetGlobal("wav")
+
+	err := lua.DoString(l, `
+		local dmx = wad.wavtodmx(wav)
+		local rate = dmx:byte(3) + dmx:byte(4) * 256
+		local back = wad.dmxtowav(dmx, 16)
+		return rate, #dmx, back:sub(1, 4)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckInteger(l, -3) != 11025 {
+		t.Errorf("incorrect DMX rate %d", lua.CheckInteger(l, -3))
+	}
+
+	if lua.CheckInteger(l, -2) != 8+1102+32 {
+		t.Errorf("incorrect DMX size %d", lua.CheckInteger(l, -2))
+	}
+
+	if lua.CheckString(l, -1) != "RIFF" {
+		t.Error("incorrect WAV header")
+	}
+}
+
+// Only rates DMX can play are accepted
+func TestLuaWAVToDMXRate(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	l.PushString(string(testStereoWAV(10)))
+	l.SetGlobal("wav")
+
+	err := lua.DoString(l, "return wad.wavtodmx(wav, 44100)")
+	if err == nil {
+		t.Error("unsupported DMX rate was accepted")
+	}
+}
+
+// DMX sound approximates into PC speaker sound that renders to WAV
+func TestLuaDMXToPCSpeaker(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	l.PushString(string(testStereoWAV(44100)))
+	l.SetGlobal("wav")
+
+	err := lua.DoString(l, `
+		local speaker = wad.dmxtopcspeaker(wad.wavtodmx(wav))
+		local tones = wad.decodepcspeaker(speaker)
+		local preview = wad.pcspeakertowav(wad.encodepcspeaker(tones))
+		return #tones, tones[70], preview:sub(1, 4)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckInteger(l, -3) != 140 {
+		t.Errorf("incorrect tone count %d", lua.CheckInteger(l, -3))
+	}
+
+	if lua.CheckInteger(l, -2) == 0 {
+		t.Error("tone is silent")
+	}
+
+	if lua.CheckString(l, -1) != "RIFF" {
+		t.Error("incorrect WAV header")
+	}
+}
@@ -0,0 +1,355 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"math"
+	"strings"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+var textureMethods = []lua.RegistryFunction{
+	{"compiletextures", cached("compiletextures", wadCompileTextures)},
+	{"decodepnames", wadDecodePNames},
+	{"decodetextures", wadDecodeTextures},
+	{"decompiletextures", cached("decompiletextures", wadDecompileTextures)},
+	{"encodepnames", wadEncodePNames},
+	{"encodetextures", wadEncodeTextures},
+	{"formattextures", wadFormatTextures},
+	{"mergetextures", wadMergeTextures},
+	{"parsetextures", wadParseTextures},
+}
+
+var textureFormatNames = []string{"doom", "strife"}
+
+// Checks for an optional texture format name at a specific stack index,
+// which defaults to the texture format of the current game.
+func checkTextureFormat(l *lua.State, index int) TextureFormat {
+	def := textureFormatNames[currentGame(l).TextureFormat]
+	return TextureFormat(checkOption(l, index, def, textureFormatNames))
+}
+
+var textureSyntaxNames = []string{"deutex", "zdoom"}
+
+// Parses texture definition text in the syntax named by the optional
+// argument at a specific stack index.
+func parseTextureText(l *lua.State, text string, index int) []Texture {
+	var textures []Texture
+	var err error
+
+	switch checkOption(l, index, "deutex", textureSyntaxNames) {
+	case 0:
+		textures, err = ParseDeutexTextures(strings.NewReader(text))
+	case 1:
+		textures, err = ParseZDoomTextures(strings.NewReader(text))
+	}
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	return textures
+}
+
+// Formats textures as definition text in the syntax named by the
+// optional argument at a specific stack index.
+func formatTextureText(l *lua.State, textures []Texture, index int) string {
+	var buffer bytes.Buffer
+	var err error
+
+	switch checkOption(l, index, "deutex", textureSyntaxNames) {
+	case 0:
+		err = WriteDeutexTextures(&buffer, textures)
+	case 1:
+		err = WriteZDoomTextures(&buffer, textures)
+	}
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	return buffer.String()
+}
+
+// Pushes a table representation of a single texture.
+func pushTexture(l *lua.State, texture *Texture) {
+	l.CreateTable(0, 7)
+	l.PushString(texture.Name)
+	l.SetField(-2, "name")
+	l.PushInteger(int(texture.Width))
+	l.SetField(-2, "width")
+	l.PushInteger(int(texture.Height))
+	l.SetField(-2, "height")
+	l.PushInteger(int(texture.Flags))
+	l.SetField(-2, "flags")
+	l.PushInteger(int(texture.ScaleX))
+	l.SetField(-2, "scalex")
+	l.PushInteger(int(texture.ScaleY))
+	l.SetField(-2, "scaley")
+
+	l.CreateTable(len(texture.Patches), 0)
+	for i, patch := range texture.Patches {
+		l.CreateTable(0, 3)
+		l.PushString(patch.Name)
+		l.SetField(-2, "name")
+		l.PushInteger(int(patch.OriginX))
+		l.SetField(-2, "x")
+		l.PushInteger(int(patch.OriginY))
+		l.SetField(-2, "y")
+		l.RawSetInt(-2, i+1)
+	}
+	l.SetField(-2, "patches")
+}
+
+// Pushes an array of texture tables.
+func pushTextures(l *lua.State, textures []Texture) {
+	l.CreateTable(len(textures), 0)
+	for i := range textures {
+		pushTexture(l, &textures[i])
+		l.RawSetInt(-2, i+1)
+	}
+}
+
+// Checks for an array of texture tables at a specific stack index.
+func checkTextures(l *lua.State, index int) []Texture {
+	index = l.AbsIndex(index)
+	lua.CheckType(l, index, lua.TypeTable)
+
+	textures := make([]Texture, lua.LengthEx(l, index))
+	for i := range textures {
+		l.RawGetInt(index, i+1)
+		if !l.IsTable(-1) {
+			lua.Errorf(l, "texture %d must be a table", i+1)
+		}
+
+		texture := &textures[i]
+		texture.Name = tableString(l, -1, "name", "")
+		texture.Width = int16(tableIntegerRange(l, -1, "width", 0, 0, math.MaxInt16))
+		texture.Height = int16(tableIntegerRange(l, -1, "height", 0, 0, math.MaxInt16))
+		texture.Flags = uint16(tableIntegerRange(l, -1, "flags", 0, 0, math.MaxUint16))
+		texture.ScaleX = uint8(tableIntegerRange(l, -1, "scalex", 0, 0, math.MaxUint8))
+		texture.ScaleY = uint8(tableIntegerRange(l, -1, "scaley", 0, 0, math.MaxUint8))
+
+		l.Field(-1, "patches")
+		if l.IsTable(-1) {
+			texture.Patches = make([]TexturePatch, lua.LengthEx(l, -1))
+			for j := range texture.Patches {
+				l.RawGetInt(-1, j+1)
+				if !l.IsTable(-1) {
+					lua.Errorf(l, "texture %s patch %d must be a table", texture.Name, j+1)
+				}
+
+				texture.Patches[j] = TexturePatch{
+					Name:    tableString(l, -1, "name", ""),
+					OriginX: int16(tableIntegerRange(l, -1, "x", 0, math.MinInt16, math.MaxInt16)),
+					OriginY: int16(tableIntegerRange(l, -1, "y", 0, math.MinInt16, math.MaxInt16)),
+				}
+				l.Pop(1)
+			}
+		} else if !l.IsNil(-1) {
+			lua.Errorf(l, "texture %s patches must be a table", texture.Name)
+		}
+		l.Pop(2)
+	}
+
+	return textures
+}
+
+// Pushes encoded data for each of the passed texture sets, followed by
+// PNAMES data built from every patch the textures reference.
+func pushEncodedTextures(l *lua.State, sets [][]Texture, format TextureFormat) int {
+	pnames := BuildPNames(sets...)
+	for _, textures := range sets {
+		data, err := EncodeTextures(textures, pnames, format)
+		if err != nil {
+			lua.Errorf(l, err.Error())
+		}
+		l.PushString(string(data))
+	}
+
+	data, err := EncodePNames(pnames)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+	l.PushString(string(data))
+
+	return len(sets) + 1
+}
+
+// Decode PNAMES data into an array of patch names.
+func wadDecodePNames(l *lua.State) int {
+	data := lua.CheckString(l, 1)
+
+	names, err := DecodePNames([]byte(data))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	pushStrings(l, names)
+	return 1
+}
+
+// Encode an array of patch names into PNAMES data.
+func wadEncodePNames(l *lua.State) int {
+	names := checkStrings(l, 1)
+
+	data, err := EncodePNames(names)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(string(data))
+	return 1
+}
+
+// Decode TEXTURE1 or TEXTURE2 data into an array of textures, using
+// PNAMES data to resolve patch names.
+func wadDecodeTextures(l *lua.State) int {
+	data := lua.CheckString(l, 1)
+	pnamesData := lua.CheckString(l, 2)
+	format := checkTextureFormat(l, 3)
+
+	pnames, err := DecodePNames([]byte(pnamesData))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	textures, err := DecodeTextures([]byte(data), pnames, format)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	pushTextures(l, textures)
+	return 1
+}
+
+// Encode one or two arrays of textures into TEXTURE1 and optionally
+// TEXTURE2 data, followed by PNAMES data built from every patch the
+// textures reference.
+func wadEncodeTextures(l *lua.State) int {
+	sets := [][]Texture{checkTextures(l, 1)}
+	formatIndex := 2
+	if l.IsTable(2) {
+		sets = append(sets, checkTextures(l, 2))
+		formatIndex = 3
+	}
+	format := checkTextureFormat(l, formatIndex)
+
+	return pushEncodedTextures(l, sets, format)
+}
+
+// Parse texture definition text into an array of textures.
+func wadParseTextures(l *lua.State) int {
+	text := lua.CheckString(l, 1)
+
+	pushTextures(l, parseTextureText(l, text, 2))
+	return 1
+}
+
+// Format an array of textures as texture definition text.
+func wadFormatTextures(l *lua.State) int {
+	textures := checkTextures(l, 1)
+
+	l.PushString(formatTextureText(l, textures, 2))
+	return 1
+}
+
+// Compile texture definition text into TEXTURE1 data, plus TEXTURE2
+// data if a second text is passed, followed by the PNAMES data both of
+// them share.
+func wadCompileTextures(l *lua.State) int {
+	sets := [][]Texture{parseTextureText(l, lua.CheckString(l, 1), 3)}
+	if !l.IsNoneOrNil(2) {
+		sets = append(sets, parseTextureText(l, lua.CheckString(l, 2), 3))
+	}
+	format := checkTextureFormat(l, 4)
+
+	return pushEncodedTextures(l, sets, format)
+}
+
+// Decompile TEXTURE1 or TEXTURE2 data into texture definition text,
+// using PNAMES data to resolve patch names.
+func wadDecompileTextures(l *lua.State) int {
+	data := lua.CheckString(l, 1)
+	pnamesData := lua.CheckString(l, 2)
+	format := checkTextureFormat(l, 4)
+
+	pnames, err := DecodePNames([]byte(pnamesData))
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	textures, err := DecodeTextures([]byte(data), pnames, format)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushString(formatTextureText(l, textures, 3))
+	return 1
+}
+
+var mergePolicyNames = []string{"firstwins", "lastwins", "error"}
+
+// Merge the textures of any number of Lumps, with an optional policy
+// for resolving conflicting definitions as the last parameter.
+// Returns a new Lumps holding the merged TEXTURE1, TEXTURE2 and PNAMES,
+// in the texture format of the current game.
+func wadMergeTextures(l *lua.State) int {
+	count := l.Top()
+	policy := MergeError
+	if l.TypeOf(count) == lua.TypeString {
+		policy = MergePolicy(checkOption(l, count, "error", mergePolicyNames))
+		count--
+	}
+
+	format := currentGame(l).TextureFormat
+
+	sets := make([]*TextureSet, count)
+	for i := range sets {
+		set, err := DecodeTextureSet(checkLumps(l, i+1), format)
+		if err != nil {
+			lua.Errorf(l, "could not decode textures of argument %d (%s)", i+1, err.Error())
+		}
+		sets[i] = set
+	}
+
+	merged, err := MergeTextures(sets, policy)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	dir, err := EncodeTextureSet(merged, format)
+	if err != nil {
+		lua.Errorf(l, err.Error())
+	}
+
+	l.PushUserData(&dir)
+	lua.SetMetaTableNamed(l, lumpsHandle)
+
+	return 1
+}
+
+// WadTexturesOpen adds all texture-related functions to the table
+// located at the top of the stack of the passed lua state.
+func WadTexturesOpen(l *lua.State) error {
+	lua.SetFunctions(l, textureMethods, 0)
+
+	return nil
+}
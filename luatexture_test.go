@@ -0,0 +1,155 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"strings"
+	"testing"
+
+	lua "github.com/Shopify/go-lua"
+)
+
+// Textures survive a round trip through Lua tables
+func TestLuaTexturesRoundTrip(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local textures = {
+			{name = "AASHITTY", width = 64, height = 64, patches = {
+				{name = "WALL00_1", x = 0, y = 0},
+			}},
+			{name = "BIGDOOR1", width = 128, height = 96, patches = {
+				{name = "W13_1", x = 0, y = 0},
+				{name = "WALL00_1", x = 64, y = -8},
+			}},
+		}
+		local texture1, pnames = wad.encodetextures(textures, "strife")
+		local names = wad.decodepnames(pnames)
+		local decoded = wad.decodetextures(texture1, pnames, "strife")
+		return #names, decoded[2].name, decoded[2].patches[2].name, decoded[2].patches[2].y`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckInteger(l, -4) != 2 {
+		t.Error("incorrect patch count")
+	}
+
+	if lua.CheckString(l, -3) != "BIGDOOR1" {
+		t.Error("incorrect texture name")
+	}
+
+	if lua.CheckString(l, -2) != "WALL00_1" {
+		t.Error("incorrect patch name")
+	}
+
+	if lua.CheckInteger(l, -1) != -8 {
+		t.Error("incorrect patch offset")
+	}
+}
+
+// TEXTURE1 and TEXTURE2 share a single PNAMES
+func TestLuaEncodeTexturesShared(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local texture1, texture2, pnames = wad.encodetextures(
+			{{name = "ONE", width = 64, height = 64, patches = {{name = "PATCHA"}}}},
+			{{name = "TWO", width = 64, height = 64, patches = {{name = "PATCHB"}}}})
+		return #wad.decodepnames(pnames), wad.decodetextures(texture2, pnames)[1].patches[1].name`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if lua.CheckInteger(l, -2) != 2 {
+		t.Error("incorrect patch count")
+	}
+
+	if lua.CheckString(l, -1) != "PATCHB" {
+		t.Error("incorrect patch name")
+	}
+}
+
+// Texture definition text compiles into lumps and back
+// Values that do not fit the fields of a texture lump are errors
+func TestLuaEncodeTexturesRange(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		return pcall(wad.encodetextures, {{name = "DOOR", width = 40000, height = 64, patches = {}}})`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if l.ToBoolean(-2) || !strings.Contains(lua.CheckString(l, -1), "field width must be between 0 and 32767") {
+		t.Errorf("incorrect error %q", lua.CheckString(l, -1))
+	}
+}
+
+func TestLuaCompileTextures(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local texture1, pnames = wad.compiletextures([[
+			Texture "DOOR", 64, 128 { Patch "DOORPTCH", 0, 0 }
+		]], nil, "zdoom")
+		return wad.decompiletextures(texture1, pnames)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := "DOOR        64   128\n*   DOORPTCH     0     0\n"
+	if lua.CheckString(l, -1) != expected {
+		t.Errorf("incorrect decompiled text %q", lua.CheckString(l, -1))
+	}
+}
+
+// Textures of several Lumps merge into a new Lumps
+func TestLuaMergeTextures(t *testing.T) {
+	l := NewLuaEnvironment()
+
+	err := lua.DoString(l, `
+		local function texturelumps(text)
+			local lumps = wad.createLumps()
+			local texture1, pnames = wad.compiletextures(text)
+			lumps:insert("PNAMES", pnames)
+			lumps:insert("TEXTURE1", texture1)
+			return lumps
+		end
+		local a = texturelumps("ONE 64 64\n* PATCHA 0 0\nTWO 64 64\n* PATCHB 0 0\n")
+		local b = texturelumps("TWO 64 128\n* PATCHC 0 0\n")
+		local ok = pcall(wad.mergetextures, a, b)
+		local merged = wad.mergetextures(a, b, "lastwins")
+		local _, texture1 = merged:get(merged:find("TEXTURE1"))
+		local _, pnames = merged:get(merged:find("PNAMES"))
+		return ok, wad.decompiletextures(texture1, pnames)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if l.ToBoolean(-2) {
+		t.Error("conflicting textures merged with default policy")
+	}
+
+	expected := "ONE         64    64\n*   PATCHA       0     0\nTWO         64   128\n*   PATCHC       0     0\n"
+	if lua.CheckString(l, -1) != expected {
+		t.Errorf("incorrect merged textures %q", lua.CheckString(l, -1))
+	}
+}
@@ -0,0 +1,272 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ManifestData designates how a manifest holds the data of lumps.
+type ManifestData int
+
+const (
+	// ManifestHashes only records the size and hash of lumps, which is
+	// enough to review changes but not to rebuild the WAD.
+	ManifestHashes ManifestData = iota
+
+	// ManifestBase64 stores the data of lumps in the manifest itself.
+	ManifestBase64
+
+	// ManifestSidecar stores the data of lumps in separate files named
+	// by the manifest.
+	ManifestSidecar
+)
+
+// ManifestLump describes a single lump in a manifest.
+type ManifestLump struct {
+	Name string `json:"name"`
+
+	// RawName holds the name in base64 if it is not valid UTF-8, which
+	// JSON and YAML cannot hold.  Name then only holds a readable
+	// version of it.
+	RawName string `json:"rawname,omitempty"`
+
+	Size      int    `json:"size"`
+	SHA256    string `json:"sha256"`
+	Type      string `json:"type,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+
+	// Data of the lump in base64, or the name of the sidecar file
+	// holding it relative to the manifest.  Empty lumps have neither.
+	Data string `json:"data,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// Manifest describes the lumps of a WAD in order, in a form that can be
+// reviewed and diffed as text.
+type Manifest struct {
+	Type  string         `json:"type"`
+	Lumps []ManifestLump `json:"lumps"`
+}
+
+// Returns the hash of lump data as stored in a manifest.
+func lumpHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the sidecar file name of every lump.  Lumps in a namespace
+// are placed in the directory of the namespace and map lumps in a
+// directory named after the map, and lumps whose name is taken by an
+// earlier lump are numbered.
+func sidecarNames(dir Directory, namespaces []string) []string {
+	mapNames := make([]string, len(dir))
+	for _, m := range FindMaps(dir) {
+		for i := m.Index + 1; i < m.Index+m.Count; i++ {
+			mapNames[i] = m.Name
+		}
+	}
+
+	names := make([]string, len(dir))
+	taken := map[string]bool{}
+	for i, lump := range dir {
+		parent := namespaces[i]
+		if mapNames[i] != "" {
+			parent = lumpFileName(mapNames[i], "")
+		}
+
+		readable, _ := manifestName(lump.Name)
+		name := path.Join(parent, lumpFileName(readable, ".lmp"))
+		for n := 2; taken[strings.ToLower(name)]; n++ {
+			name = path.Join(parent, lumpFileName(readable, fmt.Sprintf("~%d.lmp", n)))
+		}
+		taken[strings.ToLower(name)] = true
+
+		names[i] = name
+	}
+
+	return names
+}
+
+// Returns the name of a lump as stored in a manifest, along with the
+// raw name in base64 if the name is not valid UTF-8.
+func manifestName(name string) (string, string) {
+	if utf8.ValidString(name) {
+		return name, ""
+	}
+
+	return strings.ToValidUTF8(name, "\uFFFD"), base64.StdEncoding.EncodeToString([]byte(name))
+}
+
+// NewManifest describes a WAD for the passed game, which is Doom if
+// nil, holding the data of its lumps as passed.  For sidecars, the
+// files to write next to the manifest are returned as a Directory of
+// lumps named by file name.
+func NewManifest(wad *Wad, data ManifestData, game *GameProfile) (*Manifest, Directory) {
+	manifest := &Manifest{Type: "pwad", Lumps: make([]ManifestLump, len(wad.Lumps))}
+	if wad.WadType == WadTypeIWAD {
+		manifest.Type = "iwad"
+	}
+
+	namespaces := LumpNamespaces(wad.Lumps)
+	contexts := LumpContexts(wad.Lumps, game)
+
+	var names []string
+	sidecars := Directory{}
+	if data == ManifestSidecar {
+		names = sidecarNames(wad.Lumps, namespaces)
+	}
+
+	for i, lump := range wad.Lumps {
+		name, raw := manifestName(lump.Name)
+		entry := ManifestLump{
+			Name:      name,
+			RawName:   raw,
+			Size:      len(lump.Data),
+			SHA256:    lumpHash(lump.Data),
+			Namespace: namespaces[i],
+		}
+
+		if kind := DetectType(lump.Name, lump.Data, contexts[i]); kind != LumpUnknown {
+			entry.Type = kind.String()
+		}
+
+		if len(lump.Data) > 0 {
+			switch data {
+			case ManifestBase64:
+				entry.Data = base64.StdEncoding.EncodeToString(lump.Data)
+			case ManifestSidecar:
+				entry.File = names[i]
+				sidecars = append(sidecars, Lump{Name: names[i], Data: lump.Data})
+			}
+		}
+
+		manifest.Lumps[i] = entry
+	}
+
+	return manifest, sidecars
+}
+
+// SidecarLoader returns a function that reads sidecar files from the
+// passed directory, refusing files outside of it.
+func SidecarLoader(dir string) func(name string) ([]byte, error) {
+	return sidecarReader(dir, ioutil.ReadFile)
+}
+
+// sidecarReader returns a function that reads sidecar files the same
+// way as SidecarLoader, reading them with the passed function.
+func sidecarReader(dir string, read func(string) ([]byte, error)) func(name string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		clean := path.Clean(name)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("sidecar %s is outside of the manifest directory", name)
+		}
+
+		return read(filepath.Join(dir, filepath.FromSlash(clean)))
+	}
+}
+
+// Build rebuilds the WAD a manifest describes, reading sidecar files
+// with the passed function.  The data of every lump must match the
+// size and hash in the manifest.
+func (manifest *Manifest) Build(sidecar func(name string) ([]byte, error)) (*Wad, error) {
+	var wad *Wad
+	switch manifest.Type {
+	case "iwad":
+		wad = NewWad(WadTypeIWAD)
+	case "pwad":
+		wad = NewWad(WadTypePWAD)
+	default:
+		return nil, fmt.Errorf("unknown wad type %s", manifest.Type)
+	}
+
+	for i, entry := range manifest.Lumps {
+		name := entry.Name
+		if entry.RawName != "" {
+			raw, err := base64.StdEncoding.DecodeString(entry.RawName)
+			if err != nil {
+				return nil, fmt.Errorf("lump %d (%s): invalid raw name", i+1, entry.Name)
+			}
+			name = string(raw)
+		}
+
+		var data []byte
+		var err error
+		switch {
+		case entry.Data != "":
+			data, err = base64.StdEncoding.DecodeString(entry.Data)
+		case entry.File != "":
+			if sidecar == nil {
+				err = fmt.Errorf("sidecar %s cannot be read", entry.File)
+			} else {
+				data, err = sidecar(entry.File)
+			}
+		case entry.Size != 0:
+			err = errors.New("no data")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lump %d (%s): %s", i+1, entry.Name, err.Error())
+		}
+
+		if len(data) != entry.Size {
+			return nil, fmt.Errorf("lump %d (%s): data is %d bytes instead of %d",
+				i+1, entry.Name, len(data), entry.Size)
+		} else if entry.SHA256 != "" && !strings.EqualFold(lumpHash(data), entry.SHA256) {
+			return nil, fmt.Errorf("lump %d (%s): data does not match its hash", i+1, entry.Name)
+		}
+
+		wad.Lumps = append(wad.Lumps, Lump{Name: name, Data: data})
+	}
+
+	return wad, nil
+}
+
+// EncodeManifestJSON writes a manifest as indented JSON.
+func EncodeManifestJSON(w io.Writer, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// DecodeManifestJSON reads a manifest from JSON.
+func DecodeManifestJSON(data []byte) (*Manifest, error) {
+	manifest := &Manifest{}
+	err := json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,213 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A WAD with maps, namespaces and duplicate lump names
+func manifestTestWad() *Wad {
+	wad := NewWad(WadTypeIWAD)
+	wad.Lumps = Directory{
+		{Name: "DEHACKED", Data: []byte("patch")},
+		{Name: "MAP01"},
+		{Name: "THINGS", Data: []byte("things 1")},
+		{Name: "MAP02"},
+		{Name: "THINGS", Data: []byte("things 2")},
+		{Name: "S_START"},
+		{Name: "VILE\\1", Data: []byte("vile")},
+		{Name: "S_END"},
+		{Name: "DEHACKED", Data: []byte("another patch")},
+	}
+
+	return wad
+}
+
+// Returns the encoded data of a WAD.
+func encodedWad(t *testing.T, wad *Wad) []byte {
+	var buffer bytes.Buffer
+	err := Encode(&buffer, wad)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return buffer.Bytes()
+}
+
+// Manifests with base64 data rebuild the exact WAD
+func TestManifest(t *testing.T) {
+	wad := manifestTestWad()
+	manifest, sidecars := NewManifest(wad, ManifestBase64, nil)
+	if len(sidecars) != 0 {
+		t.Error("base64 manifest has sidecars")
+	}
+
+	if manifest.Type != "iwad" || len(manifest.Lumps) != len(wad.Lumps) {
+		t.Fatal("incorrect manifest")
+	}
+
+	expected := ManifestLump{
+		Name:      "VILE\\1",
+		Size:      4,
+		SHA256:    lumpHash([]byte("vile")),
+		Type:      "text",
+		Namespace: "sprites",
+		Data:      "dmlsZQ==",
+	}
+	if !reflect.DeepEqual(manifest.Lumps[6], expected) {
+		t.Errorf("incorrect lump %v", manifest.Lumps[6])
+	}
+
+	types := []string{}
+	for _, lump := range manifest.Lumps {
+		types = append(types, lump.Type)
+	}
+	if !reflect.DeepEqual(types, []string{"dehacked", "map", "maplump", "map", "maplump", "marker", "text", "marker", "dehacked"}) {
+		t.Errorf("incorrect types %q", types)
+	}
+
+	var buffer bytes.Buffer
+	err := EncodeManifestJSON(&buffer, manifest)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	decoded, err := DecodeManifestJSON(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	rebuilt, err := decoded.Build(nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !bytes.Equal(encodedWad(t, rebuilt), encodedWad(t, wad)) {
+		t.Error("rebuilt WAD differs")
+	}
+
+	// Data must match the hash
+	decoded.Lumps[0].Data = "cGF0Y2k="
+	_, err = decoded.Build(nil)
+	if err == nil || err.Error() != "lump 1 (DEHACKED): data does not match its hash" {
+		t.Errorf("incorrect hash error %v", err)
+	}
+
+	hashes, _ := NewManifest(wad, ManifestHashes, nil)
+	_, err = hashes.Build(nil)
+	if err == nil || err.Error() != "lump 1 (DEHACKED): no data" {
+		t.Errorf("incorrect data error %v", err)
+	}
+}
+
+// Names that are not valid UTF-8 survive JSON and YAML
+func TestManifestRawName(t *testing.T) {
+	wad := NewWad(WadTypePWAD)
+	wad.Lumps = Directory{{Name: "\xffBAD", Data: []byte("data")}}
+
+	manifest, sidecars := NewManifest(wad, ManifestSidecar, nil)
+	if manifest.Lumps[0].Name != "\uFFFDBAD" || manifest.Lumps[0].RawName != "/0JBRA==" {
+		t.Errorf("incorrect names %v", manifest.Lumps[0])
+	}
+
+	if sidecars[0].Name != "\uFFFDBAD.lmp" {
+		t.Errorf("incorrect sidecar name %q", sidecars[0].Name)
+	}
+
+	manifest, _ = NewManifest(wad, ManifestBase64, nil)
+	encoders := []struct {
+		encode func(io.Writer, *Manifest) error
+		decode func([]byte) (*Manifest, error)
+	}{
+		{EncodeManifestJSON, DecodeManifestJSON},
+		{EncodeManifestYAML, DecodeManifestYAML},
+	}
+	for _, encoder := range encoders {
+		var buffer bytes.Buffer
+		err := encoder.encode(&buffer, manifest)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		decoded, err := encoder.decode(buffer.Bytes())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		rebuilt, err := decoded.Build(nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if rebuilt.Lumps[0].Name != "\xffBAD" {
+			t.Errorf("incorrect rebuilt name %q", rebuilt.Lumps[0].Name)
+		}
+	}
+}
+
+// Sidecar files have unique names and are read back from a directory
+func TestManifestSidecar(t *testing.T) {
+	wad := manifestTestWad()
+	manifest, sidecars := NewManifest(wad, ManifestSidecar, nil)
+
+	names := []string{}
+	for _, file := range sidecars {
+		names = append(names, file.Name)
+	}
+	expected := []string{"DEHACKED.lmp", "MAP01/THINGS.lmp", "MAP02/THINGS.lmp", "sprites/VILE^1.lmp", "DEHACKED~2.lmp"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("incorrect sidecars %q", names)
+	}
+
+	dir, err := ioutil.TempDir("", "wadmake")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	for _, file := range sidecars {
+		filename := filepath.Join(dir, filepath.FromSlash(file.Name))
+		os.MkdirAll(filepath.Dir(filename), 0777)
+		ioutil.WriteFile(filename, file.Data, 0666)
+	}
+
+	rebuilt, err := manifest.Build(SidecarLoader(dir))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !bytes.Equal(encodedWad(t, rebuilt), encodedWad(t, wad)) {
+		t.Error("rebuilt WAD differs")
+	}
+
+	manifest.Lumps[0].File = "../DEHACKED.lmp"
+	_, err = manifest.Build(SidecarLoader(dir))
+	if err == nil || !strings.Contains(err.Error(), "outside of the manifest directory") {
+		t.Errorf("incorrect sidecar error %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EncodeManifestYAML writes a manifest as YAML.  Strings are always
+// double-quoted, so that lump names never need to be guessed at.  As
+// names that are not valid UTF-8 are kept in base64, every string is
+// valid UTF-8, for which Go and YAML quoting mean the same.
+func EncodeManifestYAML(w io.Writer, manifest *Manifest) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "type: %s\n", strconv.Quote(manifest.Type))
+	if len(manifest.Lumps) == 0 {
+		fmt.Fprint(bw, "lumps: []\n")
+	} else {
+		fmt.Fprint(bw, "lumps:\n")
+	}
+
+	for _, lump := range manifest.Lumps {
+		fmt.Fprintf(bw, "- name: %s\n", strconv.Quote(lump.Name))
+		fmt.Fprintf(bw, "  size: %d\n", lump.Size)
+		fmt.Fprintf(bw, "  sha256: %s\n", strconv.Quote(lump.SHA256))
+
+		optional := []struct {
+			key   string
+			value string
+		}{
+			{"rawname", lump.RawName},
+			{"type", lump.Type},
+			{"namespace", lump.Namespace},
+			{"data", lump.Data},
+			{"file", lump.File},
+		}
+		for _, field := range optional {
+			if field.value != "" {
+				fmt.Fprintf(bw, "  %s: %s\n", field.key, strconv.Quote(field.value))
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Splits a line of YAML into its key and scalar value.  Values may be
+// plain, single-quoted or double-quoted.
+func yamlPair(line string) (string, string, error) {
+	colon := strings.Index(line, ":")
+	if colon == -1 || colon+1 < len(line) && line[colon+1] != ' ' {
+		return "", "", fmt.Errorf("expected key and value, got %q", line)
+	}
+
+	key := strings.TrimSpace(line[:colon])
+	value := strings.TrimSpace(line[colon+1:])
+	switch {
+	case strings.HasPrefix(value, "\""):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid string %s", value)
+		}
+		value = unquoted
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", "", fmt.Errorf("invalid string %s", value)
+		}
+		value = strings.Replace(value[1:len(value)-1], "''", "'", -1)
+	default:
+		if comment := strings.Index(value, " #"); comment != -1 {
+			value = strings.TrimSpace(value[:comment])
+		}
+
+		// Anything but a plain scalar would be misread as one
+		if value != "" && strings.ContainsAny(value[:1], "|>{[&*!%@`") {
+			return "", "", fmt.Errorf("unsupported value %s", value)
+		}
+	}
+
+	return key, value, nil
+}
+
+// DecodeManifestYAML reads a manifest from YAML.  This is not a general
+// YAML decoder: only the block layout that EncodeManifestYAML writes is
+// understood, with every key and its scalar value on one line.
+// Comments, blank lines, indentation of the list and any quoting of
+// scalars are allowed, so hand edits that keep to that layout, such as
+// changing values or moving lumps around, can be read back.  Anything
+// else, such as flow collections, multi-line strings, anchors, tags and
+// repeated keys, is rejected rather than guessed at.
+func DecodeManifestYAML(data []byte) (*Manifest, error) {
+	manifest := &Manifest{Lumps: []ManifestLump{}}
+
+	var lump *ManifestLump
+	inLumps := false
+	seen := map[string]bool{}
+	lumpSeen := map[string]bool{}
+	for i, line := range strings.Split(string(data), "\n") {
+		errorf := func(format string, a ...interface{}) error {
+			return fmt.Errorf("line %d: %s", i+1, fmt.Sprintf(format, a...))
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Keys of the manifest itself are not indented
+		if line[0] != ' ' && line[0] != '-' {
+			key, value, err := yamlPair(trimmed)
+			if err != nil {
+				return nil, errorf("%s", err.Error())
+			} else if seen[key] {
+				return nil, errorf("repeated key %s", key)
+			}
+			seen[key] = true
+
+			switch key {
+			case "type":
+				manifest.Type = value
+				inLumps = false
+			case "lumps":
+				if value != "" && value != "[]" {
+					return nil, errorf("lumps must be a list")
+				}
+				inLumps = true
+			default:
+				return nil, errorf("unknown key %s", key)
+			}
+			continue
+		}
+
+		if !inLumps {
+			return nil, errorf("unexpected list or indentation")
+		}
+
+		// Every item of the list starts a lump
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			manifest.Lumps = append(manifest.Lumps, ManifestLump{})
+			lump = &manifest.Lumps[len(manifest.Lumps)-1]
+			lumpSeen = map[string]bool{}
+			trimmed = strings.TrimSpace(trimmed[1:])
+			if trimmed == "" {
+				continue
+			}
+		} else if lump == nil {
+			return nil, errorf("expected a list of lumps")
+		}
+
+		key, value, err := yamlPair(trimmed)
+		if err != nil {
+			return nil, errorf("%s", err.Error())
+		} else if lumpSeen[key] {
+			return nil, errorf("repeated lump key %s", key)
+		}
+		lumpSeen[key] = true
+
+		switch key {
+		case "name":
+			lump.Name = value
+		case "rawname":
+			lump.RawName = value
+		case "size":
+			lump.Size, err = strconv.Atoi(value)
+			if err != nil || lump.Size < 0 {
+				return nil, errorf("invalid size %s", value)
+			}
+		case "sha256":
+			lump.SHA256 = value
+		case "type":
+			lump.Type = value
+		case "namespace":
+			lump.Namespace = value
+		case "data":
+			lump.Data = value
+		case "file":
+			lump.File = value
+		default:
+			return nil, errorf("unknown lump key %s", key)
+		}
+	}
+
+	return manifest, nil
+}
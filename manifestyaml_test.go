@@ -0,0 +1,106 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Manifests survive a round trip through YAML
+func TestManifestYAML(t *testing.T) {
+	manifest, _ := NewManifest(manifestTestWad(), ManifestBase64, nil)
+
+	var buffer bytes.Buffer
+	err := EncodeManifestYAML(&buffer, manifest)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !strings.HasPrefix(buffer.String(), "type: \"iwad\"\nlumps:\n- name: \"DEHACKED\"\n  size: 5\n") {
+		t.Errorf("incorrect YAML %q", buffer.String())
+	}
+
+	decoded, err := DecodeManifestYAML(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(decoded, manifest) {
+		t.Error("decoded manifest differs")
+	}
+}
+
+// Hand-edited YAML may use comments and any quoting
+func TestDecodeManifestYAML(t *testing.T) {
+	text := `---
+# Our maps
+type: pwad
+lumps:
+  - name: MAP01 # the first map
+    size: 0
+    sha256: ''
+  -
+    name: 'DON''T'
+    size: 3
+    data: "YWJj"
+`
+
+	manifest, err := DecodeManifestYAML([]byte(text))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := &Manifest{Type: "pwad", Lumps: []ManifestLump{
+		{Name: "MAP01"},
+		{Name: "DON'T", Size: 3, Data: "YWJj"},
+	}}
+	if !reflect.DeepEqual(manifest, expected) {
+		t.Errorf("incorrect manifest %v", manifest)
+	}
+
+	_, err = DecodeManifestYAML([]byte("type: pwad\nlumps:\n- name: A\n  colour: red\n"))
+	if err == nil || err.Error() != "line 4: unknown lump key colour" {
+		t.Errorf("incorrect key error %v", err)
+	}
+
+	_, err = DecodeManifestYAML([]byte("type: pwad\n  name: A\n"))
+	if err == nil || err.Error() != "line 2: unexpected list or indentation" {
+		t.Errorf("incorrect indentation error %v", err)
+	}
+
+	// YAML outside of the layout of EncodeManifestYAML is rejected
+	unsupported := []string{
+		"type: pwad\nlumps:\n- {name: A, size: 0}\n",
+		"type: pwad\nlumps:\n- name: A\n  data: |\n    YWJj\n",
+		"type: pwad\nlumps:\n- name: &name A\n",
+		"type: pwad\nlumps:\n- name: !!str A\n",
+		"type: pwad\nlumps:\n- name: A\n  name: B\n",
+		"type: pwad\ntype: iwad\n",
+	}
+	for _, text := range unsupported {
+		_, err = DecodeManifestYAML([]byte(text))
+		if err == nil {
+			t.Errorf("%q was decoded", text)
+		}
+	}
+}
@@ -0,0 +1,515 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MapInfoProperty is a single property of a MAPINFO block, with any
+// number of values.  Keys are lowercase.
+type MapInfoProperty struct {
+	Key    string
+	Values []string
+	Line   int
+}
+
+// MapInfoMap is the definition of a single map.
+type MapInfoMap struct {
+	Map           string
+	Title         string
+	TitleLookup   bool
+	Next          string
+	SecretNext    string
+	Music         string
+	Sky1          string
+	Sky2          string
+	TitlePatch    string
+	ExitPic       string
+	EnterPic      string
+	EndPic        string
+	InterMusic    string
+	InterBackdrop string
+	Cluster       int
+	Par           int
+	Line          int
+	Properties    []MapInfoProperty
+}
+
+// MapInfoEpisode is an entry of the episode menu.
+type MapInfoEpisode struct {
+	Map     string
+	Name    string
+	PicName string
+	Key     string
+	Line    int
+}
+
+// MapInfoCluster is a group of maps sharing intermission text.
+type MapInfoCluster struct {
+	Cluster    int
+	Music      string
+	Flat       string
+	Pic        string
+	Line       int
+	Properties []MapInfoProperty
+}
+
+// MapInfo holds the maps, episodes and clusters defined by a UMAPINFO,
+// MAPINFO or ZMAPINFO lump.
+type MapInfo struct {
+	Name     string
+	Maps     []MapInfoMap
+	Episodes []MapInfoEpisode
+	Clusters []MapInfoCluster
+	Includes []string
+}
+
+// mapInfoTopLevel holds the keywords that begin a block in old-style
+// MAPINFO, where blocks are not surrounded by braces.
+var mapInfoTopLevel = map[string]bool{
+	"map": true, "defaultmap": true, "adddefaultmap": true,
+	"gamedefaults": true, "episode": true, "clusterdef": true,
+	"clearepisodes": true, "skill": true, "clearskills": true,
+	"gameinfo": true, "include": true, "intermission": true,
+	"automap": true, "automap_overlay": true, "doomednums": true,
+	"spawnnums": true, "conversationids": true, "damagetype": true,
+}
+
+// value returns a value of the property, or an empty string.
+func (prop *MapInfoProperty) value(index int) string {
+	if index < len(prop.Values) {
+		return prop.Values[index]
+	}
+	return ""
+}
+
+// mapInfoValue consumes a single property value.
+func mapInfoValue(s *textScanner) (string, error) {
+	negative, err := s.Accept("-")
+	if err != nil {
+		return "", err
+	}
+
+	value, err := s.Name()
+	if negative {
+		value = "-" + value
+	}
+	return value, err
+}
+
+// skipMapInfoBlock skips a nested block whose opening brace has been
+// consumed.
+func skipMapInfoBlock(s *textScanner) error {
+	depth := 1
+	for depth > 0 {
+		tok, err := s.Next()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case tok.Type == tokenEOF:
+			return s.errorf(tok.Line, "unterminated block")
+		case tok.Type == tokenPunct && tok.Text == "{":
+			depth++
+		case tok.Type == tokenPunct && tok.Text == "}":
+			depth--
+		}
+	}
+
+	return nil
+}
+
+// parseMapInfoBlock parses the properties of a block whose opening
+// brace has been consumed.  Values follow an equals sign and are
+// separated by commas.
+func parseMapInfoBlock(s *textScanner) ([]MapInfoProperty, error) {
+	props := []MapInfoProperty{}
+	for {
+		tok, err := s.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if tok.Type == tokenPunct && tok.Text == "}" {
+			return props, nil
+		} else if tok.Type == tokenEOF {
+			return nil, s.errorf(tok.Line, "unterminated block")
+		} else if tok.Type != tokenIdentifier {
+			return nil, s.errorf(tok.Line, "expected property, got %q", tok.Text)
+		}
+
+		prop := MapInfoProperty{Key: strings.ToLower(tok.Text), Values: []string{}, Line: tok.Line}
+
+		nested, err := s.Accept("{")
+		if err != nil {
+			return nil, err
+		} else if nested {
+			err = skipMapInfoBlock(s)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		equals, err := s.Accept("=")
+		if err != nil {
+			return nil, err
+		}
+
+		for equals {
+			value, err := mapInfoValue(s)
+			if err != nil {
+				return nil, err
+			}
+			prop.Values = append(prop.Values, value)
+
+			equals, err = s.Accept(",")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		props = append(props, prop)
+	}
+}
+
+// parseMapInfoLines parses the properties of an old-style block, where
+// each property is a line that ends at the next top-level keyword.
+func parseMapInfoLines(s *textScanner) ([]MapInfoProperty, error) {
+	props := []MapInfoProperty{}
+	for {
+		tok, err := s.Peek()
+		if err != nil {
+			return nil, err
+		}
+
+		if tok.Type == tokenEOF || (tok.Type == tokenIdentifier && mapInfoTopLevel[strings.ToLower(tok.Text)]) {
+			return props, nil
+		} else if tok.Type != tokenIdentifier {
+			return nil, s.errorf(tok.Line, "expected property, got %q", tok.Text)
+		}
+		s.Next()
+
+		prop := MapInfoProperty{Key: strings.ToLower(tok.Text), Values: []string{}, Line: tok.Line}
+		for {
+			next, err := s.Peek()
+			if err != nil {
+				return nil, err
+			} else if next.Type == tokenEOF || next.Line != tok.Line {
+				break
+			}
+
+			value, err := mapInfoValue(s)
+			if err != nil {
+				return nil, err
+			}
+			prop.Values = append(prop.Values, value)
+		}
+
+		props = append(props, prop)
+	}
+}
+
+// newMapInfoMap interprets the header and properties of a map block.
+func newMapInfoMap(args []string, props []MapInfoProperty, line int) MapInfoMap {
+	def := MapInfoMap{Map: strings.ToUpper(args[0]), Line: line, Properties: props}
+	if len(args) > 2 && strings.EqualFold(args[1], "lookup") {
+		def.Title, def.TitleLookup = args[2], true
+	} else if len(args) > 1 {
+		def.Title = args[1]
+	}
+
+	for i := range props {
+		prop := &props[i]
+		switch prop.Key {
+		case "levelname":
+			def.Title = prop.value(0)
+		case "lookup":
+			def.Title, def.TitleLookup = prop.value(0), true
+		case "next":
+			def.Next = prop.value(0)
+		case "secretnext", "nextsecret":
+			def.SecretNext = prop.value(0)
+		case "music":
+			def.Music = prop.value(0)
+		case "sky1", "skytexture":
+			def.Sky1 = prop.value(0)
+		case "sky2":
+			def.Sky2 = prop.value(0)
+		case "titlepatch", "levelpic":
+			def.TitlePatch = prop.value(0)
+		case "exitpic":
+			def.ExitPic = prop.value(0)
+		case "enterpic":
+			def.EnterPic = prop.value(0)
+		case "endpic":
+			def.EndPic = prop.value(0)
+		case "intermusic":
+			def.InterMusic = prop.value(0)
+		case "interbackdrop":
+			def.InterBackdrop = prop.value(0)
+		case "cluster":
+			def.Cluster, _ = strconv.Atoi(prop.value(0))
+		case "par", "partime":
+			def.Par, _ = strconv.Atoi(prop.value(0))
+		}
+	}
+
+	return def
+}
+
+// ParseMapInfo parses a UMAPINFO, MAPINFO or ZMAPINFO lump.  Both the
+// braced syntax of UMAPINFO and ZMAPINFO and the line-based syntax of
+// old-style MAPINFO are accepted.  Blocks other than maps, episodes
+// and clusters are skipped.  Lines may be commented with ; as in the
+// MAPINFO of Hexen.  The name is used in error messages.
+func ParseMapInfo(name string, src string) (*MapInfo, error) {
+	s := newTextScanner(name, src)
+	s.semicolonComments = true
+	info := &MapInfo{Name: name}
+
+	for {
+		tok, err := s.Next()
+		if err != nil {
+			return nil, err
+		} else if tok.Type == tokenEOF {
+			break
+		} else if tok.Type != tokenIdentifier {
+			return nil, s.errorf(tok.Line, "expected keyword, got %q", tok.Text)
+		}
+
+		// Header arguments are on the same line as the keyword
+		kind := strings.ToLower(tok.Text)
+		args := []string{}
+		for {
+			next, err := s.Peek()
+			if err != nil {
+				return nil, err
+			} else if next.Type == tokenEOF || next.Type == tokenPunct || next.Line != tok.Line {
+				break
+			}
+			s.Next()
+			args = append(args, next.Text)
+		}
+
+		braced, err := s.Accept("{")
+		if err != nil {
+			return nil, err
+		}
+
+		var props []MapInfoProperty
+		if braced {
+			props, err = parseMapInfoBlock(s)
+		} else {
+			props, err = parseMapInfoLines(s)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case "map":
+			if len(args) == 0 {
+				return nil, s.errorf(tok.Line, "map has no name")
+			}
+
+			def := newMapInfoMap(args, props, tok.Line)
+			info.Maps = append(info.Maps, def)
+
+			// UMAPINFO adds episodes from map blocks
+			for _, prop := range props {
+				if prop.Key == "episode" && !strings.EqualFold(prop.value(0), "clear") {
+					info.Episodes = append(info.Episodes, MapInfoEpisode{
+						Map:     def.Map,
+						PicName: prop.value(0),
+						Name:    prop.value(1),
+						Key:     prop.value(2),
+						Line:    prop.Line,
+					})
+				}
+			}
+		case "episode":
+			if len(args) == 0 {
+				return nil, s.errorf(tok.Line, "episode has no map")
+			}
+
+			episode := MapInfoEpisode{Map: strings.ToUpper(args[0]), Line: tok.Line}
+			for _, prop := range props {
+				switch prop.Key {
+				case "name", "lookup":
+					episode.Name = prop.value(0)
+				case "picname":
+					episode.PicName = prop.value(0)
+				case "key":
+					episode.Key = prop.value(0)
+				}
+			}
+			info.Episodes = append(info.Episodes, episode)
+		case "cluster", "clusterdef":
+			if len(args) == 0 {
+				return nil, s.errorf(tok.Line, "cluster has no number")
+			}
+
+			number, err := strconv.Atoi(args[0])
+			if err != nil {
+				return nil, s.errorf(tok.Line, "invalid cluster %q", args[0])
+			}
+
+			cluster := MapInfoCluster{Cluster: number, Line: tok.Line, Properties: props}
+			for _, prop := range props {
+				switch prop.Key {
+				case "music":
+					cluster.Music = prop.value(0)
+				case "flat":
+					cluster.Flat = prop.value(0)
+				case "pic":
+					cluster.Pic = prop.value(0)
+				}
+			}
+			info.Clusters = append(info.Clusters, cluster)
+		case "include":
+			if len(args) == 0 {
+				return nil, s.errorf(tok.Line, "include has no file")
+			}
+			info.Includes = append(info.Includes, args[0])
+		}
+	}
+
+	return info, nil
+}
+
+// lumpBaseName returns the uppercase name of a lump without any path
+// or extension, so lumps in a ZIP can be matched to lump names.
+func lumpBaseName(name string) string {
+	name = path.Base(strings.Replace(name, "\\", "/", -1))
+	if index := strings.IndexByte(name, '.'); index > 0 {
+		name = name[:index]
+	}
+	return strings.ToUpper(name)
+}
+
+// mapInfoLumpName returns the lump name of a map, which Hexen MAPINFO
+// refers to by number.
+func mapInfoLumpName(name string) string {
+	if number, err := strconv.Atoi(name); err == nil && number >= 0 {
+		return fmt.Sprintf("MAP%02d", number)
+	}
+	return name
+}
+
+// CheckMapInfo checks that every map, music lump, sky texture and
+// intermission graphic referenced by the passed MAPINFO exists in one
+// of the passed directories.  Sky textures may be defined in TEXTURE1,
+// TEXTURE2 or TEXTURES, or be a lump of their own.  Maps given by
+// number, as in Hexen, are looked for as MAPxx.
+func CheckMapInfo(info *MapInfo, format TextureFormat, dirs ...*Directory) []error {
+	errs := []error{}
+	lumps := map[string]bool{}
+	textures := map[string]bool{}
+
+	for _, dir := range dirs {
+		for _, lump := range *dir {
+			lumps[lumpBaseName(lump.Name)] = true
+
+			if lumpBaseName(lump.Name) == "TEXTURES" {
+				defs, err := ParseZDoomTextures(strings.NewReader(string(lump.Data)))
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s", lump.Name, err.Error()))
+				}
+				for _, texture := range defs {
+					textures[strings.ToUpper(texture.Name)] = true
+				}
+			}
+		}
+
+		set, err := DecodeTextureSet(dir, format)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, texture := range append(set.Texture1, set.Texture2...) {
+			textures[texture.Name] = true
+		}
+	}
+
+	report := func(line int, format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s:%d: %s", info.Name, line, fmt.Sprintf(format, a...)))
+	}
+
+	// Checks that a lump exists.  Empty names and LANGUAGE lookups are
+	// not checked.
+	check := func(line int, what string, name string, owner string) {
+		if name == "" || strings.HasPrefix(name, "$") {
+			return
+		}
+
+		if what == "map" {
+			name = mapInfoLumpName(name)
+		}
+
+		base := lumpBaseName(name)
+		if !lumps[base] && !(what == "sky texture" && textures[base]) {
+			report(line, "%s %s of %s not found", what, name, owner)
+		}
+	}
+
+	// Checks that the next map exists, unless it ends the game.
+	checkNext := func(line int, name string, owner string) {
+		if !strings.HasPrefix(strings.ToLower(name), "end") {
+			check(line, "map", name, owner)
+		}
+	}
+
+	for _, def := range info.Maps {
+		owner := "map " + def.Map
+		check(def.Line, "map", def.Map, owner)
+		checkNext(def.Line, def.Next, owner)
+		checkNext(def.Line, def.SecretNext, owner)
+		check(def.Line, "music", def.Music, owner)
+		check(def.Line, "music", def.InterMusic, owner)
+		check(def.Line, "sky texture", def.Sky1, owner)
+		check(def.Line, "sky texture", def.Sky2, owner)
+		check(def.Line, "graphic", def.TitlePatch, owner)
+		check(def.Line, "graphic", def.ExitPic, owner)
+		check(def.Line, "graphic", def.EnterPic, owner)
+		check(def.Line, "graphic", def.EndPic, owner)
+		check(def.Line, "graphic", def.InterBackdrop, owner)
+	}
+
+	for _, episode := range info.Episodes {
+		owner := "episode " + episode.Map
+		check(episode.Line, "map", episode.Map, owner)
+		check(episode.Line, "graphic", episode.PicName, owner)
+	}
+
+	for _, cluster := range info.Clusters {
+		owner := fmt.Sprintf("cluster %d", cluster.Cluster)
+		check(cluster.Line, "music", cluster.Music, owner)
+		check(cluster.Line, "graphic", cluster.Flat, owner)
+		check(cluster.Line, "graphic", cluster.Pic, owner)
+	}
+
+	return errs
+}
@@ -0,0 +1,200 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+)
+
+const testUMapInfo = `MAP MAP01
+{
+	levelname = "Entryway"
+	next = "MAP02"
+	music = "D_RUNNIN"
+	skytexture = "SKY1"
+	exitpic = "INTERPIC"
+	episode = "M_EPI1", "Knee Deep", "k"
+	partime = 30
+}
+`
+
+const testZMapInfo = `// New-style MAPINFO
+map MAP01 lookup "HUSTR_1"
+{
+	next = "MAP02"
+	secretnext = "EndGame1"
+	sky1 = "SKY1", 0.5
+	music = "$MUSIC_RUNNIN"
+	cluster = 5
+	nointermission
+	intermission { ignored = 1 }
+}
+
+cluster 5
+{
+	flat = "FLOOR4_8"
+	music = "D_READ_M"
+	exittext = "Done."
+}
+
+// Old-style MAPINFO
+map MAP02 "Underhalls"
+next MAP03
+sky1 SKY2 -1
+music D_STALKS
+clusterdef 6
+music D_EVIL
+`
+
+const testHexenMapInfo = `;  Hexen MAPINFO
+map 1 "Winnowing Hall"
+warptrans 1
+next 2
+cluster 1
+sky1 SKY2 0
+sky2 SKY3 0
+lightning
+cdtrack 13
+
+map 2 "Seven Portals"
+warptrans 2
+next 41
+secretnext 3
+cluster 1
+sky1 SKY2 0
+`
+
+// UMAPINFO parses into maps and episodes
+func TestParseUMapInfo(t *testing.T) {
+	info, err := ParseMapInfo("UMAPINFO", testUMapInfo)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(info.Maps) != 1 {
+		t.Fatal("incorrect map count")
+	}
+
+	def := info.Maps[0]
+	if def.Map != "MAP01" || def.Title != "Entryway" || def.Next != "MAP02" ||
+		def.Music != "D_RUNNIN" || def.Sky1 != "SKY1" || def.ExitPic != "INTERPIC" || def.Par != 30 {
+		t.Errorf("incorrect map %+v", def)
+	}
+
+	if len(info.Episodes) != 1 || info.Episodes[0] != (MapInfoEpisode{"MAP01", "Knee Deep", "M_EPI1", "k", 8}) {
+		t.Errorf("incorrect episodes %+v", info.Episodes)
+	}
+}
+
+// Both new-style and old-style ZDoom MAPINFO parse
+func TestParseZMapInfo(t *testing.T) {
+	info, err := ParseMapInfo("ZMAPINFO", testZMapInfo)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(info.Maps) != 2 || len(info.Clusters) != 2 {
+		t.Fatalf("incorrect definitions %+v", info)
+	}
+
+	def := info.Maps[0]
+	if def.Title != "HUSTR_1" || !def.TitleLookup || def.SecretNext != "EndGame1" ||
+		def.Sky1 != "SKY1" || def.Cluster != 5 || len(def.Properties) != 6 {
+		t.Errorf("incorrect map %+v", def)
+	}
+
+	def = info.Maps[1]
+	if def.Map != "MAP02" || def.Title != "Underhalls" || def.Next != "MAP03" ||
+		def.Sky1 != "SKY2" || def.Music != "D_STALKS" || def.Line != 21 {
+		t.Errorf("incorrect map %+v", def)
+	}
+
+	if info.Clusters[0].Flat != "FLOOR4_8" || info.Clusters[1].Music != "D_EVIL" {
+		t.Errorf("incorrect clusters %+v", info.Clusters)
+	}
+
+	_, err = ParseMapInfo("MAPINFO", "map MAP01 {\n\tnext = \n}")
+	if err == nil || err.Error() != `MAPINFO:3: expected name, got "}"` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// Missing lumps are reported, and present ones are not
+func TestCheckMapInfo(t *testing.T) {
+	info, err := ParseMapInfo("ZMAPINFO", testZMapInfo)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dir := Directory{
+		{Name: "MAP01"},
+		{Name: "maps/map02.wad"},
+		{Name: "MAP03"},
+		{Name: "FLOOR4_8"},
+		{Name: "music/d_read_m.ogg"},
+		{Name: "TEXTURES", Data: []byte("WallTexture SKY1, 256, 128 { }")},
+	}
+
+	expected := []string{
+		"ZMAPINFO:21: music D_STALKS of map MAP02 not found",
+		"ZMAPINFO:21: sky texture SKY2 of map MAP02 not found",
+		"ZMAPINFO:25: music D_EVIL of cluster 6 not found",
+	}
+
+	errs := CheckMapInfo(info, TextureFormatDoom, &dir)
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], err.Error())
+		}
+	}
+}
+
+// Hexen MAPINFO refers to maps by number, which are checked as MAPxx
+func TestCheckHexenMapInfo(t *testing.T) {
+	info, err := ParseMapInfo("MAPINFO", testHexenMapInfo)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dir := Directory{
+		{Name: "MAP01"},
+		{Name: "MAP02"},
+		{Name: "MAP03"},
+		{Name: "SKY2"},
+	}
+
+	expected := []string{
+		"MAPINFO:2: sky texture SKY3 of map 1 not found",
+		"MAPINFO:11: map MAP41 of map 2 not found",
+	}
+
+	errs := CheckMapInfo(info, TextureFormatDoom, &dir)
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], err.Error())
+		}
+	}
+}
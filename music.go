@@ -0,0 +1,504 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MUSSizeLimit is the largest MUS lump vanilla Doom can play.
+const MUSSizeLimit = 65535
+
+// musRate is the number of MUS ticks per second.
+const musRate = 140
+
+// MUS event types, held in bits 4-6 of an event descriptor.
+const (
+	musReleaseNote = iota
+	musPlayNote
+	musPitchBend
+	musSystemEvent
+	musController
+	musMeasureEnd
+	musScoreEnd
+)
+
+// musEventSizes is the minimum number of data bytes following each
+// type of MUS event descriptor.
+var musEventSizes = [8]int{1, 1, 1, 1, 2, 0, 0, 0}
+
+// musPercussion is the MUS channel used for percussion, in place of
+// MIDI channel 9.
+const musPercussion = 15
+
+// musControllers maps MUS controller numbers to MIDI ones.  MUS
+// controller zero is a program change instead.
+var musControllers = [10]byte{0, 0, 1, 7, 10, 11, 91, 93, 64, 67}
+
+// musSystemEvents maps MUS system events, numbered from 10, to MIDI
+// channel mode controllers.
+var musSystemEvents = [5]byte{120, 123, 126, 127, 121}
+
+// readVarLen reads a MIDI-style variable length quantity, returning the
+// value and the number of bytes read.
+func readVarLen(data []byte) (int, int, error) {
+	value := 0
+	for i := 0; i < 4; i++ {
+		if i >= len(data) {
+			return 0, 0, errors.New("truncated variable length quantity")
+		}
+
+		value = value<<7 | int(data[i]&0x7F)
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+
+	return 0, 0, errors.New("variable length quantity is too long")
+}
+
+// writeVarLen writes a MIDI-style variable length quantity.
+func writeVarLen(buffer *bytes.Buffer, value int) {
+	var encoded [4]byte
+	i := len(encoded) - 1
+	encoded[i] = byte(value & 0x7F)
+	for value >>= 7; value > 0 && i > 0; value >>= 7 {
+		i--
+		encoded[i] = byte(value&0x7F) | 0x80
+	}
+
+	buffer.Write(encoded[i:])
+}
+
+// MUSToMIDI converts MUS lump data into a format 0 standard MIDI file.
+func MUSToMIDI(mus []byte) ([]byte, error) {
+	if len(mus) < 16 || string(mus[0:4]) != "MUS\x1a" {
+		return nil, errors.New("invalid MUS header")
+	}
+
+	scoreLen := int(binary.LittleEndian.Uint16(mus[4:]))
+	scoreStart := int(binary.LittleEndian.Uint16(mus[6:]))
+	if scoreStart > len(mus) {
+		return nil, errors.New("MUS score out of range")
+	}
+
+	// Some MUS lumps have a wrong score length, so use whatever is
+	// there.
+	score := mus[scoreStart:]
+	if scoreLen < len(score) {
+		score = score[:scoreLen]
+	}
+
+	var track bytes.Buffer
+
+	// 70 ticks per quarter note at 120 BPM is 140 ticks per second,
+	// the same as MUS.
+	track.Write([]byte{0x00, 0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20})
+
+	var velocities [16]byte
+	for i := range velocities {
+		velocities[i] = 127
+	}
+
+	delay := 0
+	for pos := 0; ; {
+		if pos >= len(score) {
+			return nil, errors.New("MUS score is missing score end")
+		}
+
+		descriptor := score[pos]
+		pos++
+		kind := int(descriptor>>4) & 7
+		musChannel := int(descriptor & 0xF)
+
+		channel := musChannel
+		if musChannel == musPercussion {
+			channel = 9
+		} else if musChannel >= 9 {
+			channel = musChannel + 1
+		}
+
+		if pos+musEventSizes[kind] > len(score) {
+			return nil, errors.New("truncated MUS event")
+		}
+
+		var event []byte
+		switch kind {
+		case musReleaseNote:
+			event = []byte{0x80 | byte(channel), score[pos] & 0x7F, 0}
+			pos++
+		case musPlayNote:
+			note := score[pos]
+			pos++
+			if note&0x80 != 0 {
+				if pos >= len(score) {
+					return nil, errors.New("truncated MUS event")
+				}
+				velocities[musChannel] = score[pos] & 0x7F
+				pos++
+			}
+			event = []byte{0x90 | byte(channel), note & 0x7F, velocities[musChannel]}
+		case musPitchBend:
+			bend := int(score[pos]) * 64
+			pos++
+			event = []byte{0xE0 | byte(channel), byte(bend & 0x7F), byte(bend >> 7)}
+		case musSystemEvent:
+			number := int(score[pos])
+			pos++
+			if number < 10 || number > 14 {
+				return nil, fmt.Errorf("unknown MUS system event %d", number)
+			}
+			event = []byte{0xB0 | byte(channel), musSystemEvents[number-10], 0}
+			if number == 12 {
+				// Mono mode takes the number of channels
+				event[2] = 1
+			}
+		case musController:
+			number, value := int(score[pos]), score[pos+1]&0x7F
+			pos += 2
+			if number == 0 {
+				event = []byte{0xC0 | byte(channel), value}
+			} else if number < len(musControllers) {
+				event = []byte{0xB0 | byte(channel), musControllers[number], value}
+			} else {
+				return nil, fmt.Errorf("unknown MUS controller %d", number)
+			}
+		case musMeasureEnd:
+		case musScoreEnd:
+			writeVarLen(&track, delay)
+			track.Write([]byte{0xFF, 0x2F, 0x00})
+
+			var midi bytes.Buffer
+			midi.WriteString("MThd")
+			binary.Write(&midi, binary.BigEndian, []uint32{6})
+			binary.Write(&midi, binary.BigEndian, []uint16{0, 1, 70})
+			midi.WriteString("MTrk")
+			binary.Write(&midi, binary.BigEndian, uint32(track.Len()))
+			midi.Write(track.Bytes())
+			return midi.Bytes(), nil
+		default:
+			return nil, fmt.Errorf("unknown MUS event type %d", kind)
+		}
+
+		if event != nil {
+			writeVarLen(&track, delay)
+			track.Write(event)
+			delay = 0
+		}
+
+		if descriptor&0x80 != 0 {
+			value, n, err := readVarLen(score[pos:])
+			if err != nil {
+				return nil, err
+			}
+			delay += value
+			pos += n
+		}
+	}
+}
+
+// midiEvent is a channel event read from a MIDI file, along with its
+// position in time.
+type midiEvent struct {
+	tick   int
+	track  int
+	order  int
+	tempo  int
+	status byte
+	data   []byte
+}
+
+// readMIDIEvents reads every channel and tempo event from every track
+// of a standard MIDI file, along with the tick the file ends on.
+func readMIDIEvents(midi []byte) ([]midiEvent, int, int, error) {
+	if len(midi) < 14 || string(midi[0:4]) != "MThd" {
+		return nil, 0, 0, errors.New("invalid MIDI header")
+	}
+
+	headerLen := int(binary.BigEndian.Uint32(midi[4:]))
+	if headerLen < 6 || 8+headerLen > len(midi) {
+		return nil, 0, 0, errors.New("invalid MIDI header length")
+	}
+
+	format := binary.BigEndian.Uint16(midi[8:])
+	tracks := int(binary.BigEndian.Uint16(midi[10:]))
+	division := int(binary.BigEndian.Uint16(midi[12:]))
+	if format > 1 {
+		return nil, 0, 0, fmt.Errorf("unsupported MIDI format %d", format)
+	} else if division&0x8000 != 0 {
+		return nil, 0, 0, errors.New("SMPTE MIDI timing is not supported")
+	} else if division == 0 {
+		return nil, 0, 0, errors.New("invalid MIDI division")
+	}
+
+	events := []midiEvent{}
+	endTick := 0
+	order := 0
+
+	pos := 8 + headerLen
+	for track := 0; track < tracks; track++ {
+		if pos+8 > len(midi) {
+			return nil, 0, 0, errors.New("truncated MIDI track")
+		}
+
+		trackLen := int(binary.BigEndian.Uint32(midi[pos+4:]))
+		if string(midi[pos:pos+4]) != "MTrk" || pos+8+trackLen > len(midi) {
+			return nil, 0, 0, errors.New("invalid MIDI track")
+		}
+		data := midi[pos+8 : pos+8+trackLen]
+		pos += 8 + trackLen
+
+		tick := 0
+		var status byte
+		for i := 0; i < len(data); {
+			delta, n, err := readVarLen(data[i:])
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			tick += delta
+			i += n
+
+			if i >= len(data) {
+				return nil, 0, 0, errors.New("truncated MIDI event")
+			}
+
+			// Running status reuses the last status byte.
+			if data[i]&0x80 != 0 {
+				status = data[i]
+				i++
+			} else if status == 0 {
+				return nil, 0, 0, errors.New("MIDI running status without status")
+			}
+
+			switch {
+			case status == 0xFF:
+				if i >= len(data) {
+					return nil, 0, 0, errors.New("truncated MIDI meta event")
+				}
+				kind := data[i]
+				length, n, err := readVarLen(data[i+1:])
+				if err != nil {
+					return nil, 0, 0, err
+				}
+				i += 1 + n
+				if i+length > len(data) {
+					return nil, 0, 0, errors.New("truncated MIDI meta event")
+				}
+
+				if kind == 0x51 && length == 3 {
+					tempo := int(data[i])<<16 | int(data[i+1])<<8 | int(data[i+2])
+					events = append(events, midiEvent{tick: tick, track: track, order: order, tempo: tempo})
+					order++
+				}
+				i += length
+				status = 0
+			case status == 0xF0 || status == 0xF7:
+				length, n, err := readVarLen(data[i:])
+				if err != nil {
+					return nil, 0, 0, err
+				}
+				i += n + length
+				status = 0
+			default:
+				size := 2
+				if status&0xF0 == 0xC0 || status&0xF0 == 0xD0 {
+					size = 1
+				}
+				if i+size > len(data) {
+					return nil, 0, 0, errors.New("truncated MIDI event")
+				}
+
+				events = append(events, midiEvent{
+					tick: tick, track: track, order: order, status: status,
+					data: data[i : i+size],
+				})
+				order++
+				i += size
+			}
+		}
+
+		if tick > endTick {
+			endTick = tick
+		}
+	}
+
+	// Merge tracks by time, keeping file order for simultaneous events.
+	sort.SliceStable(events, func(a, b int) bool {
+		return events[a].tick < events[b].tick
+	})
+
+	return events, endTick, division, nil
+}
+
+// musEvent is a MUS event waiting to be written, along with its MUS
+// tick.
+type musEvent struct {
+	tick int
+	data []byte
+}
+
+// MIDIToMUS converts a format 0 or 1 standard MIDI file into MUS lump
+// data.  MIDI channels are remapped so that only percussion lands on
+// the MUS percussion channel, and the tempo map is flattened into MUS'
+// fixed 140 ticks per second.  The result can exceed MUSSizeLimit,
+// which callers should warn about.
+func MIDIToMUS(midi []byte) ([]byte, error) {
+	events, endTick, division, err := readMIDIEvents(midi)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map MIDI channels to MUS channels in order of first use.
+	var channels [16]int
+	for i := range channels {
+		channels[i] = -1
+	}
+	channels[9] = musPercussion
+	nextChannel := 0
+
+	var velocities [16]int
+	for i := range velocities {
+		velocities[i] = -1
+	}
+
+	instruments := []uint16{}
+	seenInstruments := map[uint16]bool{}
+	addInstrument := func(instrument uint16) {
+		if !seenInstruments[instrument] {
+			seenInstruments[instrument] = true
+			instruments = append(instruments, instrument)
+		}
+	}
+	var programs [16]int
+
+	// Convert MIDI ticks to seconds using the tempo map.
+	tempo := 500000
+	lastTick := 0
+	seconds := 0.0
+	toMUSTick := func(tick int) int {
+		seconds += float64(tick-lastTick) * float64(tempo) / 1000000 / float64(division)
+		lastTick = tick
+		return int(math.Floor(seconds*musRate + 0.5))
+	}
+
+	scoreEvents := []musEvent{}
+	for _, event := range events {
+		tick := toMUSTick(event.tick)
+		if event.status == 0 {
+			tempo = event.tempo
+			continue
+		}
+
+		midiChannel := int(event.status & 0xF)
+		if channels[midiChannel] == -1 {
+			channels[midiChannel] = nextChannel
+			nextChannel++
+		}
+		channel := byte(channels[midiChannel])
+
+		var data []byte
+		switch event.status & 0xF0 {
+		case 0x80:
+			data = []byte{musReleaseNote<<4 | channel, event.data[0] & 0x7F}
+		case 0x90:
+			note, velocity := event.data[0]&0x7F, int(event.data[1]&0x7F)
+			if velocity == 0 {
+				data = []byte{musReleaseNote<<4 | channel, note}
+			} else if velocity != velocities[channel] {
+				velocities[channel] = velocity
+				data = []byte{musPlayNote<<4 | channel, note | 0x80, byte(velocity)}
+			} else {
+				data = []byte{musPlayNote<<4 | channel, note}
+			}
+
+			if channel == musPercussion {
+				addInstrument(uint16(note) + 100)
+			} else {
+				addInstrument(uint16(programs[midiChannel]))
+			}
+		case 0xB0:
+			controller, value := event.data[0]&0x7F, event.data[1]&0x7F
+			for number, system := range musSystemEvents {
+				if controller == system {
+					data = []byte{musSystemEvent<<4 | channel, byte(number + 10)}
+				}
+			}
+			for number := 1; number < len(musControllers); number++ {
+				if controller == musControllers[number] {
+					data = []byte{musController<<4 | channel, byte(number), value}
+				}
+			}
+		case 0xC0:
+			programs[midiChannel] = int(event.data[0] & 0x7F)
+			data = []byte{musController<<4 | channel, 0, event.data[0] & 0x7F}
+		case 0xE0:
+			bend := int(event.data[0]&0x7F) | int(event.data[1]&0x7F)<<7
+			data = []byte{musPitchBend<<4 | channel, byte(bend >> 6)}
+		}
+
+		if data != nil {
+			scoreEvents = append(scoreEvents, musEvent{tick: tick, data: data})
+		}
+	}
+
+	// Score end goes at the end of the longest track.
+	endMUSTick := toMUSTick(endTick)
+	scoreEvents = append(scoreEvents, musEvent{tick: endMUSTick, data: []byte{musScoreEnd << 4}})
+
+	// Delays follow the event before them, which is flagged as having
+	// one.
+	var score bytes.Buffer
+	for i, event := range scoreEvents {
+		data := append([]byte{}, event.data...)
+		delay := 0
+		if i+1 < len(scoreEvents) {
+			delay = scoreEvents[i+1].tick - event.tick
+		}
+
+		if delay > 0 {
+			data[0] |= 0x80
+		}
+		score.Write(data)
+		if delay > 0 {
+			writeVarLen(&score, delay)
+		}
+	}
+
+	scoreStart := 16 + len(instruments)*2
+	if score.Len() > 65535 || scoreStart > 65535 {
+		return nil, errors.New("MUS score is too long")
+	}
+
+	var mus bytes.Buffer
+	mus.WriteString("MUS\x1a")
+	binary.Write(&mus, binary.LittleEndian, []uint16{
+		uint16(score.Len()), uint16(scoreStart), uint16(nextChannel), 0,
+		uint16(len(instruments)), 0,
+	})
+	binary.Write(&mus, binary.LittleEndian, instruments)
+	mus.Write(score.Bytes())
+
+	return mus.Bytes(), nil
+}
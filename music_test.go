@@ -0,0 +1,135 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// Builds a format 1 MIDI file from raw track data.
+func testMIDI(division uint16, tracks ...[]byte) []byte {
+	var midi bytes.Buffer
+	midi.WriteString("MThd")
+	binary.Write(&midi, binary.BigEndian, uint32(6))
+	binary.Write(&midi, binary.BigEndian, []uint16{1, uint16(len(tracks)), division})
+	for _, track := range tracks {
+		midi.WriteString("MTrk")
+		binary.Write(&midi, binary.BigEndian, uint32(len(track)))
+		midi.Write(track)
+	}
+
+	return midi.Bytes()
+}
+
+func TestMIDIToMUS(t *testing.T) {
+	midi := testMIDI(96,
+		// Tempo of 250000 microseconds per quarter note
+		[]byte{0x00, 0xFF, 0x51, 0x03, 0x03, 0xD0, 0x90, 0x00, 0xFF, 0x2F, 0x00},
+		[]byte{
+			// Program change on channel 15, then a note on it
+			0x00, 0xCF, 0x1E,
+			0x00, 0x9F, 0x3C, 0x64,
+			// Percussion note, using running status for release
+			0x00, 0x99, 0x24, 0x64,
+			0x60, 0x24, 0x00,
+			// Quarter note later, release the channel 15 note
+			0x00, 0x8F, 0x3C, 0x40,
+			0x00, 0xFF, 0x2F, 0x00,
+		})
+
+	mus, err := MIDIToMUS(midi)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(mus[0:4]) != "MUS\x1a" {
+		t.Fatal("incorrect MUS header")
+	}
+
+	// One primary channel, and instrument 30 plus percussion note 36
+	header := make([]uint16, 8)
+	binary.Read(bytes.NewReader(mus[4:]), binary.LittleEndian, header)
+	if header[2] != 1 || header[4] != 2 || header[6] != 30 || header[7] != 136 {
+		t.Errorf("incorrect MUS header %v", header)
+	}
+
+	expected := []byte{
+		// Channel 15 became channel 0
+		0x40, 0x00, 0x1E,
+		0x10, 0xBC, 0x64,
+		// Percussion is channel 15, with a quarter note delay at
+		// double tempo
+		0x9F, 0xA4, 0x64, 35,
+		0x0F, 0x24,
+		0x00, 0x3C,
+		0x60,
+	}
+	if !bytes.Equal(mus[int(header[1]):], expected) {
+		t.Errorf("incorrect MUS score %v", mus[header[1]:])
+	}
+}
+
+func TestMUSToMIDI(t *testing.T) {
+	mus := []byte{
+		'M', 'U', 'S', 0x1a,
+		// Score length and start
+		9, 0, 16, 0,
+		// Channels, secondary channels, instruments, reserved
+		1, 0, 0, 0, 0, 0, 0, 0,
+		// Play note 60 at volume 100 on channel 15, delay 140
+		0x9F, 0xBC, 0x64, 0x81, 0x0C,
+		// Release note
+		0x0F, 0x3C,
+		// Score end
+		0x60,
+		// Padding past the end of the score
+		0x00,
+	}
+
+	midi, err := MUSToMIDI(mus)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []byte{
+		'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0, 70,
+		'M', 'T', 'r', 'k', 0, 0, 0, 20,
+		0x00, 0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20,
+		// Percussion is channel 9
+		0x00, 0x99, 0x3C, 0x64,
+		0x81, 0x0C, 0x89, 0x3C, 0x00,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+	if !bytes.Equal(midi, expected) {
+		t.Errorf("incorrect MIDI data %v", midi)
+	}
+
+	// Converting back gives the original score
+	back, err := MIDIToMUS(midi)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !bytes.Equal(back[18:], mus[16:24]) {
+		t.Errorf("incorrect converted MUS score %v", back[18:])
+	}
+}
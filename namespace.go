@@ -0,0 +1,173 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"strings"
+)
+
+// Namespace is a section of a WAD delimited by marker lumps, which
+// corresponds to a directory in a ZIP.
+type Namespace struct {
+	// Name of the directory holding the namespace in a ZIP.
+	Name string
+
+	// Prefixes of the _START and _END markers of the namespace.  The
+	// first is used when writing markers.
+	Markers []string
+}
+
+// Namespaces holds every namespace WADs may contain.
+var Namespaces = []Namespace{
+	{"sprites", []string{"S", "SS"}},
+	{"flats", []string{"F", "FF"}},
+	{"patches", []string{"P", "PP"}},
+	{"textures", []string{"TX"}},
+	{"colormaps", []string{"C"}},
+	{"acs", []string{"A"}},
+	{"hires", []string{"HI"}},
+	{"voices", []string{"V"}},
+	{"voxels", []string{"VX"}},
+}
+
+// Returns the namespace a marker lump starts or ends, and whether it
+// starts it.
+func lookupMarker(name string) (*Namespace, bool, bool) {
+	var prefix string
+	var start bool
+	if strings.HasSuffix(name, "_START") {
+		prefix, start = strings.TrimSuffix(name, "_START"), true
+	} else if strings.HasSuffix(name, "_END") {
+		prefix = strings.TrimSuffix(name, "_END")
+	} else {
+		return nil, false, false
+	}
+
+	for i := range Namespaces {
+		for _, marker := range Namespaces[i].Markers {
+			if prefix == marker {
+				return &Namespaces[i], start, true
+			}
+		}
+	}
+
+	return nil, false, false
+}
+
+// IsMarker returns true if the passed lump name is a marker, which
+// holds no data and only delimits other lumps.
+func IsMarker(name string) bool {
+	return strings.HasSuffix(name, "_START") || strings.HasSuffix(name, "_END")
+}
+
+// LumpNamespaces returns the name of the namespace every lump of a
+// directory is in, or an empty string for lumps outside of any.  The
+// markers of a namespace are outside of it, but markers nested inside
+// a namespace such as P1_START are in it.  A namespace without an end
+// marker lasts until the end of the directory.
+func LumpNamespaces(dir Directory) []string {
+	names := make([]string, len(dir))
+
+	var current *Namespace
+	for i, lump := range dir {
+		namespace, start, ok := lookupMarker(lump.Name)
+		if ok && current == nil && start {
+			current = namespace
+			continue
+		} else if ok && current == namespace && !start {
+			current = nil
+			continue
+		}
+
+		if current != nil {
+			names[i] = current.Name
+		}
+	}
+
+	return names
+}
+
+// mapLumpNames holds the names of the lumps that follow the header
+// lump of a map.
+var mapLumpNames = map[string]bool{
+	"THINGS":   true,
+	"LINEDEFS": true,
+	"SIDEDEFS": true,
+	"VERTEXES": true,
+	"SEGS":     true,
+	"SSECTORS": true,
+	"NODES":    true,
+	"SECTORS":  true,
+	"REJECT":   true,
+	"BLOCKMAP": true,
+	"BEHAVIOR": true,
+	"SCRIPTS":  true,
+	"LEAFS":    true,
+	"LIGHTS":   true,
+	"MACROS":   true,
+	"TEXTMAP":  true,
+	"ZNODES":   true,
+	"DIALOGUE": true,
+	"ENDMAP":   true,
+}
+
+// IsMapLump returns true if the passed lump name is one of the lumps
+// that make up a map, other than its header.  GL nodes are map lumps,
+// including the header named after the map that they start with.
+func IsMapLump(name string) bool {
+	return mapLumpNames[name] || strings.HasPrefix(name, "GL_")
+}
+
+// WadMap is a map found in a directory, made of a header lump naming
+// the map and the map lumps that follow it.
+type WadMap struct {
+	Name string
+
+	// Index of the header lump in the directory, and the number of
+	// lumps in the map including the header.
+	Index int
+	Count int
+}
+
+// FindMaps returns every map in a directory in order.  A map starts at
+// any lump other than a map lump followed by THINGS, or TEXTMAP for UDMF maps, and lasts as
+// long as map lumps follow or until ENDMAP.
+func FindMaps(dir Directory) []WadMap {
+	maps := []WadMap{}
+	for i := 0; i+1 < len(dir); i++ {
+		next := dir[i+1].Name
+		if next != "THINGS" && next != "TEXTMAP" || IsMapLump(dir[i].Name) {
+			continue
+		}
+
+		count := 1
+		for i+count < len(dir) && IsMapLump(dir[i+count].Name) {
+			count++
+			if dir[i+count-1].Name == "ENDMAP" {
+				break
+			}
+		}
+
+		maps = append(maps, WadMap{Name: dir[i].Name, Index: i, Count: count})
+		i += count - 1
+	}
+
+	return maps
+}
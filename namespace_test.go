@@ -0,0 +1,65 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Returns a directory of empty lumps with the passed names.
+func namedDirectory(names ...string) Directory {
+	dir := Directory{}
+	for _, name := range names {
+		dir = append(dir, Lump{Name: name})
+	}
+
+	return dir
+}
+
+// Lumps between markers are in a namespace, but the markers are not
+func TestLumpNamespaces(t *testing.T) {
+	dir := namedDirectory("PLAYPAL", "S_START", "TROOA1", "S_END",
+		"FF_START", "F1_START", "FLOOR0_1", "F1_END", "F_END",
+		"P_END", "TX_START", "BRICK")
+
+	expected := []string{"", "", "sprites", "",
+		"", "flats", "flats", "flats", "",
+		"", "", "textures"}
+	if namespaces := LumpNamespaces(dir); !reflect.DeepEqual(namespaces, expected) {
+		t.Errorf("incorrect namespaces %q", namespaces)
+	}
+}
+
+// Maps are found by the lumps following their header
+func TestFindMaps(t *testing.T) {
+	dir := namedDirectory("MAP01", "THINGS", "LINEDEFS", "SIDEDEFS",
+		"GL_MAP01", "GL_VERT", "DEHACKED",
+		"E1M1", "TEXTMAP", "ZNODES", "ENDMAP", "THINGS",
+		"THINGS", "MAP02")
+
+	expected := []WadMap{
+		{Name: "MAP01", Index: 0, Count: 6},
+		{Name: "E1M1", Index: 7, Count: 4},
+	}
+	if maps := FindMaps(dir); !reflect.DeepEqual(maps, expected) {
+		t.Errorf("incorrect maps %v", maps)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// PCSpeakerRate is the number of tones played per second by a PC speaker
+// sound.
+const PCSpeakerRate = 140
+
+// pcSpeakerClock is the frequency of the PIT timer driving the speaker.
+const pcSpeakerClock = 1193181
+
+// pcSpeakerDivisors are the PIT timer divisors for each tone, spaced a
+// quarter tone apart.  Tone zero is silence.
+var pcSpeakerDivisors = [128]uint16{
+	0,
+	6818, 6628, 6449, 6279, 6087, 5906, 5736, 5575,
+	5423, 5279, 5120, 4971, 4830, 4697, 4554, 4435,
+	4307, 4186, 4058, 3950, 3836, 3728, 3615, 3519,
+	3418, 3323, 3224, 3131, 3043, 2960, 2875, 2794,
+	2711, 2633, 2560, 2485, 2415, 2348, 2281, 2213,
+	2153, 2089, 2032, 1975, 1918, 1864, 1810, 1757,
+	1709, 1659, 1612, 1565, 1521, 1478, 1435, 1395,
+	1355, 1316, 1280, 1242, 1207, 1173, 1140, 1107,
+	1075, 1045, 1015, 986, 959, 931, 905, 879,
+	854, 829, 806, 783, 760, 739, 718, 697,
+	677, 658, 640, 621, 604, 586, 570, 553,
+	538, 522, 507, 493, 479, 465, 452, 439,
+	427, 415, 403, 391, 380, 369, 359, 348,
+	339, 329, 319, 310, 302, 293, 285, 276,
+	269, 261, 253, 246, 239, 232, 226, 219,
+	213, 207, 201, 195, 190, 184, 179,
+}
+
+// PCSpeakerFrequency returns the frequency in Hz of a tone, or zero if
+// the tone is silence or out of range.
+func PCSpeakerFrequency(tone byte) float64 {
+	if int(tone) >= len(pcSpeakerDivisors) || tone == 0 {
+		return 0
+	}
+
+	return pcSpeakerClock / float64(pcSpeakerDivisors[tone])
+}
+
+// DecodePCSpeaker decodes PC speaker sound lump data into a list of
+// tones.
+func DecodePCSpeaker(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errors.New("PC speaker sound is too short")
+	}
+
+	if binary.LittleEndian.Uint16(data) != 0 {
+		return nil, errors.New("invalid PC speaker sound format")
+	}
+
+	count := int(binary.LittleEndian.Uint16(data[2:]))
+	if 4+count > len(data) {
+		return nil, errors.New("PC speaker sound length out of range")
+	}
+
+	tones := make([]byte, count)
+	copy(tones, data[4:])
+
+	return tones, nil
+}
+
+// EncodePCSpeaker encodes a list of tones into PC speaker sound lump
+// data.
+func EncodePCSpeaker(tones []byte) ([]byte, error) {
+	if len(tones) > 65535 {
+		return nil, errors.New("too many tones")
+	}
+
+	data := make([]byte, 4+len(tones))
+	binary.LittleEndian.PutUint16(data[2:], uint16(len(tones)))
+	for i, tone := range tones {
+		if int(tone) >= len(pcSpeakerDivisors) {
+			return nil, errors.New("tone out of range")
+		}
+		data[4+i] = tone
+	}
+
+	return data, nil
+}
+
+// RenderPCSpeaker renders a list of tones into a sound at the passed
+// sample rate, as the square wave a PC speaker would produce.
+func RenderPCSpeaker(tones []byte, rate int) *Sound {
+	length := len(tones) * rate / PCSpeakerRate
+	sound := &Sound{SampleRate: rate, Samples: make([]float64, length)}
+
+	// Phase carries across tones so that changes in pitch do not click.
+	phase := 0.0
+	for i := range sound.Samples {
+		frequency := PCSpeakerFrequency(tones[i*PCSpeakerRate/rate])
+		if frequency == 0 {
+			continue
+		}
+
+		phase = math.Mod(phase+frequency/float64(rate), 1)
+		if phase < 0.5 {
+			sound.Samples[i] = 0.5
+		} else {
+			sound.Samples[i] = -0.5
+		}
+	}
+
+	return sound
+}
+
+// nearestPCSpeakerTone returns the tone closest in pitch to a frequency.
+func nearestPCSpeakerTone(frequency float64) byte {
+	best := byte(1)
+	bestDistance := math.Inf(1)
+
+	for tone := 1; tone < len(pcSpeakerDivisors); tone++ {
+		distance := math.Abs(math.Log(PCSpeakerFrequency(byte(tone)) / frequency))
+		if distance < bestDistance {
+			best = byte(tone)
+			bestDistance = distance
+		}
+	}
+
+	return best
+}
+
+// SoundToPCSpeaker approximates a sound as a list of PC speaker tones.
+// The dominant pitch of every tone-length slice of the sound is found
+// by autocorrelation, and quiet slices become silence.
+func SoundToPCSpeaker(sound *Sound) []byte {
+	if sound.SampleRate <= 0 {
+		return []byte{}
+	}
+
+	count := len(sound.Samples) * PCSpeakerRate / sound.SampleRate
+	tones := make([]byte, count)
+
+	// Pitch can only be found within the range of the speaker, and no
+	// higher than the sample rate allows.
+	minLag := int(float64(sound.SampleRate) / PCSpeakerFrequency(127))
+	if minLag < 2 {
+		minLag = 2
+	}
+	maxLag := int(math.Ceil(float64(sound.SampleRate) / PCSpeakerFrequency(1)))
+
+	// Loudness of each slice, to tell what is quiet relative to the
+	// rest of the sound.
+	loudness := make([]float64, count)
+	loudest := 0.0
+	for i := range tones {
+		start := i * sound.SampleRate / PCSpeakerRate
+		end := (i + 1) * sound.SampleRate / PCSpeakerRate
+
+		var sum float64
+		for _, sample := range sound.Samples[start:end] {
+			sum += sample * sample
+		}
+		loudness[i] = math.Sqrt(sum / float64(end-start))
+		loudest = math.Max(loudest, loudness[i])
+	}
+
+	for i := range tones {
+		if loudness[i] < 0.02 || loudness[i] < loudest*0.1 {
+			continue
+		}
+
+		// Analyze a window long enough to hold two periods of the
+		// lowest tone, starting at the slice.
+		start := i * sound.SampleRate / PCSpeakerRate
+		end := start + maxLag*2
+		if end > len(sound.Samples) {
+			end = len(sound.Samples)
+		}
+		window := sound.Samples[start:end]
+
+		scores := make([]float64, maxLag+2)
+		bestScore := 0.0
+		for lag := minLag; lag <= maxLag && lag < len(window); lag++ {
+			var sum, energy float64
+			for j := 0; j+lag < len(window); j++ {
+				sum += window[j] * window[j+lag]
+				energy += window[j]*window[j] + window[j+lag]*window[j+lag]
+			}
+			if energy != 0 {
+				scores[lag] = 2 * sum / energy
+			}
+			bestScore = math.Max(bestScore, scores[lag])
+		}
+
+		// Multiples of the period correlate nearly as well as the
+		// period itself, so take the first peak close to the best.
+		bestLag := 0
+		for lag := minLag; lag <= maxLag; lag++ {
+			if bestScore > 0 && scores[lag] >= bestScore*0.9 && scores[lag] >= scores[lag+1] {
+				bestLag = lag
+				break
+			}
+		}
+
+		if bestLag == 0 {
+			continue
+		}
+		tones[i] = nearestPCSpeakerTone(float64(sound.SampleRate) / float64(bestLag))
+	}
+
+	return tones
+}
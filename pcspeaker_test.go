@@ -0,0 +1,95 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestPCSpeaker(t *testing.T) {
+	tones := []byte{0, 1, 64, 127}
+
+	data, err := EncodePCSpeaker(tones)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []byte{0, 0, 4, 0, 0, 1, 64, 127}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("incorrect PC speaker data %v", data)
+	}
+
+	decoded, err := DecodePCSpeaker(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !bytes.Equal(decoded, tones) {
+		t.Errorf("incorrect decoded tones %v", decoded)
+	}
+
+	_, err = EncodePCSpeaker([]byte{128})
+	if err == nil {
+		t.Error("out of range tone was encoded")
+	}
+}
+
+func TestRenderPCSpeaker(t *testing.T) {
+	sound := RenderPCSpeaker([]byte{0, 64}, 14000)
+
+	if len(sound.Samples) != 200 {
+		t.Fatalf("incorrect sample count %d", len(sound.Samples))
+	}
+
+	for _, sample := range sound.Samples[:100] {
+		if sample != 0 {
+			t.Fatal("silence is not silent")
+		}
+	}
+
+	if sound.Samples[100] == 0 {
+		t.Error("tone is silent")
+	}
+}
+
+func TestSoundToPCSpeaker(t *testing.T) {
+	// A tenth of a second of silence followed by a tenth of a second
+	// of 440 Hz.
+	sound := &Sound{SampleRate: 11025, Samples: make([]float64, 2205)}
+	for i := 1103; i < len(sound.Samples); i++ {
+		sound.Samples[i] = 0.5 * math.Sin(float64(i)*2*math.Pi*440/11025)
+	}
+
+	tones := SoundToPCSpeaker(sound)
+	if len(tones) != 28 {
+		t.Fatalf("incorrect tone count %d", len(tones))
+	}
+
+	if tones[5] != 0 {
+		t.Errorf("silence became tone %d", tones[5])
+	}
+
+	frequency := PCSpeakerFrequency(tones[20])
+	if math.Abs(frequency-440) > 440*0.03 {
+		t.Errorf("440 Hz became %f Hz", frequency)
+	}
+}
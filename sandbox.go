@@ -0,0 +1,172 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wadmake
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Sandbox restricts the files a Lua environment may access.  Files may
+// only be read from the project root or an output directory, and may
+// only be written to an output directory.
+type Sandbox struct {
+	// Root is the project root, with symbolic links resolved.
+	Root string
+
+	// Outputs are the directories files may be written to, with
+	// symbolic links resolved.
+	Outputs []string
+
+	// Execute allows running commands with os.execute.
+	Execute bool
+}
+
+// SandboxError is the error returned for a file the sandbox does not
+// allow access to.
+type SandboxError struct {
+	Name   string
+	Reason string
+}
+
+func (err *SandboxError) Error() string {
+	return err.Name + " " + err.Reason
+}
+
+// NewSandbox creates a sandbox confined to the passed project root,
+// which must exist.  Relative output directories are relative to the
+// root, and need not exist yet.
+func NewSandbox(root string, outputs []string, execute bool) (*Sandbox, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	sandbox := &Sandbox{Root: resolved, Execute: execute}
+	for _, output := range outputs {
+		if !filepath.IsAbs(output) {
+			output = filepath.Join(abs, output)
+		}
+
+		dir, err := resolvePath(output)
+		if err != nil {
+			return nil, err
+		}
+		sandbox.Outputs = append(sandbox.Outputs, dir)
+	}
+
+	return sandbox, nil
+}
+
+// resolvePath returns the absolute path of a file with every symbolic
+// link resolved.  Parts of the path that do not exist yet are kept as
+// they are, so that files about to be created can be resolved.
+func resolvePath(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+
+	dir, rest := abs, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs, nil
+		}
+
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+// inDirectory returns true if the passed path is the directory or is
+// located somewhere inside of it.
+func inDirectory(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// inOutput returns true if the passed path is located in an output
+// directory.
+func (sandbox *Sandbox) inOutput(path string) bool {
+	for _, dir := range sandbox.Outputs {
+		if inDirectory(path, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckRead returns the resolved path of a file that may be read, or
+// an error if the file is outside of the project root and every output
+// directory.  The resolved path should be used to access the file, as
+// it is the one that was checked.
+func (sandbox *Sandbox) CheckRead(name string) (string, error) {
+	path, err := resolvePath(name)
+	if err != nil {
+		return "", err
+	}
+
+	if !inDirectory(path, sandbox.Root) && !sandbox.inOutput(path) {
+		return "", &SandboxError{name, "is outside of the project root"}
+	}
+
+	return path, nil
+}
+
+// CheckWrite returns the resolved path of a file that may be written,
+// or an error if the file is not in an output directory.
+func (sandbox *Sandbox) CheckWrite(name string) (string, error) {
+	path, err := resolvePath(name)
+	if err != nil {
+		return "", err
+	}
+
+	if !sandbox.inOutput(path) {
+		return "", &SandboxError{name, "is not in an output directory"}
+	}
+
+	return path, nil
+}
+
+// ReadFile reads a file after checking that it may be read.
+func (sandbox *Sandbox) ReadFile(name string) ([]byte, error) {
+	path, err := sandbox.CheckRead(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(path)
+}
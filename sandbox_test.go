@@ -0,0 +1,141 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package wadmake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Creates a project root holding an output directory, a file and a
+// link to a file outside of the root.  Returns the directory holding
+// the root and the sandbox confined to it.
+func sandboxTestRoot(t *testing.T) (string, *Sandbox) {
+	dir, err := ioutil.TempDir("", "wadmake")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	root := filepath.Join(dir, "root")
+	for _, sub := range []string{"build", "src"} {
+		err = os.MkdirAll(filepath.Join(root, sub), 0777)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	files := map[string]string{
+		filepath.Join(root, "src", "map.wad"): "PWAD",
+		filepath.Join(dir, "secret"):          "secret",
+	}
+	for name, data := range files {
+		err = ioutil.WriteFile(name, []byte(data), 0666)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	err = os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "src", "link"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = os.Symlink(dir, filepath.Join(root, "src", "up"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sandbox, err := NewSandbox(root, []string{"build"}, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return dir, sandbox
+}
+
+// Files may be read from the root and written to output directories
+func TestSandbox(t *testing.T) {
+	dir, sandbox := sandboxTestRoot(t)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+
+	reads := []struct {
+		name string
+		ok   bool
+	}{
+		{filepath.Join(root, "src", "map.wad"), true},
+		{filepath.Join(root, "src", "missing.wad"), true},
+		{filepath.Join(root, "build", "out.wad"), true},
+		{filepath.Join(root, "src", "..", "..", "secret"), false},
+		{filepath.Join(root, "src", "link"), false},
+		{filepath.Join(root, "src", "up", "secret"), false},
+		{filepath.Join(root, "src", "missing", "..", "..", "..", "secret"), false},
+		{filepath.Join(dir, "rootless"), false},
+		{"/", false},
+	}
+	for _, read := range reads {
+		_, err := sandbox.CheckRead(read.name)
+		if read.ok && err != nil {
+			t.Errorf("could not read %s: %s", read.name, err.Error())
+		} else if !read.ok && err == nil {
+			t.Errorf("read %s outside of the root", read.name)
+		}
+	}
+
+	writes := []struct {
+		name string
+		ok   bool
+	}{
+		{filepath.Join(root, "build", "out.wad"), true},
+		{filepath.Join(root, "build", "maps", "MAP01.wad"), true},
+		{filepath.Join(root, "src", "map.wad"), false},
+		{filepath.Join(root, "build", "..", "src", "map.wad"), false},
+		{filepath.Join(root, "buildx", "out.wad"), false},
+		{filepath.Join(dir, "secret"), false},
+	}
+	for _, write := range writes {
+		_, err := sandbox.CheckWrite(write.name)
+		if write.ok && err != nil {
+			t.Errorf("could not write %s: %s", write.name, err.Error())
+		} else if !write.ok && err == nil {
+			t.Errorf("wrote %s outside of an output directory", write.name)
+		}
+	}
+
+	data, err := sandbox.ReadFile(filepath.Join(root, "src", "map.wad"))
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if string(data) != "PWAD" {
+		t.Errorf("incorrect data %q", data)
+	}
+
+	_, err = sandbox.ReadFile(filepath.Join(root, "src", "link"))
+	if err == nil {
+		t.Error("read a link to a file outside of the root")
+	}
+}
+
+// The root must exist
+func TestNewSandboxMissingRoot(t *testing.T) {
+	_, err := NewSandbox(filepath.Join(os.TempDir(), "wadmake-missing-root"), nil, false)
+	if err == nil {
+		t.Error("created a sandbox for a missing root")
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenType designates the kind of a token read by textScanner.
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+// token is a single token read by textScanner.
+type token struct {
+	Type tokenType
+	Text string
+	Line int
+}
+
+// textScanner splits ZDoom-style text lumps such as TEXTURES and
+// MAPINFO into tokens.  Both // and /* */ comments are skipped, as
+// are ; comments if semicolonComments is set.
+type textScanner struct {
+	name   string
+	src    string
+	pos    int
+	line   int
+	peeked *token
+
+	semicolonComments bool
+}
+
+// newTextScanner creates a scanner over the passed source.  The name is
+// used as the file name in error messages.
+func newTextScanner(name string, src string) *textScanner {
+	return &textScanner{name: name, src: src, line: 1}
+}
+
+// errorf returns an error prefixed with the file name and passed line.
+func (s *textScanner) errorf(line int, format string, a ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", s.name, line, fmt.Sprintf(format, a...))
+}
+
+// skipSpace skips over whitespace and comments.
+func (s *textScanner) skipSpace() error {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\n':
+			s.line++
+			s.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			s.pos++
+		case strings.HasPrefix(s.src[s.pos:], "//") || (c == ';' && s.semicolonComments):
+			end := strings.IndexByte(s.src[s.pos:], '\n')
+			if end == -1 {
+				s.pos = len(s.src)
+			} else {
+				s.pos += end
+			}
+		case strings.HasPrefix(s.src[s.pos:], "/*"):
+			end := strings.Index(s.src[s.pos+2:], "*/")
+			if end == -1 {
+				return s.errorf(s.line, "unterminated comment")
+			}
+			s.line += strings.Count(s.src[s.pos:s.pos+2+end], "\n")
+			s.pos += end + 4
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// isIdentifier returns true if the passed character can be part of an
+// unquoted identifier.  Lump names may contain a few characters that
+// would otherwise be punctuation.
+func isIdentifier(c byte) bool {
+	return c == '_' || c == '[' || c == ']' || c == '\\' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
+
+// Next returns the next token, or a token of type tokenEOF at the end
+// of the source.
+func (s *textScanner) Next() (token, error) {
+	if s.peeked != nil {
+		tok := *s.peeked
+		s.peeked = nil
+		return tok, nil
+	}
+
+	err := s.skipSpace()
+	if err != nil {
+		return token{}, err
+	}
+
+	if s.pos >= len(s.src) {
+		return token{Type: tokenEOF, Line: s.line}, nil
+	}
+
+	start, line := s.pos, s.line
+	c := s.src[s.pos]
+	switch {
+	case c == '"':
+		var value strings.Builder
+		s.pos++
+		for {
+			if s.pos >= len(s.src) {
+				return token{}, s.errorf(s.line, "unterminated string")
+			}
+
+			c = s.src[s.pos]
+			s.pos++
+			if c == '"' {
+				break
+			} else if c == '\\' && s.pos < len(s.src) {
+				c = s.src[s.pos]
+				s.pos++
+				if c == 'n' {
+					c = '\n'
+				}
+			} else if c == '\n' {
+				s.line++
+			}
+			value.WriteByte(c)
+		}
+		return token{Type: tokenString, Text: value.String(), Line: line}, nil
+	case c >= '0' && c <= '9' || c == '.' && s.pos+1 < len(s.src) && s.src[s.pos+1] >= '0' && s.src[s.pos+1] <= '9':
+		for s.pos < len(s.src) && (isIdentifier(s.src[s.pos]) || s.src[s.pos] == '.') {
+			s.pos++
+		}
+
+		// Numbers immediately followed by letters are identifiers,
+		// as lump names may start with a digit.
+		text := s.src[start:s.pos]
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			if _, err := strconv.ParseInt(text, 0, 64); err != nil {
+				return token{Type: tokenIdentifier, Text: text, Line: line}, nil
+			}
+		}
+		return token{Type: tokenNumber, Text: text, Line: line}, nil
+	case isIdentifier(c):
+		for s.pos < len(s.src) && isIdentifier(s.src[s.pos]) {
+			s.pos++
+		}
+		return token{Type: tokenIdentifier, Text: s.src[start:s.pos], Line: line}, nil
+	default:
+		s.pos++
+		return token{Type: tokenPunct, Text: string(c), Line: line}, nil
+	}
+}
+
+// Peek returns the next token without consuming it.
+func (s *textScanner) Peek() (token, error) {
+	if s.peeked != nil {
+		return *s.peeked, nil
+	}
+
+	tok, err := s.Next()
+	if err != nil {
+		return token{}, err
+	}
+	s.peeked = &tok
+
+	return tok, nil
+}
+
+// Accept consumes the next token if it is the passed punctuation or
+// case-insensitive keyword, returning true if it did.
+func (s *textScanner) Accept(text string) (bool, error) {
+	tok, err := s.Peek()
+	if err != nil {
+		return false, err
+	}
+
+	if (tok.Type == tokenPunct || tok.Type == tokenIdentifier) && strings.EqualFold(tok.Text, text) {
+		s.peeked = nil
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Expect consumes the next token, failing if it is not the passed
+// punctuation or case-insensitive keyword.
+func (s *textScanner) Expect(text string) error {
+	ok, err := s.Accept(text)
+	if err != nil {
+		return err
+	} else if !ok {
+		tok, _ := s.Peek()
+		return s.errorf(tok.Line, "expected %q, got %q", text, tok.Text)
+	}
+
+	return nil
+}
+
+// Name consumes the next token as a name, which may be quoted or not.
+func (s *textScanner) Name() (string, error) {
+	tok, err := s.Next()
+	if err != nil {
+		return "", err
+	} else if tok.Type != tokenIdentifier && tok.Type != tokenString && tok.Type != tokenNumber {
+		return "", s.errorf(tok.Line, "expected name, got %q", tok.Text)
+	}
+
+	return tok.Text, nil
+}
+
+// Number consumes the next token as a possibly negative number.
+func (s *textScanner) Number() (float64, error) {
+	negative, err := s.Accept("-")
+	if err != nil {
+		return 0, err
+	}
+
+	tok, err := s.Next()
+	if err != nil {
+		return 0, err
+	} else if tok.Type != tokenNumber {
+		return 0, s.errorf(tok.Line, "expected number, got %q", tok.Text)
+	}
+
+	value, err := strconv.ParseFloat(tok.Text, 64)
+	if err != nil {
+		integer, err := strconv.ParseInt(tok.Text, 0, 64)
+		if err != nil {
+			return 0, s.errorf(tok.Line, "invalid number %q", tok.Text)
+		}
+		value = float64(integer)
+	}
+
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+// Integer consumes the next token as a possibly negative integer.
+func (s *textScanner) Integer() (int, error) {
+	line := s.line
+	value, err := s.Number()
+	if err != nil {
+		return 0, err
+	} else if value != float64(int(value)) {
+		return 0, s.errorf(line, "expected integer, got %g", value)
+	}
+
+	return int(value), nil
+}
@@ -0,0 +1,77 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+)
+
+func TestTextScanner(t *testing.T) {
+	s := newTextScanner("test", "map MAP01 \"Entry\\\"way\" // Comment\n{ 1.5, -3, 0x10, 1ST_LUMP }")
+
+	expected := []token{
+		{Type: tokenIdentifier, Text: "map", Line: 1},
+		{Type: tokenIdentifier, Text: "MAP01", Line: 1},
+		{Type: tokenString, Text: "Entry\"way", Line: 1},
+		{Type: tokenPunct, Text: "{", Line: 2},
+		{Type: tokenNumber, Text: "1.5", Line: 2},
+		{Type: tokenPunct, Text: ",", Line: 2},
+		{Type: tokenPunct, Text: "-", Line: 2},
+		{Type: tokenNumber, Text: "3", Line: 2},
+		{Type: tokenPunct, Text: ",", Line: 2},
+		{Type: tokenNumber, Text: "0x10", Line: 2},
+		{Type: tokenPunct, Text: ",", Line: 2},
+		{Type: tokenIdentifier, Text: "1ST_LUMP", Line: 2},
+		{Type: tokenPunct, Text: "}", Line: 2},
+		{Type: tokenEOF, Line: 2},
+	}
+
+	for i, tok := range expected {
+		actual, err := s.Next()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if actual != tok {
+			t.Fatalf("token %d is %v, expected %v", i, actual, tok)
+		}
+	}
+}
+
+func TestTextScannerNumbers(t *testing.T) {
+	s := newTextScanner("test", "-12, 0x20, 2.5")
+
+	integer, err := s.Integer()
+	if err != nil || integer != -12 {
+		t.Errorf("incorrect integer %d (%v)", integer, err)
+	}
+
+	s.Expect(",")
+	integer, err = s.Integer()
+	if err != nil || integer != 32 {
+		t.Errorf("incorrect hex integer %d (%v)", integer, err)
+	}
+
+	s.Expect(",")
+	_, err = s.Integer()
+	if err == nil {
+		t.Error("fractional number parsed as integer")
+	}
+}
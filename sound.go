@@ -0,0 +1,342 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Sound is a monaural sound with samples normalized between -1 and 1.
+type Sound struct {
+	SampleRate int
+	Samples    []float64
+}
+
+// dmxPadding is the number of padding bytes that DMX places at both
+// the start and end of sample data.  They are never played.
+const dmxPadding = 16
+
+// DecodePCM decodes raw interleaved PCM data, mixing all channels down
+// into a single channel.  8-bit samples are unsigned and wider samples
+// are little-endian and signed.
+func DecodePCM(data []byte, rate int, channels int, bits int) (*Sound, error) {
+	if rate <= 0 {
+		return nil, errors.New("invalid sample rate")
+	} else if channels <= 0 {
+		return nil, errors.New("invalid channel count")
+	}
+
+	width := bits / 8
+	if bits%8 != 0 || width < 1 || width > 4 {
+		return nil, fmt.Errorf("unsupported sample size of %d bits", bits)
+	}
+
+	frames := len(data) / (width * channels)
+	sound := &Sound{SampleRate: rate, Samples: make([]float64, frames)}
+
+	for i := range sound.Samples {
+		var sum float64
+		for c := 0; c < channels; c++ {
+			offset := (i*channels + c) * width
+			sum += decodeSample(data[offset:offset+width], false)
+		}
+		sound.Samples[i] = sum / float64(channels)
+	}
+
+	return sound, nil
+}
+
+// decodeSample decodes a single integer or float sample.
+func decodeSample(sample []byte, float bool) float64 {
+	switch len(sample) {
+	case 1:
+		return (float64(sample[0]) - 128) / 128
+	case 2:
+		return float64(int16(binary.LittleEndian.Uint16(sample))) / 32768
+	case 3:
+		value := int32(sample[0])<<8 | int32(sample[1])<<16 | int32(sample[2])<<24
+		return float64(value) / 2147483648
+	case 4:
+		if float {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(sample)))
+		}
+		return float64(int32(binary.LittleEndian.Uint32(sample))) / 2147483648
+	case 8:
+		return math.Float64frombits(binary.LittleEndian.Uint64(sample))
+	}
+
+	return 0
+}
+
+// clampSample restricts a sample to between -1 and 1.
+func clampSample(sample float64) float64 {
+	return math.Max(-1, math.Min(1, sample))
+}
+
+// toUnsigned8 quantizes a sample into an unsigned 8-bit sample.  This
+// is the exact inverse of how 8-bit samples are decoded.
+func toUnsigned8(sample float64) uint8 {
+	return uint8(math.Min(255, math.Floor(clampSample(sample)*128+128.5)))
+}
+
+// DecodeWAV decodes RIFF WAVE data holding integer or floating point
+// PCM samples, mixing all channels down into a single channel.
+func DecodeWAV(data []byte) (*Sound, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("invalid WAV header")
+	}
+
+	var format, channels, bits int
+	var rate int
+	var samples []byte
+	haveFormat := false
+
+	offset := 12
+	for offset+8 <= len(data) {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		offset += 8
+		if size < 0 || offset+size > len(data) {
+			// Some writers leave the size of the last chunk wrong, so
+			// take what is there.
+			size = len(data) - offset
+		}
+		chunk := data[offset : offset+size]
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("WAV format chunk is too short")
+			}
+			format = int(binary.LittleEndian.Uint16(chunk))
+			channels = int(binary.LittleEndian.Uint16(chunk[2:]))
+			rate = int(binary.LittleEndian.Uint32(chunk[4:]))
+			bits = int(binary.LittleEndian.Uint16(chunk[14:]))
+
+			// WAVE_FORMAT_EXTENSIBLE keeps the real format at the
+			// start of the subformat GUID.
+			if format == 0xFFFE && size >= 26 {
+				format = int(binary.LittleEndian.Uint16(chunk[24:]))
+			}
+			haveFormat = true
+		case "data":
+			samples = chunk
+		}
+
+		// Chunks are padded to an even size.
+		offset += size + size%2
+	}
+
+	if !haveFormat {
+		return nil, errors.New("WAV is missing format chunk")
+	} else if samples == nil {
+		return nil, errors.New("WAV is missing data chunk")
+	}
+
+	switch format {
+	case 1:
+		return DecodePCM(samples, rate, channels, bits)
+	case 3:
+		if bits != 32 && bits != 64 {
+			return nil, fmt.Errorf("unsupported float sample size of %d bits", bits)
+		} else if rate <= 0 || channels <= 0 {
+			return nil, errors.New("invalid WAV format")
+		}
+
+		width := bits / 8
+		frames := len(samples) / (width * channels)
+		sound := &Sound{SampleRate: rate, Samples: make([]float64, frames)}
+		for i := range sound.Samples {
+			var sum float64
+			for c := 0; c < channels; c++ {
+				offset := (i*channels + c) * width
+				sum += decodeSample(samples[offset:offset+width], true)
+			}
+			sound.Samples[i] = sum / float64(channels)
+		}
+		return sound, nil
+	}
+
+	return nil, fmt.Errorf("unsupported WAV format %d", format)
+}
+
+// EncodeWAV encodes a sound into monaural RIFF WAVE data, using either
+// 8-bit unsigned or 16-bit signed samples.
+func EncodeWAV(sound *Sound, bits int) ([]byte, error) {
+	if bits != 8 && bits != 16 {
+		return nil, fmt.Errorf("unsupported sample size of %d bits", bits)
+	}
+
+	width := bits / 8
+	dataSize := len(sound.Samples) * width
+
+	var buffer bytes.Buffer
+	buffer.WriteString("RIFF")
+	binary.Write(&buffer, binary.LittleEndian, uint32(36+dataSize+dataSize%2))
+	buffer.WriteString("WAVEfmt ")
+	binary.Write(&buffer, binary.LittleEndian, uint32(16))
+	binary.Write(&buffer, binary.LittleEndian, uint16(1))
+	binary.Write(&buffer, binary.LittleEndian, uint16(1))
+	binary.Write(&buffer, binary.LittleEndian, uint32(sound.SampleRate))
+	binary.Write(&buffer, binary.LittleEndian, uint32(sound.SampleRate*width))
+	binary.Write(&buffer, binary.LittleEndian, uint16(width))
+	binary.Write(&buffer, binary.LittleEndian, uint16(bits))
+	buffer.WriteString("data")
+	binary.Write(&buffer, binary.LittleEndian, uint32(dataSize))
+
+	for _, sample := range sound.Samples {
+		if bits == 8 {
+			buffer.WriteByte(toUnsigned8(sample))
+		} else {
+			value := math.Floor(clampSample(sample)*32767.5 + 0.5)
+			binary.Write(&buffer, binary.LittleEndian, int16(math.Max(-32768, math.Min(32767, value))))
+		}
+	}
+
+	if dataSize%2 != 0 {
+		buffer.WriteByte(0)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Resample returns a copy of the sound at a different sample rate.
+// Downsampling averages every source sample that falls within each new
+// sample to avoid aliasing, while upsampling interpolates linearly.
+func (sound *Sound) Resample(rate int) *Sound {
+	if rate == sound.SampleRate || len(sound.Samples) == 0 {
+		samples := make([]float64, len(sound.Samples))
+		copy(samples, sound.Samples)
+		return &Sound{SampleRate: rate, Samples: samples}
+	}
+
+	ratio := float64(sound.SampleRate) / float64(rate)
+	length := int(math.Floor(float64(len(sound.Samples)) / ratio))
+	resampled := &Sound{SampleRate: rate, Samples: make([]float64, length)}
+
+	for i := range resampled.Samples {
+		if ratio > 1 {
+			start := int(float64(i) * ratio)
+			end := int(float64(i+1) * ratio)
+			if end > len(sound.Samples) {
+				end = len(sound.Samples)
+			}
+
+			var sum float64
+			for _, sample := range sound.Samples[start:end] {
+				sum += sample
+			}
+			resampled.Samples[i] = sum / float64(end-start)
+		} else {
+			position := float64(i) * ratio
+			index := int(position)
+			fraction := position - float64(index)
+
+			next := index + 1
+			if next >= len(sound.Samples) {
+				next = index
+			}
+			resampled.Samples[i] = sound.Samples[index]*(1-fraction) + sound.Samples[next]*fraction
+		}
+	}
+
+	return resampled
+}
+
+// DecodeDMX decodes DMX format sound lump data, as used by Doom sound
+// effects.  Padding is removed, but only if the data has it, as not
+// every tool writes it.
+func DecodeDMX(data []byte) (*Sound, error) {
+	if len(data) < 8 {
+		return nil, errors.New("DMX sound is too short")
+	}
+
+	if binary.LittleEndian.Uint16(data) != 3 {
+		return nil, errors.New("invalid DMX sound format")
+	}
+
+	rate := int(binary.LittleEndian.Uint16(data[2:]))
+	length := int(binary.LittleEndian.Uint32(data[4:]))
+	if length < 0 || 8+length > len(data) {
+		return nil, errors.New("DMX sound length out of range")
+	}
+
+	samples := data[8 : 8+length]
+	if dmxPadded(samples) {
+		samples = samples[dmxPadding : length-dmxPadding]
+	}
+
+	return DecodePCM(samples, rate, 1, 8)
+}
+
+// Returns true if DMX sample data starts and ends with padding, which
+// repeats the first and last sample of the sound.  Silence looks the
+// same, so at worst a few samples of it are lost.
+func dmxPadded(samples []byte) bool {
+	if len(samples) < dmxPadding*2 {
+		return false
+	}
+
+	first := samples[dmxPadding]
+	last := samples[len(samples)-dmxPadding-1]
+	for i := 0; i < dmxPadding; i++ {
+		if samples[i] != first || samples[len(samples)-1-i] != last {
+			return false
+		}
+	}
+
+	return true
+}
+
+// EncodeDMX encodes a sound into DMX format sound lump data, quantizing
+// samples to 8 bits and padding both ends with copies of the first and
+// last sample.
+func EncodeDMX(sound *Sound) ([]byte, error) {
+	if sound.SampleRate <= 0 || sound.SampleRate > 65535 {
+		return nil, errors.New("sample rate out of range")
+	}
+
+	length := len(sound.Samples) + dmxPadding*2
+	data := make([]byte, 8+length)
+	binary.LittleEndian.PutUint16(data, 3)
+	binary.LittleEndian.PutUint16(data[2:], uint16(sound.SampleRate))
+	binary.LittleEndian.PutUint32(data[4:], uint32(length))
+
+	samples := data[8+dmxPadding:]
+	for i, sample := range sound.Samples {
+		samples[i] = toUnsigned8(sample)
+	}
+
+	first, last := uint8(128), uint8(128)
+	if len(sound.Samples) > 0 {
+		first = samples[0]
+		last = samples[len(sound.Samples)-1]
+	}
+	for i := 0; i < dmxPadding; i++ {
+		data[8+i] = first
+		data[8+dmxPadding+len(sound.Samples)+i] = last
+	}
+
+	return data, nil
+}
@@ -0,0 +1,150 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// Builds a stereo 16-bit WAV at 44.1 kHz with a half-amplitude 440 Hz
+// sine wave in both channels.
+func testStereoWAV(frames int) []byte {
+	sound := &Sound{SampleRate: 44100}
+	wav, _ := EncodeWAV(sound, 16)
+
+	data := make([]byte, frames*4)
+	for i := 0; i < frames; i++ {
+		sample := int16(16384 * math.Sin(float64(i)*2*math.Pi*440/44100))
+		binary.LittleEndian.PutUint16(data[i*4:], uint16(sample))
+		binary.LittleEndian.PutUint16(data[i*4+2:], uint16(sample))
+	}
+
+	// Patch the header into a stereo one
+	binary.LittleEndian.PutUint32(wav[4:], uint32(36+len(data)))
+	binary.LittleEndian.PutUint16(wav[22:], 2)
+	binary.LittleEndian.PutUint32(wav[28:], 44100*4)
+	binary.LittleEndian.PutUint16(wav[32:], 4)
+	binary.LittleEndian.PutUint32(wav[40:], uint32(len(data)))
+
+	return append(wav, data...)
+}
+
+func TestDecodeWAV(t *testing.T) {
+	sound, err := DecodeWAV(testStereoWAV(4410))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if sound.SampleRate != 44100 || len(sound.Samples) != 4410 {
+		t.Fatalf("incorrect sound %d Hz, %d samples", sound.SampleRate, len(sound.Samples))
+	}
+
+	// Quarter of the way through the first period is the peak
+	peak := sound.Samples[25]
+	if peak < 0.49 || peak > 0.51 {
+		t.Errorf("incorrect downmixed sample %f", peak)
+	}
+}
+
+func TestResample(t *testing.T) {
+	sound := &Sound{SampleRate: 44100, Samples: make([]float64, 44100)}
+	for i := range sound.Samples {
+		sound.Samples[i] = 0.5
+	}
+
+	down := sound.Resample(11025)
+	if len(down.Samples) != 11025 || math.Abs(down.Samples[100]-0.5) > 0.0001 {
+		t.Errorf("incorrect downsampled sound (%d samples)", len(down.Samples))
+	}
+
+	up := down.Resample(22050)
+	if len(up.Samples) != 22050 || math.Abs(up.Samples[101]-0.5) > 0.0001 {
+		t.Errorf("incorrect upsampled sound (%d samples)", len(up.Samples))
+	}
+}
+
+func TestDMX(t *testing.T) {
+	sound := &Sound{SampleRate: 11025, Samples: []float64{-1, 0, 0.5, 1}}
+
+	data, err := EncodeDMX(sound)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(data) != 8+4+32 {
+		t.Fatalf("incorrect DMX size %d", len(data))
+	}
+
+	if binary.LittleEndian.Uint16(data) != 3 || binary.LittleEndian.Uint16(data[2:]) != 11025 ||
+		binary.LittleEndian.Uint32(data[4:]) != 36 {
+		t.Errorf("incorrect DMX header %v", data[:8])
+	}
+
+	// Padding copies the first and last samples
+	if data[8] != 0 || data[8+16] != 0 || data[8+16+2] != 192 || data[len(data)-1] != 255 {
+		t.Errorf("incorrect DMX samples %v", data[8:])
+	}
+
+	decoded, err := DecodeDMX(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reencoded, err := EncodeDMX(decoded)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(reencoded) != string(data) {
+		t.Error("DMX sound did not survive a round trip")
+	}
+}
+
+// Samples are only stripped from DMX sounds that have padding
+func TestDMXPadding(t *testing.T) {
+	tests := []struct {
+		samples  []byte
+		expected int
+	}{
+		{bytes.Repeat([]byte{128}, 32), 0},
+		{append(append(bytes.Repeat([]byte{10}, 17), 20, 30), bytes.Repeat([]byte{40}, 17)...), 4},
+		{append(append(bytes.Repeat([]byte{10}, 16), 20, 30), bytes.Repeat([]byte{40}, 17)...), 35},
+		{append([]byte{0, 64, 128, 192, 255}, bytes.Repeat([]byte{128}, 35)...), 40},
+		{[]byte{0, 64, 128}, 3},
+	}
+
+	for _, test := range tests {
+		data := []byte{3, 0, 0x11, 0x2b, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(data[4:], uint32(len(test.samples)))
+		data = append(data, test.samples...)
+
+		sound, err := DecodeDMX(data)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if len(sound.Samples) != test.expected {
+			t.Errorf("%v decoded to %d samples instead of %d", test.samples, len(sound.Samples), test.expected)
+		}
+	}
+}
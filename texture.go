@@ -0,0 +1,278 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TextureFormat designates the binary layout of a TEXTURE1/TEXTURE2
+// lump.
+type TextureFormat int
+
+const (
+	// TextureFormatDoom is the layout used by Doom, Heretic and Hexen.
+	TextureFormatDoom TextureFormat = iota
+
+	// TextureFormatStrife is the layout used by Strife, which drops
+	// the obsolete columndirectory field from textures and the stepdir
+	// and colormap fields from patches.
+	TextureFormatStrife
+)
+
+// TextureFlagWorldPanning is the ZDoom texture flag that causes
+// texture offsets to be measured in world units instead of texels.
+const TextureFlagWorldPanning = 0x8000
+
+// TexturePatch is a single patch placed inside of a composite texture.
+type TexturePatch struct {
+	Name    string
+	OriginX int16
+	OriginY int16
+}
+
+// Texture is a composite texture as defined in a TEXTURE1 or TEXTURE2
+// lump.  Patches are referenced by name, not by PNAMES index.
+type Texture struct {
+	Name    string
+	Flags   uint16
+	ScaleX  uint8
+	ScaleY  uint8
+	Width   int16
+	Height  int16
+	Patches []TexturePatch
+}
+
+// nullTerminated returns the string held in a fixed-size name field.
+// Such a name is either null-terminated and shorter than the field, or
+// exactly as long as the field and not null-terminated.
+func nullTerminated(name []byte) string {
+	index := bytes.IndexByte(name, byte(0))
+	if index != -1 {
+		return string(name[:index])
+	}
+
+	return string(name)
+}
+
+// putName copies a name into a fixed-size name field, failing if the
+// name does not fit.
+func putName(field []byte, name string) error {
+	if len(name) > len(field) {
+		return fmt.Errorf("name %q is too long", name)
+	}
+
+	copy(field, name)
+	return nil
+}
+
+// DecodePNames decodes PNAMES lump data into a list of patch names.
+func DecodePNames(data []byte) ([]string, error) {
+	if len(data) < 4 {
+		return nil, errors.New("PNAMES is too short")
+	}
+
+	count := int32(binary.LittleEndian.Uint32(data))
+	if count < 0 || int64(len(data)-4) < int64(count)*8 {
+		return nil, errors.New("PNAMES patch count out of range")
+	}
+
+	names := make([]string, count)
+	for i := range names {
+		offset := 4 + i*8
+		names[i] = strings.ToUpper(nullTerminated(data[offset : offset+8]))
+	}
+
+	return names, nil
+}
+
+// EncodePNames encodes a list of patch names into PNAMES lump data.
+func EncodePNames(names []string) ([]byte, error) {
+	data := make([]byte, 4+len(names)*8)
+	binary.LittleEndian.PutUint32(data, uint32(len(names)))
+
+	for i, name := range names {
+		offset := 4 + i*8
+		err := putName(data[offset:offset+8], name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// BuildPNames returns a list of every patch name referenced by the
+// passed texture sets, in order of first appearance.  TEXTURE1 and
+// TEXTURE2 share a single PNAMES, so pass both sets at once.
+func BuildPNames(sets ...[]Texture) []string {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, textures := range sets {
+		for _, texture := range textures {
+			for _, patch := range texture.Patches {
+				name := strings.ToUpper(patch.Name)
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+// DecodeTextures decodes TEXTURE1 or TEXTURE2 lump data, resolving
+// patch indexes using the passed list of patch names.
+func DecodeTextures(data []byte, pnames []string, format TextureFormat) ([]Texture, error) {
+	var headerSize, patchSize int
+	switch format {
+	case TextureFormatDoom:
+		headerSize, patchSize = 22, 10
+	case TextureFormatStrife:
+		headerSize, patchSize = 18, 6
+	default:
+		return nil, errors.New("unknown texture format")
+	}
+
+	if len(data) < 4 {
+		return nil, errors.New("texture lump is too short")
+	}
+
+	count := int32(binary.LittleEndian.Uint32(data))
+	if count < 0 || int64(len(data)-4) < int64(count)*4 {
+		return nil, errors.New("texture count out of range")
+	}
+
+	textures := make([]Texture, count)
+	for i := range textures {
+		offset := int(int32(binary.LittleEndian.Uint32(data[4+i*4:])))
+		if offset < 0 || offset+headerSize > len(data) {
+			return nil, fmt.Errorf("texture %d offset out of range", i)
+		}
+
+		texture := &textures[i]
+		texture.Name = strings.ToUpper(nullTerminated(data[offset : offset+8]))
+		texture.Flags = binary.LittleEndian.Uint16(data[offset+8:])
+		texture.ScaleX = data[offset+10]
+		texture.ScaleY = data[offset+11]
+		texture.Width = int16(binary.LittleEndian.Uint16(data[offset+12:]))
+		texture.Height = int16(binary.LittleEndian.Uint16(data[offset+14:]))
+
+		// Patch count is the last field of the header, after the
+		// columndirectory if the format has one.
+		patchCount := int(int16(binary.LittleEndian.Uint16(data[offset+headerSize-2:])))
+		if patchCount < 0 || offset+headerSize+patchCount*patchSize > len(data) {
+			return nil, fmt.Errorf("texture %s patch count out of range", texture.Name)
+		}
+
+		texture.Patches = make([]TexturePatch, patchCount)
+		for j := range texture.Patches {
+			patchOffset := offset + headerSize + j*patchSize
+			patch := &texture.Patches[j]
+			patch.OriginX = int16(binary.LittleEndian.Uint16(data[patchOffset:]))
+			patch.OriginY = int16(binary.LittleEndian.Uint16(data[patchOffset+2:]))
+
+			index := int(int16(binary.LittleEndian.Uint16(data[patchOffset+4:])))
+			if index < 0 || index >= len(pnames) {
+				return nil, fmt.Errorf("texture %s patch %d not in PNAMES", texture.Name, index)
+			}
+			patch.Name = pnames[index]
+		}
+	}
+
+	return textures, nil
+}
+
+// EncodeTextures encodes textures into TEXTURE1 or TEXTURE2 lump data,
+// writing patch indexes from the passed list of patch names.
+func EncodeTextures(textures []Texture, pnames []string, format TextureFormat) ([]byte, error) {
+	if format != TextureFormatDoom && format != TextureFormatStrife {
+		return nil, errors.New("unknown texture format")
+	}
+
+	indexes := make(map[string]int, len(pnames))
+	for i, name := range pnames {
+		name = strings.ToUpper(name)
+		if _, ok := indexes[name]; !ok {
+			indexes[name] = i
+		}
+	}
+
+	var body bytes.Buffer
+	offsets := make([]int32, len(textures))
+	headerSize := int32(4 + len(textures)*4)
+
+	for i, texture := range textures {
+		offsets[i] = headerSize + int32(body.Len())
+
+		var name [8]byte
+		err := putName(name[:], strings.ToUpper(texture.Name))
+		if err != nil {
+			return nil, err
+		}
+		body.Write(name[:])
+		binary.Write(&body, binary.LittleEndian, texture.Flags)
+		body.WriteByte(texture.ScaleX)
+		body.WriteByte(texture.ScaleY)
+		binary.Write(&body, binary.LittleEndian, texture.Width)
+		binary.Write(&body, binary.LittleEndian, texture.Height)
+		if format == TextureFormatDoom {
+			// Obsolete columndirectory
+			binary.Write(&body, binary.LittleEndian, int32(0))
+		}
+
+		if len(texture.Patches) > 32767 {
+			return nil, fmt.Errorf("texture %s has too many patches", texture.Name)
+		}
+		binary.Write(&body, binary.LittleEndian, int16(len(texture.Patches)))
+
+		for _, patch := range texture.Patches {
+			index, ok := indexes[strings.ToUpper(patch.Name)]
+			if !ok {
+				return nil, fmt.Errorf("texture %s patch %s not in PNAMES", texture.Name, patch.Name)
+			} else if index > 32767 {
+				return nil, fmt.Errorf("texture %s patch %s index out of range", texture.Name, patch.Name)
+			}
+
+			binary.Write(&body, binary.LittleEndian, patch.OriginX)
+			binary.Write(&body, binary.LittleEndian, patch.OriginY)
+			binary.Write(&body, binary.LittleEndian, int16(index))
+			if format == TextureFormatDoom {
+				// Unused stepdir and colormap, set to vanilla values
+				binary.Write(&body, binary.LittleEndian, int16(1))
+				binary.Write(&body, binary.LittleEndian, int16(0))
+			}
+		}
+	}
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, int32(len(textures)))
+	binary.Write(&data, binary.LittleEndian, offsets)
+	data.Write(body.Bytes())
+
+	return data.Bytes(), nil
+}
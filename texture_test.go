@@ -0,0 +1,126 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testTextures = []Texture{
+	{
+		Name:   "AASHITTY",
+		Width:  64,
+		Height: 64,
+		Patches: []TexturePatch{
+			{Name: "WALL00_1", OriginX: 0, OriginY: 0},
+		},
+	},
+	{
+		Name:   "BIGDOOR1",
+		Flags:  TextureFlagWorldPanning,
+		Width:  128,
+		Height: 96,
+		Patches: []TexturePatch{
+			{Name: "W13_1", OriginX: 0, OriginY: 0},
+			{Name: "W13_A", OriginX: 64, OriginY: -8},
+			{Name: "WALL00_1", OriginX: 96, OriginY: 0},
+		},
+	},
+}
+
+func TestPNames(t *testing.T) {
+	pnames := BuildPNames(testTextures)
+	expected := []string{"WALL00_1", "W13_1", "W13_A"}
+	if !reflect.DeepEqual(pnames, expected) {
+		t.Fatalf("incorrect patch names %v", pnames)
+	}
+
+	data, err := EncodePNames(pnames)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(data) != 4+3*8 {
+		t.Fatalf("incorrect PNAMES size %d", len(data))
+	}
+
+	decoded, err := DecodePNames(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("incorrect decoded patch names %v", decoded)
+	}
+}
+
+func TestTexturesDoom(t *testing.T) {
+	pnames := BuildPNames(testTextures)
+
+	data, err := EncodeTextures(testTextures, pnames, TextureFormatDoom)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Header, offsets, two textures and four patches
+	if len(data) != 4+2*4+2*22+4*10 {
+		t.Fatalf("incorrect TEXTURE1 size %d", len(data))
+	}
+
+	decoded, err := DecodeTextures(data, pnames, TextureFormatDoom)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(decoded, testTextures) {
+		t.Errorf("incorrect decoded textures %v", decoded)
+	}
+}
+
+func TestTexturesStrife(t *testing.T) {
+	pnames := BuildPNames(testTextures)
+
+	data, err := EncodeTextures(testTextures, pnames, TextureFormatStrife)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Header, offsets, two textures and four patches
+	if len(data) != 4+2*4+2*18+4*6 {
+		t.Fatalf("incorrect TEXTURE1 size %d", len(data))
+	}
+
+	decoded, err := DecodeTextures(data, pnames, TextureFormatStrife)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(decoded, testTextures) {
+		t.Errorf("incorrect decoded textures %v", decoded)
+	}
+}
+
+func TestTexturesMissingPatch(t *testing.T) {
+	_, err := EncodeTextures(testTextures, []string{"WALL00_1"}, TextureFormatDoom)
+	if err == nil {
+		t.Error("texture with missing patch was encoded")
+	}
+}
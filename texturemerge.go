@@ -0,0 +1,169 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MergePolicy designates how conflicting definitions are resolved when
+// merging.
+type MergePolicy int
+
+const (
+	// MergeFirstWins keeps the first definition of a name.
+	MergeFirstWins MergePolicy = iota
+
+	// MergeLastWins replaces earlier definitions of a name with later
+	// ones, keeping the position of the first definition.
+	MergeLastWins
+
+	// MergeError fails on differing definitions of the same name.
+	MergeError
+)
+
+// TextureSet holds the composite textures of a single WAD.  PNAMES is
+// not stored, as it is rebuilt from patch names when encoding.
+type TextureSet struct {
+	Texture1 []Texture
+	Texture2 []Texture
+}
+
+// DecodeTextureSet decodes the PNAMES, TEXTURE1 and TEXTURE2 lumps
+// found in a directory.  Missing texture lumps result in empty lists.
+func DecodeTextureSet(dir *Directory, format TextureFormat) (*TextureSet, error) {
+	set := &TextureSet{}
+
+	pnamesIndex, ok := dir.Search("PNAMES", 0)
+	if !ok {
+		return set, nil
+	}
+
+	pnames, err := DecodePNames((*dir)[pnamesIndex].Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if index, ok := dir.Search("TEXTURE1", 0); ok {
+		set.Texture1, err = DecodeTextures((*dir)[index].Data, pnames, format)
+		if err != nil {
+			return nil, fmt.Errorf("TEXTURE1: %s", err.Error())
+		}
+	}
+
+	if index, ok := dir.Search("TEXTURE2", 0); ok {
+		set.Texture2, err = DecodeTextures((*dir)[index].Data, pnames, format)
+		if err != nil {
+			return nil, fmt.Errorf("TEXTURE2: %s", err.Error())
+		}
+	}
+
+	return set, nil
+}
+
+// EncodeTextureSet encodes a texture set into a directory holding a
+// TEXTURE1 lump, a TEXTURE2 lump if there are any textures for it, and
+// a PNAMES lump shared by both.
+func EncodeTextureSet(set *TextureSet, format TextureFormat) (Directory, error) {
+	dir := Directory{}
+	pnames := BuildPNames(set.Texture1, set.Texture2)
+
+	data, err := EncodeTextures(set.Texture1, pnames, format)
+	if err != nil {
+		return nil, err
+	}
+	dir = append(dir, Lump{Name: "TEXTURE1", Data: data})
+
+	if len(set.Texture2) > 0 {
+		data, err = EncodeTextures(set.Texture2, pnames, format)
+		if err != nil {
+			return nil, err
+		}
+		dir = append(dir, Lump{Name: "TEXTURE2", Data: data})
+	}
+
+	data, err = EncodePNames(pnames)
+	if err != nil {
+		return nil, err
+	}
+	dir = append(dir, Lump{Name: "PNAMES", Data: data})
+
+	return dir, nil
+}
+
+// MergeTextures merges texture sets in order.  Textures from TEXTURE1
+// and TEXTURE2 share a single namespace, and a texture that is
+// redefined stays in the lump it was first defined in.  Identical
+// redefinitions are never a conflict.
+func MergeTextures(sets []*TextureSet, policy MergePolicy) (*TextureSet, error) {
+	merged := &TextureSet{Texture1: []Texture{}, Texture2: []Texture{}}
+
+	// Where each texture name lives in the merged set, and which set
+	// it came from.
+	type location struct {
+		textures *[]Texture
+		index    int
+		source   int
+	}
+	locations := map[string]location{}
+
+	for source, set := range sets {
+		lists := []struct {
+			from []Texture
+			to   *[]Texture
+		}{
+			{set.Texture1, &merged.Texture1},
+			{set.Texture2, &merged.Texture2},
+		}
+
+		for _, list := range lists {
+			for _, texture := range list.from {
+				name := strings.ToUpper(texture.Name)
+				loc, ok := locations[name]
+				if !ok {
+					locations[name] = location{list.to, len(*list.to), source}
+					*list.to = append(*list.to, texture)
+					continue
+				}
+
+				existing := &(*loc.textures)[loc.index]
+				if reflect.DeepEqual(*existing, texture) {
+					continue
+				}
+
+				switch policy {
+				case MergeFirstWins:
+				case MergeLastWins:
+					*existing = texture
+					locations[name] = location{loc.textures, loc.index, source}
+				case MergeError:
+					return nil, fmt.Errorf("texture %s is defined differently in sets %d and %d",
+						name, loc.source+1, source+1)
+				default:
+					return nil, fmt.Errorf("unknown merge policy %d", policy)
+				}
+			}
+		}
+	}
+
+	return merged, nil
+}
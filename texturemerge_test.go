@@ -0,0 +1,139 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"testing"
+)
+
+func testTextureSet(names ...string) *TextureSet {
+	set := &TextureSet{}
+	for _, name := range names {
+		set.Texture1 = append(set.Texture1, Texture{
+			Name:    name,
+			Width:   64,
+			Height:  64,
+			Patches: []TexturePatch{{Name: name + "P"}},
+		})
+	}
+
+	return set
+}
+
+func TestMergeTexturesFirstWins(t *testing.T) {
+	a := testTextureSet("ONE", "TWO")
+	b := testTextureSet("TWO", "THREE")
+	b.Texture1[0].Width = 128
+
+	merged, err := MergeTextures([]*TextureSet{a, b}, MergeFirstWins)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(merged.Texture1) != 3 {
+		t.Fatalf("incorrect texture count %d", len(merged.Texture1))
+	}
+
+	if merged.Texture1[1].Width != 64 {
+		t.Error("first definition did not win")
+	}
+}
+
+func TestMergeTexturesLastWins(t *testing.T) {
+	a := testTextureSet("ONE", "TWO")
+	b := testTextureSet("TWO", "THREE")
+	b.Texture1[0].Width = 128
+
+	merged, err := MergeTextures([]*TextureSet{a, b}, MergeLastWins)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Replaced texture keeps its original position
+	if merged.Texture1[1].Name != "TWO" || merged.Texture1[1].Width != 128 {
+		t.Error("last definition did not win")
+	}
+}
+
+func TestMergeTexturesError(t *testing.T) {
+	a := testTextureSet("ONE", "TWO")
+	b := testTextureSet("TWO")
+
+	// Identical definitions are not a conflict
+	_, err := MergeTextures([]*TextureSet{a, b}, MergeError)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	b.Texture1[0].Height = 128
+	_, err = MergeTextures([]*TextureSet{a, b}, MergeError)
+	if err == nil {
+		t.Error("conflicting definitions did not fail")
+	}
+}
+
+func TestTextureSetRemapsPatches(t *testing.T) {
+	a := testTextureSet("ONE")
+	b := testTextureSet("TWO")
+	b.Texture2 = b.Texture1
+	b.Texture1 = nil
+
+	// Each set has its patch at PNAMES index zero.
+	var dirs []Directory
+	for _, set := range []*TextureSet{a, b} {
+		dir, err := EncodeTextureSet(set, TextureFormatDoom)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		dirs = append(dirs, dir)
+	}
+
+	var sets []*TextureSet
+	for i := range dirs {
+		set, err := DecodeTextureSet(&dirs[i], TextureFormatDoom)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sets = append(sets, set)
+	}
+
+	merged, err := MergeTextures(sets, MergeError)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dir, err := EncodeTextureSet(merged, TextureFormatDoom)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(dir) != 3 || dir[0].Name != "TEXTURE1" || dir[1].Name != "TEXTURE2" || dir[2].Name != "PNAMES" {
+		t.Fatalf("incorrect merged directory %v", dir)
+	}
+
+	decoded, err := DecodeTextureSet(&dir, TextureFormatDoom)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if decoded.Texture2[0].Patches[0].Name != "TWOP" {
+		t.Error("patch index was not remapped")
+	}
+}
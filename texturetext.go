@@ -0,0 +1,328 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseDeutexTextures parses texture definitions written in the syntax
+// of deutex's texture1.txt.  Each texture starts with a line holding its
+// name, width and height, followed by one line per patch holding an
+// asterisk, the patch name and its x and y offsets.  Lines starting
+// with a semicolon or hash are comments.
+func ParseDeutexTextures(r io.Reader) ([]Texture, error) {
+	textures := []Texture{}
+	scanner := bufio.NewScanner(r)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], ";") || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if fields[0] == "*" {
+			// Patch
+			if len(textures) == 0 {
+				return nil, fmt.Errorf("line %d: patch without a texture", line)
+			} else if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: patch needs a name, x and y", line)
+			}
+
+			x, err := strconv.ParseInt(fields[2], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid patch x offset %q", line, fields[2])
+			}
+			y, err := strconv.ParseInt(fields[3], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid patch y offset %q", line, fields[3])
+			}
+
+			texture := &textures[len(textures)-1]
+			texture.Patches = append(texture.Patches, TexturePatch{
+				Name:    strings.ToUpper(fields[1]),
+				OriginX: int16(x),
+				OriginY: int16(y),
+			})
+		} else {
+			// Texture
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: texture needs a name, width and height", line)
+			}
+
+			width, err := strconv.ParseInt(fields[1], 10, 16)
+			if err != nil || width < 0 {
+				return nil, fmt.Errorf("line %d: invalid texture width %q", line, fields[1])
+			}
+			height, err := strconv.ParseInt(fields[2], 10, 16)
+			if err != nil || height < 0 {
+				return nil, fmt.Errorf("line %d: invalid texture height %q", line, fields[2])
+			}
+
+			textures = append(textures, Texture{
+				Name:    strings.ToUpper(fields[0]),
+				Width:   int16(width),
+				Height:  int16(height),
+				Patches: []TexturePatch{},
+			})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return textures, nil
+}
+
+// WriteDeutexTextures writes texture definitions in the syntax of
+// deutex's texture1.txt.  Flags and scales cannot be represented in
+// this syntax and are dropped.
+func WriteDeutexTextures(w io.Writer, textures []Texture) error {
+	for _, texture := range textures {
+		_, err := fmt.Fprintf(w, "%-8s %5d %5d\n", texture.Name, texture.Width, texture.Height)
+		if err != nil {
+			return err
+		}
+
+		for _, patch := range texture.Patches {
+			_, err = fmt.Fprintf(w, "*   %-8s %5d %5d\n", patch.Name, patch.OriginX, patch.OriginY)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// scaleToByte converts a ZDoom texture scale into the byte stored in a
+// texture lump, where the scale is a multiple of 1/8.
+func scaleToByte(scale float64) (uint8, error) {
+	value := math.Floor(scale*8 + 0.5)
+	if value < 1 || value > 255 {
+		return 0, fmt.Errorf("scale %g out of range", scale)
+	}
+
+	return uint8(value), nil
+}
+
+// Consumes an integer stored in a 16-bit field of a texture, which must
+// not be negative if it is a size.
+func scanTextureInt(s *textScanner, what string, size bool) (int16, error) {
+	tok, err := s.Peek()
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := s.Integer()
+	if err != nil {
+		return 0, err
+	} else if value < math.MinInt16 || value > math.MaxInt16 {
+		return 0, s.errorf(tok.Line, "%s %d is out of range", what, value)
+	} else if size && value < 0 {
+		return 0, s.errorf(tok.Line, "%s %d is negative", what, value)
+	}
+
+	return int16(value), nil
+}
+
+// ParseZDoomTextures parses texture definitions written in the syntax of
+// ZDoom's TEXTURES lump.  Only texture definitions that can be stored
+// in a texture lump are accepted.
+func ParseZDoomTextures(r io.Reader) ([]Texture, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newTextScanner("TEXTURES", string(src))
+	textures := []Texture{}
+
+	for {
+		tok, err := s.Next()
+		if err != nil {
+			return nil, err
+		} else if tok.Type == tokenEOF {
+			break
+		}
+
+		if tok.Type != tokenIdentifier ||
+			(!strings.EqualFold(tok.Text, "Texture") && !strings.EqualFold(tok.Text, "WallTexture")) {
+			return nil, s.errorf(tok.Line, "unsupported definition %q", tok.Text)
+		}
+
+		// Optional textures are an ordinary texture as far as we are
+		// concerned.
+		if _, err = s.Accept("optional"); err != nil {
+			return nil, err
+		}
+
+		texture := Texture{Patches: []TexturePatch{}}
+		if texture.Name, err = s.Name(); err != nil {
+			return nil, err
+		}
+		texture.Name = strings.ToUpper(texture.Name)
+
+		if err = s.Expect(","); err != nil {
+			return nil, err
+		}
+		if texture.Width, err = scanTextureInt(s, "width", true); err != nil {
+			return nil, err
+		}
+		if err = s.Expect(","); err != nil {
+			return nil, err
+		}
+		if texture.Height, err = scanTextureInt(s, "height", true); err != nil {
+			return nil, err
+		}
+
+		if err = s.Expect("{"); err != nil {
+			return nil, err
+		}
+
+		for {
+			tok, err := s.Next()
+			if err != nil {
+				return nil, err
+			}
+
+			if tok.Type == tokenPunct && tok.Text == "}" {
+				break
+			} else if tok.Type != tokenIdentifier {
+				return nil, s.errorf(tok.Line, "expected texture property, got %q", tok.Text)
+			}
+
+			switch strings.ToLower(tok.Text) {
+			case "patch":
+				patch := TexturePatch{}
+				if patch.Name, err = s.Name(); err != nil {
+					return nil, err
+				}
+				patch.Name = strings.ToUpper(patch.Name)
+
+				if err = s.Expect(","); err != nil {
+					return nil, err
+				}
+				if patch.OriginX, err = scanTextureInt(s, "x offset", false); err != nil {
+					return nil, err
+				}
+				if err = s.Expect(","); err != nil {
+					return nil, err
+				}
+				if patch.OriginY, err = scanTextureInt(s, "y offset", false); err != nil {
+					return nil, err
+				}
+
+				block, err := s.Peek()
+				if err != nil {
+					return nil, err
+				} else if block.Type == tokenPunct && block.Text == "{" {
+					return nil, s.errorf(block.Line, "patch properties cannot be stored in a texture lump")
+				}
+
+				texture.Patches = append(texture.Patches, patch)
+			case "xscale", "yscale":
+				line := tok.Line
+				scale, err := s.Number()
+				if err != nil {
+					return nil, err
+				}
+
+				value, err := scaleToByte(scale)
+				if err != nil {
+					return nil, s.errorf(line, err.Error())
+				}
+
+				if strings.EqualFold(tok.Text, "xscale") {
+					texture.ScaleX = value
+				} else {
+					texture.ScaleY = value
+				}
+			case "worldpanning":
+				texture.Flags |= TextureFlagWorldPanning
+			case "nulltexture":
+				// The first texture in TEXTURE1 is always treated as the
+				// null texture, so there is nothing to store.
+			default:
+				return nil, s.errorf(tok.Line, "texture property %q cannot be stored in a texture lump", tok.Text)
+			}
+		}
+
+		textures = append(textures, texture)
+	}
+
+	return textures, nil
+}
+
+// WriteZDoomTextures writes texture definitions in the syntax of ZDoom's
+// TEXTURES lump.
+func WriteZDoomTextures(w io.Writer, textures []Texture) error {
+	for i, texture := range textures {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, "\n"); err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "WallTexture \"%s\", %d, %d\n{\n", texture.Name, texture.Width, texture.Height)
+		if err != nil {
+			return err
+		}
+
+		if texture.ScaleX != 0 {
+			if _, err = fmt.Fprintf(w, "\tXScale %g\n", float64(texture.ScaleX)/8); err != nil {
+				return err
+			}
+		}
+		if texture.ScaleY != 0 {
+			if _, err = fmt.Fprintf(w, "\tYScale %g\n", float64(texture.ScaleY)/8); err != nil {
+				return err
+			}
+		}
+		if texture.Flags&TextureFlagWorldPanning != 0 {
+			if _, err = fmt.Fprint(w, "\tWorldPanning\n"); err != nil {
+				return err
+			}
+		}
+
+		for _, patch := range texture.Patches {
+			_, err = fmt.Fprintf(w, "\tPatch \"%s\", %d, %d\n", patch.Name, patch.OriginX, patch.OriginY)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err = fmt.Fprint(w, "}\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
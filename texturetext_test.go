@@ -0,0 +1,161 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDeutexTextures = `; Comment
+AASHITTY    64    64
+*   WALL00_1     0     0
+
+BIGDOOR1   128    96
+*   W13_1        0     0
+*   w13_a       64    -8
+*   WALL00_1    96     0
+`
+
+func TestParseDeutexTextures(t *testing.T) {
+	textures, err := ParseDeutexTextures(strings.NewReader(testDeutexTextures))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []Texture{testTextures[0], testTextures[1]}
+	expected[1].Flags = 0
+	if !reflect.DeepEqual(textures, expected) {
+		t.Errorf("incorrect parsed textures %v", textures)
+	}
+}
+
+func TestWriteDeutexTextures(t *testing.T) {
+	var buffer bytes.Buffer
+	err := WriteDeutexTextures(&buffer, testTextures)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	textures, err := ParseDeutexTextures(&buffer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(textures) != 2 || len(textures[1].Patches) != 3 || textures[1].Patches[1].OriginY != -8 {
+		t.Errorf("incorrect reparsed textures %v", textures)
+	}
+}
+
+const testZDoomTextures = `// Comment
+WallTexture "AASHITTY", 64, 64
+{
+	Patch "WALL00_1", 0, 0
+}
+
+Texture BIGDOOR1, 128, 96
+{
+	WorldPanning
+	Patch "W13_1", 0, 0 /* Multi-line
+	comment */
+	Patch "W13_A", 64, -8
+	Patch "WALL00_1", 96, 0
+}
+`
+
+func TestParseZDoomTextures(t *testing.T) {
+	textures, err := ParseZDoomTextures(strings.NewReader(testZDoomTextures))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(textures, testTextures) {
+		t.Errorf("incorrect parsed textures %v", textures)
+	}
+}
+
+func TestParseZDoomTexturesUnsupported(t *testing.T) {
+	_, err := ParseZDoomTextures(strings.NewReader(`Sprite "TROOA1", 64, 64 {}`))
+	if err == nil {
+		t.Error("sprite definition was parsed")
+	}
+
+	_, err = ParseZDoomTextures(strings.NewReader("Texture \"TEST\", 64, 64\n{\n\tRotate 90\n}"))
+	if err == nil || !strings.Contains(err.Error(), ":3:") {
+		t.Errorf("unsupported property was not reported on the correct line (%v)", err)
+	}
+}
+
+// Values that do not fit the fields of a texture lump are errors
+func TestParseTexturesRange(t *testing.T) {
+	zdoom := []string{
+		"Texture \"TEST\", 40000, 64 {}",
+		"Texture \"TEST\", 64, -64 {}",
+		"Texture \"TEST\", 64, 64 {\n\tPatch \"WALL\", -32769, 0\n}",
+		"Texture \"TEST\", 64, 64 {\n\tPatch \"WALL\", 0, 32768\n}",
+	}
+	for _, src := range zdoom {
+		_, err := ParseZDoomTextures(strings.NewReader(src))
+		if err == nil {
+			t.Errorf("%q was parsed", src)
+		}
+	}
+
+	textures, err := ParseZDoomTextures(strings.NewReader("Texture \"TEST\", 64, 64 {\n\tPatch \"WALL\", -32768, 32767\n}"))
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if textures[0].Patches[0].OriginX != -32768 || textures[0].Patches[0].OriginY != 32767 {
+		t.Errorf("incorrect offsets %v", textures[0].Patches[0])
+	}
+
+	deutex := []string{
+		"TEST 40000 64\n",
+		"TEST -64 64\n",
+		"TEST 64 64\n*WALL 40000 0\n",
+	}
+	for _, src := range deutex {
+		_, err := ParseDeutexTextures(strings.NewReader(src))
+		if err == nil {
+			t.Errorf("%q was parsed", src)
+		}
+	}
+}
+
+func TestWriteZDoomTextures(t *testing.T) {
+	textures := append([]Texture{}, testTextures...)
+	textures[0].ScaleX = 16
+
+	var buffer bytes.Buffer
+	err := WriteZDoomTextures(&buffer, textures)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reparsed, err := ParseZDoomTextures(&buffer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(reparsed, textures) {
+		t.Errorf("incorrect reparsed textures %v", reparsed)
+	}
+}
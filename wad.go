@@ -125,12 +125,7 @@ func Decode(r io.ReadSeeker) (*Wad, error) {
 
 		// Name is either null-terminated and less than 8 bytes, or
 		// 8 bytes exactly and not null-terminated.
-		index := bytes.IndexByte(name[:], byte(0))
-		if index != -1 {
-			lump.Name = string(name[:index])
-		} else {
-			lump.Name = string(name[:])
-		}
+		lump.Name = nullTerminated(name[:])
 
 		// If size is 0, file position could be nonsense, so only
 		// attempt to read data if size > 0.
@@ -0,0 +1,84 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlexMax/wadmake"
+	"github.com/Shopify/go-lua"
+)
+
+// Loads the build file and runs the targets named by the arguments of
+// the build subcommand, or lists the targets.  The build cache lives
+// next to the build file, and is removed first if it should be
+// cleaned.  Returns the exit code of the shell.
+func buildCommand(env *lua.State, args []string, clean bool) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	file := flags.String("f", wadmake.BuildFileName, "the build file to load")
+	list := flags.Bool("l", false, "list the targets of the build file")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: wadsh build [-f file] [-l] [target...]\n")
+		flags.PrintDefaults()
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if !useCache(env, filepath.Dir(*file), clean) {
+		return 1
+	}
+
+	err = lua.DoFile(env, *file)
+	if err != nil {
+		printError(env, err)
+		return 1
+	}
+
+	if *list {
+		for _, target := range wadmake.LuaBuildTargets(env) {
+			fmt.Print(target.Name)
+			if len(target.Deps) > 0 {
+				fmt.Printf(" (%s)", strings.Join(target.Deps, ", "))
+			}
+			if target.Description != "" {
+				fmt.Printf(": %s", target.Description)
+			}
+			fmt.Print("\n")
+		}
+		return 0
+	}
+
+	err = wadmake.RunBuildTargets(env, flags.Args(), func(name string) {
+		fmt.Fprintf(os.Stderr, "Building %s\n", name)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
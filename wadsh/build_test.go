@@ -0,0 +1,120 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexMax/wadmake"
+)
+
+// Targets of a build file run after their dependencies and leave their
+// outputs, while cycles and unknown targets fail the build
+func TestBuildCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wadsh")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"wadmake.lua": `
+			local function output(name, text)
+				local file = assert(io.open(dir .. "/" .. name, "a"))
+				file:write(text)
+				file:close()
+			end
+			target("release", {deps = {"maps", "textures"}}, function()
+				output("order.txt", "release\n")
+				local lumps = wad.createLumps()
+				lumps:insert("MAPINFO", "map MAP01 \"Entryway\"")
+				lumps:writewad(dir .. "/release.wad")
+			end)
+			target("maps", {deps = {"textures"}}, function()
+				output("order.txt", "maps\n")
+				output("maps.txt", "MAP01")
+			end)
+			target("textures", function()
+				output("order.txt", "textures\n")
+				output("textures.txt", "SKY1")
+			end)`,
+		"cycle.lua": `
+			target("maps", {deps = {"textures"}}, function() end)
+			target("textures", {deps = {"maps"}}, function() end)`,
+	}
+	for name, script := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0644)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"-f", filepath.Join(dir, "wadmake.lua"), "release"}, 0},
+		{[]string{"-f", filepath.Join(dir, "wadmake.lua"), "missing"}, 1},
+		{[]string{"-f", filepath.Join(dir, "cycle.lua"), "maps"}, 1},
+		{[]string{"-f", filepath.Join(dir, "missing.lua")}, 1},
+		{[]string{"-x"}, 2},
+	}
+
+	for _, test := range tests {
+		env := wadmake.NewLuaEnvironment()
+		env.PushString(dir)
+		env.SetGlobal("dir")
+
+		if code := buildCommand(env, test.args, false); code != test.code {
+			t.Errorf("%q: incorrect exit code %d", test.args, code)
+		}
+	}
+
+	outputs := map[string]string{
+		"order.txt":    "textures\nmaps\nrelease\n",
+		"maps.txt":     "MAP01",
+		"textures.txt": "SKY1",
+	}
+	for name, expected := range outputs {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%s: %s", name, err.Error())
+		} else if string(data) != expected {
+			t.Errorf("%s: incorrect output %q", name, data)
+		}
+	}
+
+	file, err := os.Open(filepath.Join(dir, "release.wad"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer file.Close()
+
+	wad, err := wadmake.Decode(file)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(wad.Lumps) != 1 || wad.Lumps[0].Name != "MAPINFO" {
+		t.Errorf("incorrect release lumps %v", wad.Lumps)
+	}
+}
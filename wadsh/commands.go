@@ -0,0 +1,555 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/AlexMax/wadmake"
+)
+
+// Subcommands that work on WAD and ZIP files without a script.
+var commands = map[string]func(args []string) int{
+	"add":     addCommand,
+	"convert": convertCommand,
+	"extract": extractCommand,
+	"info":    infoCommand,
+	"list":    listCommand,
+	"remove":  removeCommand,
+}
+
+// archive is a WAD or ZIP file read by a subcommand.  The lumps of a
+// ZIP are named by the path of each file.
+type archive struct {
+	zip     bool
+	wadType wadmake.WadType
+	lumps   wadmake.Directory
+}
+
+// Returns the type of the archive the way readwad names it.
+func (a *archive) typeName() string {
+	if a.zip {
+		return "zip"
+	} else if a.wadType == wadmake.WadTypeIWAD {
+		return "iwad"
+	}
+	return "pwad"
+}
+
+// Returns true if the passed file name has the extension of a ZIP.
+func isZipName(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".zip", ".pk3", ".ipk3", ".pke":
+		return true
+	}
+	return false
+}
+
+// Reads a WAD or ZIP file, telling them apart by their contents.
+func readArchive(filename string) (*archive, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, []byte("PK")) {
+		lumps, err := wadmake.DecodeZip(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err.Error())
+		}
+		return &archive{zip: true, lumps: lumps}, nil
+	}
+
+	wad, err := wadmake.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err.Error())
+	}
+	return &archive{wadType: wad.WadType, lumps: wad.Lumps}, nil
+}
+
+// Writes an archive in the format it was read in.
+func writeArchive(filename string, a *archive) error {
+	var buffer bytes.Buffer
+	var err error
+	if a.zip {
+		err = wadmake.EncodeZip(&buffer, a.lumps)
+	} else {
+		err = wadmake.Encode(&buffer, &wadmake.Wad{WadType: a.wadType, Lumps: a.lumps})
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err.Error())
+	}
+
+	return ioutil.WriteFile(filename, buffer.Bytes(), 0666)
+}
+
+// Returns the index of the first lump with the passed name, ignoring
+// case, or -1 if there is none.
+func findLump(lumps wadmake.Directory, name string) int {
+	for i, lump := range lumps {
+		if strings.EqualFold(lump.Name, name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Parses flags that may come before, between or after positional
+// arguments, which the flag package alone does not allow.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Creates the flags of a subcommand with the passed usage line.
+func commandFlags(name string, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: wadsh %s %s\n", name, usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// Prints an error and returns the exit code of a failed subcommand.
+func commandError(err error) int {
+	fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+	return 1
+}
+
+// Prints a value as indented JSON.
+func printJSON(value interface{}) int {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return commandError(err)
+	}
+
+	fmt.Printf("%s\n", data)
+	return 0
+}
+
+// lumpEntry is a lump as listed by the list subcommand.
+type lumpEntry struct {
+	Index     int    `json:"index"`
+	Name      string `json:"name"`
+	Size      int    `json:"size"`
+	Type      string `json:"type"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Lists the lumps of a WAD or the files of a ZIP.
+func listCommand(args []string) int {
+	flags := commandFlags("list", "[-json] [-game name] file")
+	asJSON := flags.Bool("json", false, "print the list as JSON")
+	gameName := flags.String("game", "doom", "the game to detect lump types for")
+
+	files, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	} else if len(files) != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	game, ok := wadmake.LookupGame(*gameName)
+	if !ok {
+		return commandError(fmt.Errorf("unknown game %s", *gameName))
+	}
+
+	a, err := readArchive(files[0])
+	if err != nil {
+		return commandError(err)
+	}
+
+	// Files in a ZIP have no place in a directory to detect types by
+	contexts := make([]wadmake.DetectContext, len(a.lumps))
+	for i := range contexts {
+		contexts[i].Game = game
+	}
+	if !a.zip {
+		contexts = wadmake.LumpContexts(a.lumps, game)
+	}
+
+	entries := make([]lumpEntry, len(a.lumps))
+	for i, lump := range a.lumps {
+		name := lump.Name
+		if a.zip {
+			name = path.Base(name)
+			if dot := strings.IndexByte(name, '.'); dot != -1 {
+				name = name[:dot]
+			}
+		}
+
+		kind := wadmake.DetectType(name, lump.Data, contexts[i])
+		entries[i] = lumpEntry{i + 1, lump.Name, len(lump.Data), kind.String(), contexts[i].Namespace}
+	}
+
+	if *asJSON {
+		return printJSON(entries)
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%5d  %-8s  %10d  %s", entry.Index, entry.Name, entry.Size, entry.Type)
+		if entry.Namespace != "" {
+			fmt.Printf(" (%s)", entry.Namespace)
+		}
+		fmt.Print("\n")
+	}
+	return 0
+}
+
+// Characters of lump names that cannot be part of a file name, because
+// they would separate directories or name a drive.
+var extractReplacer = strings.NewReplacer("\\", "^", "/", "^", ":", "^")
+
+// Returns the name of the file a lump is extracted to, which is never a
+// path outside of the directory it is extracted to.
+func extractName(a *archive, name string) string {
+	if !a.zip {
+		return extractReplacer.Replace(name) + ".lmp"
+	}
+
+	name = path.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == ".." || name == "/" {
+		name = strings.Replace(name, ".", "^", -1)
+	}
+	return extractReplacer.Replace(name)
+}
+
+// Extracts lumps by name to files, or to stdout if the output is -.
+func extractCommand(args []string) int {
+	flags := commandFlags("extract", "[-o output] file lump...")
+	output := flags.String("o", "", "the file to extract a single lump to, or - for stdout, or the directory to extract several lumps to")
+
+	files, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	} else if len(files) < 2 {
+		flags.Usage()
+		return 2
+	}
+
+	a, err := readArchive(files[0])
+	if err != nil {
+		return commandError(err)
+	}
+
+	names := files[1:]
+	dir := *output
+	if len(names) > 1 && dir == "" {
+		dir = "."
+	}
+
+	// Lumps that would overwrite each other are refused before writing
+	indexes := []int{}
+	filenames := map[string]int{}
+	for _, name := range names {
+		index := findLump(a.lumps, name)
+		if index == -1 {
+			return commandError(fmt.Errorf("%s not found in %s", name, files[0]))
+		}
+		if len(names) == 1 {
+			indexes = append(indexes, index)
+			break
+		}
+
+		filename := strings.ToLower(extractName(a, a.lumps[index].Name))
+		if other, ok := filenames[filename]; ok && other == index {
+			continue
+		} else if ok {
+			return commandError(fmt.Errorf("%s and %s would both be extracted to %s",
+				a.lumps[other].Name, a.lumps[index].Name, extractName(a, a.lumps[index].Name)))
+		}
+		filenames[filename] = index
+		indexes = append(indexes, index)
+	}
+
+	for _, index := range indexes {
+		lump := a.lumps[index]
+
+		filename := *output
+		if len(names) > 1 {
+			filename = filepath.Join(dir, extractName(a, lump.Name))
+		} else if filename == "" {
+			filename = extractName(a, lump.Name)
+		}
+
+		if filename == "-" {
+			os.Stdout.Write(lump.Data)
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(filename), 0777)
+		if err == nil {
+			err = ioutil.WriteFile(filename, lump.Data, 0666)
+		}
+		if err != nil {
+			return commandError(err)
+		}
+	}
+
+	return 0
+}
+
+// Adds files as lumps, replacing lumps of the same name, and creates
+// the WAD or ZIP if it does not exist yet.  Lumps of a WAD are named
+// after the file name without extension, and files of a ZIP are stored
+// under the path they were passed with.
+func addCommand(args []string) int {
+	flags := commandFlags("add", "[-o output] [-n name] file input...")
+	output := flags.String("o", "", "the file to write instead of changing the input file")
+	name := flags.String("n", "", "the lump name of a single input")
+
+	files, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	} else if len(files) < 2 || *name != "" && len(files) != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	a, err := readArchive(files[0])
+	if os.IsNotExist(err) {
+		a = &archive{zip: isZipName(files[0]), wadType: wadmake.WadTypePWAD}
+	} else if err != nil {
+		return commandError(err)
+	}
+
+	for _, input := range files[1:] {
+		data, err := ioutil.ReadFile(input)
+		if err != nil {
+			return commandError(err)
+		}
+
+		lumpName := *name
+		if lumpName == "" && a.zip {
+			lumpName = filepath.ToSlash(filepath.Clean(input))
+		} else if lumpName == "" {
+			base := filepath.Base(input)
+			lumpName = strings.ToUpper(strings.TrimSuffix(base, filepath.Ext(base)))
+		}
+		if !a.zip && len(lumpName) > 8 {
+			return commandError(fmt.Errorf("lump name %s is longer than 8 characters", lumpName))
+		}
+
+		if index := findLump(a.lumps, lumpName); index != -1 {
+			a.lumps[index].Data = data
+		} else {
+			a.lumps = append(a.lumps, wadmake.Lump{Name: lumpName, Data: data})
+		}
+	}
+
+	if *output == "" {
+		*output = files[0]
+	}
+	err = writeArchive(*output, a)
+	if err != nil {
+		return commandError(err)
+	}
+
+	return 0
+}
+
+// Removes every lump with the passed names.
+func removeCommand(args []string) int {
+	flags := commandFlags("remove", "[-o output] file lump...")
+	output := flags.String("o", "", "the file to write instead of changing the input file")
+
+	files, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	} else if len(files) < 2 {
+		flags.Usage()
+		return 2
+	}
+
+	a, err := readArchive(files[0])
+	if err != nil {
+		return commandError(err)
+	}
+
+	for _, name := range files[1:] {
+		if findLump(a.lumps, name) == -1 {
+			return commandError(fmt.Errorf("%s not found in %s", name, files[0]))
+		}
+
+		lumps := wadmake.Directory{}
+		for _, lump := range a.lumps {
+			if !strings.EqualFold(lump.Name, name) {
+				lumps = append(lumps, lump)
+			}
+		}
+		a.lumps = lumps
+	}
+
+	if *output == "" {
+		*output = files[0]
+	}
+	err = writeArchive(*output, a)
+	if err != nil {
+		return commandError(err)
+	}
+
+	return 0
+}
+
+// Converts between WAD and ZIP files, telling the output format apart
+// by its extension.
+func convertCommand(args []string) int {
+	flags := commandFlags("convert", "input output")
+
+	files, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	} else if len(files) != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	toZip := isZipName(files[1])
+	if !toZip && strings.ToLower(filepath.Ext(files[1])) != ".wad" {
+		return commandError(errors.New("output must be a .wad, .zip or .pk3 file"))
+	}
+
+	a, err := readArchive(files[0])
+	if err != nil {
+		return commandError(err)
+	}
+
+	if a.zip && !toZip {
+		a.lumps, err = wadmake.ZipToWad(a.lumps)
+		a.wadType = wadmake.WadTypePWAD
+	} else if !a.zip && toZip {
+		a.lumps, err = wadmake.WadToZip(a.lumps)
+	}
+	if err != nil {
+		return commandError(err)
+	}
+	a.zip = toZip
+
+	err = writeArchive(files[1], a)
+	if err != nil {
+		return commandError(err)
+	}
+
+	return 0
+}
+
+// archiveInfo summarizes a WAD or ZIP for the info subcommand.
+type archiveInfo struct {
+	Type       string         `json:"type"`
+	Lumps      int            `json:"lumps"`
+	Size       int            `json:"size"`
+	Maps       []string       `json:"maps"`
+	Namespaces map[string]int `json:"namespaces"`
+}
+
+// Prints the type, size, maps and namespaces of a WAD or ZIP.
+func infoCommand(args []string) int {
+	flags := commandFlags("info", "[-json] file")
+	asJSON := flags.Bool("json", false, "print the summary as JSON")
+
+	files, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	} else if len(files) != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	a, err := readArchive(files[0])
+	if err != nil {
+		return commandError(err)
+	}
+
+	info := archiveInfo{
+		Type:       a.typeName(),
+		Lumps:      len(a.lumps),
+		Maps:       []string{},
+		Namespaces: map[string]int{},
+	}
+	for _, lump := range a.lumps {
+		info.Size += len(lump.Data)
+	}
+
+	// ZIPs keep maps and namespaces in directories
+	if a.zip {
+		for _, lump := range a.lumps {
+			dir, base := path.Split(lump.Name)
+			dir = strings.ToLower(strings.TrimSuffix(dir, "/"))
+			if dir == "maps" && strings.EqualFold(path.Ext(base), ".wad") {
+				info.Maps = append(info.Maps, strings.ToUpper(strings.TrimSuffix(base, path.Ext(base))))
+			} else if dir != "" {
+				info.Namespaces[strings.SplitN(dir, "/", 2)[0]]++
+			}
+		}
+	} else {
+		for _, m := range wadmake.FindMaps(a.lumps) {
+			info.Maps = append(info.Maps, m.Name)
+		}
+		for _, namespace := range wadmake.LumpNamespaces(a.lumps) {
+			if namespace != "" {
+				info.Namespaces[namespace]++
+			}
+		}
+	}
+
+	if *asJSON {
+		return printJSON(info)
+	}
+
+	fmt.Printf("Type: %s\n", info.Type)
+	fmt.Printf("Lumps: %d\n", info.Lumps)
+	fmt.Printf("Size: %d bytes\n", info.Size)
+	fmt.Printf("Maps: %s\n", strings.Join(info.Maps, " "))
+
+	namespaces := []string{}
+	for namespace, count := range info.Namespaces {
+		namespaces = append(namespaces, fmt.Sprintf("%s (%d)", namespace, count))
+	}
+	sort.Strings(namespaces)
+	fmt.Printf("Namespaces: %s\n", strings.Join(namespaces, ", "))
+
+	return 0
+}
@@ -0,0 +1,268 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/AlexMax/wadmake"
+)
+
+// Flags may come before, between or after positional arguments
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		args       []string
+		positional []string
+		output     string
+		asJSON     bool
+	}{
+		{[]string{}, []string{}, "", false},
+		{[]string{"a.wad", "LUMP"}, []string{"a.wad", "LUMP"}, "", false},
+		{[]string{"-o", "out", "a.wad"}, []string{"a.wad"}, "out", false},
+		{[]string{"a.wad", "-o", "out", "LUMP"}, []string{"a.wad", "LUMP"}, "out", false},
+		{[]string{"a.wad", "LUMP", "-json"}, []string{"a.wad", "LUMP"}, "", true},
+		{[]string{"-json", "a.wad", "--", "-o"}, []string{"a.wad", "-o"}, "", true},
+	}
+
+	for _, test := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		output := flags.String("o", "", "")
+		asJSON := flags.Bool("json", false, "")
+
+		positional, err := parseFlags(flags, test.args)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if !reflect.DeepEqual(positional, test.positional) {
+			t.Errorf("%v: incorrect positional arguments %v", test.args, positional)
+		}
+		if *output != test.output || *asJSON != test.asJSON {
+			t.Errorf("%v: incorrect flags %s %v", test.args, *output, *asJSON)
+		}
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	_, err := parseFlags(flags, []string{"a.wad", "-unknown"})
+	if err == nil {
+		t.Error("unknown flag was parsed")
+	}
+}
+
+// Lumps are extracted to files named after them
+func TestExtractName(t *testing.T) {
+	tests := []struct {
+		zip      bool
+		name     string
+		filename string
+	}{
+		{false, "THINGS", "THINGS.lmp"},
+		{false, "VILE\\1", "VILE^1.lmp"},
+		{false, "A\\B\\", "A^B^.lmp"},
+		{true, "decorate.txt", "decorate.txt"},
+		{true, "sprites/vile/vilea1.png", "vilea1.png"},
+		{false, "../../ab", "..^..^ab.lmp"},
+		{false, "/ab", "^ab.lmp"},
+		{false, "C:AB", "C^AB.lmp"},
+		{false, "..", "...lmp"},
+		{true, "../../ab", "ab"},
+		{true, "a\\..\\..\\ab", "ab"},
+		{true, "C:ab", "C^ab"},
+		{true, "a/..", "^^"},
+		{true, "/", "^"},
+	}
+
+	for _, test := range tests {
+		filename := extractName(&archive{zip: test.zip}, test.name)
+		if filename != test.filename {
+			t.Errorf("%s: incorrect file name %s", test.name, filename)
+		}
+	}
+}
+
+// Creates a directory with test.wad and test.zip holding the passed lumps
+func commandTestDir(t *testing.T, wadLumps wadmake.Directory, zipLumps wadmake.Directory) string {
+	dir, err := ioutil.TempDir("", "wadsh")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	archives := []*archive{
+		{wadType: wadmake.WadTypePWAD, lumps: wadLumps},
+		{zip: true, lumps: zipLumps},
+	}
+	for _, a := range archives {
+		filename := filepath.Join(dir, "test.wad")
+		if a.zip {
+			filename = filepath.Join(dir, "test.zip")
+		}
+
+		err = writeArchive(filename, a)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	return dir
+}
+
+// Extracting several lumps refuses to overwrite one with another
+func TestExtractCommand(t *testing.T) {
+	dir := commandTestDir(t, wadmake.Directory{
+		{Name: "THINGS", Data: []byte("first")},
+		{Name: "THINGS", Data: []byte("second")},
+		{Name: "../../ab", Data: []byte("hostile")},
+	}, wadmake.Directory{
+		{Name: "things", Data: []byte("first")},
+		{Name: "a/dup.txt", Data: []byte("a")},
+		{Name: "b/dup.txt", Data: []byte("b")},
+	})
+	defer os.RemoveAll(dir)
+
+	wad := filepath.Join(dir, "test.wad")
+	zip := filepath.Join(dir, "test.zip")
+	out := filepath.Join(dir, "out")
+
+	tests := []struct {
+		args  []string
+		code  int
+		files map[string]string
+	}{
+		{[]string{wad}, 2, nil},
+		{[]string{wad, "MISSING", "-o", out}, 1, nil},
+		{[]string{wad, "THINGS", "-o", filepath.Join(out, "x.lmp")}, 0, map[string]string{"x.lmp": "first"}},
+		{[]string{wad, "THINGS", "things", "-o", out}, 0, map[string]string{"THINGS.lmp": "first"}},
+		{[]string{wad, "../../ab", "THINGS", "-o", out}, 0, map[string]string{"..^..^ab.lmp": "hostile", "THINGS.lmp": "first"}},
+		{[]string{zip, "a/dup.txt", "b/dup.txt", "-o", out}, 1, map[string]string{}},
+		{[]string{zip, "a/dup.txt", "things", "-o", out}, 0, map[string]string{"dup.txt": "a", "things": "first"}},
+	}
+
+	for _, test := range tests {
+		os.RemoveAll(out)
+		code := extractCommand(test.args)
+		if code != test.code {
+			t.Errorf("%v: incorrect exit code %d", test.args, code)
+		}
+
+		files, _ := ioutil.ReadDir(out)
+		if test.files == nil {
+			continue
+		} else if len(files) != len(test.files) {
+			t.Errorf("%v: incorrect number of files %d", test.args, len(files))
+		}
+		for name, expected := range test.files {
+			data, err := ioutil.ReadFile(filepath.Join(out, name))
+			if err != nil || string(data) != expected {
+				t.Errorf("%v: incorrect %s %q", test.args, name, data)
+			}
+		}
+	}
+
+	// Hostile lump names are never written outside of the output
+	if _, err := os.Stat(filepath.Join(dir, "..", "ab.lmp")); err == nil {
+		t.Error("lump was extracted outside of the output directory")
+	}
+}
+
+// Adding and removing lumps round trips through WADs and ZIPs
+func TestAddRemoveCommand(t *testing.T) {
+	lumps := wadmake.Directory{{Name: "MAPINFO", Data: []byte("mapinfo")}}
+	dir := commandTestDir(t, lumps, lumps)
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "decorate.txt")
+	err := ioutil.WriteFile(input, []byte("decorate"), 0666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		filename string
+		name     string
+	}{
+		{"test.wad", "DECORATE"},
+		{"test.zip", filepath.ToSlash(filepath.Clean(input))},
+		{"new.wad", "DECORATE"},
+		{"new.pk3", filepath.ToSlash(filepath.Clean(input))},
+	}
+
+	for _, test := range tests {
+		filename := filepath.Join(dir, test.filename)
+		_, err := os.Stat(filename)
+		existed := err == nil
+
+		// Adding the same file twice replaces the lump
+		for i := 0; i < 2; i++ {
+			if code := addCommand([]string{filename, input}); code != 0 {
+				t.Fatalf("%s: incorrect add exit code %d", test.filename, code)
+			}
+		}
+
+		a, err := readArchive(filename)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if a.zip != isZipName(filename) {
+			t.Errorf("%s: incorrect archive type %s", test.filename, a.typeName())
+		}
+
+		index := findLump(a.lumps, test.name)
+		if index == -1 || string(a.lumps[index].Data) != "decorate" {
+			t.Errorf("%s: %s was not added", test.filename, test.name)
+		}
+		if existed && len(a.lumps) != 2 || !existed && len(a.lumps) != 1 {
+			t.Errorf("%s: incorrect number of lumps %d", test.filename, len(a.lumps))
+		}
+
+		if code := removeCommand([]string{filename, test.name}); code != 0 {
+			t.Fatalf("%s: incorrect remove exit code %d", test.filename, code)
+		}
+		if code := removeCommand([]string{filename, test.name}); code != 1 {
+			t.Errorf("%s: removed missing lump", test.filename)
+		}
+
+		a, err = readArchive(filename)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if findLump(a.lumps, test.name) != -1 {
+			t.Errorf("%s: %s was not removed", test.filename, test.name)
+		}
+		if existed && (len(a.lumps) != 1 || a.lumps[0].Name != "MAPINFO") {
+			t.Errorf("%s: incorrect remaining lumps %v", test.filename, a.lumps)
+		}
+	}
+
+	// Lump names of a WAD are limited to 8 characters
+	long := filepath.Join(dir, "toolongname.txt")
+	err = ioutil.WriteFile(long, []byte{}, 0666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if code := addCommand([]string{filepath.Join(dir, "test.wad"), long}); code != 1 {
+		t.Errorf("incorrect long name exit code %d", code)
+	}
+}
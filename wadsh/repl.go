@@ -0,0 +1,89 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shopify/go-lua"
+)
+
+// The file in the home directory that history is saved to.
+const historyFileName = ".wadsh_history"
+
+// Returns the path of the history file, or an empty string to keep no
+// history if there is no home directory.
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, historyFileName)
+}
+
+// Loads a chunk typed into the shell.  The chunk is first tried as an
+// expression, so that its values can be printed.  Returns false if the
+// chunk is incomplete and more lines should be read first, like the
+// standalone Lua interpreter does.
+func loadLine(env *lua.State, chunk string) (bool, error) {
+	err := lua.LoadBuffer(env, "return "+chunk, "=stdin", "")
+	if err == nil {
+		return true, nil
+	}
+	env.Pop(1)
+
+	err = lua.LoadBuffer(env, chunk, "=stdin", "")
+	if err != nil {
+		message, _ := env.ToString(-1)
+		if strings.HasSuffix(message, "<eof>") {
+			env.Pop(1)
+			return false, nil
+		}
+	}
+
+	return true, err
+}
+
+// Runs a chunk typed into the shell and prints any values it returns.
+// Returns false without running anything if the chunk is incomplete.
+func runLine(env *lua.State, chunk string) bool {
+	complete, err := loadLine(env, chunk)
+	if !complete {
+		return false
+	}
+
+	if err == nil {
+		err = env.ProtectedCall(0, lua.MultipleReturns, 0)
+	}
+	if err == nil && env.Top() > 0 {
+		env.Global("print")
+		env.Insert(1)
+		err = env.ProtectedCall(env.Top()-1, 0, 0)
+	}
+
+	if err != nil {
+		printError(env, err)
+	}
+	env.SetTop(0)
+	return true
+}
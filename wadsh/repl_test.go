@@ -0,0 +1,117 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/go-lua"
+)
+
+// Creates a Lua state whose print collects its arguments
+func replTestState(printed *[]string) *lua.State {
+	env := lua.NewState()
+	lua.OpenLibraries(env)
+	env.Register("print", func(l *lua.State) int {
+		for i := 1; i <= l.Top(); i++ {
+			s, _ := lua.ToStringMeta(l, i)
+			*printed = append(*printed, s)
+			l.Pop(1)
+		}
+		return 0
+	})
+
+	return env
+}
+
+// Chunks are loaded as expressions first, and incomplete chunks wait
+// for more lines
+func TestLoadLine(t *testing.T) {
+	tests := []struct {
+		chunk    string
+		complete bool
+		valid    bool
+	}{
+		{"1 + 2", true, true},
+		{"x = 1", true, true},
+		{"function f()", false, true},
+		{"function f()\n  return 1", false, true},
+		{"function f()\n  return 1\nend", true, true},
+		{"print(", false, true},
+		{"if true then", false, true},
+		{"x = = 1", true, false},
+		{"1 +* 2", true, false},
+		{"end", true, false},
+		{"x = )", true, false},
+	}
+
+	for _, test := range tests {
+		printed := []string{}
+		env := replTestState(&printed)
+
+		complete, err := loadLine(env, test.chunk)
+		if complete != test.complete {
+			t.Errorf("%q: incorrect completeness %v", test.chunk, complete)
+		}
+		if test.complete && (err == nil) != test.valid {
+			t.Errorf("%q: incorrect error %v", test.chunk, err)
+		}
+		if !complete && env.Top() != 0 {
+			t.Errorf("%q: incomplete chunk left %d values", test.chunk, env.Top())
+		}
+	}
+}
+
+// Values of expressions are printed and statements print nothing
+func TestRunLine(t *testing.T) {
+	printed := []string{}
+	env := replTestState(&printed)
+
+	tests := []struct {
+		chunk    string
+		complete bool
+		printed  []string
+	}{
+		{"1 + 2", true, []string{"3"}},
+		{"x = 5", true, []string{}},
+		{"x, 'a', nil", true, []string{"5", "a", "nil"}},
+		{"function f(n)", false, []string{}},
+		{"function f(n)\n  return n * 2\nend", true, []string{}},
+		{"f(x)", true, []string{"10"}},
+		{"x = = 1", true, []string{}},
+		{"error('failed')", true, []string{}},
+		{"x", true, []string{"5"}},
+	}
+
+	for _, test := range tests {
+		printed = printed[:0]
+		complete := runLine(env, test.chunk)
+		if complete != test.complete {
+			t.Errorf("%q: incorrect completeness %v", test.chunk, complete)
+		}
+		if !reflect.DeepEqual(printed, test.printed) {
+			t.Errorf("%q: incorrect output %v", test.chunk, printed)
+		}
+		if env.Top() != 0 {
+			t.Errorf("%q: left %d values", test.chunk, env.Top())
+		}
+	}
+}
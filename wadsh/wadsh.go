@@ -23,58 +23,277 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/AlexMax/wadmake"
 	"github.com/Shopify/go-lua"
 	"github.com/chzyer/readline"
 )
 
-func main() {
-	env := wadmake.NewLuaEnvironment()
+// Prints the error of a failed Lua call, preferring the message left
+// on the stack by Lua.
+func printError(env *lua.State, err error) {
+	errString, ok := env.ToString(-1)
+	if ok {
+		fmt.Fprintf(os.Stderr, "%s\n", errString)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+	}
+	env.SetTop(0)
+}
 
-	if len(os.Args) > 1 {
-		if os.Args[1] == "-" {
-			// First parameter is -, read script from stdin.
-		} else {
-			// Read scripts in as filenames.
-			err := lua.DoFile(env, os.Args[1])
-			if err != nil {
+// Sets the global arg table the way the standalone Lua interpreter
+// does, with the script name at index 0, the arguments after it at
+// positive indexes and the interpreter itself at negative ones.
+func setArgs(env *lua.State, script int) {
+	env.CreateTable(len(os.Args)-script-1, script+1)
+	for i, value := range os.Args {
+		env.PushString(value)
+		env.RawSetInt(-2, i-script)
+	}
+	env.SetGlobal("arg")
+}
+
+// Makes conversions use the build cache of the passed project
+// directory, removing the cache first if it should be cleaned.  A
+// sandboxed shell only uses a cache in one of its output directories.
+// Returns false if the cache could not be removed.
+func useCache(env *lua.State, dir string, clean bool) bool {
+	dir = filepath.Join(dir, wadmake.CacheDirName)
+	if sandbox := wadmake.LuaSandbox(env); sandbox != nil {
+		path, err := sandbox.CheckWrite(dir)
+		if err != nil {
+			if clean {
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-				os.Exit(1)
+				return false
+			}
+			return true
+		}
+		dir = path
+	}
+
+	cache := wadmake.NewCache(dir)
+	if clean {
+		err := cache.Clean()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return false
+		}
+	}
+
+	wadmake.SetLuaCache(env, cache)
+	return true
+}
+
+// Runs the script named by the command-line argument at the passed
+// index, reading it from stdin if the name is -, with the arguments
+// after it passed both in arg and as the varargs of the script.  The
+// build cache lives next to the script, or in the current directory
+// for stdin.  Returns the exit code of the shell.
+func runScript(env *lua.State, script int, clean bool) int {
+	setArgs(env, script)
+
+	name, dir := os.Args[script], filepath.Dir(os.Args[script])
+	if name == "-" {
+		// An empty file name makes Lua read stdin
+		name, dir = "", "."
+	}
+
+	if !useCache(env, dir, clean) {
+		return 1
+	}
+
+	err := lua.LoadFile(env, name, "")
+	if err == nil {
+		args := os.Args[script+1:]
+		for _, arg := range args {
+			env.PushString(arg)
+		}
+		err = env.ProtectedCall(len(args), 0, 0)
+	}
+
+	if err != nil {
+		printError(env, err)
+		return 1
+	}
+	return 0
+}
+
+// completer completes global names, fields of tables and methods of
+// userdata against the live Lua state of the shell.
+type completer struct {
+	env *lua.State
+}
+
+// Do returns the rest of every name that completes the one being typed
+// before the cursor, along with the length of what was already typed.
+func (c *completer) Do(line []rune, pos int) ([][]rune, int) {
+	names, prefix := wadmake.LuaCompletions(c.env, string(line[:pos]))
+
+	suffixes := make([][]rune, len(names))
+	for i, name := range names {
+		suffixes[i] = []rune(name[len(prefix):])
+	}
+	return suffixes, len([]rune(prefix))
+}
+
+// Options of the shell, which come before the script name or
+// subcommand.
+type options struct {
+	clean   bool
+	sandbox string
+	outputs []string
+	execute bool
+}
+
+// Parses the options of the shell, returning them along with the index
+// of the first command-line argument that is not an option.
+func parseOptions() (options, int, error) {
+	opts, first := options{}, 1
+	for ; first < len(os.Args); first++ {
+		switch os.Args[first] {
+		case "--clean":
+			opts.clean = true
+		case "--allow-execute":
+			opts.execute = true
+		case "--sandbox", "--output":
+			if first+1 == len(os.Args) {
+				return opts, first, fmt.Errorf("%s requires a directory", os.Args[first])
 			}
-			os.Exit(0)
+
+			if os.Args[first] == "--sandbox" {
+				opts.sandbox = os.Args[first+1]
+			} else {
+				opts.outputs = append(opts.outputs, os.Args[first+1])
+			}
+			first++
+		default:
+			return opts, first, nil
+		}
+	}
+
+	return opts, first, nil
+}
+
+// Creates the Lua environment of the shell, which is confined to the
+// project root passed with --sandbox if there is one.
+func newEnvironment(opts options) (*lua.State, error) {
+	if opts.sandbox == "" {
+		if len(opts.outputs) > 0 || opts.execute {
+			return nil, fmt.Errorf("--output and --allow-execute require --sandbox")
 		}
+		return wadmake.NewLuaEnvironment(), nil
+	}
+
+	sandbox, err := wadmake.NewSandbox(opts.sandbox, opts.outputs, opts.execute)
+	if err != nil {
+		return nil, err
+	}
+	return wadmake.NewSandboxedLuaEnvironment(sandbox), nil
+}
+
+func main() {
+	opts, first, err := parseOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(2)
 	}
 
-	rl, err := readline.New("> ")
+	env, err := newEnvironment(opts)
 	if err != nil {
-		fmt.Fprint(os.Stderr, err.Error)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(2)
+	}
+
+	os.Exit(run(env, opts, first))
+}
+
+// Runs the shell with the command-line arguments starting at the passed
+// index.  Returns the exit code of the shell.
+func run(env *lua.State, opts options, first int) int {
+	// --clean removes the build cache before running anything, or on
+	// its own removes the cache of the current directory.
+	clean := opts.clean
+	if clean && len(os.Args) == first {
+		if !useCache(env, ".", true) {
+			return 1
+		}
+		return 0
+	}
+
+	if len(os.Args) > first {
+		if os.Args[first] == "build" {
+			return buildCommand(env, os.Args[first+1:], clean)
+		} else if command, ok := commands[os.Args[first]]; ok {
+			// Subcommands access files directly rather than through
+			// the Lua environment, so the sandbox cannot confine them.
+			if opts.sandbox != "" {
+				fmt.Fprintf(os.Stderr, "--sandbox cannot be used with the %s subcommand\n", os.Args[first])
+				return 2
+			}
+			return command(os.Args[first+1:])
+		}
+
+		// The first parameter is a script file name, or - to read the
+		// script from stdin.
+		return runScript(env, first, clean)
+	}
+
+	// help is only a global in the shell, so that scripts do not come
+	// to rely on it.
+	env.Global("wad")
+	env.Field(-1, "help")
+	env.SetGlobal("help")
+	env.Pop(1)
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:       "> ",
+		AutoComplete: &completer{env},
+		HistoryFile:  historyFile(),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return 1
 	}
 	defer rl.Close()
 
 	fmt.Fprint(os.Stderr, "WADmake shell\n")
-	fmt.Fprint(os.Stderr, "Press Ctrl-C to quit the shell.\n")
+	fmt.Fprint(os.Stderr, "Type help() for a list of functions, or help(wad.readwad) for one.\n")
+	fmt.Fprint(os.Stderr, "Press Tab to complete names.\n")
+	fmt.Fprint(os.Stderr, "Press Ctrl-C to discard input, or to quit the shell on an empty line.\n")
 	fmt.Fprint(os.Stderr, "Press Ctrl-D on an empty line to quit the shell.\n")
 
+	// Lines are collected until they form a complete chunk
+	chunk := ""
 	for {
 		line, err := rl.Readline()
-		if err != nil {
-			if err == io.EOF {
+		if err == readline.ErrInterrupt {
+			if chunk == "" && line == "" {
 				break
 			}
 
+			chunk = ""
+			rl.SetPrompt("> ")
+			continue
+		} else if err == io.EOF {
+			break
+		} else if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			continue
 		}
 
-		err = lua.DoString(env, line)
-		if err != nil {
-			errString, ok := env.ToString(-1)
-			if ok {
-				fmt.Fprintf(os.Stderr, "%s\n", errString)
-			} else {
-				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-			}
+		if chunk != "" {
+			chunk += "\n"
+		}
+		chunk += line
+
+		if runLine(env, chunk) {
+			chunk = ""
+			rl.SetPrompt("> ")
+		} else {
+			rl.SetPrompt(">> ")
 		}
 	}
+
+	return 0
 }
@@ -0,0 +1,243 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/AlexMax/wadmake"
+	"github.com/Shopify/go-lua"
+)
+
+// Replaces os.Args for the duration of a test
+func scriptTestArgs(args ...string) func() {
+	saved := os.Args
+	os.Args = args
+	return func() { os.Args = saved }
+}
+
+// Returns the string values of a global table from index first to last
+func scriptTestTable(env *lua.State, name string, first int, last int) []string {
+	values := []string{}
+	env.Global(name)
+	for i := first; i <= last; i++ {
+		env.RawGetInt(-1, i)
+		value, _ := env.ToString(-1)
+		values = append(values, value)
+		env.Pop(1)
+	}
+	env.Pop(1)
+	return values
+}
+
+// The arg table holds the script name at 0, the arguments after it at
+// positive indexes and the shell and its options at negative ones
+func TestSetArgs(t *testing.T) {
+	defer scriptTestArgs("wadsh", "--clean", "build.lua", "one", "two")()
+
+	env := lua.NewState()
+	setArgs(env, 2)
+
+	values := scriptTestTable(env, "arg", -2, 2)
+	expected := []string{"wadsh", "--clean", "build.lua", "one", "two"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("incorrect arg table %q", values)
+	}
+
+	env.Global("arg")
+	env.RawGetInt(-1, 3)
+	if !env.IsNil(-1) {
+		t.Errorf("arg table continues past the arguments")
+	}
+	env.RawGetInt(-2, -3)
+	if !env.IsNil(-1) {
+		t.Errorf("arg table continues before the shell")
+	}
+}
+
+// Scripts get their arguments both in arg and as varargs, and a failing
+// script makes the shell exit with an error
+func TestRunScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wadsh")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	scripts := map[string]string{
+		"args.lua":   "name, first, count = arg[0], arg[1], #arg\nvarargs = {...}",
+		"error.lua":  "error('script failed')",
+		"syntax.lua": "x = = 1",
+	}
+	for name, script := range scripts {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0644)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	script := filepath.Join(dir, "args.lua")
+	defer scriptTestArgs("wadsh", script, "one", "two")()
+	env := lua.NewState()
+	lua.OpenLibraries(env)
+	if code := runScript(env, 1, false); code != 0 {
+		t.Fatalf("incorrect exit code %d", code)
+	}
+
+	globals := []string{}
+	for _, name := range []string{"name", "first", "count"} {
+		env.Global(name)
+		value, _ := env.ToString(-1)
+		globals = append(globals, value)
+		env.Pop(1)
+	}
+	if !reflect.DeepEqual(globals, []string{script, "one", "2"}) {
+		t.Errorf("incorrect arguments %q", globals)
+	}
+	if varargs := scriptTestTable(env, "varargs", 1, 2); !reflect.DeepEqual(varargs, []string{"one", "two"}) {
+		t.Errorf("incorrect varargs %q", varargs)
+	}
+
+	for _, name := range []string{"error.lua", "syntax.lua", "missing.lua"} {
+		os.Args = []string{"wadsh", filepath.Join(dir, name)}
+		env := lua.NewState()
+		lua.OpenLibraries(env)
+		if code := runScript(env, 1, false); code != 1 {
+			t.Errorf("%s: incorrect exit code %d", name, code)
+		}
+	}
+}
+
+// A script name of - reads the script from stdin
+func TestRunScriptStdin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wadsh")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// The cache of a script from stdin is in the current directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Chdir(wd)
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		script string
+		code   int
+	}{
+		{"name, varargs = arg[0], {...}", 0},
+		{"error('script failed')", 1},
+	}
+
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	for _, test := range tests {
+		err = ioutil.WriteFile("stdin.lua", []byte(test.script), 0644)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		file, err := os.Open("stdin.lua")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		os.Stdin = file
+
+		restore := scriptTestArgs("wadsh", "-", "one")
+		env := lua.NewState()
+		lua.OpenLibraries(env)
+		code := runScript(env, 1, false)
+		restore()
+		file.Close()
+
+		if code != test.code {
+			t.Errorf("%q: incorrect exit code %d", test.script, code)
+		}
+		if test.code != 0 {
+			continue
+		}
+
+		env.Global("name")
+		if name, _ := env.ToString(-1); name != "-" {
+			t.Errorf("%q: incorrect script name %q", test.script, name)
+		}
+		env.Pop(1)
+		if varargs := scriptTestTable(env, "varargs", 1, 1); !reflect.DeepEqual(varargs, []string{"one"}) {
+			t.Errorf("%q: incorrect varargs %q", test.script, varargs)
+		}
+	}
+}
+
+// Returns true if the environment has a build cache
+func scriptTestCached(env *lua.State) bool {
+	err := lua.DoString(env, "return wad.getcache()")
+	if err != nil {
+		return false
+	}
+	defer env.Pop(1)
+	return !env.IsNil(-1)
+}
+
+// A sandboxed shell only keeps its cache in an output directory
+func TestUseCacheSandbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wadsh")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		outputs []string
+		cached  bool
+	}{
+		{nil, false},
+		{[]string{filepath.Join(dir, "out")}, false},
+		{[]string{dir}, true},
+	}
+
+	for _, test := range tests {
+		sandbox, err := wadmake.NewSandbox(dir, test.outputs, false)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		env := wadmake.NewSandboxedLuaEnvironment(sandbox)
+
+		if !useCache(env, dir, false) {
+			t.Errorf("%q: could not use the cache", test.outputs)
+		}
+		if cached := scriptTestCached(env); cached != test.cached {
+			t.Errorf("%q: incorrect use of the cache %v", test.outputs, cached)
+		}
+
+		// Cleaning a cache that may not be written is an error
+		if useCache(env, dir, true) != test.cached {
+			t.Errorf("%q: incorrect result of cleaning the cache", test.outputs)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// DecodeZip decodes ZIP file data into a Directory of files named by
+// their path.  Directories themselves are skipped.
+func DecodeZip(r io.ReaderAt, size int64) (Directory, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := Directory{}
+	for _, file := range reader.File {
+		if strings.HasSuffix(file.Name, "/") {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Name, err.Error())
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Name, err.Error())
+		}
+
+		dir = append(dir, Lump{Name: file.Name, Data: data})
+	}
+
+	return dir, nil
+}
+
+// EncodeZip encodes a Directory into ZIP file data, using lump names as
+// paths.  Files carry no modification time, so that the same lumps
+// always encode to the same data.
+func EncodeZip(w io.Writer, dir Directory) error {
+	writer := zip.NewWriter(w)
+	for _, lump := range dir {
+		file, err := writer.CreateHeader(&zip.FileHeader{
+			Name:   lump.Name,
+			Method: zip.Deflate,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = file.Write(lump.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// Returns the file name a lump is stored under in a ZIP, with the
+// passed extension.  Backslashes are not allowed in paths, so they are
+// stored as carets like ZDoom expects.
+func lumpFileName(name string, extension string) string {
+	return strings.Replace(name, "\\", "^", -1) + extension
+}
+
+// Returns the lump name a file in a ZIP is loaded as, which is its file
+// name without extension, in upper case.
+func fileLumpName(filename string) (string, error) {
+	name := path.Base(filename)
+	if dot := strings.IndexByte(name, '.'); dot != -1 {
+		name = name[:dot]
+	}
+	name = strings.ToUpper(strings.Replace(name, "^", "\\", -1))
+
+	if name == "" {
+		return "", fmt.Errorf("file %s has no lump name", filename)
+	} else if len(name) > 8 {
+		return "", fmt.Errorf("file %s: lump name %s is longer than 8 characters", filename, name)
+	}
+	return name, nil
+}
+
+// WadToZip moves the lumps of a WAD into the files a ZIP holds them in.
+// Lumps in a namespace go to the directory of the namespace, maps
+// become WAD files in the maps directory and every other lump stays at
+// the root.  Markers are dropped, as directories take their place.
+func WadToZip(dir Directory) (Directory, error) {
+	namespaces := LumpNamespaces(dir)
+
+	maps := map[int]WadMap{}
+	for _, m := range FindMaps(dir) {
+		maps[m.Index] = m
+	}
+
+	zipDir := Directory{}
+	paths := map[string]bool{}
+	for i := 0; i < len(dir); i++ {
+		lump := dir[i]
+
+		var filename string
+		var data []byte
+		if m, ok := maps[i]; ok && namespaces[i] == "" {
+			wad := NewWad(WadTypePWAD)
+			wad.Lumps = dir[m.Index : m.Index+m.Count]
+
+			var buffer bytes.Buffer
+			err := Encode(&buffer, wad)
+			if err != nil {
+				return nil, fmt.Errorf("map %s: %s", m.Name, err.Error())
+			}
+
+			filename, data = "maps/"+lumpFileName(m.Name, ".wad"), buffer.Bytes()
+			i += m.Count - 1
+		} else if IsMarker(lump.Name) && (namespaces[i] != "" || isNamespaceMarker(lump.Name)) {
+			continue
+		} else if namespaces[i] != "" {
+			filename, data = namespaces[i]+"/"+lumpFileName(lump.Name, ".lmp"), lump.Data
+		} else {
+			filename, data = lumpFileName(lump.Name, ".lmp"), lump.Data
+		}
+
+		if paths[filename] {
+			return nil, fmt.Errorf("lump %s would be stored in %s twice", lump.Name, filename)
+		}
+		paths[filename] = true
+
+		zipDir = append(zipDir, Lump{Name: filename, Data: data})
+	}
+
+	return zipDir, nil
+}
+
+// Returns true if the passed lump name starts or ends a namespace.
+func isNamespaceMarker(name string) bool {
+	_, _, ok := lookupMarker(name)
+	return ok
+}
+
+// ZipToWad moves the files of a ZIP into the lumps of a WAD, the
+// inverse of WadToZip.  Files at the root and maps are placed first in
+// order, followed by every namespace between its markers.  Files in
+// directories that have no namespace cannot be placed.
+func ZipToWad(dir Directory) (Directory, error) {
+	wadDir := Directory{}
+	namespaced := map[string]Directory{}
+
+	for _, file := range dir {
+		parent, base := path.Split(file.Name)
+		parent = strings.ToLower(strings.TrimSuffix(parent, "/"))
+
+		if parent == "maps" && strings.HasSuffix(strings.ToLower(base), ".wad") {
+			wad, err := Decode(bytes.NewReader(file.Data))
+			if err != nil {
+				return nil, fmt.Errorf("file %s: %s", file.Name, err.Error())
+			}
+			wadDir = append(wadDir, wad.Lumps...)
+			continue
+		}
+
+		name, err := fileLumpName(base)
+		if err != nil {
+			return nil, err
+		}
+		lump := Lump{Name: name, Data: file.Data}
+
+		if parent == "" {
+			wadDir = append(wadDir, lump)
+			continue
+		}
+
+		found := false
+		for _, namespace := range Namespaces {
+			if parent == namespace.Name {
+				namespaced[parent] = append(namespaced[parent], lump)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("file %s is in a directory WADs have no namespace for", file.Name)
+		}
+	}
+
+	for _, namespace := range Namespaces {
+		lumps, ok := namespaced[namespace.Name]
+		if !ok {
+			continue
+		}
+
+		marker := namespace.Markers[0]
+		wadDir = append(wadDir, Lump{Name: marker + "_START"})
+		wadDir = append(wadDir, lumps...)
+		wadDir = append(wadDir, Lump{Name: marker + "_END"})
+	}
+
+	return wadDir, nil
+}
@@ -0,0 +1,113 @@
+/*
+ *  Copyright 2016 Alex Mayfield
+ *
+ *  This file is part of WADmake.
+ *
+ *  WADmake is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU Affero General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  WADmake is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU Affero General Public License for more details.
+ *
+ *  You should have received a copy of the GNU Affero General Public License
+ *  along with WADmake.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package wadmake
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Files survive a round trip through ZIP data
+func TestZip(t *testing.T) {
+	dir := Directory{
+		{Name: "DEHACKED.lmp", Data: []byte("patch")},
+		{Name: "sprites/TROOA1.lmp", Data: bytes.Repeat([]byte("imp"), 100)},
+	}
+
+	var buffer bytes.Buffer
+	err := EncodeZip(&buffer, dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var again bytes.Buffer
+	EncodeZip(&again, dir)
+	if !bytes.Equal(buffer.Bytes(), again.Bytes()) {
+		t.Error("encoding the same lumps twice gave different data")
+	}
+
+	decoded, err := DecodeZip(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(decoded, dir) {
+		t.Error("incorrect lumps")
+	}
+}
+
+// Lumps move between namespaces and directories in both directions
+func TestWadToZip(t *testing.T) {
+	dir := Directory{
+		{Name: "DEHACKED", Data: []byte("patch")},
+		{Name: "MAP01", Data: nil},
+		{Name: "THINGS", Data: []byte("things")},
+		{Name: "S_START"},
+		{Name: "VILE\\1", Data: []byte("vile")},
+		{Name: "S_END"},
+		{Name: "FF_START"},
+		{Name: "F1_START"},
+		{Name: "FLOOR0_1", Data: []byte("floor")},
+		{Name: "F1_END"},
+		{Name: "FF_END"},
+	}
+
+	zipDir, err := WadToZip(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	names := []string{}
+	for _, lump := range zipDir {
+		names = append(names, lump.Name)
+	}
+	expected := []string{"DEHACKED.lmp", "maps/MAP01.wad", "sprites/VILE^1.lmp", "flats/FLOOR0_1.lmp"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("incorrect files %q", names)
+	}
+
+	wadDir, err := ZipToWad(zipDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expectedDir := Directory{dir[0], dir[1], dir[2], dir[3], dir[4], dir[5],
+		{Name: "F_START"}, dir[8], {Name: "F_END"}}
+	if !reflect.DeepEqual(wadDir, expectedDir) {
+		t.Errorf("incorrect lumps %v", wadDir)
+	}
+
+	_, err = WadToZip(namedDirectory("DEHACKED", "DEHACKED"))
+	if err == nil || !strings.Contains(err.Error(), "twice") {
+		t.Errorf("incorrect duplicate error %v", err)
+	}
+
+	_, err = ZipToWad(Directory{{Name: "music/D_RUNNIN.mid"}})
+	if err == nil || !strings.Contains(err.Error(), "no namespace") {
+		t.Errorf("incorrect directory error %v", err)
+	}
+
+	_, err = ZipToWad(Directory{{Name: "LONGLUMPNAME.txt"}})
+	if err == nil || !strings.Contains(err.Error(), "longer than 8") {
+		t.Errorf("incorrect name error %v", err)
+	}
+}